@@ -5,14 +5,23 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/3scale/3scale-istio-adapter/pkg/account"
 	"github.com/3scale/3scale-istio-adapter/pkg/kubernetes"
+	"github.com/3scale/3scale-istio-adapter/pkg/openapi"
+	"github.com/3scale/3scale-istio-adapter/pkg/secrets"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 var (
 	accessToken   string
+	tokenFile     string
 	svcID         string
 	threescaleURL string
 	backendURL    string
@@ -20,13 +29,22 @@ var (
 	outputTo      string
 	authType      int
 	namespace     string
+	specFile      string
+	pruneRules    bool
+	noVerify      bool
 
+	clientTimeout  time.Duration
+	systemTimeout  time.Duration
+	backendTimeout time.Duration
+
+	command string
 	version string
 )
 
 const (
 	nameDescription       = "Unique name for this (url,token) pair (required)"
 	tokenDescription      = "3scale access token (required)"
+	tokenFileDescription  = "File to read the 3scale access token from, in place of --token"
 	threescaleDescription = "The 3scale admin portal URL (required)"
 	backendDescription    = "The 3scale backend url"
 
@@ -34,6 +52,20 @@ const (
 	outputDescription    = "File to output templates. Prints to stdout if none provided"
 	authTypeDescription  = "3scale authentication pattern to use. 1=ApiKey, 2=AppID, 3=OpenID Connect. Default template supports a hybrid if none provided"
 	namespaceDescription = "The namespace which the manifests should be generated for. Default 'istio-system'"
+	specDescription      = "OpenAPI document to generate mapping rules and a matching rule from (openapi command only)"
+	pruneDescription     = "Delete mapping rules of the service which are not described by the OpenAPI document (openapi command only)"
+	noVerifyDescription  = "Skip verifying the access token, and looking up the tenant and service names, against the 3scale admin portal"
+
+	clientTimeoutDescription  = "Maximum time the adapter waits on 3scale to authorize a request, such as 2s. Unset if none provided"
+	systemTimeoutDescription  = "Maximum time the adapter waits on 3scale system for the proxy config, such as 1s. Unset if none provided"
+	backendTimeoutDescription = "Maximum time the adapter waits on 3scale backend to authorize a request, such as 500ms. Unset if none provided"
+
+	// tenantAnnotation and serviceAnnotation name the 3scale tenant and service on the generated handler
+	tenantAnnotation  = "3scale.net/tenant"
+	serviceAnnotation = "3scale.net/service-name"
+
+	// openAPICommand generates the mapping rules of a service and a rule which matches the same requests from an OpenAPI document
+	openAPICommand = "openapi"
 
 	outputDefault, tokenDefault, svcDefault, urlDefault = "", "", "", ""
 
@@ -43,6 +75,7 @@ const (
 func init() {
 	flag.StringVar(&accessToken, "token", tokenDefault, tokenDescription)
 	flag.StringVar(&accessToken, "t", tokenDefault, tokenDescription+" (short)")
+	flag.StringVar(&tokenFile, "token-file", "", tokenFileDescription)
 
 	flag.StringVar(&svcID, "service", svcDefault, svcIDDescription)
 
@@ -61,9 +94,22 @@ func init() {
 	flag.StringVar(&namespace, "namespace", istioNamespaceDefault, namespaceDescription)
 	flag.StringVar(&namespace, "n", istioNamespaceDefault, namespaceDescription+" (short)")
 
+	flag.StringVar(&specFile, "spec", "", specDescription)
+	flag.BoolVar(&pruneRules, "prune", false, pruneDescription)
+	flag.BoolVar(&noVerify, "no-verify", false, noVerifyDescription)
+
+	flag.DurationVar(&clientTimeout, "client-timeout", 0, clientTimeoutDescription)
+	flag.DurationVar(&systemTimeout, "system-timeout", 0, systemTimeoutDescription)
+	flag.DurationVar(&backendTimeout, "backend-timeout", 0, backendTimeoutDescription)
+
 	v := flag.Bool("version", false, "Prints CLI version")
 
 	flag.Parse()
+	if flag.Arg(0) == openAPICommand {
+		command = openAPICommand
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+
 	if *v {
 		if version == "" {
 			version = "undefined"
@@ -72,7 +118,61 @@ func init() {
 		os.Exit(0)
 	}
 
+	checkSecretArgs()
+	readTokenFile()
 	checkEnv()
+	promptToken()
+}
+
+// checkSecretArgs exits when THREESCALE_FORBID_SECRET_ARGS is true and the access token was passed as an argument,
+// where it would be visible in the process list of the host
+func checkSecretArgs() {
+	if os.Getenv("THREESCALE_FORBID_SECRET_ARGS") != "true" {
+		return
+	}
+
+	if secrets.InArgs(os.Args[1:], accessToken) {
+		log.Fatal("refusing to run - the access token appears in the command line arguments, provide it via THREESCALE_ACCESS_TOKEN")
+	}
+}
+
+// readTokenFile reads the access token from the file provided by --token-file, if any
+func readTokenFile() {
+	if tokenFile == "" {
+		return
+	}
+
+	if accessToken != "" {
+		log.Fatal("only one of --token and --token-file can be provided")
+	}
+
+	b, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		log.Fatalf("error reading access token file %s", err.Error())
+	}
+	accessToken = strings.TrimSpace(string(b))
+	secrets.Zero(b)
+
+	if accessToken == "" {
+		log.Fatalf("access token file %s is empty", tokenFile)
+	}
+}
+
+// promptToken asks for the access token, without echoing it, when it has not been provided by any other means and
+// the program is run interactively
+func promptToken() {
+	if accessToken != "" || !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return
+	}
+
+	fmt.Fprint(os.Stderr, "3scale access token: ")
+	b, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		log.Fatalf("error reading access token %s", err.Error())
+	}
+	accessToken = strings.TrimSpace(string(b))
+	secrets.Zero(b)
 }
 
 func checkEnv() {
@@ -99,6 +199,20 @@ func validate() []error {
 		errs = append(errs, errors.New("error missing parameter. --url is required"))
 	}
 
+	if clientTimeout < 0 || systemTimeout < 0 || backendTimeout < 0 {
+		errs = append(errs, errors.New("error invalid parameter. timeouts must not be negative"))
+	}
+
+	if command == openAPICommand {
+		if specFile == "" {
+			errs = append(errs, errors.New("error missing parameter. --spec is required"))
+		}
+
+		if svcID == "" {
+			errs = append(errs, errors.New("error missing parameter. --service is required"))
+		}
+	}
+
 	return errs
 }
 
@@ -112,6 +226,12 @@ func execute() error {
 
 	// set the optional backend url override
 	handler.Params.BackendUrl = backendURL
+	handler.SetTimeouts(clientTimeout, systemTimeout, backendTimeout)
+
+	annotations, err := verifyCredentials()
+	if err != nil {
+		return err
+	}
 
 	var instance *kubernetes.BaseInstance
 	switch authType {
@@ -128,9 +248,18 @@ func execute() error {
 
 	}
 
+	conditions := kubernetes.GetDefaultMatchConditions(name)
+	if command == openAPICommand {
+		condition, err := syncOpenAPI()
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, condition)
+	}
+
 	handlerName := fmt.Sprintf("%s.handler.%s", name, namespace)
 	instanceName := fmt.Sprintf("%s.instance.%s", name, namespace)
-	rule := kubernetes.NewRule(kubernetes.GetDefaultMatchConditions(name), handlerName, instanceName)
+	rule := kubernetes.NewRule(conditions, handlerName, instanceName)
 
 	cg, err := kubernetes.NewConfigGenerator(name, *handler, *instance, rule)
 	if err != nil {
@@ -138,6 +267,7 @@ func execute() error {
 	}
 
 	cg.SetNamespace(namespace)
+	cg.SetHandlerAnnotations(annotations)
 
 	if outputTo == "" {
 		writeTo = os.Stdout
@@ -152,6 +282,57 @@ func execute() error {
 	return cg.OutputAll(writeTo)
 }
 
+// verifyCredentials checks the access token against the 3scale admin portal before anything is generated, returning
+// annotations naming the tenant, and the service where set, for the handler. Nothing is checked with --no-verify.
+func verifyCredentials() (map[string]string, error) {
+	if noVerify {
+		return nil, nil
+	}
+
+	acc, err := account.Lookup(&http.Client{Timeout: 30 * time.Second}, threescaleURL, accessToken, svcID)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying credentials " + err.Error())
+	}
+
+	annotations := map[string]string{tenantAnnotation: acc.Tenant}
+	if acc.ServiceName != "" {
+		annotations[serviceAnnotation] = acc.ServiceName
+	}
+	return annotations, nil
+}
+
+// syncOpenAPI creates the mapping rules of the service described by the OpenAPI document, returning the match
+// condition which limits the rule to the same operations
+func syncOpenAPI() (string, error) {
+	data, err := ioutil.ReadFile(specFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading OpenAPI document " + err.Error())
+	}
+
+	doc, err := openapi.Parse(data)
+	if err != nil {
+		return "", err
+	}
+	ops := doc.Operations()
+
+	syncer := openapi.NewRuleSyncer(&http.Client{Timeout: 30 * time.Second}, threescaleURL, accessToken)
+	result, err := syncer.Sync(svcID, ops, pruneRules)
+	if err != nil {
+		return "", fmt.Errorf("error syncing mapping rules " + err.Error())
+	}
+
+	for _, op := range result.Created {
+		log.Printf("created mapping rule %s %s", op.Method, op.Pattern())
+	}
+	for _, rule := range result.Deleted {
+		log.Printf("deleted mapping rule %s", rule)
+	}
+	log.Printf("%d mapping rules created, %d deleted and %d unchanged for service %s",
+		len(result.Created), len(result.Deleted), len(result.Unchanged), svcID)
+
+	return openapi.MatchCondition(ops), nil
+}
+
 func main() {
 	errs := validate()
 	if errs != nil {
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/adapterclient"
+	"github.com/3scale/3scale-istio-adapter/pkg/secrets"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+var (
+	addr          string
+	caFile        string
+	certFile      string
+	keyFile       string
+	accessToken   string
+	threescaleURL string
+	timeout       time.Duration
+
+	request adapterclient.Request
+)
+
+const (
+	addrDescription       = "Address of the adapter gRPC server"
+	caFileDescription     = "PEM encoded CA used to verify the adapter when it serves gRPC over TLS"
+	certFileDescription   = "PEM encoded certificate presented to the adapter when it requires client certificates"
+	keyFileDescription    = "PEM encoded private key of the client certificate"
+	tokenDescription      = "3scale access token (required)"
+	threescaleDescription = "The 3scale admin portal URL (required)"
+	timeoutDescription    = "Time to wait for the adapter to respond"
+
+	serviceDescription  = "The ID of the 3scale service (required)"
+	methodDescription   = "HTTP method of the request to authorize"
+	pathDescription     = "Path of the request to authorize"
+	userKeyDescription  = "User key of the application (API key pattern)"
+	appIDDescription    = "Application ID (App ID pattern)"
+	appKeyDescription   = "Application key (App ID pattern)"
+	clientIDDescription = "Client ID of the application (OpenID Connect pattern)"
+
+	addrDefault = "localhost:3333"
+)
+
+func init() {
+	flag.StringVar(&addr, "addr", addrDefault, addrDescription)
+	flag.StringVar(&caFile, "ca-file", "", caFileDescription)
+	flag.StringVar(&certFile, "cert-file", "", certFileDescription)
+	flag.StringVar(&keyFile, "key-file", "", keyFileDescription)
+	flag.StringVar(&accessToken, "token", "", tokenDescription)
+	flag.StringVar(&threescaleURL, "url", "", threescaleDescription)
+	flag.DurationVar(&timeout, "timeout", 5*time.Second, timeoutDescription)
+
+	flag.StringVar(&request.Service, "service", "", serviceDescription)
+	flag.StringVar(&request.Method, "method", "GET", methodDescription)
+	flag.StringVar(&request.Path, "path", "/", pathDescription)
+	flag.StringVar(&request.UserKey, "user-key", "", userKeyDescription)
+	flag.StringVar(&request.AppID, "app-id", "", appIDDescription)
+	flag.StringVar(&request.AppKey, "app-key", "", appKeyDescription)
+	flag.StringVar(&request.ClientID, "client-id", "", clientIDDescription)
+
+	flag.Parse()
+	checkSecretArgs()
+
+	if accessToken == "" {
+		accessToken = os.Getenv("THREESCALE_ACCESS_TOKEN")
+	}
+
+	if threescaleURL == "" {
+		threescaleURL = os.Getenv("THREESCALE_ADMIN_PORTAL")
+	}
+}
+
+// checkSecretArgs exits when THREESCALE_FORBID_SECRET_ARGS is true and the access token was passed as an argument,
+// where it would be visible in the process list of the host
+func checkSecretArgs() {
+	if os.Getenv("THREESCALE_FORBID_SECRET_ARGS") != "true" {
+		return
+	}
+
+	if secrets.InArgs(os.Args[1:], accessToken) {
+		log.Fatal("refusing to run - the access token appears in the command line arguments, provide it via THREESCALE_ACCESS_TOKEN")
+	}
+}
+
+func validate() []error {
+	var errs []error
+	if accessToken == "" {
+		errs = append(errs, errors.New("error missing parameter. --token is required"))
+	}
+
+	if threescaleURL == "" {
+		errs = append(errs, errors.New("error missing parameter. --url is required"))
+	}
+
+	if request.Service == "" {
+		errs = append(errs, errors.New("error missing parameter. --service is required"))
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		errs = append(errs, errors.New("error missing parameter. --cert-file and --key-file must be set together"))
+	}
+
+	if certFile != "" && caFile == "" {
+		errs = append(errs, errors.New("error missing parameter. --cert-file requires --ca-file"))
+	}
+
+	return errs
+}
+
+// clientTLSConfig returns the TLS config verifying the adapter with the CA, presenting the client certificate if set
+func clientTLSConfig() (*tls.Config, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading CA " + err.Error())
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("error loading CA - no certificates found in %s", caFile)
+	}
+
+	conf := &tls.Config{RootCAs: roots}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate " + err.Error())
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+func execute() (*adapterclient.Response, error) {
+	var opts []grpc.DialOption
+	if caFile != "" {
+		conf, err := clientTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(conf)))
+	}
+
+	c, err := adapterclient.Dial(addr, config.Params{
+		SystemUrl:   threescaleURL,
+		AccessToken: accessToken,
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to adapter " + err.Error())
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return c.Authorize(ctx, request)
+}
+
+func main() {
+	errs := validate()
+	if errs != nil {
+		log.Println("Error validating input:")
+		for _, i := range errs {
+			fmt.Println(i.Error())
+		}
+		os.Exit(1)
+	}
+
+	resp, err := execute()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("allowed: %t\nreason: %s\nstatus: %d %s\n", resp.Allowed, resp.Reason, resp.Status.Code, resp.Status.Message)
+	if !resp.Allowed {
+		os.Exit(2)
+	}
+}
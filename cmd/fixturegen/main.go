@@ -0,0 +1,226 @@
+// Command fixturegen snapshots the proxy config of a 3scale service into a test fixture, so that the decisions the
+// adapter reaches for it can be pinned by the fixture tests in pkg/threescale. Credentials are redacted from the
+// snapshot, and a request is generated for each mapping rule, which can be edited to reproduce an incident.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/secrets"
+)
+
+var (
+	accessToken   string
+	svcID         string
+	threescaleURL string
+	environment   string
+	name          string
+	outputTo      string
+	overwrite     bool
+)
+
+const (
+	tokenDescription       = "3scale access token, read from THREESCALE_ACCESS_TOKEN when not provided"
+	threescaleDescription  = "The 3scale admin portal URL (required)"
+	svcIDDescription       = "The ID of the 3scale service to snapshot (required)"
+	environmentDescription = "The proxy config environment to snapshot, production or sandbox"
+	nameDescription        = "Name of the fixture, used as its directory (required)"
+	outputDescription      = "Directory the fixture is created in"
+	overwriteDescription   = "Replace an existing fixture of the same name"
+
+	proxyConfigEndpoint = "/admin/api/services/%s/proxy/configs/%s/latest.json"
+
+	// validCredential is authorized by the fake authorizer of the fixture tests
+	validCredential = "VALID"
+
+	outputDefault = "pkg/threescale/testdata/fixtures"
+)
+
+// fixtureRequest mirrors the requests read by the fixture tests in pkg/threescale
+type fixtureRequest struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	UserKey  string `json:"user_key,omitempty"`
+	AppID    string `json:"app_id,omitempty"`
+	AppKey   string `json:"app_key,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// parseFlags is called from main rather than init, so that the flags do not clash with those of the tests
+func parseFlags() {
+	flag.StringVar(&accessToken, "token", "", tokenDescription)
+	flag.StringVar(&threescaleURL, "url", "", threescaleDescription)
+	flag.StringVar(&svcID, "service", "", svcIDDescription)
+	flag.StringVar(&environment, "environment", "production", environmentDescription)
+	flag.StringVar(&name, "name", "", nameDescription)
+	flag.StringVar(&outputTo, "output", outputDefault, outputDescription)
+	flag.BoolVar(&overwrite, "overwrite", false, overwriteDescription)
+
+	flag.Parse()
+
+	if accessToken == "" {
+		accessToken = os.Getenv("THREESCALE_ACCESS_TOKEN")
+	} else if os.Getenv("THREESCALE_FORBID_SECRET_ARGS") == "true" && secrets.InArgs(os.Args[1:], accessToken) {
+		log.Fatal("refusing to run - the access token appears in the command line arguments, provide it via THREESCALE_ACCESS_TOKEN")
+	}
+}
+
+func validate() []error {
+	var errs []error
+	if accessToken == "" {
+		errs = append(errs, errors.New("error missing parameter. --token or THREESCALE_ACCESS_TOKEN is required"))
+	}
+
+	if threescaleURL == "" {
+		errs = append(errs, errors.New("error missing parameter. --url is required"))
+	}
+
+	if svcID == "" {
+		errs = append(errs, errors.New("error missing parameter. --service is required"))
+	}
+
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		errs = append(errs, errors.New("error missing parameter. --name is required and must not contain path separators"))
+	}
+
+	return errs
+}
+
+// fetchProxyConfig returns the decoded latest.json response for the service
+func fetchProxyConfig() (map[string]interface{}, error) {
+	query := url.Values{}
+	query.Set("access_token", accessToken)
+	endpoint := strings.TrimSuffix(threescaleURL, "/") + fmt.Sprintf(proxyConfigEndpoint, url.PathEscape(svcID), environment) +
+		"?" + query.Encode()
+
+	client := &http.Client{Timeout: time.Second * 30}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		// the error includes the URL, which holds the access token
+		return nil, fmt.Errorf("error fetching proxy config - %v", strings.Replace(err.Error(), accessToken, redacted, -1))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching proxy config - unexpected status %s", resp.Status)
+	}
+
+	var doc map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error decoding proxy config - %v", err)
+	}
+
+	if _, ok := doc["proxy_config"]; !ok {
+		return nil, errors.New("error decoding proxy config - no proxy_config in response")
+	}
+	return doc, nil
+}
+
+// requestsFor returns a request matching each mapping rule of the proxy config, authenticated as the service expects
+func requestsFor(doc map[string]interface{}) []fixtureRequest {
+	content, _ := lookup(doc, "proxy_config", "content").(map[string]interface{})
+	backendVersion, _ := content["backend_version"].(string)
+	rules, _ := lookup(content, "proxy", "proxy_rules").([]interface{})
+
+	var requests []fixtureRequest
+	for _, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		method, _ := rule["http_method"].(string)
+		pattern, _ := rule["pattern"].(string)
+		request := fixtureRequest{Method: strings.ToUpper(method), Path: examplePath(pattern)}
+
+		switch backendVersion {
+		case "2":
+			request.AppID = validCredential
+		case "oauth":
+			request.ClientID = validCredential
+		default:
+			request.UserKey = validCredential
+		}
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+func lookup(v interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+func execute() (string, error) {
+	dir := filepath.Join(outputTo, name)
+	if _, err := os.Stat(dir); err == nil && !overwrite {
+		return "", fmt.Errorf("fixture %s already exists, use --overwrite to replace it", dir)
+	}
+
+	doc, err := fetchProxyConfig()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	if err := writeJSON(filepath.Join(dir, "proxy_config.json"), sanitize(doc)); err != nil {
+		return "", err
+	}
+
+	if err := writeJSON(filepath.Join(dir, "requests.json"), requestsFor(doc)); err != nil {
+		return "", err
+	}
+
+	// the decisions are recorded by the fixture tests, so a stale golden file must not be left behind
+	if err := os.Remove(filepath.Join(dir, "decisions.golden.json")); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return dir, nil
+}
+
+func main() {
+	parseFlags()
+	if errs := validate(); errs != nil {
+		log.Println("Error validating input:")
+		for _, err := range errs {
+			log.Println(err.Error())
+		}
+		os.Exit(1)
+	}
+
+	dir, err := execute()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	fmt.Printf("Created fixture %s. Review the redacted proxy config and requests, then record the decisions with:\n", dir)
+	fmt.Println("  go test ./pkg/threescale -run TestFixtures -update-fixtures")
+}
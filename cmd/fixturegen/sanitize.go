@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// secretKeys are the proxy config fields which hold credentials, in addition to any whose name suggests a secret
+var secretKeys = map[string]bool{
+	"backend_authentication_value": true,
+}
+
+// secretKeyFragments identify fields holding credentials by their name
+var secretKeyFragments = []string{"secret", "token", "password", "private_key"}
+
+var placeholder = regexp.MustCompile(`\{[^}]*\}`)
+
+// sanitize replaces credentials in a decoded proxy config, in place, so it can be committed as a fixture.
+// String fields named as secrets are redacted, and passwords embedded in URLs, such as the client secret in the
+// OpenID Connect issuer endpoint, are removed.
+func sanitize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if s, ok := field.(string); ok && s != "" && isSecretKey(key) {
+				value[key] = redacted
+				continue
+			}
+			value[key] = sanitize(field)
+		}
+		return value
+	case []interface{}:
+		for i := range value {
+			value[i] = sanitize(value[i])
+		}
+		return value
+	case string:
+		return sanitizeURL(value)
+	}
+	return v
+}
+
+func isSecretKey(key string) bool {
+	if secretKeys[key] {
+		return true
+	}
+
+	key = strings.ToLower(key)
+	for _, fragment := range secretKeyFragments {
+		if strings.Contains(key, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeURL redacts the password of a URL with user info, leaving other strings unchanged
+func sanitizeURL(s string) string {
+	if !strings.Contains(s, "://") || !strings.Contains(s, "@") {
+		return s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
+
+// examplePath returns a path matched by a mapping rule pattern, filling placeholders and dropping the query and anchor
+func examplePath(pattern string) string {
+	if i := strings.Index(pattern, "?"); i >= 0 {
+		pattern = pattern[:i]
+	}
+
+	path := strings.TrimSuffix(placeholder.ReplaceAllString(pattern, "1"), "$")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// metricName matches the names Prometheus accepts for a metric
+var metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// NameMapping renames or drops metrics as they are exported, so that they can follow the naming conventions of an
+// organisation. Metrics are only mapped on the metrics endpoint, so saved counters keep their original names.
+type NameMapping struct {
+	// Rename maps the original name of a metric to the name it is exported as
+	Rename map[string]string `json:"rename"`
+	// Drop lists the original names of metrics which are not exported
+	Drop []string `json:"drop"`
+}
+
+// ParseNameMapping reads a NameMapping from YAML or JSON, rejecting invalid names and metrics renamed to the same name
+func ParseNameMapping(data []byte) (*NameMapping, error) {
+	mapping := &NameMapping{}
+	if err := yaml.Unmarshal(data, mapping); err != nil {
+		return nil, fmt.Errorf("unable to parse metric name mapping - %v", err)
+	}
+
+	seen := make(map[string]string, len(mapping.Rename))
+	for from, to := range mapping.Rename {
+		if !metricName.MatchString(to) {
+			return nil, fmt.Errorf("invalid metric name %q for %s", to, from)
+		}
+
+		if other, ok := seen[to]; ok {
+			return nil, fmt.Errorf("metrics %s and %s are both renamed to %s", other, from, to)
+		}
+		seen[to] = from
+	}
+	return mapping, nil
+}
+
+// mappedGatherer applies a NameMapping to the metrics collected by the wrapped Gatherer
+type mappedGatherer struct {
+	next    prometheus.Gatherer
+	mapping *NameMapping
+	drop    map[string]bool
+}
+
+func newMappedGatherer(next prometheus.Gatherer, mapping *NameMapping) *mappedGatherer {
+	drop := make(map[string]bool, len(mapping.Drop))
+	for _, name := range mapping.Drop {
+		drop[name] = true
+	}
+
+	return &mappedGatherer{
+		next:    next,
+		mapping: mapping,
+		drop:    drop,
+	}
+}
+
+// Gather implements prometheus.Gatherer
+func (g *mappedGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.next.Gather()
+
+	mapped := families[:0]
+	names := make(map[string]bool, len(families))
+	for _, family := range families {
+		if g.drop[family.GetName()] {
+			continue
+		}
+
+		if to, ok := g.mapping.Rename[family.GetName()]; ok {
+			family.Name = &to
+		}
+
+		if names[family.GetName()] {
+			return nil, fmt.Errorf("metric name %s is exported more than once after mapping", family.GetName())
+		}
+		names[family.GetName()] = true
+		mapped = append(mapped, family)
+	}
+
+	sort.Slice(mapped, func(i, j int) bool {
+		return mapped[i].GetName() < mapped[j].GetName()
+	})
+	return mapped, err
+}
+
+// GetMappedHandler returns the metrics handler, exporting metrics as per the mapping when one is provided
+func GetMappedHandler(mapping *NameMapping) http.Handler {
+	if mapping == nil {
+		return GetHandler()
+	}
+
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(newMappedGatherer(prometheus.DefaultGatherer, mapping), promhttp.HandlerOpts{}),
+	)
+}
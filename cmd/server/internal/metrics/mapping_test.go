@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseNameMapping(t *testing.T) {
+	inputs := []struct {
+		name      string
+		data      string
+		expectErr bool
+	}{
+		{
+			name: "Test valid mapping",
+			data: "rename:\n  threescale_latency: acme_latency_seconds\ndrop:\n- threescale_system_cache_hits\n",
+		},
+		{
+			name: "Test valid JSON mapping",
+			data: `{"drop":["threescale_system_cache_hits"]}`,
+		},
+		{
+			name:      "Test invalid metric name",
+			data:      "rename:\n  threescale_latency: acme-latency\n",
+			expectErr: true,
+		},
+		{
+			name:      "Test metrics renamed to the same name",
+			data:      "rename:\n  threescale_latency: acme_latency\n  threescale_http_total: acme_latency\n",
+			expectErr: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			_, err := ParseNameMapping([]byte(input.data))
+			if (err != nil) != input.expectErr {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestMappedGatherer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	for _, name := range []string{"a_total", "b_total", "c_total"} {
+		registry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: name}))
+	}
+
+	g := newMappedGatherer(registry, &NameMapping{
+		Rename: map[string]string{"a_total": "z_total"},
+		Drop:   []string{"b_total"},
+	})
+
+	families, err := g.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if len(families) != 2 || families[0].GetName() != "c_total" || families[1].GetName() != "z_total" {
+		t.Errorf("unexpected metrics exported %v", families)
+	}
+
+	g = newMappedGatherer(registry, &NameMapping{Rename: map[string]string{"a_total": "c_total"}})
+	if _, err := g.Gather(); err == nil {
+		t.Errorf("expected error when a metric is renamed to the name of another")
+	}
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatherer collects the counters to save, which are registered with the default registry by Register
+var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+
+// counterState is the saved value of a single counter series
+type counterState struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// countersState is the format counters are saved in
+type countersState struct {
+	SavedAt  time.Time      `json:"saved_at"`
+	Counters []counterState `json:"counters"`
+}
+
+// persistedCounters returns the counters which are saved and restored across restarts, by metric name.
+// The latency histogram and gauges are not, since they describe recent behaviour rather than accumulated usage.
+func persistedCounters() map[string]func(labels prometheus.Labels) (prometheus.Counter, error) {
+	vec := func(v *prometheus.CounterVec) func(prometheus.Labels) (prometheus.Counter, error) {
+		return func(labels prometheus.Labels) (prometheus.Counter, error) {
+			return v.GetMetricWith(labels)
+		}
+	}
+
+	single := func(c prometheus.Counter) func(prometheus.Labels) (prometheus.Counter, error) {
+		return func(labels prometheus.Labels) (prometheus.Counter, error) {
+			if len(labels) > 0 {
+				return nil, fmt.Errorf("unexpected labels")
+			}
+			return c, nil
+		}
+	}
+
+	return map[string]func(prometheus.Labels) (prometheus.Counter, error){
+		"threescale_http_total":                      vec(threescaleHTTP),
+		"threescale_system_cache_hits":               single(cacheHitsSystem),
+		"threescale_backend_cache_hits":              single(cacheHitsBackend),
+		"threescale_deprecated_config_total":         vec(deprecatedConfig),
+		"threescale_backend_hedged_total":            vec(backendHedged),
+		"threescale_api_version_requests_total":      vec(apiVersionRequests),
+		"threescale_concurrency_limited_total":       single(concurrencyLimited),
+		"threescale_invalid_service_token_total":     vec(invalidServiceToken),
+		"threescale_application_requests_total":      vec(applicationRequests),
+		"threescale_budget_exceeded_total":           vec(budgetExceeded),
+		"threescale_onboarding_would_deny_total":     vec(onboardingWouldDeny),
+		"threescale_fail_open_total":                 vec(failOpenRequests),
+		"threescale_config_unmarshal_failures_total": vec(configUnmarshalFailures),
+	}
+}
+
+// SaveCounters writes the current value of the persisted counters to w, so that they can be restored after a restart
+func SaveCounters(w io.Writer, now time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	counters := persistedCounters()
+	state := countersState{SavedAt: now, Counters: []counterState{}}
+	for _, family := range families {
+		if _, ok := counters[family.GetName()]; !ok {
+			continue
+		}
+
+		for _, m := range family.GetMetric() {
+			c := counterState{Name: family.GetName(), Value: m.GetCounter().GetValue()}
+			if len(m.GetLabel()) > 0 {
+				c.Labels = make(map[string]string, len(m.GetLabel()))
+				for _, label := range m.GetLabel() {
+					c.Labels[label.GetName()] = label.GetValue()
+				}
+			}
+			state.Counters = append(state.Counters, c)
+		}
+	}
+
+	return json.NewEncoder(w).Encode(state)
+}
+
+// RestoreCounters adds the values saved by SaveCounters to the persisted counters, so must be called before the
+// counters are first incremented. Series which no longer match the labels of their counter are skipped.
+// It returns the number of series restored and the time they were saved at.
+func RestoreCounters(r io.Reader) (int, time.Time, error) {
+	state := countersState{}
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	var restored int
+	counters := persistedCounters()
+	for _, c := range state.Counters {
+		get, ok := counters[c.Name]
+		if !ok || c.Value <= 0 {
+			continue
+		}
+
+		counter, err := get(prometheus.Labels(c.Labels))
+		if err != nil {
+			continue
+		}
+		counter.Add(c.Value)
+		restored++
+	}
+	return restored, state.SavedAt, nil
+}
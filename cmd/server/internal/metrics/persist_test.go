@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSaveAndRestoreCounters(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(concurrencyLimited, applicationRequests, systemThrottled)
+	gatherer = registry
+	defer func() { gatherer = prometheus.DefaultGatherer }()
+
+	single := testutil.ToFloat64(concurrencyLimited)
+	series := applicationRequests.WithLabelValues("persist", "abc")
+	IncrementConcurrencyLimited()
+	IncrementApplicationRequests("persist", "abc")
+	IncrementApplicationRequests("persist", "abc")
+	SetSystemThrottled("persist.example.com", true)
+
+	savedAt := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	buf := &bytes.Buffer{}
+	if err := SaveCounters(buf, savedAt); err != nil {
+		t.Fatalf("unexpected error saving counters - %v", err)
+	}
+
+	restored, at, err := RestoreCounters(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error restoring counters - %v", err)
+	}
+
+	if restored != 2 || !at.Equal(savedAt) {
+		t.Errorf("expected both counter series to be restored from %s, got %d from %s", savedAt, restored, at)
+	}
+
+	// restoring into the same process adds the saved values to the current ones
+	if got := testutil.ToFloat64(series); got != 4 {
+		t.Errorf("unexpected restored value for counter vector %v", got)
+	}
+
+	if got := testutil.ToFloat64(concurrencyLimited); got != 2*(single+1) {
+		t.Errorf("unexpected restored value for counter %v", got)
+	}
+
+	if got := testutil.ToFloat64(systemThrottled.WithLabelValues("persist.example.com")); got != 1 {
+		t.Errorf("expected gauges not to be restored, got %v", got)
+	}
+
+	if _, _, err := RestoreCounters(bytes.NewReader([]byte("corrupt"))); err == nil {
+		t.Errorf("expected error restoring corrupt state")
+	}
+
+	stale := `{"counters": [{"name": "threescale_application_requests_total", "labels": {"removed": "x"}, "value": 1}]}`
+	if restored, _, err := RestoreCounters(bytes.NewReader([]byte(stale))); err != nil || restored != 0 {
+		t.Errorf("expected series with stale labels to be skipped, got %d - %v", restored, err)
+	}
+}
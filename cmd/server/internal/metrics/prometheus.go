@@ -3,8 +3,11 @@ package metrics
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/pkg/httpclient"
+	"github.com/3scale/3scale-istio-adapter/pkg/selftest"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -40,12 +43,219 @@ var (
 		},
 	)
 
+	cacheMissesSystem = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "threescale_system_cache_misses_total",
+			Help: "Total number of requests to 3scale system which could not be served from the compressed cache",
+		},
+	)
+
+	cacheFetchFailuresSystem = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_system_cache_fetch_failures_total",
+			Help: "Total number of proxy config fetches, including background refreshes, by the compressed cache which failed",
+		},
+		[]string{"service_id"},
+	)
+
+	cacheEvictionsSystem = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "threescale_system_cache_evictions_total",
+			Help: "Total number of proxy configs evicted from the compressed cache to make room for another",
+		},
+	)
+
+	cacheEntriesSystem = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "threescale_system_cache_entries",
+			Help: "Number of proxy configs held by the compressed cache",
+		},
+	)
+
+	cacheEntryAgeSystem = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "threescale_system_cache_entry_age_seconds",
+			Help:    "Time since each proxy config served from the compressed cache was fetched from 3scale system",
+			Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+		},
+	)
+
 	cacheHitsBackend = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "threescale_backend_cache_hits",
 			Help: "Total number of requests to 3scale backend fetched from cache",
 		},
 	)
+
+	systemThrottled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "threescale_system_throttled",
+			Help: "Set to 1 while requests to 3scale system are held back due to rate limiting",
+		},
+		[]string{"host"},
+	)
+
+	deprecatedConfig = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_deprecated_config_total",
+			Help: "Total number of handlers seen using deprecated configuration",
+		},
+		[]string{"field"},
+	)
+
+	selfTestReachable = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "threescale_self_test_reachable",
+			Help: "Set to 1 when the last self-test probe against 3scale succeeded",
+		},
+		[]string{"target"},
+	)
+
+	backendHedged = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_backend_hedged_total",
+			Help: "Total number of hedged requests sent to 3scale backend",
+		},
+		[]string{"host"},
+	)
+
+	apiVersionRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_api_version_requests_total",
+			Help: "Total number of authorized requests for each API version identified by api_version_pattern",
+		},
+		[]string{"service_id", "api_version"},
+	)
+
+	concurrencyLimited = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "threescale_concurrency_limited_total",
+			Help: "Total number of authorization requests rejected due to the concurrency limit",
+		},
+	)
+
+	invalidServiceToken = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_invalid_service_token_total",
+			Help: "Total number of handlers found to be using a service whose service token is rejected by 3scale backend",
+		},
+		[]string{"service_id"},
+	)
+
+	applicationRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_application_requests_total",
+			Help: "Total number of authorized requests for each application, identified by a salted hash of its credentials",
+		},
+		[]string{"service_id", "application"},
+	)
+
+	budgetExceeded = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_budget_exceeded_total",
+			Help: "Total number of requests to 3scale cancelled for exhausting the latency budget of their stage",
+		},
+		[]string{"stage", "host"},
+	)
+
+	onboardingWouldDeny = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_onboarding_would_deny_total",
+			Help: "Total number of requests allowed while their service is onboarding which would otherwise be denied",
+		},
+		[]string{"service_id", "reason"},
+	)
+
+	failOpenRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_fail_open_total",
+			Help: "Total number of requests allowed despite an error reaching 3scale, since they were failing open",
+		},
+		[]string{"service_id"},
+	)
+
+	backendErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_backend_errors_total",
+			Help: "Total number of requests which could not be authorized due to an error reaching 3scale backend, by class of error",
+		},
+		[]string{"service_id", "class"},
+	)
+
+	authorizations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_authorizations_total",
+			Help: "Total number of authorization decisions, by service, decision and reason",
+		},
+		[]string{"service_id", "decision", "reason"},
+	)
+
+	backendResponses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_backend_responses_total",
+			Help: "Total number of requests sent to 3scale backend to authorize, by service and class of response",
+		},
+		[]string{"service_id", "class"},
+	)
+
+	configUnmarshalFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "threescale_config_unmarshal_failures_total",
+			Help: "Total number of requests whose handler config could not be unmarshalled, by instance and handler fingerprint",
+		},
+		[]string{"instance", "handler"},
+	)
+
+	systemServiceDegraded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "threescale_system_service_degraded",
+			Help: "Set to 1 while fetches of the proxy configuration of a service are held back due to flapping",
+		},
+		[]string{"host", "service_id"},
+	)
+
+	serviceFailOpen = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "threescale_service_fail_open",
+			Help: "Set to 1 while a service fails open after exceeding its error budget reaching 3scale backend",
+		},
+		[]string{"service_id"},
+	)
+
+	deadlineRemaining = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "threescale_grpc_deadline_remaining_seconds",
+			Help:    "Time remaining until the deadline set by the caller when a gRPC request arrived at the adapter",
+			Buckets: []float64{.01, .025, .05, .1, .25, .5, 1.0, 2.5, 5.0, 10.0},
+		},
+		[]string{"method"},
+	)
+
+	deadlineConsumed = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "threescale_grpc_deadline_consumed_ratio",
+			Help:    "Fraction of the deadline remaining on arrival which was consumed handling a gRPC request",
+			Buckets: []float64{.05, .1, .25, .5, .75, .9, 1.0, 1.5},
+		},
+		[]string{"method"},
+	)
+
+	staleConfigServed = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "threescale_system_config_staleness_seconds",
+			Help:    "Time since expiry of each proxy config served stale as it could not be refreshed from 3scale system",
+			Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+		},
+		[]string{"service_id"},
+	)
+
+	endpointHealthScore = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "threescale_endpoint_health_score",
+			Help: "Health score, between 0 and 1, of a 3scale host from the success and latency of recent requests to it",
+		},
+		[]string{"host"},
+	)
 )
 
 func ReportCB(tr authorizer.TelemetryReport) {
@@ -64,8 +274,200 @@ func IncrementCacheHits(cache authorizer.Cache) {
 	cacheHitsBackend.Inc()
 }
 
+// ObserveSystemCacheHit increments proxy configurations read from the compressed cache, recording the time since the
+// configuration was fetched
+func ObserveSystemCacheHit(age time.Duration) {
+	cacheHitsSystem.Inc()
+	cacheEntryAgeSystem.Observe(age.Seconds())
+}
+
+// IncrementSystemCacheMisses increments requests for proxy configurations the compressed cache could not serve
+func IncrementSystemCacheMisses() {
+	cacheMissesSystem.Inc()
+}
+
+// IncrementSystemCacheFetchFailures increments failed fetches of the proxy configuration of a service by the
+// compressed cache
+func IncrementSystemCacheFetchFailures(serviceID string) {
+	cacheFetchFailuresSystem.WithLabelValues(serviceID).Inc()
+}
+
+// IncrementSystemCacheEvictions increments proxy configurations evicted from the compressed cache
+func IncrementSystemCacheEvictions() {
+	cacheEvictionsSystem.Inc()
+}
+
+// SetSystemCacheEntries records the number of proxy configurations held by the compressed cache
+func SetSystemCacheEntries(entries int) {
+	cacheEntriesSystem.Set(float64(entries))
+}
+
+// SetSystemThrottled records whether requests to the 3scale system host are being held back due to rate limiting
+func SetSystemThrottled(host string, throttled bool) {
+	var val float64
+	if throttled {
+		val = 1
+	}
+	systemThrottled.WithLabelValues(host).Set(val)
+}
+
+// IncrementDeprecatedConfig increments the number of handlers found to be using a deprecated configuration field
+func IncrementDeprecatedConfig(field string) {
+	deprecatedConfig.WithLabelValues(field).Inc()
+}
+
+// SetSelfTestResult records the outcome of the latest self-test probe against the 3scale target
+func SetSelfTestResult(target selftest.Target, err error) {
+	var val float64
+	if err == nil {
+		val = 1
+	}
+	selfTestReachable.WithLabelValues(string(target)).Set(val)
+}
+
+// IncrementBackendHedged increments the number of hedged requests sent to the 3scale backend host
+func IncrementBackendHedged(host string) {
+	backendHedged.WithLabelValues(host).Inc()
+}
+
+// IncrementAPIVersionRequests increments the number of authorized requests to the version of the service
+func IncrementAPIVersionRequests(serviceID string, version string) {
+	apiVersionRequests.WithLabelValues(serviceID, version).Inc()
+}
+
+// IncrementConcurrencyLimited increments the number of requests rejected due to the concurrency limit
+func IncrementConcurrencyLimited() {
+	concurrencyLimited.Inc()
+}
+
+// IncrementInvalidServiceToken increments the number of handlers found using a service with an invalid service token
+func IncrementInvalidServiceToken(serviceID string) {
+	invalidServiceToken.WithLabelValues(serviceID).Inc()
+}
+
+// IncrementApplicationRequests increments the number of authorized requests made by the hashed application
+func IncrementApplicationRequests(serviceID string, application string) {
+	applicationRequests.WithLabelValues(serviceID, application).Inc()
+}
+
+// IncrementBudgetExceeded increments the number of requests to the host cancelled for exhausting the stage budget
+func IncrementBudgetExceeded(stage httpclient.Stage, host string) {
+	budgetExceeded.WithLabelValues(string(stage), host).Inc()
+}
+
+// IncrementOnboardingWouldDeny increments the number of requests to the onboarding service which would be denied
+func IncrementOnboardingWouldDeny(serviceID string, reason string) {
+	onboardingWouldDeny.WithLabelValues(serviceID, reason).Inc()
+}
+
+// IncrementFailOpen increments the number of requests for the service allowed despite an error reaching 3scale
+func IncrementFailOpen(serviceID string) {
+	failOpenRequests.WithLabelValues(serviceID).Inc()
+}
+
+// IncrementBackendErrors increments the number of requests which could not be authorized due to an error reaching
+// 3scale backend, labelled by the class of the error
+func IncrementBackendErrors(serviceID string, class string) {
+	backendErrors.WithLabelValues(serviceID, class).Inc()
+}
+
+// IncrementAuthorizations increments the number of authorization decisions for the service, labelled as allowed or
+// denied along with the reason for the decision
+func IncrementAuthorizations(serviceID string, allowed bool, reason string) {
+	decision := "denied"
+	if allowed {
+		decision = "allowed"
+	}
+	authorizations.WithLabelValues(serviceID, decision, reason).Inc()
+}
+
+// IncrementBackendResponses increments the number of requests sent to 3scale backend to authorize for the service,
+// labelled by the class of the response
+func IncrementBackendResponses(serviceID string, class string) {
+	backendResponses.WithLabelValues(serviceID, class).Inc()
+}
+
+// IncrementConfigUnmarshalFailures increments the number of requests whose handler config could not be unmarshalled
+func IncrementConfigUnmarshalFailures(instance string, handler string) {
+	configUnmarshalFailures.WithLabelValues(instance, handler).Inc()
+}
+
+// SetSystemServiceDegraded records whether fetches of the proxy configuration of the service are being held back
+func SetSystemServiceDegraded(host string, serviceID string, degraded bool) {
+	var val float64
+	if degraded {
+		val = 1
+	}
+	systemServiceDegraded.WithLabelValues(host, serviceID).Set(val)
+}
+
+// SetEndpointHealthScore records the latest health score of the 3scale host
+func SetEndpointHealthScore(host string, score float64) {
+	endpointHealthScore.WithLabelValues(host).Set(score)
+}
+
+// ObserveDeadline records the time remaining until the deadline of a gRPC request when it arrived, and the fraction
+// of it consumed handling the request. Requests arriving with their deadline already passed consume all of it.
+func ObserveDeadline(method string, remaining time.Duration, consumed time.Duration) {
+	ratio := 1.0
+	if remaining > 0 {
+		ratio = consumed.Seconds() / remaining.Seconds()
+	} else {
+		remaining = 0
+	}
+
+	deadlineRemaining.WithLabelValues(method).Observe(remaining.Seconds())
+	deadlineConsumed.WithLabelValues(method).Observe(ratio)
+}
+
+// ObserveStaleConfig records how long the proxy config of a service had been expired when it was served stale, as it
+// could not be refreshed from 3scale system
+func ObserveStaleConfig(serviceID string, staleness time.Duration) {
+	staleConfigServed.WithLabelValues(serviceID).Observe(staleness.Seconds())
+}
+
+// SetServiceFailOpen records whether the service is failing open after exceeding its error budget
+func SetServiceFailOpen(serviceID string, failingOpen bool) {
+	var val float64
+	if failingOpen {
+		val = 1
+	}
+	serviceFailOpen.WithLabelValues(serviceID).Set(val)
+}
+
 func Register() {
-	prometheus.MustRegister(threescaleLatency, threescaleHTTP, cacheHitsSystem, cacheHitsBackend)
+	prometheus.MustRegister(
+		threescaleLatency,
+		threescaleHTTP,
+		cacheHitsSystem,
+		cacheMissesSystem,
+		cacheFetchFailuresSystem,
+		cacheEvictionsSystem,
+		cacheEntriesSystem,
+		cacheEntryAgeSystem,
+		cacheHitsBackend,
+		systemThrottled,
+		deprecatedConfig,
+		selfTestReachable,
+		backendHedged,
+		apiVersionRequests,
+		concurrencyLimited,
+		invalidServiceToken,
+		applicationRequests,
+		budgetExceeded,
+		onboardingWouldDeny,
+		failOpenRequests,
+		backendErrors,
+		authorizations,
+		backendResponses,
+		configUnmarshalFailures,
+		systemServiceDegraded,
+		serviceFailOpen,
+		endpointHealthScore,
+		deadlineRemaining,
+		deadlineConsumed,
+		staleConfigServed,
+	)
 }
 
 func GetHandler() http.Handler {
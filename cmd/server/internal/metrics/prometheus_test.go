@@ -1,12 +1,15 @@
 package metrics
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/pkg/httpclient"
+	"github.com/3scale/3scale-istio-adapter/pkg/selftest"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
@@ -77,3 +80,324 @@ func TestIncrementCacheHits(t *testing.T) {
 		t.Errorf("unexpected counter value for %s", backendCollector.Desc().String())
 	}
 }
+
+func TestSetSystemThrottled(t *testing.T) {
+	collector := systemThrottled.WithLabelValues(url)
+
+	SetSystemThrottled(url, true)
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected gauge value for throttled host")
+	}
+
+	SetSystemThrottled(url, false)
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected gauge value for released host")
+	}
+}
+
+func TestIncrementDeprecatedConfig(t *testing.T) {
+	collector := deprecatedConfig.WithLabelValues("backend_url")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for deprecated field")
+	}
+
+	IncrementDeprecatedConfig("backend_url")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for deprecated field")
+	}
+}
+
+func TestSetSelfTestResult(t *testing.T) {
+	collector := selfTestReachable.WithLabelValues(string(selftest.Backend))
+
+	SetSelfTestResult(selftest.Backend, nil)
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected gauge value for reachable target")
+	}
+
+	SetSelfTestResult(selftest.Backend, errors.New("unreachable"))
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected gauge value for unreachable target")
+	}
+}
+
+func TestIncrementBackendHedged(t *testing.T) {
+	collector := backendHedged.WithLabelValues(url)
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for hedged requests")
+	}
+
+	IncrementBackendHedged(url)
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for hedged requests")
+	}
+}
+
+func TestIncrementAPIVersionRequests(t *testing.T) {
+	collector := apiVersionRequests.WithLabelValues("123", "v2")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for api version")
+	}
+
+	IncrementAPIVersionRequests("123", "v2")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for api version")
+	}
+}
+
+func TestIncrementConcurrencyLimited(t *testing.T) {
+	before := testutil.ToFloat64(concurrencyLimited)
+
+	IncrementConcurrencyLimited()
+	if testutil.ToFloat64(concurrencyLimited) != before+1 {
+		t.Errorf("unexpected counter value for concurrency limited requests")
+	}
+}
+
+func TestIncrementInvalidServiceToken(t *testing.T) {
+	collector := invalidServiceToken.WithLabelValues("123")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for invalid service token")
+	}
+
+	IncrementInvalidServiceToken("123")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for invalid service token")
+	}
+}
+
+func TestIncrementApplicationRequests(t *testing.T) {
+	collector := applicationRequests.WithLabelValues("123", "5f3a9c2e1b7d4e60")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for application")
+	}
+
+	IncrementApplicationRequests("123", "5f3a9c2e1b7d4e60")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for application")
+	}
+}
+
+func TestIncrementBudgetExceeded(t *testing.T) {
+	collector := budgetExceeded.WithLabelValues("backend", "su1.3scale.net")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for exceeded budget")
+	}
+
+	IncrementBudgetExceeded(httpclient.BackendStage, "su1.3scale.net")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for exceeded budget")
+	}
+}
+
+func TestIncrementOnboardingWouldDeny(t *testing.T) {
+	collector := onboardingWouldDeny.WithLabelValues("123", "limits")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for onboarding")
+	}
+
+	IncrementOnboardingWouldDeny("123", "limits")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for onboarding")
+	}
+}
+
+func TestIncrementFailOpen(t *testing.T) {
+	collector := failOpenRequests.WithLabelValues("123")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for fail open")
+	}
+
+	IncrementFailOpen("123")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for fail open")
+	}
+}
+
+func TestIncrementBackendErrors(t *testing.T) {
+	collector := backendErrors.WithLabelValues("123", "timeout")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for backend errors")
+	}
+
+	IncrementBackendErrors("123", "timeout")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for backend errors")
+	}
+}
+
+func TestIncrementAuthorizations(t *testing.T) {
+	allowed := authorizations.WithLabelValues("123", "allowed", "ok")
+	denied := authorizations.WithLabelValues("123", "denied", "limits")
+
+	IncrementAuthorizations("123", true, "ok")
+	IncrementAuthorizations("123", false, "limits")
+	IncrementAuthorizations("123", false, "limits")
+	if testutil.ToFloat64(allowed) != 1 || testutil.ToFloat64(denied) != 2 {
+		t.Errorf("unexpected counter values for authorizations")
+	}
+}
+
+func TestIncrementBackendResponses(t *testing.T) {
+	collector := backendResponses.WithLabelValues("123", "2xx")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for backend responses")
+	}
+
+	IncrementBackendResponses("123", "2xx")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for backend responses")
+	}
+}
+
+func TestIncrementConfigUnmarshalFailures(t *testing.T) {
+	collector := configUnmarshalFailures.WithLabelValues("threescale-authorization.istio-system", "0a1b2c3d4e5f6789")
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected counter value for config unmarshal failures")
+	}
+
+	IncrementConfigUnmarshalFailures("threescale-authorization.istio-system", "0a1b2c3d4e5f6789")
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected counter value for config unmarshal failures")
+	}
+}
+
+func TestSetSystemServiceDegraded(t *testing.T) {
+	collector := systemServiceDegraded.WithLabelValues(url, "123")
+
+	SetSystemServiceDegraded(url, "123", true)
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected gauge value for degraded service")
+	}
+
+	SetSystemServiceDegraded(url, "123", false)
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected gauge value for recovered service")
+	}
+}
+
+func TestSetServiceFailOpen(t *testing.T) {
+	collector := serviceFailOpen.WithLabelValues("123")
+
+	SetServiceFailOpen("123", true)
+	if testutil.ToFloat64(collector) != 1 {
+		t.Errorf("unexpected gauge value for service failing open")
+	}
+
+	SetServiceFailOpen("123", false)
+	if testutil.ToFloat64(collector) != 0 {
+		t.Errorf("unexpected gauge value for enforced service")
+	}
+}
+
+func TestSetEndpointHealthScore(t *testing.T) {
+	SetEndpointHealthScore("su1.3scale.net", 0.75)
+	if testutil.ToFloat64(endpointHealthScore.WithLabelValues("su1.3scale.net")) != 0.75 {
+		t.Errorf("unexpected gauge value for endpoint health score")
+	}
+}
+
+func TestObserveDeadline(t *testing.T) {
+	const metricName = "threescale_grpc_deadline_consumed_ratio"
+	const expect = `
+                # HELP threescale_grpc_deadline_consumed_ratio Fraction of the deadline remaining on arrival which was consumed handling a gRPC request
+                # TYPE threescale_grpc_deadline_consumed_ratio histogram
+                threescale_grpc_deadline_consumed_ratio_bucket{method="HandleAuthorization",le="0.05"} 0
+                threescale_grpc_deadline_consumed_ratio_bucket{method="HandleAuthorization",le="0.1"} 0
+                threescale_grpc_deadline_consumed_ratio_bucket{method="HandleAuthorization",le="0.25"} 1
+                threescale_grpc_deadline_consumed_ratio_bucket{method="HandleAuthorization",le="0.5"} 1
+                threescale_grpc_deadline_consumed_ratio_bucket{method="HandleAuthorization",le="0.75"} 1
+                threescale_grpc_deadline_consumed_ratio_bucket{method="HandleAuthorization",le="0.9"} 1
+                threescale_grpc_deadline_consumed_ratio_bucket{method="HandleAuthorization",le="1"} 2
+                threescale_grpc_deadline_consumed_ratio_bucket{method="HandleAuthorization",le="1.5"} 2
+                threescale_grpc_deadline_consumed_ratio_bucket{method="HandleAuthorization",le="+Inf"} 2
+                threescale_grpc_deadline_consumed_ratio_sum{method="HandleAuthorization"} 1.25
+                threescale_grpc_deadline_consumed_ratio_count{method="HandleAuthorization"} 2
+        `
+
+	ObserveDeadline("HandleAuthorization", 2*time.Second, 500*time.Millisecond)
+	// a request arriving after its deadline has passed consumes all of it
+	ObserveDeadline("HandleAuthorization", -time.Second, time.Millisecond)
+
+	err := testutil.CollectAndCompare(deadlineConsumed, strings.NewReader(expect), metricName)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
+func TestObserveStaleConfig(t *testing.T) {
+	const metricName = "threescale_system_config_staleness_seconds"
+	const expect = `
+                # HELP threescale_system_config_staleness_seconds Time since expiry of each proxy config served stale as it could not be refreshed from 3scale system
+                # TYPE threescale_system_config_staleness_seconds histogram
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="1"} 0
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="5"} 0
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="15"} 0
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="30"} 1
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="60"} 1
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="120"} 2
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="300"} 2
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="600"} 2
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="1800"} 2
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="3600"} 2
+                threescale_system_config_staleness_seconds_bucket{service_id="123",le="+Inf"} 2
+                threescale_system_config_staleness_seconds_sum{service_id="123"} 110
+                threescale_system_config_staleness_seconds_count{service_id="123"} 2
+        `
+
+	ObserveStaleConfig("123", 20*time.Second)
+	ObserveStaleConfig("123", 90*time.Second)
+
+	err := testutil.CollectAndCompare(staleConfigServed, strings.NewReader(expect), metricName)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+}
+
+func TestSystemCacheMetrics(t *testing.T) {
+	ObserveSystemCacheHit(20 * time.Second)
+	IncrementSystemCacheMisses()
+	IncrementSystemCacheFetchFailures("123")
+	IncrementSystemCacheEvictions()
+	SetSystemCacheEntries(5)
+
+	if testutil.ToFloat64(cacheMissesSystem) != 1 {
+		t.Errorf("unexpected counter value for cache misses")
+	}
+
+	if testutil.ToFloat64(cacheFetchFailuresSystem.WithLabelValues("123")) != 1 {
+		t.Errorf("unexpected counter value for cache fetch failures")
+	}
+
+	if testutil.ToFloat64(cacheEvictionsSystem) != 1 {
+		t.Errorf("unexpected counter value for cache evictions")
+	}
+
+	if testutil.ToFloat64(cacheEntriesSystem) != 5 {
+		t.Errorf("unexpected gauge value for cache entries")
+	}
+
+	const metricName = "threescale_system_cache_entry_age_seconds"
+	const expect = `
+                # HELP threescale_system_cache_entry_age_seconds Time since each proxy config served from the compressed cache was fetched from 3scale system
+                # TYPE threescale_system_cache_entry_age_seconds histogram
+                threescale_system_cache_entry_age_seconds_bucket{le="1"} 0
+                threescale_system_cache_entry_age_seconds_bucket{le="5"} 0
+                threescale_system_cache_entry_age_seconds_bucket{le="15"} 0
+                threescale_system_cache_entry_age_seconds_bucket{le="30"} 1
+                threescale_system_cache_entry_age_seconds_bucket{le="60"} 1
+                threescale_system_cache_entry_age_seconds_bucket{le="120"} 1
+                threescale_system_cache_entry_age_seconds_bucket{le="300"} 1
+                threescale_system_cache_entry_age_seconds_bucket{le="600"} 1
+                threescale_system_cache_entry_age_seconds_bucket{le="1800"} 1
+                threescale_system_cache_entry_age_seconds_bucket{le="3600"} 1
+                threescale_system_cache_entry_age_seconds_bucket{le="+Inf"} 1
+                threescale_system_cache_entry_age_seconds_sum 20
+                threescale_system_cache_entry_age_seconds_count 1
+        `
+
+	err := testutil.CollectAndCompare(cacheEntryAgeSystem, strings.NewReader(expect), metricName)
+	if err != nil {
+		t.Errorf(err.Error())
+	}
+}
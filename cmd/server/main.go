@@ -2,12 +2,18 @@ package main
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -15,6 +21,13 @@ import (
 	"github.com/3scale/3scale-authorizer/pkg/authorizer"
 	"github.com/3scale/3scale-authorizer/pkg/backend/v1"
 	"github.com/3scale/3scale-istio-adapter/cmd/server/internal/metrics"
+	"github.com/3scale/3scale-istio-adapter/pkg/admin"
+	"github.com/3scale/3scale-istio-adapter/pkg/httpclient"
+	"github.com/3scale/3scale-istio-adapter/pkg/kubernetes"
+	"github.com/3scale/3scale-istio-adapter/pkg/logging"
+	"github.com/3scale/3scale-istio-adapter/pkg/redis"
+	"github.com/3scale/3scale-istio-adapter/pkg/secrets"
+	"github.com/3scale/3scale-istio-adapter/pkg/selftest"
 	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
 	"github.com/spf13/viper"
 
@@ -32,29 +45,143 @@ const (
 	defaultSystemCacheTTLSeconds             = 300
 	defaultSystemCacheRefreshIntervalSeconds = 180
 	defaultSystemCacheSize                   = 1000
+	defaultConfigSnapshotSeconds             = 60
+	defaultConfigSnapshotMaxAgeSeconds       = 86400
 
 	defaultMetricsEndpoint = "/metrics"
+	defaultHealthEndpoint  = "/health"
+	defaultReadyEndpoint   = "/ready"
+	defaultLivenessProbe   = "/healthz"
+	defaultReadinessProbe  = "/readyz"
+	defaultLoggingEndpoint = "/logging/sampling"
+	defaultConfigEndpoint  = "/config"
+	defaultTuningEndpoint  = "/tuning"
+	defaultOpenAPIEndpoint = "/openapi.json"
+	defaultUsageEndpoint   = "/usage"
+	defaultEndpointScores  = "/endpoints"
 	defaultMetricsPort     = 8080
 
+	defaultLogSamplingFirst = 5
+
 	defaultBackendCacheFlushInterval = time.Second * 15
+
+	defaultLeaderElectionName = "3scale-istio-adapter-leader"
+	defaultRuntimeConfigName  = "3scale-istio-adapter"
+	serviceAccountNamespace   = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 )
 
 func init() {
 	viper.BindEnv("log_level")
 	viper.BindEnv("log_json")
 	viper.BindEnv("log_grpc")
+	viper.BindEnv("log_sampling_first")
+	viper.BindEnv("log_sampling_interval_seconds")
 	viper.BindEnv("listen_addr")
 	viper.BindEnv("report_metrics")
 	viper.BindEnv("metrics_port")
+	viper.BindEnv("metrics_state_file")
+	viper.BindEnv("metrics_mapping_file")
+
+	viper.BindEnv("admin_port")
+	viper.BindEnv("admin_tls_cert_file")
+	viper.BindEnv("admin_tls_key_file")
+	viper.BindEnv("admin_tls_client_ca_file")
+	viper.BindEnv("admin_auth_token")
+	viper.BindEnv("probe_port")
+	viper.BindEnv("forbid_secret_args")
 
 	viper.BindEnv("cache_ttl_seconds")
 	viper.BindEnv("cache_refresh_seconds")
 	viper.BindEnv("cache_entries_max")
+	viper.BindEnv("cache_compression")
+	viper.BindEnv("cache_stale_if_error_seconds")
+	viper.BindEnv("config_snapshot_file")
+	viper.BindEnv("config_snapshot_seconds")
+	viper.BindEnv("config_snapshot_max_age_seconds")
+	viper.BindEnv("shared_cache_redis_addr")
+	viper.BindEnv("shared_cache_redis_password")
+	viper.BindEnv("shared_cache_redis_db")
 
 	viper.BindEnv("client_timeout_seconds")
 	viper.BindEnv("allow_insecure_conn")
+	viper.BindEnv("client_tls_ca_file")
+	viper.BindEnv("client_tls_min_version")
+	viper.BindEnv("client_tls_cipher_suites")
+	viper.BindEnv("client_tls_curve_preferences")
+
+	viper.BindEnv("system_backoff_seconds")
+	viper.BindEnv("system_backoff_max_seconds")
+
+	viper.BindEnv("system_flap_threshold")
+	viper.BindEnv("system_flap_backoff_seconds")
+	viper.BindEnv("system_flap_backoff_max_seconds")
+
+	viper.BindEnv("system_negative_cache_seconds")
+
+	viper.BindEnv("backend_hedge_delay_ms")
+	viper.BindEnv("backend_hedge_max_ratio")
+
+	viper.BindEnv("system_budget_ms")
+	viper.BindEnv("backend_budget_ms")
 
 	viper.BindEnv("grpc_conn_max_seconds")
+	viper.BindEnv("grpc_tls_cert_file")
+	viper.BindEnv("grpc_tls_key_file")
+	viper.BindEnv("grpc_tls_client_ca_file")
+	viper.BindEnv("grpc_tls_client_allowed_sans")
+	viper.BindEnv("grpc_tls_min_version")
+	viper.BindEnv("grpc_tls_cipher_suites")
+	viper.BindEnv("grpc_tls_curve_preferences")
+	viper.BindEnv("max_concurrent_requests")
+	viper.BindEnv("service_priorities")
+	viper.BindEnv("priority_normal_share")
+	viper.BindEnv("priority_low_share")
+	viper.BindEnv("config_strategies")
+	viper.BindEnv("strict_config")
+	viper.BindEnv("session_based")
+
+	viper.BindEnv("plan_restrictions_enabled")
+	viper.BindEnv("plan_methods_attribute")
+	viper.BindEnv("plan_size_attribute")
+
+	viper.BindEnv("upgrade_hints_enabled")
+	viper.BindEnv("upgrade_url_annotation")
+
+	viper.BindEnv("validate_service_tokens")
+
+	viper.BindEnv("preconnect_enabled")
+
+	viper.BindEnv("rate_limit_config_file")
+
+	viper.BindEnv("error_budget_threshold")
+	viper.BindEnv("error_budget_window_seconds")
+	viper.BindEnv("error_budget_min_requests")
+
+	viper.BindEnv("startup_fail_open_seconds")
+	viper.BindEnv("startup_fail_open_until_warm")
+
+	viper.BindEnv("application_metrics_enabled")
+	viper.BindEnv("application_metrics_salt")
+	viper.BindEnv("usage_report_enabled")
+
+	viper.BindEnv("self_test_interval_seconds")
+	viper.BindEnv("self_test_system_url")
+	viper.BindEnv("self_test_access_token")
+	viper.BindEnv("self_test_service_id")
+
+	viper.BindEnv("leader_election_enabled")
+	viper.BindEnv("leader_election_namespace")
+	viper.BindEnv("leader_election_name")
+	viper.BindEnv("pod_name")
+
+	viper.BindEnv("kubernetes_events_enabled")
+
+	viper.BindEnv("runtime_config_enabled")
+	viper.BindEnv("runtime_config_name")
+	viper.BindEnv("runtime_config_interval_seconds")
+
+	viper.BindEnv("shard_count")
+	viper.BindEnv("shard_index")
 
 	viper.BindEnv("use_cached_backend")
 	viper.BindEnv("backend_cache_flush_interval_seconds")
@@ -96,18 +223,281 @@ func stringToLogLevel(loglevel string) log.Level {
 	return log.InfoLevel
 }
 
-func parseMetricsConfig() *authorizer.MetricsReporter {
-	if !viper.IsSet("report_metrics") || !viper.GetBool("report_metrics") {
+// createLogSampler returns a sampler for identical errors which can be reconfigured at runtime via the admin endpoints.
+// Messages suppressed within an interval are logged once it ends until stop is closed, should they not recur.
+func createLogSampler(mux *http.ServeMux, stop <-chan struct{}) *logging.Sampler {
+	sampler := logging.NewSampler(logSamplingConfig())
+	mux.Handle(defaultLoggingEndpoint, guardChanges(sampler))
+	go sampler.RunFlush(func(msg string, suppressed int) {
+		log.Warnf("%s (%d identical messages suppressed)", msg, suppressed)
+	}, stop)
+	return sampler
+}
+
+// logSamplingConfig returns the log sampling provided via the environment
+func logSamplingConfig() (int, time.Duration) {
+	first := defaultLogSamplingFirst
+	if viper.IsSet("log_sampling_first") {
+		first = viper.GetInt("log_sampling_first")
+	}
+
+	interval := time.Duration(viper.GetInt("log_sampling_interval_seconds")) * time.Second
+	return first, interval
+}
+
+// createConfigEndpoint serves the effective configuration, with credentials redacted, via the admin endpoints,
+// returning the handler so that sections only available once the adapter has been created can be added
+func createConfigEndpoint(mux *http.ServeMux, sampler *logging.Sampler, flap *httpclient.FlapTransport, tuning *threescale.Tuning,
+	budget *threescale.ErrorBudget) *admin.ConfigHandler {
+	handler := admin.NewConfigHandler()
+
+	handler.Add("version", func() interface{} {
+		return version
+	})
+
+	handler.Add("env", func() interface{} {
+		return admin.Redact(viper.AllSettings())
+	})
+
+	handler.Add("system_cache", func() interface{} {
+		conf := systemCacheConfig()
+		return map[string]interface{}{
+			"entries_max":     conf.MaxSize,
+			"refresh_retries": conf.NumRetryFailedRefresh,
+			"refresh_seconds": int(conf.RefreshInterval / time.Second),
+			"ttl_seconds":     int(conf.TTL / time.Second),
+			"compression":     viper.GetBool("cache_compression"),
+			"shard_count":     viper.GetInt("shard_count"),
+			"shard_index":     viper.GetString("shard_index"),
+		}
+	})
+
+	handler.Add("backend_cache", func() interface{} {
+		return map[string]interface{}{
+			"enabled":                viper.GetBool("use_cached_backend"),
+			"flush_interval_seconds": int(backendCacheFlushInterval() / time.Second),
+			"policy_fail_closed":     !viper.IsSet("backend_cache_policy_fail_closed") || viper.GetBool("backend_cache_policy_fail_closed"),
+		}
+	})
+
+	handler.Add("log_sampling", func() interface{} {
+		return sampler.Config()
+	})
+
+	handler.Add("tuning", func() interface{} {
+		return tuning.Config()
+	})
+
+	handler.Add("degraded_services", func() interface{} {
+		if flap == nil {
+			return []httpclient.DegradedService{}
+		}
+		return flap.Degraded()
+	})
+
+	handler.Add("fail_open_services", func() interface{} {
+		return budget.FailingOpen()
+	})
+
+	mux.Handle(defaultConfigEndpoint, guardDiagnostics(handler))
+	return handler
+}
+
+// createGRPCTLSConfig returns nil unless a certificate and key are set for the gRPC listener, requiring clients to
+// present a certificate signed by the client CA when one is set, and naming one of the allowed SANs when those are set.
+// The adapter fails to start on invalid settings.
+func createGRPCTLSConfig() *tls.Config {
+	certFile, keyFile := viper.GetString("grpc_tls_cert_file"), viper.GetString("grpc_tls_key_file")
+	caFile := viper.GetString("grpc_tls_client_ca_file")
+
+	var allowedSANs []string
+	for _, san := range strings.Split(viper.GetString("grpc_tls_client_allowed_sans"), ",") {
+		if san = strings.TrimSpace(san); san != "" {
+			allowedSANs = append(allowedSANs, san)
+		}
+	}
+
+	if len(allowedSANs) > 0 && caFile == "" {
+		log.Fatalf("GRPC_TLS_CLIENT_ALLOWED_SANS requires GRPC_TLS_CLIENT_CA_FILE to be set")
+	}
+
+	policy := createTLSPolicy("grpc")
+
+	if certFile == "" && keyFile == "" {
+		if caFile != "" {
+			log.Fatalf("GRPC_TLS_CLIENT_CA_FILE requires GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE to be set")
+		}
+		if !policy.IsZero() {
+			log.Fatalf("GRPC_TLS_MIN_VERSION, GRPC_TLS_CIPHER_SUITES and GRPC_TLS_CURVE_PREFERENCES require GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE to be set")
+		}
+		return nil
+	}
+
+	if certFile == "" || keyFile == "" {
+		log.Fatalf("both GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE are required to serve gRPC over TLS")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		log.Fatalf("failed to load gRPC certificate - %v", err)
+	}
+
+	conf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	policy.Apply(conf)
+
+	if caFile == "" {
+		return conf
+	}
+
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		log.Fatalf("failed to read gRPC client CA - %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		log.Fatalf("no certificates found in gRPC client CA file %s", caFile)
+	}
+
+	conf.ClientCAs = pool
+	conf.ClientAuth = tls.RequireAndVerifyClientCert
+	if len(allowedSANs) > 0 {
+		conf.VerifyPeerCertificate = threescale.VerifyPeerSANs(allowedSANs)
+	}
+	return conf
+}
+
+// createClientRootCAs returns nil unless a CA bundle has been set for connections to 3scale, in which case the bundle
+// is trusted in addition to the CAs of the system, so that 3scale deployments using a private CA can be reached without
+// disabling verification. The adapter fails to start when the bundle cannot be loaded.
+func createClientRootCAs() *x509.CertPool {
+	caFile := viper.GetString("client_tls_ca_file")
+	if caFile == "" {
+		return nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Warnf("unable to load system CAs, trusting only %s - %v", caFile, err)
+		pool = x509.NewCertPool()
+	}
+
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		log.Fatalf("failed to read 3scale client CA bundle - %v", err)
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		log.Fatalf("no certificates found in 3scale client CA bundle %s", caFile)
+	}
+
+	log.Infof("trusting the CAs in %s when connecting to 3scale", caFile)
+	return pool
+}
+
+// createTLSPolicy returns the TLS policy set by the <prefix>_tls_min_version, <prefix>_tls_cipher_suites and
+// <prefix>_tls_curve_preferences settings. The adapter fails to start on invalid settings.
+func createTLSPolicy(prefix string) threescale.TLSPolicy {
+	policy, err := threescale.ParseTLSPolicy(
+		viper.GetString(prefix+"_tls_min_version"),
+		viper.GetString(prefix+"_tls_cipher_suites"),
+		viper.GetString(prefix+"_tls_curve_preferences"),
+	)
+	if err != nil {
+		log.Fatalf("invalid %s TLS settings - %v", strings.ToUpper(prefix), err)
+	}
+	return policy
+}
+
+// serveAdmin serves the admin endpoints registered on mux. When ADMIN_PORT is set they are served on a dedicated
+// listener with its own TLS and authentication, otherwise they are served alongside the metrics endpoint.
+func serveAdmin(mux *http.ServeMux, grpcAddr string) *admin.Server {
+	if !serveDiagnostics() {
+		log.Infof("the /config and /endpoints admin endpoints are only served on an ADMIN_PORT which authenticates clients")
+	}
+
+	if !viper.IsSet("admin_port") {
+		http.Handle("/", mux)
 		return nil
 	}
 
-	port := defaultMetricsPort
+	port := viper.GetInt("admin_port")
+	if strconv.Itoa(port) == grpcAddr || (viper.GetBool("report_metrics") && port == metricsPort()) {
+		log.Fatalf("admin port %d must differ from the gRPC and metrics ports", port)
+	}
+
+	conf := adminServerConfig()
+	conf.Port = port
+	server, err := admin.NewServer(conf, mux)
+	if err != nil {
+		log.Fatalf("failed to start admin server %v", err)
+	}
+
+	go func() {
+		if err := server.Serve(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("admin server has shut down: err %v", err)
+		}
+	}()
+	log.Infof("Serving admin endpoints on port %d", port)
+
+	return server
+}
+
+// adminServerConfig returns the TLS and authentication settings of the dedicated admin listener
+func adminServerConfig() admin.ServerConfig {
+	return admin.ServerConfig{
+		CertFile:     viper.GetString("admin_tls_cert_file"),
+		KeyFile:      viper.GetString("admin_tls_key_file"),
+		ClientCAFile: viper.GetString("admin_tls_client_ca_file"),
+		Token:        viper.GetString("admin_auth_token"),
+	}
+}
+
+// guardChanges makes an admin endpoint which can change the behaviour of the adapter read only, unless it is served on
+// the dedicated admin listener and that listener authenticates clients. Otherwise anything able to reach the metrics
+// port could, for example, disable authorization by failing open.
+func guardChanges(handler http.Handler) http.Handler {
+	if viper.IsSet("admin_port") && adminServerConfig().Authenticated() {
+		return handler
+	}
+	return admin.ReadOnly(handler)
+}
+
+// guardDiagnostics serves an admin endpoint which exposes the configuration or traffic of the adapter only on the
+// dedicated admin listener, and only when that listener authenticates clients. Otherwise it would be served to anything
+// able to reach the metrics port.
+func guardDiagnostics(handler http.Handler) http.Handler {
+	if serveDiagnostics() {
+		return handler
+	}
+	return admin.Forbidden()
+}
+
+// serveDiagnostics reports whether the admin endpoints exposing the configuration or traffic of the adapter are served
+func serveDiagnostics() bool {
+	return viper.IsSet("admin_port") && adminServerConfig().Authenticated()
+}
+
+func metricsPort() int {
 	if viper.IsSet("metrics_port") {
-		port = viper.GetInt("metrics_port")
+		return viper.GetInt("metrics_port")
+	}
+	return defaultMetricsPort
+}
+
+func parseMetricsConfig() *authorizer.MetricsReporter {
+	if !viper.IsSet("report_metrics") || !viper.GetBool("report_metrics") {
+		return nil
 	}
 
+	port := metricsPort()
+
 	metrics.Register()
-	http.Handle(defaultMetricsEndpoint, metrics.GetHandler())
+	restoreMetrics()
+	http.Handle(defaultMetricsEndpoint, metrics.GetMappedHandler(loadMetricsMapping()))
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		log.Fatalf("failed to start metrics server %v", err)
@@ -122,147 +512,1349 @@ func parseMetricsConfig() *authorizer.MetricsReporter {
 	}
 }
 
-func parseClientConfig() *http.Client {
-	c := &http.Client{
-		// Setting some sensible default here for http timeouts
-		Timeout: time.Duration(time.Second * 10),
+// loadMetricsMapping returns the mapping applied to the names of exported metrics, when a mapping file is configured.
+// The adapter fails to start with an invalid mapping, rather than exporting metrics under unexpected names.
+func loadMetricsMapping() *metrics.NameMapping {
+	path := viper.GetString("metrics_mapping_file")
+	if path == "" {
+		return nil
 	}
 
-	if viper.IsSet("client_timeout_seconds") {
-		c.Timeout = time.Duration(viper.GetInt("client_timeout_seconds")) * time.Second
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalf("unable to read metric name mapping - %v", err)
 	}
 
-	if viper.IsSet("allow_insecure_conn") {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: viper.GetBool("allow_insecure_conn")},
-		}
-		c.Transport = tr
+	mapping, err := metrics.ParseNameMapping(data)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
 
-	return c
+	log.Infof("exporting metrics with %d renamed and %d dropped as per %s", len(mapping.Rename), len(mapping.Drop), path)
+	return mapping
 }
 
-func createSystemCache() *authorizer.SystemCache {
-	cacheTTL := defaultSystemCacheTTLSeconds
-	cacheEntriesMax := defaultSystemCacheSize
-	cacheUpdateRetries := defaultSystemCacheRetries
-	cacheRefreshInterval := defaultSystemCacheRefreshIntervalSeconds
-
-	if viper.IsSet("cache_ttl_seconds") {
-		cacheTTL = viper.GetInt("cache_ttl_seconds")
+// checkSecretArgs refuses to start when FORBID_SECRET_ARGS is set and a secret configured for the adapter appears in
+// its arguments, where it would be visible in the process list of the host
+func checkSecretArgs() {
+	if !viper.GetBool("forbid_secret_args") {
+		return
 	}
 
-	if viper.IsSet("cache_refresh_seconds") {
-		cacheRefreshInterval = viper.GetInt("cache_refresh_seconds")
+	if secrets.InArgs(os.Args[1:],
+		viper.GetString("admin_auth_token"),
+		viper.GetString("self_test_access_token"),
+		viper.GetString("shared_cache_redis_password"),
+		viper.GetString("application_metrics_salt"),
+	) {
+		log.Fatalf("refusing to start - a secret appears in the command line arguments, provide it via the environment only")
 	}
+}
 
-	if viper.IsSet("cache_entries_max") {
-		cacheEntriesMax = viper.GetInt("cache_entries_max")
+// loadRateLimitConfig returns the domains served by the Envoy rate limit service, when a config file is configured.
+// The adapter fails to start if the file cannot be loaded.
+func loadRateLimitConfig() *threescale.RateLimitConfig {
+	path := viper.GetString("rate_limit_config_file")
+	if path == "" {
+		return nil
 	}
 
-	if viper.IsSet("cache_refresh_retries") {
-		cacheUpdateRetries = viper.GetInt("cache_refresh_retries")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Fatalf("unable to read rate limit config - %v", err)
 	}
 
-	config := authorizer.SystemCacheConfig{
-		MaxSize:               cacheEntriesMax,
-		NumRetryFailedRefresh: cacheUpdateRetries,
-		RefreshInterval:       time.Duration(cacheRefreshInterval) * time.Second,
-		TTL:                   time.Duration(cacheTTL) * time.Second,
+	conf, err := threescale.ParseRateLimitConfig(data)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
+	return conf
+}
 
-	return authorizer.NewSystemCache(config, make(chan struct{}))
+// readyHandler responds with 200 once the server has started, including its background workers, and 503 before
+func readyHandler(s threescale.Server) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-s.Ready():
+			fmt.Fprintln(w, "ready")
+		default:
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+		}
+	})
 }
 
-func createBackendConfig() authorizer.BackendConfig {
-	logger := log.FindScope(log.DefaultScopeName)
+// serveProbes serves the liveness and readiness endpoints for Kubernetes probes. When PROBE_PORT is set they are served
+// on a dedicated listener without TLS or authentication, which kubelet probes cannot provide, otherwise they are served
+// alongside the admin endpoints. The server is only live while the gRPC server is serving, once started, and only ready
+// once it can authorize requests.
+func serveProbes(mux *http.ServeMux, s threescale.Server, grpcAddr string) *admin.Server {
+	started := func() error {
+		select {
+		case <-s.Ready():
+			return nil
+		default:
+			return errors.New("adapter is starting")
+		}
+	}
 
-	if viper.GetBool("use_cached_backend") {
-		interval := time.Second * time.Duration(viper.GetInt("backend_cache_flush_interval_seconds"))
-		if interval == 0 {
-			interval = defaultBackendCacheFlushInterval
+	liveness := admin.NewProbeHandler(admin.Check{Name: "grpc", Fn: func() error {
+		if started() != nil {
+			return nil
 		}
+		return s.Serving()
+	}})
 
-		log.Infof("backend cache set to flush at %s intervals", interval.String())
+	readiness := admin.NewProbeHandler(
+		admin.Check{Name: "startup", Fn: started},
+		admin.Check{Name: "grpc", Fn: s.Serving},
+		admin.Check{Name: "config", Fn: s.ConfigReady},
+	)
 
-		return authorizer.BackendConfig{
-			EnableCaching:      true,
-			CacheFlushInterval: interval,
-			Logger:             logger,
-			Policy:             getFailurePolicy(),
+	if !viper.IsSet("probe_port") {
+		mux.Handle(defaultLivenessProbe, liveness)
+		mux.Handle(defaultReadinessProbe, readiness)
+		return nil
+	}
+
+	port := viper.GetInt("probe_port")
+	if strconv.Itoa(port) == grpcAddr || (viper.GetBool("report_metrics") && port == metricsPort()) ||
+		(viper.IsSet("admin_port") && port == viper.GetInt("admin_port")) {
+		log.Fatalf("probe port %d must differ from the gRPC, metrics and admin ports", port)
+	}
+
+	probeMux := http.NewServeMux()
+	probeMux.Handle(defaultLivenessProbe, liveness)
+	probeMux.Handle(defaultReadinessProbe, readiness)
+
+	server, err := admin.NewServer(admin.ServerConfig{Port: port}, probeMux)
+	if err != nil {
+		log.Fatalf("failed to start probe server %v", err)
+	}
+
+	go func() {
+		if err := server.Serve(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("probe server has shut down: err %v", err)
 		}
+	}()
+	log.Infof("Serving probe endpoints on port %d", port)
+
+	return server
+}
+
+// restoreMetrics restores the counters saved by saveMetrics on the previous shutdown, when a state file is configured.
+// A missing file is expected on the first start.
+func restoreMetrics() {
+	path := viper.GetString("metrics_state_file")
+	if path == "" {
+		return
 	}
 
-	return authorizer.BackendConfig{
-		Logger: logger,
+	f, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("unable to read metrics state - %v", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	restored, savedAt, err := metrics.RestoreCounters(f)
+	if err != nil {
+		log.Warnf("unable to restore metrics state from %s - %v", path, err)
+		return
 	}
+	log.Infof("restored %d counters saved at %s", restored, savedAt.Format(time.RFC3339))
 }
 
-func getFailurePolicy() backend.FailurePolicy {
-	policy := backend.FailClosedPolicy
+// saveMetrics saves the counters to the state file, if configured, replacing it only once fully written
+func saveMetrics() {
+	path := viper.GetString("metrics_state_file")
+	if path == "" || !viper.GetBool("report_metrics") {
+		return
+	}
 
-	if viper.IsSet("backend_cache_policy_fail_closed") && !viper.GetBool("backend_cache_policy_fail_closed") {
-		policy = backend.FailOpenPolicy
-		log.Infof("backend cache fail policy set to open")
-	} else {
-		log.Infof("backend cache fail policy set to closed")
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		log.Warnf("unable to save metrics state - %v", err)
+		return
 	}
 
-	return policy
+	err = metrics.SaveCounters(f, time.Now())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+
+	if err != nil {
+		log.Warnf("unable to save metrics state to %s - %v", path, err)
+		return
+	}
+	log.Infof("saved metrics state to %s", path)
 }
 
-func main() {
-	var addr string
+// clientTransports are the transports of the client used to call 3scale which are configured or inspected at runtime
+type clientTransports struct {
+	health *httpclient.HealthTransport
+	// flap is nil when flap detection has been disabled
+	flap       *httpclient.FlapTransport
+	extensions *httpclient.ExtensionsTransport
+}
 
-	if viper.IsSet("listen_addr") {
-		addr = viper.GetString("listen_addr")
+// parseClientConfig returns the client used to call 3scale, along with those of its transports which are configured or
+// inspected at runtime
+func parseClientConfig() (*http.Client, clientTransports) {
+	return newClient(http.ProxyFromEnvironment, false, nil)
+}
+
+// newClient returns a client used to call 3scale, sending requests through the proxies chosen by proxy, along with those
+// of its transports which are configured or inspected at runtime. TLS certificates are not verified when skipVerify is
+// set. When extensions is set, the client sends the backend extensions set via it rather than having its own.
+func newClient(proxy func(*http.Request) (*url.URL, error), skipVerify bool,
+	extensions *httpclient.ExtensionsTransport) (*http.Client, clientTransports) {
+	c := &http.Client{
+		// Setting some sensible default here for http timeouts
+		Timeout: time.Duration(time.Second * 10),
+	}
+
+	if viper.IsSet("client_timeout_seconds") {
+		c.Timeout = time.Duration(viper.GetInt("client_timeout_seconds")) * time.Second
+	}
+
+	var conf *tls.Config
+	rootCAs := createClientRootCAs()
+	if policy := createTLSPolicy("client"); viper.IsSet("allow_insecure_conn") || !policy.IsZero() || rootCAs != nil {
+		conf = &tls.Config{
+			InsecureSkipVerify: viper.GetBool("allow_insecure_conn"),
+			RootCAs:            rootCAs,
+		}
+		policy.Apply(conf)
+	}
+
+	if skipVerify {
+		if conf == nil {
+			conf = &tls.Config{}
+		}
+		conf.InsecureSkipVerify = true
+	}
+
+	var transports clientTransports
+	// scores each request actually sent, so hedges are scored individually while held back requests are not scored
+	transports.health = httpclient.NewHealthTransport(newClientTransport(proxy, conf), httpclient.HealthConfig{
+		ScoreCB: metrics.SetEndpointHealthScore,
+	})
+	c.Transport = transports.health
+
+	if viper.GetInt("backend_hedge_delay_ms") > 0 {
+		// only authorizations are hedged, which the adapter makes solely via the backend cache
+		if viper.GetBool("use_cached_backend") {
+			c.Transport = httpclient.NewHedgingTransport(c.Transport, parseHedgingConfig())
+		} else {
+			log.Errorf("hedging requests to backend requires USE_CACHED_BACKEND - ignoring BACKEND_HEDGE_DELAY_MS")
+		}
+	}
+
+	if !viper.IsSet("system_flap_threshold") || viper.GetInt("system_flap_threshold") > 0 {
+		transports.flap = httpclient.NewFlapTransport(c.Transport, parseFlapConfig())
+		c.Transport = transports.flap
+	}
+
+	if !viper.IsSet("system_negative_cache_seconds") || viper.GetInt("system_negative_cache_seconds") > 0 {
+		c.Transport = httpclient.NewNegativeCacheTransport(c.Transport, parseNegativeCacheConfig())
+	}
+
+	c.Transport = httpclient.NewBackoffTransport(c.Transport, parseBackoffConfig())
+
+	if extensions != nil {
+		transports.extensions = extensions.Wrap(c.Transport)
 	} else {
-		addr = defaultListenAddr
+		transports.extensions = httpclient.NewExtensionsTransport(c.Transport)
 	}
+	c.Transport = transports.extensions
 
-	grpcKeepAliveFor := time.Minute
-	if viper.IsSet("grpc_conn_max_seconds") {
-		grpcKeepAliveFor = time.Second * time.Duration(viper.GetInt("grpc_conn_max_seconds"))
+	if viper.GetInt("system_budget_ms") > 0 || viper.GetInt("backend_budget_ms") > 0 {
+		// outermost, so that the budget of a stage covers any retries and hedges
+		c.Transport = httpclient.NewBudgetTransport(c.Transport, parseBudgetConfig())
 	}
 
-	authorizer := authorizer.NewManager(
-		parseClientConfig(),
-		createSystemCache(),
-		createBackendConfig(),
-		parseMetricsConfig(),
-	)
+	return c, transports
+}
 
-	adapterConf := &threescale.AdapterConfig{
-		Authorizer:      authorizer,
-		KeepAliveMaxAge: grpcKeepAliveFor,
+// newClientTransport returns a transport matching http.DefaultTransport, apart from the choice of proxy and TLS config
+func newClientTransport(proxy func(*http.Request) (*url.URL, error), conf *tls.Config) *http.Transport {
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       conf,
 	}
+}
 
-	s, err := threescale.NewThreescale(addr, adapterConf)
-	if err != nil {
-		log.Fatalf("Unable to start sever: %v", err)
+func parseBudgetConfig() httpclient.BudgetConfig {
+	conf := httpclient.BudgetConfig{
+		System:     time.Duration(viper.GetInt("system_budget_ms")) * time.Millisecond,
+		Backend:    time.Duration(viper.GetInt("backend_budget_ms")) * time.Millisecond,
+		ExceededCB: metrics.IncrementBudgetExceeded,
 	}
 
-	shutdown := make(chan error, 1)
-	go func() {
-		if version == "" {
-			version = "undefined"
-		}
-		log.Infof("Starting server version %s", version)
-		s.Run(shutdown)
-	}()
+	log.Infof("latency budget for requests to system set to %s and to backend set to %s", conf.System, conf.Backend)
+	return conf
+}
 
-	sigC := make(chan os.Signal, 1)
-	signal.Notify(sigC, syscall.SIGTERM, syscall.SIGINT)
+func parseBackoffConfig() httpclient.BackoffConfig {
+	conf := httpclient.BackoffConfig{
+		DefaultBackoff: httpclient.DefaultBackoff,
+		MaxBackoff:     httpclient.DefaultMaxBackoff,
+		ThrottledCB:    metrics.SetSystemThrottled,
+	}
+
+	if viper.IsSet("system_backoff_seconds") {
+		conf.DefaultBackoff = time.Duration(viper.GetInt("system_backoff_seconds")) * time.Second
+	}
+
+	if viper.IsSet("system_backoff_max_seconds") {
+		conf.MaxBackoff = time.Duration(viper.GetInt("system_backoff_max_seconds")) * time.Second
+	}
+
+	return conf
+}
+
+func parseFlapConfig() httpclient.FlapConfig {
+	return httpclient.FlapConfig{
+		Threshold:  viper.GetInt("system_flap_threshold"),
+		Backoff:    time.Duration(viper.GetInt("system_flap_backoff_seconds")) * time.Second,
+		MaxBackoff: time.Duration(viper.GetInt("system_flap_backoff_max_seconds")) * time.Second,
+		DegradedCB: func(host string, serviceID string, degraded bool) {
+			if degraded {
+				log.Warnf("proxy config fetches for service %s from %s are flapping, holding back further fetches", serviceID, host)
+			} else {
+				log.Infof("proxy config fetches for service %s from %s have recovered", serviceID, host)
+			}
+			metrics.SetSystemServiceDegraded(host, serviceID, degraded)
+		},
+	}
+}
+
+func parseNegativeCacheConfig() httpclient.NegativeCacheConfig {
+	return httpclient.NegativeCacheConfig{
+		TTL: time.Duration(viper.GetInt("system_negative_cache_seconds")) * time.Second,
+		CachedCB: func(host string, serviceID string) {
+			log.Warnf("failed to fetch proxy config for service %s from %s, failing further fetches fast", serviceID, host)
+		},
+	}
+}
+
+func parseHedgingConfig() httpclient.HedgingConfig {
+	conf := httpclient.HedgingConfig{
+		Delay:    time.Duration(viper.GetInt("backend_hedge_delay_ms")) * time.Millisecond,
+		MaxRatio: httpclient.DefaultHedgeMaxRatio,
+		HedgedCB: metrics.IncrementBackendHedged,
+	}
+
+	if viper.IsSet("backend_hedge_max_ratio") {
+		conf.MaxRatio = viper.GetFloat64("backend_hedge_max_ratio")
+	}
+
+	log.Infof("hedging requests to backend after %s, for at most %.2f of requests", conf.Delay, conf.MaxRatio)
+	return conf
+}
+
+// newUncachedSystemCache returns a system cache which does not hold any entries
+func newUncachedSystemCache() *authorizer.SystemCache {
+	return authorizer.NewSystemCache(authorizer.SystemCacheConfig{MaxSize: 0}, make(chan struct{}))
+}
+
+func systemCacheTTL() time.Duration {
+	cacheTTL := defaultSystemCacheTTLSeconds
+	if viper.IsSet("cache_ttl_seconds") {
+		cacheTTL = viper.GetInt("cache_ttl_seconds")
+	}
+	return time.Duration(cacheTTL) * time.Second
+}
+
+func createSystemCache() *authorizer.SystemCache {
+	return authorizer.NewSystemCache(systemCacheConfig(), make(chan struct{}))
+}
+
+func systemCacheConfig() authorizer.SystemCacheConfig {
+	cacheEntriesMax := defaultSystemCacheSize
+	cacheUpdateRetries := defaultSystemCacheRetries
+	cacheRefreshInterval := defaultSystemCacheRefreshIntervalSeconds
+
+	if viper.IsSet("cache_refresh_seconds") {
+		cacheRefreshInterval = viper.GetInt("cache_refresh_seconds")
+	}
+
+	if viper.IsSet("cache_entries_max") {
+		cacheEntriesMax = viper.GetInt("cache_entries_max")
+	}
+
+	if viper.IsSet("cache_refresh_retries") {
+		cacheUpdateRetries = viper.GetInt("cache_refresh_retries")
+	}
+
+	return authorizer.SystemCacheConfig{
+		MaxSize:               cacheEntriesMax,
+		NumRetryFailedRefresh: cacheUpdateRetries,
+		RefreshInterval:       time.Duration(cacheRefreshInterval) * time.Second,
+		TTL:                   systemCacheTTL(),
+	}
+}
+
+// createAuthorizer returns the Authorizer used by the adapter, along with the options which can be tuned at runtime
+// and the snapshots of the config cache, which are nil unless enabled.
+// When sharding is enabled, only the services owned by this replica are cached, and any other service is fetched from
+// 3scale system on demand. Compressed configs are snapshotted in the background until stop is closed, and refreshed by
+// a task added to singletons, so that only the leader refreshes them when leader election is enabled.
+func createAuthorizer(httpClient *http.Client, metricsReporter *authorizer.MetricsReporter, singletons *singletonTasks, stop <-chan struct{}) (threescale.Authorizer, *threescale.Tuning, *configSnapshots) {
+	var manager threescale.Authorizer
+	var tuning *threescale.Tuning
+	var snapshots *configSnapshots
+	if viper.GetBool("cache_compression") {
+		log.Infof("caching proxy configurations compressed")
+		cache := threescale.NewCompressedConfigCache(
+			withSharedCache(authorizer.NewManager(httpClient, newUncachedSystemCache(), createBackendConfig(), metricsReporter)),
+			systemCacheTTL(),
+			systemCacheConfig().MaxSize,
+		)
+		if refresh := systemCacheConfig().RefreshInterval; refresh > 0 && refresh < systemCacheTTL() {
+			log.Infof("refreshing compressed proxy configurations in use every %s", refresh)
+			singletons.add(func(stop <-chan struct{}) {
+				cache.RunRefresh(refresh, stop)
+			})
+		}
+		if metricsReporter != nil {
+			cache.SetMetricsReporter(&threescale.MetricsReporter{
+				ConfigCacheHitCB:     metrics.ObserveSystemCacheHit,
+				ConfigCacheMissCB:    metrics.IncrementSystemCacheMisses,
+				ConfigFetchFailedCB:  metrics.IncrementSystemCacheFetchFailures,
+				ConfigEvictedCB:      metrics.IncrementSystemCacheEvictions,
+				ConfigCacheEntriesCB: metrics.SetSystemCacheEntries,
+			})
+		}
+		if grace := viper.GetInt("cache_stale_if_error_seconds"); grace > 0 {
+			log.Infof("serving expired proxy configurations for up to %ds when they cannot be refreshed", grace)
+			cache.ServeStaleIfError(time.Duration(grace)*time.Second, metrics.ObserveStaleConfig)
+		} else if grace < 0 {
+			log.Fatalf("CACHE_STALE_IF_ERROR_SECONDS must not be negative")
+		}
+		snapshots = createConfigSnapshots(cache, stop)
+		manager, tuning = cache, threescale.NewTuning(cache)
+	} else {
+		if viper.IsSet("shared_cache_redis_addr") {
+			log.Errorf("sharing proxy configurations via redis requires CACHE_COMPRESSION - ignoring SHARED_CACHE_REDIS_ADDR")
+		}
+		if viper.IsSet("config_snapshot_file") {
+			log.Errorf("snapshotting proxy configurations requires CACHE_COMPRESSION - ignoring CONFIG_SNAPSHOT_FILE")
+		}
+		if viper.IsSet("cache_stale_if_error_seconds") {
+			log.Errorf("serving expired proxy configurations requires CACHE_COMPRESSION - ignoring CACHE_STALE_IF_ERROR_SECONDS")
+		}
+		// the system cache of the authorizer cannot be reconfigured once created
+		manager = authorizer.NewManager(httpClient, createSystemCache(), createBackendConfig(), metricsReporter)
+		tuning = threescale.NewTuning(nil)
+	}
+
+	shardCount := viper.GetInt("shard_count")
+	if shardCount <= 1 {
+		return manager, tuning, snapshots
+	}
+
+	shardIndex, err := parseShardIndex()
+	if err != nil {
+		log.Fatalf("unable to determine shard index - %v", err)
+	}
+
+	sharded, err := threescale.NewShardedAuthorizer(manager, createUncachedAuthorizer(httpClient, metricsReporter), shardIndex, shardCount)
+	if err != nil {
+		log.Fatalf("invalid sharding configuration - %v", err)
+	}
+
+	log.Infof("caching services for shard %d of %d", shardIndex, shardCount)
+	return sharded, tuning, snapshots
+}
+
+// configSnapshots saves the compressed config cache to a file, so that requests can be authorized after a restart
+// while 3scale system cannot be reached
+type configSnapshots struct {
+	cache  *threescale.CompressedConfigCache
+	path   string
+	maxAge time.Duration
+}
+
+// createConfigSnapshots returns nil unless a snapshot file has been configured. Otherwise the configs saved in the file
+// are restored, unless older than CONFIG_SNAPSHOT_MAX_AGE_SECONDS, and the cache is saved to it every
+// CONFIG_SNAPSHOT_SECONDS until stop is closed.
+func createConfigSnapshots(cache *threescale.CompressedConfigCache, stop <-chan struct{}) *configSnapshots {
+	path := viper.GetString("config_snapshot_file")
+	if path == "" {
+		return nil
+	}
+
+	interval := defaultConfigSnapshotSeconds
+	if viper.IsSet("config_snapshot_seconds") {
+		interval = viper.GetInt("config_snapshot_seconds")
+	}
+
+	if interval <= 0 {
+		log.Fatalf("CONFIG_SNAPSHOT_SECONDS must be positive")
+	}
+
+	maxAge := defaultConfigSnapshotMaxAgeSeconds
+	if viper.IsSet("config_snapshot_max_age_seconds") {
+		maxAge = viper.GetInt("config_snapshot_max_age_seconds")
+	}
+
+	if maxAge <= 0 {
+		log.Fatalf("CONFIG_SNAPSHOT_MAX_AGE_SECONDS must be positive")
+	}
+
+	snapshots := &configSnapshots{cache: cache, path: path, maxAge: time.Duration(maxAge) * time.Second}
+	snapshots.restore()
+	go snapshots.run(time.Duration(interval)*time.Second, stop)
+	log.Infof("snapshotting proxy configurations to %s every %d seconds", path, interval)
+	return snapshots
+}
+
+// restore caches the configs saved to the snapshot file. A missing file is expected on the first start.
+func (s *configSnapshots) restore() {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("unable to read proxy configuration snapshot - %v", err)
+		}
+		return
+	}
+	defer f.Close()
+
+	restored, savedAt, err := s.cache.RestoreSnapshot(f, s.maxAge)
+	if err != nil {
+		log.Warnf("unable to restore proxy configurations from %s - %v", s.path, err)
+		return
+	}
+	log.Infof("restored %d proxy configurations saved at %s", restored, savedAt.Format(time.RFC3339))
+}
+
+// run saves the snapshot every interval until stop is closed
+func (s *configSnapshots) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.save()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// save writes the cached configs to the snapshot file, readable only by the adapter, replacing it only once fully
+// written. Nothing is saved if s is nil.
+func (s *configSnapshots) save() {
+	if s == nil {
+		return
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		log.Warnf("unable to save proxy configuration snapshot - %v", err)
+		return
+	}
+
+	// the mode is only applied on creation, so a file left behind with another mode is restricted too
+	err = f.Chmod(0600)
+	if err == nil {
+		err = s.cache.SaveSnapshot(f, time.Now())
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+
+	if err != nil {
+		log.Warnf("unable to save proxy configuration snapshot to %s - %v", s.path, err)
+		return
+	}
+	log.Debugf("saved proxy configuration snapshot to %s", s.path)
+}
+
+// createUncachedAuthorizer returns an Authorizer which neither caches proxy configurations nor authorizations, so that
+// every request is fetched from or authorized by 3scale
+func createUncachedAuthorizer(httpClient *http.Client, metricsReporter *authorizer.MetricsReporter) threescale.Authorizer {
+	return authorizer.NewManager(
+		httpClient,
+		newUncachedSystemCache(),
+		authorizer.BackendConfig{Logger: log.FindScope(log.DefaultScopeName)},
+		metricsReporter,
+	)
+}
+
+// withSharedCache shares the proxy configurations fetched by next with other replicas via redis, if configured
+func withSharedCache(next threescale.Authorizer) threescale.Authorizer {
+	addr := viper.GetString("shared_cache_redis_addr")
+	if addr == "" {
+		return next
+	}
+
+	client := redis.NewClient(redis.Config{
+		Addr:     addr,
+		Password: viper.GetString("shared_cache_redis_password"),
+		DB:       viper.GetInt("shared_cache_redis_db"),
+	})
+
+	if err := client.Ping(); err != nil {
+		log.Warnf("unable to reach redis at %s, proxy configurations will be fetched from 3scale system until it is - %v", addr, err)
+	}
+
+	log.Infof("sharing proxy configurations via redis at %s", addr)
+	return threescale.NewSharedConfigCache(next, client, systemCacheTTL())
+}
+
+// parseShardIndex reads the shard index from the environment, falling back to the ordinal suffix
+// of the pod name, as assigned to each replica of a StatefulSet
+func parseShardIndex() (int, error) {
+	if viper.IsSet("shard_index") {
+		return viper.GetInt("shard_index"), nil
+	}
+
+	name := podName()
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return 0, fmt.Errorf("no ordinal found in pod name %q", name)
+	}
+	return strconv.Atoi(name[i+1:])
+}
+
+func createBackendConfig() authorizer.BackendConfig {
+	logger := log.FindScope(log.DefaultScopeName)
+
+	if viper.GetBool("use_cached_backend") {
+		interval := backendCacheFlushInterval()
+		log.Infof("backend cache set to flush at %s intervals", interval.String())
+
+		return authorizer.BackendConfig{
+			EnableCaching:      true,
+			CacheFlushInterval: interval,
+			Logger:             logger,
+			Policy:             getFailurePolicy(),
+		}
+	}
+
+	return authorizer.BackendConfig{
+		Logger: logger,
+	}
+}
+
+func backendCacheFlushInterval() time.Duration {
+	interval := time.Second * time.Duration(viper.GetInt("backend_cache_flush_interval_seconds"))
+	if interval == 0 {
+		interval = defaultBackendCacheFlushInterval
+	}
+	return interval
+}
+
+// createPlanRestrictions returns nil unless plan based method and size restrictions have been enabled
+func createPlanRestrictions(httpClient *http.Client) threescale.PlanRestrictions {
+	if !viper.GetBool("plan_restrictions_enabled") {
+		return nil
+	}
+
+	ttl := systemCacheTTL()
+
+	attribute := viper.GetString("plan_methods_attribute")
+	sizeAttribute := viper.GetString("plan_size_attribute")
+	log.Infof("enforcing HTTP methods and request sizes permitted by application plans, ttl set to %s", ttl.String())
+
+	return threescale.NewPlanMethodRestrictions(httpClient, attribute, sizeAttribute, ttl)
+}
+
+// createUpgradeHints returns nil unless pointing API consumers who exceed their limits to an upgrade URL has been enabled
+func createUpgradeHints(httpClient *http.Client) threescale.UpgradeHints {
+	if !viper.GetBool("upgrade_hints_enabled") {
+		return nil
+	}
+
+	ttl := systemCacheTTL()
+	log.Infof("adding upgrade urls from service annotations to limits exceeded denials, ttl set to %s", ttl.String())
+	return threescale.NewServiceAnnotationUpgradeHints(httpClient, viper.GetString("upgrade_url_annotation"), ttl)
+}
+
+// createCredentialsValidator returns nil unless validation of service tokens has been enabled
+func createCredentialsValidator(httpClient *http.Client) threescale.CredentialsValidator {
+	if !viper.GetBool("validate_service_tokens") {
+		return nil
+	}
+
+	log.Infof("validating the service token of each service when first used by a handler")
+	return func(backendURL, serviceID, authType, authValue string) error {
+		return selftest.ValidateBackendCredentials(httpClient, backendURL, serviceID, authType, authValue)
+	}
+}
+
+// createPreconnector returns nil unless preconnecting to the 3scale endpoints named by each handler has been enabled
+func createPreconnector(httpClient *http.Client) threescale.Preconnector {
+	if !viper.GetBool("preconnect_enabled") {
+		return nil
+	}
+
+	log.Infof("preconnecting to the 3scale endpoints of each handler when first used")
+	p := httpclient.NewPreconnector(httpClient)
+	p.ErrorCB = func(host string, err error) {
+		log.Debugf("unable to preconnect to %s - %v", host, err)
+	}
+	return p.Preconnect
+}
+
+// createHandlerAuthorizerBuilder builds the Authorizer of handlers which send their requests to 3scale through their own
+// proxies or skip TLS verification. Each has a client of its own, so that the settings of a handler never apply to the
+// requests of other handlers using the same 3scale endpoints, along with its own proxy config and backend caches.
+func createHandlerAuthorizerBuilder(extensions *httpclient.ExtensionsTransport, metricsReporter *authorizer.MetricsReporter) threescale.HandlerAuthorizerBuilder {
+	return func(client threescale.HandlerClient) (threescale.Authorizer, error) {
+		proxy, err := httpclient.NewHandlerProxy(client.SystemURL, client.SystemProxyURL, client.BackendProxyURL)
+		if err != nil {
+			return nil, err
+		}
+
+		if client.InsecureSkipVerify {
+			log.Warnf("INSECURE: TLS certificates are not verified for handlers of %s which set insecure_skip_verify - "+
+				"requests and credentials they send can be intercepted, which is only acceptable in lab environments", client.SystemURL)
+		}
+
+		httpClient, _ := newClient(proxy.Proxy, client.InsecureSkipVerify, extensions)
+		log.Infof("created a client for handlers of %s which set their own proxies or TLS verification", client.SystemURL)
+		return authorizer.NewManager(httpClient, createSystemCache(), createBackendConfig(), metricsReporter), nil
+	}
+}
+
+// createExtensionsRouter requests the 3scale backend extensions enabled by the handler of each service
+func createExtensionsRouter(extensions *httpclient.ExtensionsTransport) threescale.ExtensionsRouter {
+	return func(endpoint string, serviceID string, options string) {
+		if err := extensions.SetOptions(endpoint, serviceID, options); err != nil {
+			log.Errorf("unable to enable backend extensions for service %s - %v", serviceID, err)
+		}
+	}
+}
+
+// createStartupGrace returns nil unless a startup fail open window has been set. The window ends early once a proxy
+// config has been fetched unless that has been disabled.
+func createStartupGrace() *threescale.StartupGrace {
+	seconds := viper.GetInt("startup_fail_open_seconds")
+	if seconds <= 0 {
+		return nil
+	}
+
+	grace := &threescale.StartupGrace{
+		Window:    time.Duration(seconds) * time.Second,
+		UntilWarm: !viper.IsSet("startup_fail_open_until_warm") || viper.GetBool("startup_fail_open_until_warm"),
+	}
+	log.Infof("failing open for up to %v after starting", grace.Window)
+	return grace
+}
+
+// createErrorBudget returns nil unless an error budget threshold has been set
+func createErrorBudget(events *kubernetes.EventRecorder) *threescale.ErrorBudget {
+	threshold := viper.GetFloat64("error_budget_threshold")
+	if threshold <= 0 {
+		return nil
+	}
+
+	conf := threescale.ErrorBudgetConfig{
+		Threshold:   threshold,
+		Window:      time.Duration(viper.GetInt("error_budget_window_seconds")) * time.Second,
+		MinRequests: viper.GetInt("error_budget_min_requests"),
+		FailOpenCB: func(serviceID string, failingOpen bool) {
+			metrics.SetServiceFailOpen(serviceID, failingOpen)
+			if failingOpen {
+				events.Warning("FailingOpen", "service %s exceeded its error budget reaching 3scale backend - requests are allowed", serviceID)
+			}
+		},
+	}
+
+	log.Infof("failing open services whose error rate reaching 3scale backend exceeds %v", threshold)
+	return threescale.NewErrorBudget(conf)
+}
+
+// createPriorities returns nil unless services have been assigned priority classes, in the form
+// <service id>:<class> separated by commas, and a concurrency limit is set for them to apply to
+func createPriorities() *threescale.PriorityConfig {
+	assignments := viper.GetString("service_priorities")
+	if assignments == "" {
+		return nil
+	}
+
+	if viper.GetInt("max_concurrent_requests") <= 0 {
+		log.Warnf("service priorities require MAX_CONCURRENT_REQUESTS to be set - priorities ignored")
+		return nil
+	}
+
+	conf := &threescale.PriorityConfig{
+		Services: make(map[string]threescale.PriorityClass),
+		Shares:   make(map[threescale.PriorityClass]float64),
+	}
+
+	for _, assignment := range strings.Split(assignments, ",") {
+		parts := strings.SplitN(strings.TrimSpace(assignment), ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			log.Errorf("invalid service priority %q - expected <service id>:<class>", assignment)
+			continue
+		}
+
+		class, err := threescale.ParsePriorityClass(strings.TrimSpace(parts[1]))
+		if err != nil {
+			log.Errorf("invalid service priority for service %s - %v", parts[0], err)
+			continue
+		}
+		conf.Services[parts[0]] = class
+	}
+
+	if viper.IsSet("priority_normal_share") {
+		conf.Shares[threescale.PriorityNormal] = viper.GetFloat64("priority_normal_share")
+	}
+
+	if viper.IsSet("priority_low_share") {
+		conf.Shares[threescale.PriorityLow] = viper.GetFloat64("priority_low_share")
+	}
+	return conf
+}
+
+// createConfigStrategies returns nil unless priority classes have been assigned a config strategy, in the form
+// <class>:<strategy> separated by commas
+func createConfigStrategies() map[threescale.PriorityClass]threescale.ConfigStrategy {
+	assignments := viper.GetString("config_strategies")
+	if assignments == "" {
+		return nil
+	}
+
+	if !viper.GetBool("cache_compression") || viper.GetInt("shard_count") > 1 {
+		log.Warnf("config strategies require CACHE_COMPRESSION to be set without sharding - strategies ignored")
+		return nil
+	}
+
+	strategies := make(map[threescale.PriorityClass]threescale.ConfigStrategy)
+	for _, assignment := range strings.Split(assignments, ",") {
+		parts := strings.SplitN(strings.TrimSpace(assignment), ":", 2)
+		if len(parts) != 2 {
+			log.Errorf("invalid config strategy %q - expected <class>:<strategy>", assignment)
+			continue
+		}
+
+		class, err := threescale.ParsePriorityClass(strings.TrimSpace(parts[0]))
+		if err != nil {
+			log.Errorf("invalid config strategy %q - %v", assignment, err)
+			continue
+		}
+
+		strategy, err := threescale.ParseConfigStrategy(strings.TrimSpace(parts[1]))
+		if err != nil {
+			log.Errorf("invalid config strategy for class %s - %v", class, err)
+			continue
+		}
+		strategies[class] = strategy
+	}
+	return strategies
+}
+
+// createEventRecorder returns nil unless Kubernetes Events have been enabled and the adapter's Pod can be found
+func createEventRecorder() *kubernetes.EventRecorder {
+	if !viper.GetBool("kubernetes_events_enabled") {
+		return nil
+	}
+
+	namespace, err := podNamespace()
+	if err != nil {
+		log.Errorf("unable to determine namespace for kubernetes events - %v - events disabled", err)
+		return nil
+	}
+
+	client, err := kubernetes.NewK8Client("", nil)
+	if err != nil {
+		log.Errorf("unable to create kubernetes client for events - %v - events disabled", err)
+		return nil
+	}
+
+	recorder, err := client.NewPodEventRecorder(namespace, podName())
+	if err != nil {
+		log.Errorf("unable to create kubernetes event recorder - %v - events disabled", err)
+		return nil
+	}
+
+	log.Infof("emitting kubernetes events for critical conditions")
+	return recorder
+}
+
+// createMetricsReporter returns the reporter for metrics recorded by the adapter.
+// Per application metrics are only reported where a salt has been provided to hash credentials with.
+func createMetricsReporter(events *kubernetes.EventRecorder) *threescale.MetricsReporter {
+	reporter := &threescale.MetricsReporter{
+		DeprecatedConfigCB:   metrics.IncrementDeprecatedConfig,
+		APIVersionCB:         metrics.IncrementAPIVersionRequests,
+		ConcurrencyLimitedCB: metrics.IncrementConcurrencyLimited,
+		OnboardingCB:         metrics.IncrementOnboardingWouldDeny,
+		FailOpenCB:           metrics.IncrementFailOpen,
+		ConfigUnmarshalCB:    metrics.IncrementConfigUnmarshalFailures,
+		DeadlineCB:           metrics.ObserveDeadline,
+		BackendErrorCB:       metrics.IncrementBackendErrors,
+		DecisionCB:           metrics.IncrementAuthorizations,
+		BackendResponseCB:    metrics.IncrementBackendResponses,
+		InvalidCredentialsCB: func(serviceID string) {
+			metrics.IncrementInvalidServiceToken(serviceID)
+			events.Warning("InvalidCredentials", "invalid service token for service %s - requests will be denied by 3scale backend", serviceID)
+		},
+	}
+
+	if !viper.GetBool("application_metrics_enabled") {
+		return reporter
+	}
+
+	salt := viper.GetString("application_metrics_salt")
+	if salt == "" {
+		log.Errorf("application metrics require a salt to hash credentials with - application metrics disabled")
+		return reporter
+	}
+
+	log.Infof("reporting metrics per application, identified by a salted hash of its credentials")
+	reporter.ApplicationCB = metrics.IncrementApplicationRequests
+	reporter.ApplicationSalt = salt
+	return reporter
+}
+
+// createUsageRecorder returns nil unless the usage report has been enabled. Applications are identified by the same
+// salted hash of their credentials as application metrics, so the two can be correlated.
+func createUsageRecorder() *threescale.UsageRecorder {
+	if !viper.GetBool("usage_report_enabled") {
+		return nil
+	}
+
+	if !serveDiagnostics() {
+		log.Errorf("usage report requires ADMIN_PORT with ADMIN_AUTH_TOKEN or ADMIN_TLS_CLIENT_CA_FILE - ignoring USAGE_REPORT_ENABLED")
+		return nil
+	}
+
+	log.Infof("recording usage by service and application for the usage report")
+	return threescale.NewUsageRecorder(viper.GetString("application_metrics_salt"))
+}
+
+// runUsageCommand prints the usage summary served by a running adapter and returns the exit code. By default it reads
+// from the admin endpoints of the adapter it runs alongside, as configured by the environment, so that it can be run
+// with kubectl exec.
+func runUsageCommand(args []string) int {
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	adminURL := fs.String("admin-url", defaultAdminURL(), "base URL of the admin endpoints of the adapter")
+	minutes := fs.Int("minutes", int(threescale.DefaultUsageWindow/time.Minute), "number of minutes to summarise, up to 60")
+	token := fs.String("token", viper.GetString("admin_auth_token"), "bearer token for the admin endpoints")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s?minutes=%d", strings.TrimSuffix(*adminURL, "/"), defaultUsageEndpoint, *minutes), nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid admin url - %v\n", err)
+		return 1
+	}
+
+	if *token != "" {
+		req.Header.Set("Authorization", "Bearer "+*token)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to fetch usage - %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		fmt.Fprintln(os.Stderr, "usage report is not enabled on the adapter - set USAGE_REPORT_ENABLED")
+		return 1
+	default:
+		body, _ := ioutil.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "unable to fetch usage - %s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+
+	var report threescale.UsageReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to decode usage - %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("requests over the last %d minutes\n\n", report.WindowMinutes)
+	if err := threescale.WriteUsageTable(os.Stdout, report); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to print usage - %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// defaultAdminURL returns the URL the admin endpoints are served on locally, which is the metrics listener unless a
+// dedicated admin port has been set
+func defaultAdminURL() string {
+	if !viper.IsSet("admin_port") {
+		return fmt.Sprintf("http://localhost:%d", metricsPort())
+	}
+
+	scheme := "http"
+	if viper.GetString("admin_tls_cert_file") != "" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://localhost:%d", scheme, viper.GetInt("admin_port"))
+}
+
+// createSelfTest returns nil unless the self-test has been configured with the credentials to probe with
+func createSelfTest(httpClient *http.Client, events *kubernetes.EventRecorder) *selftest.Prober {
+	if !viper.IsSet("self_test_interval_seconds") || viper.GetInt("self_test_interval_seconds") <= 0 {
+		return nil
+	}
+
+	conf := selftest.Config{
+		SystemURL:   viper.GetString("self_test_system_url"),
+		AccessToken: viper.GetString("self_test_access_token"),
+		ServiceID:   viper.GetString("self_test_service_id"),
+		Interval:    time.Duration(viper.GetInt("self_test_interval_seconds")) * time.Second,
+		ResultCB:    selfTestResultCB(events),
+	}
+
+	if conf.SystemURL == "" || conf.AccessToken == "" || conf.ServiceID == "" {
+		log.Errorf("self-test requires a system url, access token and service id - self-test disabled")
+		return nil
+	}
+
+	log.Infof("self-test against %s set to run at %s intervals", conf.SystemURL, conf.Interval.String())
+	return selftest.NewProber(httpClient, conf)
+}
+
+// selfTestResultCB records the outcome of each probe and emits an event when a target becomes unreachable
+func selfTestResultCB(events *kubernetes.EventRecorder) func(target selftest.Target, err error) {
+	failing := make(map[selftest.Target]bool)
+
+	return func(target selftest.Target, err error) {
+		metrics.SetSelfTestResult(target, err)
+
+		if err != nil && !failing[target] {
+			reason := "SystemUnreachable"
+			if target == selftest.Backend {
+				reason = "BackendUnreachable"
+			}
+			events.Warning(reason, "self-test against 3scale %s failed - %v", target, err)
+		}
+		failing[target] = err != nil
+	}
+}
+
+// podNamespace returns the namespace the adapter is running in
+func podNamespace() (string, error) {
+	ns, err := ioutil.ReadFile(serviceAccountNamespace)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(ns)), nil
+}
+
+// podName returns the name of the Pod the adapter is running in, which defaults to the hostname
+func podName() string {
+	if name := viper.GetString("pod_name"); name != "" {
+		return name
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+// singletonTasks are the background tasks which should only be performed by one replica at a time
+type singletonTasks []func(stop <-chan struct{})
+
+func (t *singletonTasks) add(task func(stop <-chan struct{})) {
+	*t = append(*t, task)
+}
+
+// run starts each task, which runs until stop is closed
+func (t singletonTasks) run(stop <-chan struct{}) {
+	for _, task := range t {
+		go task(stop)
+	}
+}
+
+// runSingletonTasks runs the background tasks which should only be performed by one replica at a time.
+// When leader election is enabled, the tasks are started whenever this replica becomes the leader and stopped if the
+// lease is lost, and changes of leadership are recorded with events where enabled. Otherwise the tasks run until stop
+// is closed.
+func runSingletonTasks(tasks singletonTasks, events *kubernetes.EventRecorder, stop <-chan struct{}) {
+	if len(tasks) == 0 {
+		return
+	}
+
+	if !viper.GetBool("leader_election_enabled") {
+		tasks.run(stop)
+		return
+	}
+
+	conf := kubernetes.LeaderElectionConfig{
+		Namespace: viper.GetString("leader_election_namespace"),
+		Name:      viper.GetString("leader_election_name"),
+		Identity:  podName(),
+		Events:    events,
+	}
+
+	if conf.Namespace == "" {
+		ns, err := podNamespace()
+		if err != nil {
+			log.Fatalf("unable to determine namespace for leader election - %v", err)
+		}
+		conf.Namespace = ns
+	}
+
+	if conf.Name == "" {
+		conf.Name = defaultLeaderElectionName
+	}
+
+	client, err := kubernetes.NewK8Client("", nil)
+	if err != nil {
+		log.Fatalf("unable to create kubernetes client for leader election - %v", err)
+	}
+
+	go func() {
+		err := client.RunLeaderElection(conf, func(leaderStop <-chan struct{}) {
+			log.Infof("%s elected leader, starting singleton background tasks", conf.Identity)
+			tasks.run(leaderStop)
+		})
+		if err != nil {
+			log.Fatalf("leader election failed - %v", err)
+		}
+	}()
+}
+
+// runRuntimeConfigController applies the options held in the AdapterRuntimeConfig resource whenever it changes,
+// until stop is closed. Every replica runs the controller, since each applies the options to itself.
+func runRuntimeConfigController(sampler *logging.Sampler, stop <-chan struct{}) {
+	if !viper.GetBool("runtime_config_enabled") {
+		return
+	}
+
+	conf := kubernetes.RuntimeConfigControllerConfig{
+		Name:     viper.GetString("runtime_config_name"),
+		Interval: time.Duration(viper.GetInt("runtime_config_interval_seconds")) * time.Second,
+		ErrorCB: func(err error) {
+			log.Warnf("unable to read adapter runtime config - %v", err)
+		},
+	}
+
+	if conf.Name == "" {
+		conf.Name = defaultRuntimeConfigName
+	}
+
+	ns, err := podNamespace()
+	if err != nil {
+		log.Fatalf("unable to determine namespace for adapter runtime config - %v", err)
+	}
+	conf.Namespace = ns
+
+	k8, err := kubernetes.NewK8Client("", nil)
+	if err != nil {
+		log.Fatalf("unable to create kubernetes client for adapter runtime config - %v", err)
+	}
+
+	client, err := k8.NewRuntimeConfigClient()
+	if err != nil {
+		log.Fatalf("unable to create adapter runtime config client - %v", err)
+	}
+
+	log.Infof("applying adapter runtime config from %s/%s", conf.Namespace, conf.Name)
+	go func() {
+		err := client.RunController(conf, func(spec kubernetes.RuntimeConfigSpec) {
+			applyRuntimeConfig(sampler, spec)
+		}, stop)
+		if err != nil {
+			log.Fatalf("adapter runtime config controller failed - %v", err)
+		}
+	}()
+}
+
+// applyRuntimeConfig overlays the options set in the spec on those provided via the environment
+func applyRuntimeConfig(sampler *logging.Sampler, spec kubernetes.RuntimeConfigSpec) {
+	level := viper.GetString("log_level")
+	if spec.LogLevel != "" {
+		level = spec.LogLevel
+	}
+	log.FindScope(log.DefaultScopeName).SetOutputLevel(stringToLogLevel(level))
+
+	first, interval := logSamplingConfig()
+	if spec.LogSamplingFirst != nil {
+		first = *spec.LogSamplingFirst
+	}
+
+	if spec.LogSamplingIntervalSeconds != nil {
+		interval = time.Duration(*spec.LogSamplingIntervalSeconds) * time.Second
+	}
+	sampler.Configure(first, interval)
+
+	sampling := sampler.Config()
+	log.Infof("applied adapter runtime config - log level %s, log sampling first %d per %ds",
+		level, sampling.First, sampling.IntervalSeconds)
+}
+
+func getFailurePolicy() backend.FailurePolicy {
+	policy := backend.FailClosedPolicy
+
+	if viper.IsSet("backend_cache_policy_fail_closed") && !viper.GetBool("backend_cache_policy_fail_closed") {
+		policy = backend.FailOpenPolicy
+		log.Infof("backend cache fail policy set to open")
+	} else {
+		log.Infof("backend cache fail policy set to closed")
+	}
+
+	return policy
+}
+
+func main() {
+	if version == "" {
+		version = "undefined"
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "usage" {
+		os.Exit(runUsageCommand(os.Args[2:]))
+	}
+
+	checkSecretArgs()
+
+	var addr string
+
+	if viper.IsSet("listen_addr") {
+		addr = viper.GetString("listen_addr")
+	} else {
+		addr = defaultListenAddr
+	}
+
+	grpcKeepAliveFor := time.Minute
+	if viper.IsSet("grpc_conn_max_seconds") {
+		grpcKeepAliveFor = time.Second * time.Duration(viper.GetInt("grpc_conn_max_seconds"))
+	}
+
+	httpClient, transports := parseClientConfig()
+
+	metricsReporter := parseMetricsConfig()
+	stopBackground := make(chan struct{})
+	var singletons singletonTasks
+	authorizer, tuning, snapshots := createAuthorizer(httpClient, metricsReporter, &singletons, stopBackground)
+
+	events := createEventRecorder()
+	budget := createErrorBudget(events)
+
+	adminMux := http.NewServeMux()
+	sampler := createLogSampler(adminMux, stopBackground)
+	configHandler := createConfigEndpoint(adminMux, sampler, transports.flap, tuning, budget)
+	adminMux.Handle(defaultEndpointScores, guardDiagnostics(transports.health))
+	adminMux.Handle(defaultTuningEndpoint, guardChanges(tuning))
+	adminMux.Handle(defaultOpenAPIEndpoint, admin.NewOpenAPIHandler(version, viper.IsSet("admin_port") && viper.GetString("admin_auth_token") != ""))
+
+	prober := createSelfTest(httpClient, events)
+	var healthCheck func() error
+	if prober != nil {
+		adminMux.Handle(defaultHealthEndpoint, prober)
+		healthCheck = prober.Healthy
+	}
+
+	var decisionHooks []threescale.DecisionHook
+	if usage := createUsageRecorder(); usage != nil {
+		adminMux.Handle(defaultUsageEndpoint, guardDiagnostics(usage))
+		decisionHooks = append(decisionHooks, usage)
+	}
+
+	adapterConf := &threescale.AdapterConfig{
+		Authorizer:            authorizer,
+		UncachedAuthorizer:    createUncachedAuthorizer(httpClient, metricsReporter),
+		HandlerAuthorizers:    createHandlerAuthorizerBuilder(transports.extensions, metricsReporter),
+		KeepAliveMaxAge:       grpcKeepAliveFor,
+		TLS:                   createGRPCTLSConfig(),
+		StrictConfig:          viper.GetBool("strict_config"),
+		SessionBased:          viper.GetBool("session_based"),
+		MetricsReporter:       createMetricsReporter(events),
+		PlanRestrictions:      createPlanRestrictions(httpClient),
+		UpgradeHints:          createUpgradeHints(httpClient),
+		CredentialsValidator:  createCredentialsValidator(httpClient),
+		Preconnector:          createPreconnector(httpClient),
+		ExtensionsRouter:      createExtensionsRouter(transports.extensions),
+		RateLimit:             loadRateLimitConfig(),
+		LogSampler:            sampler,
+		MaxConcurrentRequests: viper.GetInt("max_concurrent_requests"),
+		Priorities:            createPriorities(),
+		ConfigStrategies:      createConfigStrategies(),
+		Tuning:                tuning,
+		ErrorBudget:           budget,
+		HealthCheck:           healthCheck,
+		StartupGrace:          createStartupGrace(),
+		DecisionHooks:         decisionHooks,
+		StartupHooks: []threescale.StartupHook{
+			func() error {
+				// every replica probes, so that its own health reflects whether it can reach 3scale
+				if prober != nil {
+					prober.Start(stopBackground)
+				}
+				runSingletonTasks(singletons, events, stopBackground)
+				runRuntimeConfigController(sampler, stopBackground)
+				return nil
+			},
+		},
+	}
+
+	s, err := threescale.NewThreescale(addr, adapterConf)
+	if err != nil {
+		log.Fatalf("Unable to start sever: %v", err)
+	}
+	adminMux.Handle(defaultReadyEndpoint, readyHandler(s))
+	configHandler.Add("config_versions", func() interface{} {
+		return s.ConfigVersions()
+	})
+
+	probeServer := serveProbes(adminMux, s, addr)
+	adminServer := serveAdmin(adminMux, addr)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		log.Infof("Starting server version %s", version)
+		s.Run(shutdown)
+	}()
+
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGTERM, syscall.SIGINT)
 
 	for {
 		select {
 		case sig := <-sigC:
 			log.Infof("\n%s received. Attempting graceful shutdown\n", sig.String())
-			authorizer.Shutdown()
+			// stop reading signals, since shutting down twice would close the background tasks twice
+			sigC = nil
+			// drain in-flight requests before shutting down the authorizer, so that no usage is
+			// cached by the backend after it has been stopped and is lost during rolling updates
 			err := s.Close()
 			if err != nil {
 				log.Fatalf("Error calling graceful shutdown")
 			}
+			authorizer.Shutdown()
+			saveMetrics()
+			snapshots.save()
+			close(stopBackground)
+			if adminServer != nil {
+				adminServer.Close()
+			}
+			if probeServer != nil {
+				probeServer.Close()
+			}
 
 		case err = <-shutdown:
 			if err != nil {
@@ -19,6 +19,8 @@ import fmt "fmt"
 import math "math"
 import _ "github.com/gogo/protobuf/gogoproto"
 
+import github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
+
 import strings "strings"
 import reflect "reflect"
 
@@ -45,6 +47,54 @@ type Params struct {
 	AccessToken string `protobuf:"bytes,3,opt,name=access_token,json=accessToken,proto3" json:"access_token,omitempty"`
 	// Url to connect to 3scale backend - optional - override system config to use internal dns etc..
 	BackendUrl string `protobuf:"bytes,4,opt,name=backend_url,json=backendUrl,proto3" json:"backend_url,omitempty"`
+	// Map of workload locality, in the form region or region/zone, to the preferred 3scale backend url - optional - the most specific match for the locality provided in the instance takes precedence over backend_url
+	BackendUrlByLocality map[string]string `protobuf:"bytes,5,rep,name=backend_url_by_locality,json=backendUrlByLocality" json:"backend_url_by_locality,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Regular expression matched against the request path to identify the API version, taken from the first capture group or else the whole match - optional - for example ^/(v[0-9]+)/
+	ApiVersionPattern string `protobuf:"bytes,6,opt,name=api_version_pattern,json=apiVersionPattern,proto3" json:"api_version_pattern,omitempty"`
+	// When true, usage is also reported against a 3scale metric named after each matched metric, suffixed with the API version, such as hits_v1 - optional - requires api_version_pattern and the suffixed metrics must be defined in 3scale
+	ApiVersionMetricSuffix bool `protobuf:"varint,7,opt,name=api_version_metric_suffix,json=apiVersionMetricSuffix,proto3" json:"api_version_metric_suffix,omitempty"`
+	// Type of credential used to authenticate against 3scale backend, one of service_token or provider_key - optional - overrides the type advertised in the proxy config, such as while migrating credentials before 3scale system has been updated - requires backend_auth_value
+	BackendAuthType string `protobuf:"bytes,8,opt,name=backend_auth_type,json=backendAuthType,proto3" json:"backend_auth_type,omitempty"`
+	// Credential used to authenticate against 3scale backend - optional - overrides the value advertised in the proxy config
+	BackendAuthValue string `protobuf:"bytes,9,opt,name=backend_auth_value,json=backendAuthValue,proto3" json:"backend_auth_value,omitempty"`
+	// Time until which the service is onboarding, in RFC 3339 format such as 2020-10-01T00:00:00Z - optional - until then, requests which would be denied by 3scale are logged and allowed, after which the adapter enforces automatically
+	OnboardingUntil string `protobuf:"bytes,10,opt,name=onboarding_until,json=onboardingUntil,proto3" json:"onboarding_until,omitempty"`
+	// Secret shared with a trusted gateway, used to verify the HMAC-SHA256 signature of the application identity it provides in the trusted_identity subject property - optional - when the signature is valid, the identity is used in place of the credentials presented by the client, which are not checked again
+	TrustedIdentitySecret string `protobuf:"bytes,11,opt,name=trusted_identity_secret,json=trustedIdentitySecret,proto3" json:"trusted_identity_secret,omitempty"`
+	// Maximum age, in seconds, of a signed trusted identity before it is rejected - optional - defaults to 300
+	TrustedIdentityMaxAgeSeconds int64 `protobuf:"varint,12,opt,name=trusted_identity_max_age_seconds,json=trustedIdentityMaxAgeSeconds,proto3" json:"trusted_identity_max_age_seconds,omitempty"`
+	// When true, requests which cannot be authorized because 3scale system or backend is unreachable or fails are allowed, logged and counted, rather than denied - optional - defaults to false
+	FailOpen bool `protobuf:"varint,13,opt,name=fail_open,json=failOpen,proto3" json:"fail_open,omitempty"`
+	// Map of request header, optionally preceded by an HTTP method and a space such as "POST content-type", to a regular expression its value must match - optional - an empty expression only requires the header to be present and non-empty - requests failing a check are denied with INVALID_ARGUMENT before 3scale is called, and headers are read from the request_headers action property of the instance
+	RequiredHeaders map[string]string `protobuf:"bytes,14,rep,name=required_headers,json=requiredHeaders" json:"required_headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// ID of a 3scale service which requests are authorized and reported against in place of service_id while the service is onboarding - optional - requires onboarding_until - lets plans be sized with real traffic without touching the analytics of the production service, and should hold the same applications, metrics and mapping rules
+	ShadowServiceId string `protobuf:"bytes,15,opt,name=shadow_service_id,json=shadowServiceId,proto3" json:"shadow_service_id,omitempty"`
+	// When true, requests to a service using OpenID Connect which carry no client_id are authorized by the user key of the subject instead - optional - defaults to false - supports moving a service from the API key pattern to OpenID Connect, and the user key is ignored whenever a client_id is present
+	OidcUserKeyFallback bool `protobuf:"varint,16,opt,name=oidc_user_key_fallback,json=oidcUserKeyFallback,proto3" json:"oidc_user_key_fallback,omitempty"`
+	// Maximum time an authorization waits on 3scale overall, as a duration such as 500ms or 2s - optional - requests which exceed it fail as 3scale being unavailable, and are subject to fail_open
+	ClientTimeout string `protobuf:"bytes,17,opt,name=client_timeout,json=clientTimeout,proto3" json:"client_timeout,omitempty"`
+	// Maximum time an authorization waits for the proxy config to be fetched from 3scale system, as a duration such as 500ms or 2s - optional - bounded by client_timeout when both are set
+	SystemTimeout string `protobuf:"bytes,18,opt,name=system_timeout,json=systemTimeout,proto3" json:"system_timeout,omitempty"`
+	// Maximum time an authorization waits for 3scale backend to authorize the request, as a duration such as 500ms or 2s - optional - bounded by client_timeout when both are set - the request may still be reported to 3scale after the timeout has been reached
+	BackendTimeout string `protobuf:"bytes,19,opt,name=backend_timeout,json=backendTimeout,proto3" json:"backend_timeout,omitempty"`
+	// URL of the HTTP proxy, such as http://proxy.example.com:3128, requests to 3scale system are sent through for this handler - optional - defaults to the proxy set by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables of the adapter - applies to every handler using the same system_url
+	SystemProxyUrl string `protobuf:"bytes,20,opt,name=system_proxy_url,json=systemProxyUrl,proto3" json:"system_proxy_url,omitempty"`
+	// URL of the HTTP proxy requests to 3scale backend are sent through for this handler - optional - defaults to the proxy set by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables of the adapter - applies to every handler using the same backend
+	BackendProxyUrl string `protobuf:"bytes,21,opt,name=backend_proxy_url,json=backendProxyUrl,proto3" json:"backend_proxy_url,omitempty"`
+	// When true, the TLS certificates of 3scale system and backend are not verified for this handler - optional - defaults to false - for lab environments only, since requests and credentials can then be intercepted - applies to every handler using the same system_url or backend until the adapter restarts
+	InsecureSkipVerify bool `protobuf:"varint,22,opt,name=insecure_skip_verify,json=insecureSkipVerify,proto3" json:"insecure_skip_verify,omitempty"`
+	// Secret which, when presented in the cache_bypass subject property of a request, makes the adapter fetch the proxy config and authorize the request with 3scale without using any cache - optional - the property is ignored unless set - intended for test tooling diagnosing stale caches, and the token should only be known to it
+	CacheBypassToken string `protobuf:"bytes,23,opt,name=cache_bypass_token,json=cacheBypassToken,proto3" json:"cache_bypass_token,omitempty"`
+	// Engine matching requests against the mapping rules of the service, one of regex or prefix - optional - defaults to regex, which evaluates each pattern as a regular expression in turn - prefix anchors patterns at the start of the path, as 3scale does, and finds literal patterns such as /orders or /orders/new$ in time independent of the number of rules, suiting services with many rules
+	MappingRuleMatcher string `protobuf:"bytes,24,opt,name=mapping_rule_matcher,json=mappingRuleMatcher,proto3" json:"mapping_rule_matcher,omitempty"`
+	// Period the proxy config of the service is cached for, as a duration such as 30s or 5m - optional - defaults to the period set by CACHE_TTL_SECONDS - only applies when the adapter caches configs compressed with CACHE_COMPRESSION - when the config cannot be refreshed it may be served stale for the same period again by the serve-stale config strategy
+	SystemCacheTtl string `protobuf:"bytes,25,opt,name=system_cache_ttl,json=systemCacheTtl,proto3" json:"system_cache_ttl,omitempty"`
+	// Period after which a cached proxy config of the service is refreshed in the background by the next request using it, as a duration such as 30s or 5m - optional - defaults to no refresh, so the config is fetched once it expires - only applies when the adapter caches configs compressed with CACHE_COMPRESSION - should be less than system_cache_ttl to take effect
+	SystemCacheRefreshInterval string `protobuf:"bytes,26,opt,name=system_cache_refresh_interval,json=systemCacheRefreshInterval,proto3" json:"system_cache_refresh_interval,omitempty"`
+	// Number of times a failed fetch of the proxy config of the service is retried - optional - defaults to 0 - only applies when the adapter caches configs compressed with CACHE_COMPRESSION
+	SystemCacheMaxRetries int64 `protobuf:"varint,27,opt,name=system_cache_max_retries,json=systemCacheMaxRetries,proto3" json:"system_cache_max_retries,omitempty"`
+	// 3scale backend extensions enabled when authorizing requests, any of rejection_reason_header and limit_headers - optional - rejection_reason_header reports why a request was denied, limit_headers reports the remaining usage of the most constrained limit of the application and when it resets, both appended to the check status message as threescale.* attributes - applies to every handler of the same service using the same backend - no data is reported for requests authorized from the backend cache
+	BackendExtensions []string `protobuf:"bytes,28,rep,name=backend_extensions,json=backendExtensions" json:"backend_extensions,omitempty"`
 }
 
 func (m *Params) Reset()                    { *m = Params{} }
@@ -79,8 +129,178 @@ func (m *Params) GetBackendUrl() string {
 	return ""
 }
 
+func (m *Params) GetBackendUrlByLocality() map[string]string {
+	if m != nil {
+		return m.BackendUrlByLocality
+	}
+	return nil
+}
+
+func (m *Params) GetApiVersionPattern() string {
+	if m != nil {
+		return m.ApiVersionPattern
+	}
+	return ""
+}
+
+func (m *Params) GetApiVersionMetricSuffix() bool {
+	if m != nil {
+		return m.ApiVersionMetricSuffix
+	}
+	return false
+}
+
+func (m *Params) GetBackendAuthType() string {
+	if m != nil {
+		return m.BackendAuthType
+	}
+	return ""
+}
+
+func (m *Params) GetBackendAuthValue() string {
+	if m != nil {
+		return m.BackendAuthValue
+	}
+	return ""
+}
+
+func (m *Params) GetOnboardingUntil() string {
+	if m != nil {
+		return m.OnboardingUntil
+	}
+	return ""
+}
+
+func (m *Params) GetTrustedIdentitySecret() string {
+	if m != nil {
+		return m.TrustedIdentitySecret
+	}
+	return ""
+}
+
+func (m *Params) GetTrustedIdentityMaxAgeSeconds() int64 {
+	if m != nil {
+		return m.TrustedIdentityMaxAgeSeconds
+	}
+	return 0
+}
+
+func (m *Params) GetFailOpen() bool {
+	if m != nil {
+		return m.FailOpen
+	}
+	return false
+}
+
+func (m *Params) GetRequiredHeaders() map[string]string {
+	if m != nil {
+		return m.RequiredHeaders
+	}
+	return nil
+}
+
+func (m *Params) GetShadowServiceId() string {
+	if m != nil {
+		return m.ShadowServiceId
+	}
+	return ""
+}
+
+func (m *Params) GetOidcUserKeyFallback() bool {
+	if m != nil {
+		return m.OidcUserKeyFallback
+	}
+	return false
+}
+
+func (m *Params) GetClientTimeout() string {
+	if m != nil {
+		return m.ClientTimeout
+	}
+	return ""
+}
+
+func (m *Params) GetSystemTimeout() string {
+	if m != nil {
+		return m.SystemTimeout
+	}
+	return ""
+}
+
+func (m *Params) GetBackendTimeout() string {
+	if m != nil {
+		return m.BackendTimeout
+	}
+	return ""
+}
+
+func (m *Params) GetSystemProxyUrl() string {
+	if m != nil {
+		return m.SystemProxyUrl
+	}
+	return ""
+}
+
+func (m *Params) GetBackendProxyUrl() string {
+	if m != nil {
+		return m.BackendProxyUrl
+	}
+	return ""
+}
+
+func (m *Params) GetInsecureSkipVerify() bool {
+	if m != nil {
+		return m.InsecureSkipVerify
+	}
+	return false
+}
+
+func (m *Params) GetCacheBypassToken() string {
+	if m != nil {
+		return m.CacheBypassToken
+	}
+	return ""
+}
+
+func (m *Params) GetMappingRuleMatcher() string {
+	if m != nil {
+		return m.MappingRuleMatcher
+	}
+	return ""
+}
+
+func (m *Params) GetSystemCacheTtl() string {
+	if m != nil {
+		return m.SystemCacheTtl
+	}
+	return ""
+}
+
+func (m *Params) GetSystemCacheRefreshInterval() string {
+	if m != nil {
+		return m.SystemCacheRefreshInterval
+	}
+	return ""
+}
+
+func (m *Params) GetSystemCacheMaxRetries() int64 {
+	if m != nil {
+		return m.SystemCacheMaxRetries
+	}
+	return 0
+}
+
+func (m *Params) GetBackendExtensions() []string {
+	if m != nil {
+		return m.BackendExtensions
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*Params)(nil), "adapter.threescale.config.Params")
+	proto.RegisterMapType((map[string]string)(nil), "adapter.threescale.config.Params.BackendUrlByLocalityEntry")
+	proto.RegisterMapType((map[string]string)(nil), "adapter.threescale.config.Params.RequiredHeadersEntry")
 }
 func (this *Params) Equal(that interface{}) bool {
 	if that == nil {
@@ -113,18 +333,153 @@ func (this *Params) Equal(that interface{}) bool {
 	if this.BackendUrl != that1.BackendUrl {
 		return false
 	}
+	if len(this.BackendUrlByLocality) != len(that1.BackendUrlByLocality) {
+		return false
+	}
+	for i := range this.BackendUrlByLocality {
+		if this.BackendUrlByLocality[i] != that1.BackendUrlByLocality[i] {
+			return false
+		}
+	}
+	if this.ApiVersionPattern != that1.ApiVersionPattern {
+		return false
+	}
+	if this.ApiVersionMetricSuffix != that1.ApiVersionMetricSuffix {
+		return false
+	}
+	if this.BackendAuthType != that1.BackendAuthType {
+		return false
+	}
+	if this.BackendAuthValue != that1.BackendAuthValue {
+		return false
+	}
+	if this.OnboardingUntil != that1.OnboardingUntil {
+		return false
+	}
+	if this.TrustedIdentitySecret != that1.TrustedIdentitySecret {
+		return false
+	}
+	if this.TrustedIdentityMaxAgeSeconds != that1.TrustedIdentityMaxAgeSeconds {
+		return false
+	}
+	if this.FailOpen != that1.FailOpen {
+		return false
+	}
+	if len(this.RequiredHeaders) != len(that1.RequiredHeaders) {
+		return false
+	}
+	for i := range this.RequiredHeaders {
+		if this.RequiredHeaders[i] != that1.RequiredHeaders[i] {
+			return false
+		}
+	}
+	if this.ShadowServiceId != that1.ShadowServiceId {
+		return false
+	}
+	if this.OidcUserKeyFallback != that1.OidcUserKeyFallback {
+		return false
+	}
+	if this.ClientTimeout != that1.ClientTimeout {
+		return false
+	}
+	if this.SystemTimeout != that1.SystemTimeout {
+		return false
+	}
+	if this.BackendTimeout != that1.BackendTimeout {
+		return false
+	}
+	if this.SystemProxyUrl != that1.SystemProxyUrl {
+		return false
+	}
+	if this.BackendProxyUrl != that1.BackendProxyUrl {
+		return false
+	}
+	if this.InsecureSkipVerify != that1.InsecureSkipVerify {
+		return false
+	}
+	if this.CacheBypassToken != that1.CacheBypassToken {
+		return false
+	}
+	if this.MappingRuleMatcher != that1.MappingRuleMatcher {
+		return false
+	}
+	if this.SystemCacheTtl != that1.SystemCacheTtl {
+		return false
+	}
+	if this.SystemCacheRefreshInterval != that1.SystemCacheRefreshInterval {
+		return false
+	}
+	if this.SystemCacheMaxRetries != that1.SystemCacheMaxRetries {
+		return false
+	}
+	if len(this.BackendExtensions) != len(that1.BackendExtensions) {
+		return false
+	}
+	for i := range this.BackendExtensions {
+		if this.BackendExtensions[i] != that1.BackendExtensions[i] {
+			return false
+		}
+	}
 	return true
 }
 func (this *Params) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
+	s := make([]string, 0, 32)
 	s = append(s, "&config.Params{")
 	s = append(s, "ServiceId: "+fmt.Sprintf("%#v", this.ServiceId)+",\n")
 	s = append(s, "SystemUrl: "+fmt.Sprintf("%#v", this.SystemUrl)+",\n")
 	s = append(s, "AccessToken: "+fmt.Sprintf("%#v", this.AccessToken)+",\n")
 	s = append(s, "BackendUrl: "+fmt.Sprintf("%#v", this.BackendUrl)+",\n")
+	keysForBackendUrlByLocality := make([]string, 0, len(this.BackendUrlByLocality))
+	for k, _ := range this.BackendUrlByLocality {
+		keysForBackendUrlByLocality = append(keysForBackendUrlByLocality, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForBackendUrlByLocality)
+	mapStringForBackendUrlByLocality := "map[string]string{"
+	for _, k := range keysForBackendUrlByLocality {
+		mapStringForBackendUrlByLocality += fmt.Sprintf("%#v: %#v,", k, this.BackendUrlByLocality[k])
+	}
+	mapStringForBackendUrlByLocality += "}"
+	if this.BackendUrlByLocality != nil {
+		s = append(s, "BackendUrlByLocality: "+mapStringForBackendUrlByLocality+",\n")
+	}
+	s = append(s, "ApiVersionPattern: "+fmt.Sprintf("%#v", this.ApiVersionPattern)+",\n")
+	s = append(s, "ApiVersionMetricSuffix: "+fmt.Sprintf("%#v", this.ApiVersionMetricSuffix)+",\n")
+	s = append(s, "BackendAuthType: "+fmt.Sprintf("%#v", this.BackendAuthType)+",\n")
+	s = append(s, "BackendAuthValue: "+fmt.Sprintf("%#v", this.BackendAuthValue)+",\n")
+	s = append(s, "OnboardingUntil: "+fmt.Sprintf("%#v", this.OnboardingUntil)+",\n")
+	s = append(s, "TrustedIdentitySecret: "+fmt.Sprintf("%#v", this.TrustedIdentitySecret)+",\n")
+	s = append(s, "TrustedIdentityMaxAgeSeconds: "+fmt.Sprintf("%#v", this.TrustedIdentityMaxAgeSeconds)+",\n")
+	s = append(s, "FailOpen: "+fmt.Sprintf("%#v", this.FailOpen)+",\n")
+	keysForRequiredHeaders := make([]string, 0, len(this.RequiredHeaders))
+	for k, _ := range this.RequiredHeaders {
+		keysForRequiredHeaders = append(keysForRequiredHeaders, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForRequiredHeaders)
+	mapStringForRequiredHeaders := "map[string]string{"
+	for _, k := range keysForRequiredHeaders {
+		mapStringForRequiredHeaders += fmt.Sprintf("%#v: %#v,", k, this.RequiredHeaders[k])
+	}
+	mapStringForRequiredHeaders += "}"
+	if this.RequiredHeaders != nil {
+		s = append(s, "RequiredHeaders: "+mapStringForRequiredHeaders+",\n")
+	}
+	s = append(s, "ShadowServiceId: "+fmt.Sprintf("%#v", this.ShadowServiceId)+",\n")
+	s = append(s, "OidcUserKeyFallback: "+fmt.Sprintf("%#v", this.OidcUserKeyFallback)+",\n")
+	s = append(s, "ClientTimeout: "+fmt.Sprintf("%#v", this.ClientTimeout)+",\n")
+	s = append(s, "SystemTimeout: "+fmt.Sprintf("%#v", this.SystemTimeout)+",\n")
+	s = append(s, "BackendTimeout: "+fmt.Sprintf("%#v", this.BackendTimeout)+",\n")
+	s = append(s, "SystemProxyUrl: "+fmt.Sprintf("%#v", this.SystemProxyUrl)+",\n")
+	s = append(s, "BackendProxyUrl: "+fmt.Sprintf("%#v", this.BackendProxyUrl)+",\n")
+	s = append(s, "InsecureSkipVerify: "+fmt.Sprintf("%#v", this.InsecureSkipVerify)+",\n")
+	s = append(s, "CacheBypassToken: "+fmt.Sprintf("%#v", this.CacheBypassToken)+",\n")
+	s = append(s, "MappingRuleMatcher: "+fmt.Sprintf("%#v", this.MappingRuleMatcher)+",\n")
+	s = append(s, "SystemCacheTtl: "+fmt.Sprintf("%#v", this.SystemCacheTtl)+",\n")
+	s = append(s, "SystemCacheRefreshInterval: "+fmt.Sprintf("%#v", this.SystemCacheRefreshInterval)+",\n")
+	s = append(s, "SystemCacheMaxRetries: "+fmt.Sprintf("%#v", this.SystemCacheMaxRetries)+",\n")
+	s = append(s, "BackendExtensions: "+fmt.Sprintf("%#v", this.BackendExtensions)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -175,6 +530,221 @@ func (m *Params) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintConfig(dAtA, i, uint64(len(m.BackendUrl)))
 		i += copy(dAtA[i:], m.BackendUrl)
 	}
+	if len(m.BackendUrlByLocality) > 0 {
+		for k, _ := range m.BackendUrlByLocality {
+			dAtA[i] = 0x2a
+			i++
+			v := m.BackendUrlByLocality[k]
+			mapSize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			i = encodeVarintConfig(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	if len(m.ApiVersionPattern) > 0 {
+		dAtA[i] = 0x32
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ApiVersionPattern)))
+		i += copy(dAtA[i:], m.ApiVersionPattern)
+	}
+	if m.ApiVersionMetricSuffix {
+		dAtA[i] = 0x38
+		i++
+		if m.ApiVersionMetricSuffix {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.BackendAuthType) > 0 {
+		dAtA[i] = 0x42
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.BackendAuthType)))
+		i += copy(dAtA[i:], m.BackendAuthType)
+	}
+	if len(m.BackendAuthValue) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.BackendAuthValue)))
+		i += copy(dAtA[i:], m.BackendAuthValue)
+	}
+	if len(m.OnboardingUntil) > 0 {
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.OnboardingUntil)))
+		i += copy(dAtA[i:], m.OnboardingUntil)
+	}
+	if len(m.TrustedIdentitySecret) > 0 {
+		dAtA[i] = 0x5a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.TrustedIdentitySecret)))
+		i += copy(dAtA[i:], m.TrustedIdentitySecret)
+	}
+	if m.TrustedIdentityMaxAgeSeconds != 0 {
+		dAtA[i] = 0x60
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.TrustedIdentityMaxAgeSeconds))
+	}
+	if m.FailOpen {
+		dAtA[i] = 0x68
+		i++
+		if m.FailOpen {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.RequiredHeaders) > 0 {
+		for k, _ := range m.RequiredHeaders {
+			dAtA[i] = 0x72
+			i++
+			v := m.RequiredHeaders[k]
+			mapSize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			i = encodeVarintConfig(dAtA, i, uint64(mapSize))
+			dAtA[i] = 0xa
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(k)))
+			i += copy(dAtA[i:], k)
+			dAtA[i] = 0x12
+			i++
+			i = encodeVarintConfig(dAtA, i, uint64(len(v)))
+			i += copy(dAtA[i:], v)
+		}
+	}
+	if len(m.ShadowServiceId) > 0 {
+		dAtA[i] = 0x7a
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ShadowServiceId)))
+		i += copy(dAtA[i:], m.ShadowServiceId)
+	}
+	if m.OidcUserKeyFallback {
+		dAtA[i] = 0x80
+		i++
+		dAtA[i] = 0x1
+		i++
+		if m.OidcUserKeyFallback {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.ClientTimeout) > 0 {
+		dAtA[i] = 0x8a
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.ClientTimeout)))
+		i += copy(dAtA[i:], m.ClientTimeout)
+	}
+	if len(m.SystemTimeout) > 0 {
+		dAtA[i] = 0x92
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.SystemTimeout)))
+		i += copy(dAtA[i:], m.SystemTimeout)
+	}
+	if len(m.BackendTimeout) > 0 {
+		dAtA[i] = 0x9a
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.BackendTimeout)))
+		i += copy(dAtA[i:], m.BackendTimeout)
+	}
+	if len(m.SystemProxyUrl) > 0 {
+		dAtA[i] = 0xa2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.SystemProxyUrl)))
+		i += copy(dAtA[i:], m.SystemProxyUrl)
+	}
+	if len(m.BackendProxyUrl) > 0 {
+		dAtA[i] = 0xaa
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.BackendProxyUrl)))
+		i += copy(dAtA[i:], m.BackendProxyUrl)
+	}
+	if m.InsecureSkipVerify {
+		dAtA[i] = 0xb0
+		i++
+		dAtA[i] = 0x1
+		i++
+		if m.InsecureSkipVerify {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.CacheBypassToken) > 0 {
+		dAtA[i] = 0xba
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.CacheBypassToken)))
+		i += copy(dAtA[i:], m.CacheBypassToken)
+	}
+	if len(m.MappingRuleMatcher) > 0 {
+		dAtA[i] = 0xc2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.MappingRuleMatcher)))
+		i += copy(dAtA[i:], m.MappingRuleMatcher)
+	}
+	if len(m.SystemCacheTtl) > 0 {
+		dAtA[i] = 0xca
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.SystemCacheTtl)))
+		i += copy(dAtA[i:], m.SystemCacheTtl)
+	}
+	if len(m.SystemCacheRefreshInterval) > 0 {
+		dAtA[i] = 0xd2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(len(m.SystemCacheRefreshInterval)))
+		i += copy(dAtA[i:], m.SystemCacheRefreshInterval)
+	}
+	if m.SystemCacheMaxRetries != 0 {
+		dAtA[i] = 0xd8
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintConfig(dAtA, i, uint64(m.SystemCacheMaxRetries))
+	}
+	if len(m.BackendExtensions) > 0 {
+		for _, s := range m.BackendExtensions {
+			dAtA[i] = 0xe2
+			i++
+			dAtA[i] = 0x1
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
 	return i, nil
 }
 
@@ -206,11 +776,111 @@ func (m *Params) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovConfig(uint64(l))
 	}
-	return n
-}
-
-func sovConfig(x uint64) (n int) {
-	for {
+	if len(m.BackendUrlByLocality) > 0 {
+		for k, v := range m.BackendUrlByLocality {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			n += mapEntrySize + 1 + sovConfig(uint64(mapEntrySize))
+		}
+	}
+	l = len(m.ApiVersionPattern)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if m.ApiVersionMetricSuffix {
+		n += 2
+	}
+	l = len(m.BackendAuthType)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.BackendAuthValue)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.OnboardingUntil)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	l = len(m.TrustedIdentitySecret)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if m.TrustedIdentityMaxAgeSeconds != 0 {
+		n += 1 + sovConfig(uint64(m.TrustedIdentityMaxAgeSeconds))
+	}
+	if m.FailOpen {
+		n += 2
+	}
+	if len(m.RequiredHeaders) > 0 {
+		for k, v := range m.RequiredHeaders {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovConfig(uint64(len(k))) + 1 + len(v) + sovConfig(uint64(len(v)))
+			n += mapEntrySize + 1 + sovConfig(uint64(mapEntrySize))
+		}
+	}
+	l = len(m.ShadowServiceId)
+	if l > 0 {
+		n += 1 + l + sovConfig(uint64(l))
+	}
+	if m.OidcUserKeyFallback {
+		n += 3
+	}
+	l = len(m.ClientTimeout)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.SystemTimeout)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.BackendTimeout)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.SystemProxyUrl)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.BackendProxyUrl)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	if m.InsecureSkipVerify {
+		n += 3
+	}
+	l = len(m.CacheBypassToken)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.MappingRuleMatcher)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.SystemCacheTtl)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	l = len(m.SystemCacheRefreshInterval)
+	if l > 0 {
+		n += 2 + l + sovConfig(uint64(l))
+	}
+	if m.SystemCacheMaxRetries != 0 {
+		n += 2 + sovConfig(uint64(m.SystemCacheMaxRetries))
+	}
+	if len(m.BackendExtensions) > 0 {
+		for _, s := range m.BackendExtensions {
+			l = len(s)
+			n += 2 + l + sovConfig(uint64(l))
+		}
+	}
+	return n
+}
+
+func sovConfig(x uint64) (n int) {
+	for {
 		n++
 		x >>= 7
 		if x == 0 {
@@ -226,11 +896,55 @@ func (this *Params) String() string {
 	if this == nil {
 		return "nil"
 	}
+	keysForBackendUrlByLocality := make([]string, 0, len(this.BackendUrlByLocality))
+	for k, _ := range this.BackendUrlByLocality {
+		keysForBackendUrlByLocality = append(keysForBackendUrlByLocality, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForBackendUrlByLocality)
+	mapStringForBackendUrlByLocality := "map[string]string{"
+	for _, k := range keysForBackendUrlByLocality {
+		mapStringForBackendUrlByLocality += fmt.Sprintf("%v: %v,", k, this.BackendUrlByLocality[k])
+	}
+	mapStringForBackendUrlByLocality += "}"
+	keysForRequiredHeaders := make([]string, 0, len(this.RequiredHeaders))
+	for k, _ := range this.RequiredHeaders {
+		keysForRequiredHeaders = append(keysForRequiredHeaders, k)
+	}
+	github_com_gogo_protobuf_sortkeys.Strings(keysForRequiredHeaders)
+	mapStringForRequiredHeaders := "map[string]string{"
+	for _, k := range keysForRequiredHeaders {
+		mapStringForRequiredHeaders += fmt.Sprintf("%v: %v,", k, this.RequiredHeaders[k])
+	}
+	mapStringForRequiredHeaders += "}"
 	s := strings.Join([]string{`&Params{`,
 		`ServiceId:` + fmt.Sprintf("%v", this.ServiceId) + `,`,
 		`SystemUrl:` + fmt.Sprintf("%v", this.SystemUrl) + `,`,
 		`AccessToken:` + fmt.Sprintf("%v", this.AccessToken) + `,`,
 		`BackendUrl:` + fmt.Sprintf("%v", this.BackendUrl) + `,`,
+		`BackendUrlByLocality:` + mapStringForBackendUrlByLocality + `,`,
+		`ApiVersionPattern:` + fmt.Sprintf("%v", this.ApiVersionPattern) + `,`,
+		`ApiVersionMetricSuffix:` + fmt.Sprintf("%v", this.ApiVersionMetricSuffix) + `,`,
+		`BackendAuthType:` + fmt.Sprintf("%v", this.BackendAuthType) + `,`,
+		`BackendAuthValue:` + fmt.Sprintf("%v", this.BackendAuthValue) + `,`,
+		`OnboardingUntil:` + fmt.Sprintf("%v", this.OnboardingUntil) + `,`,
+		`TrustedIdentitySecret:` + fmt.Sprintf("%v", this.TrustedIdentitySecret) + `,`,
+		`TrustedIdentityMaxAgeSeconds:` + fmt.Sprintf("%v", this.TrustedIdentityMaxAgeSeconds) + `,`,
+		`FailOpen:` + fmt.Sprintf("%v", this.FailOpen) + `,`,
+		`RequiredHeaders:` + mapStringForRequiredHeaders + `,`,
+		`ShadowServiceId:` + fmt.Sprintf("%v", this.ShadowServiceId) + `,`,
+		`OidcUserKeyFallback:` + fmt.Sprintf("%v", this.OidcUserKeyFallback) + `,`,
+		`ClientTimeout:` + fmt.Sprintf("%v", this.ClientTimeout) + `,`,
+		`SystemTimeout:` + fmt.Sprintf("%v", this.SystemTimeout) + `,`,
+		`BackendTimeout:` + fmt.Sprintf("%v", this.BackendTimeout) + `,`,
+		`SystemProxyUrl:` + fmt.Sprintf("%v", this.SystemProxyUrl) + `,`,
+		`BackendProxyUrl:` + fmt.Sprintf("%v", this.BackendProxyUrl) + `,`,
+		`InsecureSkipVerify:` + fmt.Sprintf("%v", this.InsecureSkipVerify) + `,`,
+		`CacheBypassToken:` + fmt.Sprintf("%v", this.CacheBypassToken) + `,`,
+		`MappingRuleMatcher:` + fmt.Sprintf("%v", this.MappingRuleMatcher) + `,`,
+		`SystemCacheTtl:` + fmt.Sprintf("%v", this.SystemCacheTtl) + `,`,
+		`SystemCacheRefreshInterval:` + fmt.Sprintf("%v", this.SystemCacheRefreshInterval) + `,`,
+		`SystemCacheMaxRetries:` + fmt.Sprintf("%v", this.SystemCacheMaxRetries) + `,`,
+		`BackendExtensions:` + fmt.Sprintf("%v", this.BackendExtensions) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -388,6 +1102,824 @@ func (m *Params) Unmarshal(dAtA []byte) error {
 			}
 			m.BackendUrl = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BackendUrlByLocality", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.BackendUrlByLocality == nil {
+				m.BackendUrlByLocality = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowConfig
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipConfig(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthConfig
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.BackendUrlByLocality[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ApiVersionPattern", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ApiVersionPattern = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ApiVersionMetricSuffix", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.ApiVersionMetricSuffix = bool(v != 0)
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BackendAuthType", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BackendAuthType = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BackendAuthValue", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BackendAuthValue = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OnboardingUntil", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.OnboardingUntil = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TrustedIdentitySecret", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TrustedIdentitySecret = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TrustedIdentityMaxAgeSeconds", wireType)
+			}
+			m.TrustedIdentityMaxAgeSeconds = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TrustedIdentityMaxAgeSeconds |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FailOpen", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.FailOpen = bool(v != 0)
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RequiredHeaders", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.RequiredHeaders == nil {
+				m.RequiredHeaders = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowConfig
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= (uint64(b) & 0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowConfig
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= (uint64(b) & 0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthConfig
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipConfig(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if skippy < 0 {
+						return ErrInvalidLengthConfig
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.RequiredHeaders[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ShadowServiceId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ShadowServiceId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 16:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field OidcUserKeyFallback", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.OidcUserKeyFallback = bool(v != 0)
+		case 17:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ClientTimeout", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ClientTimeout = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 18:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SystemTimeout", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SystemTimeout = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 19:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BackendTimeout", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BackendTimeout = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 20:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SystemProxyUrl", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SystemProxyUrl = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 21:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BackendProxyUrl", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BackendProxyUrl = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 22:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InsecureSkipVerify", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.InsecureSkipVerify = bool(v != 0)
+		case 23:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CacheBypassToken", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CacheBypassToken = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 24:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MappingRuleMatcher", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.MappingRuleMatcher = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 25:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SystemCacheTtl", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SystemCacheTtl = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 26:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SystemCacheRefreshInterval", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SystemCacheRefreshInterval = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 27:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SystemCacheMaxRetries", wireType)
+			}
+			m.SystemCacheMaxRetries = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SystemCacheMaxRetries |= (int64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 28:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BackendExtensions", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowConfig
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthConfig
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BackendExtensions = append(m.BackendExtensions, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipConfig(dAtA[iNdEx:])
@@ -519,22 +2051,60 @@ func init() {
 }
 
 var fileDescriptorConfig = []byte{
-	// 258 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x34, 0x8f, 0xbd, 0x4e, 0xc3, 0x30,
-	0x14, 0x85, 0x73, 0x01, 0x45, 0xaa, 0xcb, 0x14, 0x31, 0x04, 0x24, 0x2e, 0x3f, 0x13, 0x4b, 0x93,
-	0xa1, 0x88, 0x07, 0x60, 0x63, 0x43, 0x88, 0x2e, 0x2c, 0x91, 0xeb, 0x5c, 0x82, 0xd5, 0x24, 0xae,
-	0xae, 0x0d, 0x82, 0x8d, 0x99, 0x89, 0xc7, 0xe0, 0x51, 0x18, 0x3b, 0x32, 0x12, 0xb3, 0x30, 0xf6,
-	0x11, 0x50, 0x9d, 0x30, 0xd9, 0xfa, 0xce, 0x77, 0xae, 0x74, 0xc4, 0x45, 0xa3, 0x9f, 0x89, 0x73,
-	0x59, 0xca, 0xa5, 0x23, 0xce, 0xa7, 0x56, 0xc9, 0x9a, 0x26, 0xda, 0x3a, 0x6d, 0x26, 0xff, 0x50,
-	0x99, 0xf6, 0x5e, 0x57, 0xc3, 0x93, 0x2d, 0xd9, 0x38, 0x93, 0xec, 0x0f, 0x61, 0xe6, 0x1e, 0x98,
-	0x28, 0xb4, 0xb2, 0x5e, 0x38, 0xd8, 0xab, 0x4c, 0x65, 0x82, 0x95, 0x6f, 0x7e, 0x7d, 0xe1, 0xf4,
-	0x0d, 0x44, 0x7c, 0x2d, 0x59, 0x36, 0x36, 0x39, 0x14, 0xc2, 0x12, 0x3f, 0x69, 0x45, 0x85, 0x2e,
-	0x53, 0x38, 0x86, 0xb3, 0xd1, 0xcd, 0x68, 0x20, 0x57, 0x65, 0x88, 0x5f, 0xac, 0xa3, 0xa6, 0x78,
-	0xe4, 0x3a, 0xdd, 0x1a, 0xe2, 0x40, 0x66, 0x5c, 0x27, 0x27, 0x62, 0x57, 0x2a, 0x45, 0xd6, 0x16,
-	0xce, 0x2c, 0xa8, 0x4d, 0xb7, 0x83, 0x30, 0xee, 0xd9, 0xed, 0x06, 0x25, 0x47, 0x62, 0x3c, 0x97,
-	0x6a, 0x41, 0x6d, 0x19, 0x4e, 0xec, 0x04, 0x43, 0x0c, 0x68, 0xc6, 0xf5, 0xe5, 0xf9, 0xaa, 0xc3,
-	0xe8, 0xab, 0xc3, 0x68, 0xdd, 0x21, 0xbc, 0x7a, 0x84, 0x0f, 0x8f, 0xf0, 0xe9, 0x11, 0x56, 0x1e,
-	0xe1, 0xdb, 0x23, 0xfc, 0x7a, 0x8c, 0xd6, 0x1e, 0xe1, 0xfd, 0x07, 0xa3, 0xbb, 0xb8, 0x1f, 0x36,
-	0x8f, 0xc3, 0x92, 0xe9, 0x5f, 0x00, 0x00, 0x00, 0xff, 0xff, 0xd3, 0x6f, 0x2d, 0xbe, 0x34, 0x01,
-	0x00, 0x00,
+	// 878 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x95, 0xcd, 0x6e, 0x23, 0x45,
+	0x10, 0xc7, 0x33, 0x6b, 0x36, 0x24, 0x9d, 0x6c, 0xe2, 0x74, 0x9c, 0xa4, 0xe3, 0xdd, 0x1d, 0x0c,
+	0x12, 0xc2, 0x20, 0xe2, 0xac, 0x08, 0x0a, 0x5f, 0xa7, 0x18, 0xed, 0x42, 0x04, 0x11, 0x91, 0x9d,
+	0xe4, 0xc0, 0xa5, 0xd5, 0x9e, 0x29, 0xdb, 0x2d, 0x8f, 0xa7, 0x87, 0xee, 0x9e, 0xe0, 0xb9, 0xf1,
+	0x08, 0xfb, 0x18, 0x3c, 0x0a, 0xc7, 0x3d, 0x72, 0x24, 0xe6, 0xc2, 0x71, 0x1f, 0x01, 0xf5, 0x87,
+	0xed, 0x31, 0x6c, 0x84, 0x38, 0x65, 0xf2, 0xff, 0xff, 0xaa, 0x66, 0xaa, 0xbb, 0xaa, 0x8c, 0x4e,
+	0xc7, 0x7c, 0x02, 0xf2, 0x98, 0xc5, 0x2c, 0xd3, 0x20, 0x8f, 0x4f, 0x54, 0xc4, 0x12, 0x38, 0xe2,
+	0x4a, 0x73, 0x71, 0x34, 0x13, 0x23, 0x91, 0xf6, 0xf9, 0xc0, 0xff, 0x69, 0x65, 0x52, 0x68, 0x81,
+	0x0f, 0xbd, 0xd9, 0xd2, 0x43, 0x09, 0x60, 0xa3, 0x5a, 0x0e, 0xa8, 0xd7, 0x06, 0x62, 0x20, 0x2c,
+	0x75, 0x6c, 0x9e, 0x5c, 0xc0, 0x7b, 0x2f, 0x37, 0xd1, 0xea, 0x25, 0x93, 0x6c, 0xac, 0xf0, 0x53,
+	0x84, 0x14, 0xc8, 0x5b, 0x1e, 0x01, 0xe5, 0x31, 0x09, 0x1a, 0x41, 0x73, 0xbd, 0xb3, 0xee, 0x95,
+	0xf3, 0xd8, 0xda, 0x85, 0xd2, 0x30, 0xa6, 0xb9, 0x4c, 0xc8, 0x03, 0x6f, 0x5b, 0xe5, 0x5a, 0x26,
+	0xf8, 0x5d, 0xb4, 0xc9, 0xa2, 0x08, 0x94, 0xa2, 0x5a, 0x8c, 0x20, 0x25, 0x15, 0x0b, 0x6c, 0x38,
+	0xed, 0xca, 0x48, 0xf8, 0x1d, 0xb4, 0xd1, 0x63, 0xd1, 0x08, 0xd2, 0xd8, 0xa6, 0x78, 0xcb, 0x12,
+	0xc8, 0x4b, 0x26, 0x87, 0x44, 0x07, 0x25, 0x80, 0xf6, 0x0a, 0x9a, 0x88, 0x88, 0x25, 0x5c, 0x17,
+	0xe4, 0x61, 0xa3, 0xd2, 0xdc, 0xf8, 0xe4, 0xab, 0xd6, 0xbd, 0xf5, 0xb5, 0x5c, 0x15, 0xad, 0xf6,
+	0x3c, 0x5d, 0xbb, 0xf8, 0xde, 0x47, 0x3f, 0x4f, 0xb5, 0x2c, 0x3a, 0xb5, 0xde, 0x1b, 0x2c, 0xdc,
+	0x42, 0xbb, 0x2c, 0xe3, 0xf4, 0x16, 0xa4, 0xe2, 0x22, 0xa5, 0x19, 0xd3, 0x1a, 0x64, 0x4a, 0x56,
+	0xed, 0xc7, 0xed, 0xb0, 0x8c, 0xdf, 0x38, 0xe7, 0xd2, 0x19, 0xf8, 0x0b, 0x74, 0x58, 0xe6, 0xc7,
+	0xa0, 0x25, 0x8f, 0xa8, 0xca, 0xfb, 0x7d, 0x3e, 0x21, 0x6f, 0x37, 0x82, 0xe6, 0x5a, 0x67, 0x7f,
+	0x11, 0x75, 0x61, 0xed, 0xae, 0x75, 0xf1, 0x47, 0x68, 0x67, 0x56, 0x1e, 0xcb, 0xf5, 0x90, 0xea,
+	0x22, 0x03, 0xb2, 0x66, 0x5f, 0xb4, 0xed, 0x8d, 0xb3, 0x5c, 0x0f, 0xaf, 0x8a, 0x0c, 0xf0, 0xc7,
+	0x08, 0x2f, 0xb1, 0xb7, 0x2c, 0xc9, 0x81, 0xac, 0x5b, 0xb8, 0x5a, 0x82, 0x6f, 0x8c, 0x8e, 0x3f,
+	0x44, 0x55, 0x91, 0xf6, 0x04, 0x93, 0x31, 0x4f, 0x07, 0x34, 0x4f, 0x35, 0x4f, 0x08, 0x72, 0x89,
+	0x17, 0xfa, 0xb5, 0x91, 0xf1, 0x29, 0x3a, 0xd0, 0x32, 0x57, 0x1a, 0x62, 0xca, 0x63, 0x48, 0x35,
+	0xd7, 0x05, 0x55, 0x10, 0x49, 0xd0, 0x64, 0xc3, 0x46, 0xec, 0x79, 0xfb, 0xdc, 0xbb, 0x5d, 0x6b,
+	0xe2, 0x17, 0xa8, 0xf1, 0xaf, 0xb8, 0x31, 0x9b, 0x50, 0x36, 0x00, 0x13, 0x2f, 0xd2, 0x58, 0x91,
+	0xcd, 0x46, 0xd0, 0xac, 0x74, 0x9e, 0xfc, 0x23, 0xc1, 0x05, 0x9b, 0x9c, 0x0d, 0xa0, 0xeb, 0x18,
+	0xfc, 0x18, 0xad, 0xf7, 0x19, 0x4f, 0xa8, 0xc8, 0x20, 0x25, 0x8f, 0xec, 0x79, 0xad, 0x19, 0xe1,
+	0x87, 0x0c, 0x52, 0xcc, 0x50, 0x55, 0xc2, 0x4f, 0x39, 0x97, 0x10, 0xd3, 0x21, 0xb0, 0x18, 0xa4,
+	0x22, 0x5b, 0xf6, 0xe6, 0x4f, 0xff, 0xfb, 0xe6, 0x3b, 0x3e, 0xf2, 0x5b, 0x17, 0xe8, 0x2e, 0x7d,
+	0x5b, 0x2e, 0xab, 0xe6, 0x12, 0xd4, 0x90, 0xc5, 0xe2, 0x67, 0x5a, 0x6a, 0xf6, 0x6d, 0x77, 0x56,
+	0xce, 0xe8, 0xce, 0x5b, 0xfe, 0x04, 0xed, 0x0b, 0x1e, 0x47, 0x34, 0x57, 0x20, 0xe9, 0x08, 0x0a,
+	0xda, 0x67, 0x49, 0x62, 0x0e, 0x9f, 0x54, 0xed, 0x87, 0xef, 0x1a, 0xf7, 0x5a, 0x81, 0xfc, 0x0e,
+	0x8a, 0x17, 0xde, 0xc2, 0xef, 0xa3, 0xad, 0x28, 0xe1, 0x90, 0x6a, 0xaa, 0xf9, 0x18, 0x44, 0xae,
+	0xc9, 0x8e, 0xcd, 0xfe, 0xc8, 0xa9, 0x57, 0x4e, 0x34, 0x98, 0x1f, 0xa7, 0x19, 0x86, 0x1d, 0xe6,
+	0xd4, 0x19, 0xf6, 0x01, 0x9a, 0xb5, 0xc6, 0x9c, 0xdb, 0xb5, 0xdc, 0x96, 0x97, 0x67, 0x60, 0x13,
+	0x55, 0x7d, 0xbe, 0x4c, 0x8a, 0x49, 0x61, 0x27, 0xac, 0xe6, 0x48, 0xa7, 0x5f, 0x1a, 0xd9, 0x4c,
+	0x59, 0xa9, 0x0d, 0x17, 0xe8, 0xde, 0x52, 0x1b, 0xce, 0xd9, 0x67, 0xa8, 0xc6, 0x53, 0x05, 0x51,
+	0x2e, 0x81, 0xaa, 0x11, 0xcf, 0x4c, 0xdf, 0xf3, 0x7e, 0x41, 0xf6, 0x6d, 0xfd, 0x78, 0xe6, 0x75,
+	0x47, 0x3c, 0xbb, 0xb1, 0x8e, 0x69, 0xdc, 0x88, 0x45, 0x43, 0xa0, 0xbd, 0x22, 0x63, 0xf3, 0x6d,
+	0x70, 0xe0, 0x1a, 0xd7, 0x3a, 0x6d, 0x6b, 0xb8, 0x95, 0xf0, 0x0c, 0xd5, 0xc6, 0x2c, 0xcb, 0x4c,
+	0xd7, 0xca, 0x3c, 0x01, 0x3a, 0x66, 0x3a, 0x1a, 0x82, 0x24, 0xc4, 0xf2, 0xd8, 0x7b, 0x9d, 0x3c,
+	0x81, 0x0b, 0xe7, 0x94, 0xea, 0x74, 0xaf, 0xd1, 0x3a, 0x21, 0x87, 0xe5, 0x3a, 0xbf, 0x36, 0xf2,
+	0x95, 0x4e, 0xf0, 0x19, 0x7a, 0xba, 0x44, 0x4a, 0xe8, 0x4b, 0x50, 0x43, 0xca, 0x53, 0x0d, 0xf2,
+	0x96, 0x25, 0xa4, 0x6e, 0xc3, 0xea, 0xa5, 0xb0, 0x8e, 0x43, 0xce, 0x3d, 0x81, 0x3f, 0x43, 0x64,
+	0x29, 0x85, 0x69, 0x78, 0x69, 0x46, 0x1a, 0x14, 0x79, 0x6c, 0x9b, 0x7d, 0xaf, 0x14, 0x7d, 0xc1,
+	0x26, 0x1d, 0x67, 0xe2, 0xa3, 0xc5, 0xf8, 0xc2, 0x44, 0x43, 0x6a, 0x76, 0x81, 0x22, 0x4f, 0x1a,
+	0x15, 0xb3, 0x54, 0xbc, 0xf3, 0x7c, 0x6e, 0xd4, 0xbf, 0x41, 0x87, 0xf7, 0xee, 0x2d, 0x5c, 0x45,
+	0x95, 0x11, 0x14, 0x7e, 0x21, 0x9b, 0x47, 0x5c, 0x43, 0x0f, 0xdd, 0x3e, 0x70, 0x5b, 0xd8, 0xfd,
+	0xf3, 0xe5, 0x83, 0xcf, 0x83, 0x7a, 0x1b, 0xd5, 0xde, 0x34, 0x06, 0xff, 0x27, 0x47, 0xfb, 0xd3,
+	0x1f, 0x57, 0xdd, 0x60, 0xbd, 0xba, 0x0b, 0x57, 0x7e, 0xbf, 0x0b, 0x57, 0x5e, 0xdf, 0x85, 0xc1,
+	0x2f, 0xd3, 0x30, 0xf8, 0x75, 0x1a, 0x06, 0xbf, 0x4d, 0xc3, 0xe0, 0xd5, 0x34, 0x0c, 0xfe, 0x98,
+	0x86, 0xc1, 0x5f, 0xd3, 0x70, 0xe5, 0xf5, 0x34, 0x0c, 0x5e, 0xfe, 0x19, 0xae, 0xf4, 0x56, 0xed,
+	0xef, 0xc9, 0xc9, 0xdf, 0x03, 0x00, 0xec, 0xb4, 0xdb, 0x67, 0xba, 0x06, 0x00, 0x00,
 }
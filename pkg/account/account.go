@@ -0,0 +1,106 @@
+// Package account verifies 3scale access tokens against the Account Management API, looking up the names of the
+// tenant and service they are used for, so that configuration can be checked and labelled before it is applied.
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	providerEndpoint = "/admin/api/provider.json"
+	serviceEndpoint  = "/admin/api/services/%s.json"
+)
+
+var (
+	// ErrUnauthorized is returned when 3scale rejects the access token
+	ErrUnauthorized = errors.New("access token was rejected by 3scale")
+	// ErrServiceNotFound is returned when the service does not exist or cannot be read with the access token
+	ErrServiceNotFound = errors.New("service was not found in 3scale")
+
+	errNotFound = errors.New("not found")
+)
+
+// Account describes the 3scale tenant an access token belongs to
+type Account struct {
+	// Tenant is the organization name of the tenant
+	Tenant string
+	// ServiceName is the name of the service looked up, empty when no service was requested
+	ServiceName string
+}
+
+type providerResponse struct {
+	Account struct {
+		OrgName string `json:"org_name"`
+	} `json:"account"`
+}
+
+type serviceResponse struct {
+	Service struct {
+		Name string `json:"name"`
+	} `json:"service"`
+}
+
+// Lookup verifies accessToken against the 3scale admin portal at systemURL, returning the tenant it belongs to along
+// with the name of the service serviceID, when set. If client is nil, http.DefaultClient is used.
+func Lookup(client *http.Client, systemURL string, accessToken string, serviceID string) (Account, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	systemURL = strings.TrimSuffix(systemURL, "/")
+
+	var account Account
+	provider := &providerResponse{}
+	if err := get(client, systemURL+providerEndpoint, accessToken, provider); err == errNotFound {
+		return account, fmt.Errorf("%s does not serve the 3scale Account Management API", systemURL)
+	} else if err != nil {
+		return account, err
+	}
+	account.Tenant = provider.Account.OrgName
+
+	if serviceID == "" {
+		return account, nil
+	}
+
+	service := &serviceResponse{}
+	err := get(client, systemURL+fmt.Sprintf(serviceEndpoint, url.PathEscape(serviceID)), accessToken, service)
+	if err == errNotFound {
+		return account, ErrServiceNotFound
+	} else if err != nil {
+		return account, err
+	}
+	account.ServiceName = service.Service.Name
+	return account, nil
+}
+
+// get calls the endpoint with the access token and decodes the response into into
+func get(client *http.Client, endpoint string, accessToken string, into interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint+"?"+url.Values{"access_token": {accessToken}}.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// the error includes the URL, and so the access token, so only the cause is kept
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("unable to reach 3scale at %s - %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices:
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
@@ -0,0 +1,91 @@
+package account
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	const accessToken = "secret-token"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != accessToken {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/admin/api/provider.json":
+			w.Write([]byte(`{"account": {"id": 2, "org_name": "Acme"}}`))
+		case "/admin/api/services/123.json":
+			w.Write([]byte(`{"service": {"id": 123, "name": "Echo API"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	inputs := []struct {
+		name         string
+		url          string
+		token        string
+		serviceID    string
+		expect       Account
+		expectErr    error
+		expectAnyErr bool
+	}{
+		{
+			name:   "Test tenant is looked up",
+			url:    server.URL,
+			token:  accessToken,
+			expect: Account{Tenant: "Acme"},
+		},
+		{
+			name:      "Test tenant and service are looked up",
+			url:       server.URL + "/",
+			token:     accessToken,
+			serviceID: "123",
+			expect:    Account{Tenant: "Acme", ServiceName: "Echo API"},
+		},
+		{
+			name:      "Test rejected token",
+			url:       server.URL,
+			token:     "wrong",
+			expectErr: ErrUnauthorized,
+		},
+		{
+			name:      "Test unknown service",
+			url:       server.URL,
+			token:     accessToken,
+			serviceID: "456",
+			expectErr: ErrServiceNotFound,
+		},
+		{
+			name:         "Test url which is not an admin portal",
+			url:          server.URL + "/elsewhere",
+			token:        accessToken,
+			expectAnyErr: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			account, err := Lookup(nil, input.url, input.token, input.serviceID)
+			if input.expectAnyErr || input.expectErr != nil {
+				if err == nil || (input.expectErr != nil && err != input.expectErr) {
+					t.Errorf("expected error %v but got %v", input.expectErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if account != input.expect {
+				t.Errorf("expected %+v but got %+v", input.expect, account)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package adapterclient provides a client for callers other than Mixer, such as custom gateways, to request
+// authorization from the adapter directly over gRPC
+package adapterclient
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc"
+
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// Request describes the request to be authorized, in the terms of the authorization instance Mixer would send
+type Request struct {
+	// Method and Path of the request, which are matched against the mapping rules of the service
+	Method string
+	Path   string
+	// Service is the 3scale service ID, required unless provided by the handler params
+	Service string
+	// UserKey authenticates the application using the API key pattern
+	UserKey string
+	// AppID and optional AppKey authenticate the application using the application ID pattern
+	AppID  string
+	AppKey string
+	// ClientID authenticates the application for services configured for OpenID Connect
+	ClientID string
+	// Locality of the caller, used to select a regional 3scale backend
+	Locality string
+}
+
+// Response is the authorization decision made by the adapter
+type Response struct {
+	// Allowed is true when the request should be forwarded
+	Allowed bool
+	// Reason describes why the decision was made
+	Reason threescale.DecisionReason
+	// Status is the status returned by the adapter, which carries further detail when the request is denied
+	Status rpc.Status
+}
+
+// Client calls the adapter on behalf of a single handler
+type Client struct {
+	conn    *grpc.ClientConn
+	rpc     authorization.HandleAuthorizationServiceClient
+	handler *types.Any
+}
+
+// Dial connects to the adapter listening at addr. The params are sent with each request in place of the handler
+// Mixer would otherwise provide, so must include the system URL and access token. Further options, such as transport
+// credentials, can be provided via opts, otherwise the connection is insecure.
+func Dial(addr string, params config.Params, opts ...grpc.DialOption) (*Client, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithInsecure()}
+	}
+
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := New(conn, params)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.conn = conn
+	return c, nil
+}
+
+// New creates a client using an existing connection to the adapter. Closing the client does not close conn.
+func New(conn *grpc.ClientConn, params config.Params) (*Client, error) {
+	if params.SystemUrl == "" || params.AccessToken == "" {
+		return nil, errors.New("system url and access token are required")
+	}
+
+	b, err := params.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		rpc:     authorization.NewHandleAuthorizationServiceClient(conn),
+		handler: &types.Any{Value: b},
+	}, nil
+}
+
+// Authorize asks the adapter whether the request should be allowed. An error is only returned when the adapter could
+// not be called or failed unexpectedly, denials are described by the Response.
+func (c *Client) Authorize(ctx context.Context, req Request) (*Response, error) {
+	result, err := c.rpc.HandleAuthorization(ctx, &authorization.HandleAuthorizationRequest{
+		Instance:      NewInstance(req),
+		AdapterConfig: c.handler,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Allowed: result.Status.Code == int32(rpc.OK),
+		Reason:  reasonFromMessage(result.Status.Message),
+		Status:  result.Status,
+	}, nil
+}
+
+// Close the connection to the adapter if it was opened by Dial
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// NewInstance builds the authorization instance for the request, placing credentials in the subject fields and
+// properties the adapter reads them from
+func NewInstance(req Request) *authorization.InstanceMsg {
+	properties := make(map[string]*v1beta1.Value)
+	for key, val := range map[string]string{
+		threescale.AppIDAttributeKey:  req.AppID,
+		threescale.AppKeyAttributeKey: req.AppKey,
+		threescale.OIDCAttributeKey:   req.ClientID,
+	} {
+		if val != "" {
+			properties[key] = stringValue(val)
+		}
+	}
+
+	actionProperties := make(map[string]*v1beta1.Value)
+	if req.Locality != "" {
+		actionProperties[threescale.LocalityAttributeKey] = stringValue(req.Locality)
+	}
+
+	return &authorization.InstanceMsg{
+		Subject: &authorization.SubjectMsg{
+			User:       req.UserKey,
+			Properties: properties,
+		},
+		Action: &authorization.ActionMsg{
+			Method:     req.Method,
+			Path:       req.Path,
+			Service:    req.Service,
+			Properties: actionProperties,
+		},
+	}
+}
+
+func stringValue(val string) *v1beta1.Value {
+	return &v1beta1.Value{Value: &v1beta1.Value_StringValue{StringValue: val}}
+}
+
+// reasonFromMessage extracts the decision reason which the adapter adds to the status message
+func reasonFromMessage(message string) threescale.DecisionReason {
+	prefix := threescale.DecisionReasonAttribute + "="
+	for _, field := range strings.Fields(message) {
+		if strings.HasPrefix(field, prefix) {
+			return threescale.DecisionReason(strings.TrimPrefix(field, prefix))
+		}
+	}
+	return ""
+}
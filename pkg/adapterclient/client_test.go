@@ -0,0 +1,134 @@
+package adapterclient
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
+	"github.com/gogo/googleapis/google/rpc"
+	"google.golang.org/grpc"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+type fakeAdapter struct {
+	result *v1beta1.CheckResult
+	got    *authorization.HandleAuthorizationRequest
+}
+
+func (f *fakeAdapter) HandleAuthorization(ctx context.Context, r *authorization.HandleAuthorizationRequest) (*v1beta1.CheckResult, error) {
+	f.got = r
+	return f.result, nil
+}
+
+func TestClientAuthorize(t *testing.T) {
+	inputs := []struct {
+		name          string
+		status        rpc.Status
+		expectAllowed bool
+		expectReason  threescale.DecisionReason
+	}{
+		{
+			name:          "Test allowed request",
+			status:        rpc.Status{Code: int32(rpc.OK), Message: "threescale.decision_reason=ok"},
+			expectAllowed: true,
+			expectReason:  threescale.ReasonOK,
+		},
+		{
+			name:         "Test denied request",
+			status:       rpc.Status{Code: int32(rpc.RESOURCE_EXHAUSTED), Message: "usage limits are exceeded - threescale.decision_reason=limits threescale.limit_remaining=0"},
+			expectReason: threescale.ReasonLimits,
+		},
+		{
+			name:   "Test status without decision reason",
+			status: rpc.Status{Code: int32(rpc.INTERNAL), Message: "failed"},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			adapter := &fakeAdapter{result: &v1beta1.CheckResult{Status: input.status}}
+			addr, stop := serve(t, adapter)
+			defer stop()
+
+			params := config.Params{SystemUrl: "https://www.fake-system.3scale.net", AccessToken: "any"}
+			c, err := Dial(addr, params)
+			if err != nil {
+				t.Fatalf("unexpected error dialing adapter - %v", err)
+			}
+			defer c.Close()
+
+			resp, err := c.Authorize(context.Background(), Request{Method: "GET", Path: "/test", Service: "123", UserKey: "secret"})
+			if err != nil {
+				t.Fatalf("unexpected error calling adapter - %v", err)
+			}
+
+			if resp.Allowed != input.expectAllowed || resp.Reason != input.expectReason {
+				t.Errorf("unexpected response %+v", resp)
+			}
+
+			got := &config.Params{}
+			if err := got.Unmarshal(adapter.got.AdapterConfig.Value); err != nil || got.SystemUrl != params.SystemUrl {
+				t.Errorf("expected handler params to be sent with the request but got %v", got)
+			}
+
+			if adapter.got.Instance.Subject.User != "secret" || adapter.got.Instance.Action.Service != "123" {
+				t.Errorf("unexpected instance %v", adapter.got.Instance)
+			}
+		})
+	}
+}
+
+func TestNewInvalidParams(t *testing.T) {
+	if _, err := New(nil, config.Params{SystemUrl: "https://www.fake-system.3scale.net"}); err == nil {
+		t.Errorf("expected error when access token is not provided")
+	}
+}
+
+func TestNewInstance(t *testing.T) {
+	instance := NewInstance(Request{
+		Method:   "POST",
+		Path:     "/orders",
+		AppID:    "app",
+		AppKey:   "key",
+		Locality: "us-east1",
+	})
+
+	if instance.Action.Method != "POST" || instance.Action.Path != "/orders" {
+		t.Errorf("unexpected action %v", instance.Action)
+	}
+
+	if instance.Subject.User != "" {
+		t.Errorf("unexpected user key %s", instance.Subject.User)
+	}
+
+	props := instance.Subject.Properties
+	if props[threescale.AppIDAttributeKey].GetStringValue() != "app" || props[threescale.AppKeyAttributeKey].GetStringValue() != "key" {
+		t.Errorf("unexpected subject properties %v", props)
+	}
+
+	if _, ok := props[threescale.OIDCAttributeKey]; ok {
+		t.Errorf("expected unset credentials to be omitted")
+	}
+
+	if instance.Action.Properties[threescale.LocalityAttributeKey].GetStringValue() != "us-east1" {
+		t.Errorf("unexpected action properties %v", instance.Action.Properties)
+	}
+}
+
+// serve the fake adapter on a random port, returning its address and a function to stop the server
+func serve(t *testing.T, adapter *fakeAdapter) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error listening - %v", err)
+	}
+
+	server := grpc.NewServer()
+	authorization.RegisterHandleAuthorizationServiceServer(server, adapter)
+	go server.Serve(listener)
+
+	return listener.Addr().String(), server.Stop
+}
@@ -0,0 +1,86 @@
+// Package admin provides the handlers served on the adapter admin port, either alongside the metrics endpoint or on a
+// dedicated listener with its own TLS and authentication
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// redacted replaces the value of sensitive settings
+const redacted = "[redacted]"
+
+// sensitiveSuffixes identify settings which hold credentials and must never be served
+var sensitiveSuffixes = []string{"token", "secret", "password", "credentials", "salt"}
+
+// ConfigHandler serves the effective configuration of the adapter as JSON, so that the running configuration can be
+// confirmed without access to the pod. Each section is evaluated on request, reflecting any changes made at runtime.
+type ConfigHandler struct {
+	mutex    sync.RWMutex
+	sections map[string]func() interface{}
+}
+
+// NewConfigHandler returns a ConfigHandler without any sections
+func NewConfigHandler() *ConfigHandler {
+	return &ConfigHandler{
+		sections: make(map[string]func() interface{}),
+	}
+}
+
+// Add registers a section of the configuration. The value returned by fn must be JSON serializable
+// and must already have any credentials removed, for example by using Redact.
+func (h *ConfigHandler) Add(name string, fn func() interface{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.sections[name] = fn
+}
+
+// ServeHTTP responds to GET requests with each section of the configuration
+func (h *ConfigHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mutex.RLock()
+	conf := make(map[string]interface{}, len(h.sections))
+	for name, fn := range h.sections {
+		conf[name] = fn()
+	}
+	h.mutex.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(conf)
+}
+
+// Redact returns a copy of settings with the value of any setting which holds credentials replaced
+func Redact(settings map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(settings))
+	for key, val := range settings {
+		if nested, ok := val.(map[string]interface{}); ok {
+			out[key] = Redact(nested)
+			continue
+		}
+
+		if isSensitive(key) && val != nil && val != "" {
+			val = redacted
+		}
+		out[key] = val
+	}
+	return out
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConfigHandler(t *testing.T) {
+	handler := NewConfigHandler()
+	handler.Add("version", func() interface{} {
+		return "v1.0.0"
+	})
+	handler.Add("env", func() interface{} {
+		return Redact(map[string]interface{}{
+			"cache_ttl_seconds":      "300",
+			"self_test_access_token": "abc123",
+		})
+	})
+
+	inputs := []struct {
+		method       string
+		expectStatus int
+	}{
+		{method: http.MethodGet, expectStatus: http.StatusOK},
+		{method: http.MethodPost, expectStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, input := range inputs {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(input.method, "/config", nil))
+
+		if rec.Code != input.expectStatus {
+			t.Errorf("unexpected status for %s - %d", input.method, rec.Code)
+			continue
+		}
+
+		if rec.Code != http.StatusOK {
+			continue
+		}
+
+		var conf struct {
+			Version string            `json:"version"`
+			Env     map[string]string `json:"env"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&conf); err != nil {
+			t.Fatalf("unexpected error decoding response - %v", err)
+		}
+
+		if conf.Version != "v1.0.0" {
+			t.Errorf("unexpected version %s", conf.Version)
+		}
+
+		if conf.Env["cache_ttl_seconds"] != "300" {
+			t.Errorf("expected setting to be served - %v", conf.Env)
+		}
+
+		if conf.Env["self_test_access_token"] != redacted {
+			t.Errorf("expected credentials to be redacted - %v", conf.Env)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	settings := map[string]interface{}{
+		"access_token": "abc123",
+		"empty_token":  "",
+		"shard_count":  3,
+		"metrics_salt": "pepper",
+		"backend": map[string]interface{}{
+			"client_secret": "s3cr3t",
+			"url":           "https://su1.3scale.net",
+		},
+	}
+
+	got := Redact(settings)
+
+	if got["access_token"] != redacted {
+		t.Errorf("expected token to be redacted")
+	}
+
+	if got["empty_token"] != "" {
+		t.Errorf("expected unset token to remain empty")
+	}
+
+	if got["metrics_salt"] != redacted {
+		t.Errorf("expected salt to be redacted")
+	}
+
+	if got["shard_count"] != 3 {
+		t.Errorf("expected non sensitive setting to be unchanged")
+	}
+
+	nested := got["backend"].(map[string]interface{})
+	if nested["client_secret"] != redacted || nested["url"] != "https://su1.3scale.net" {
+		t.Errorf("unexpected nested settings - %v", nested)
+	}
+
+	if settings["access_token"] != "abc123" {
+		t.Errorf("expected original settings to be unmodified")
+	}
+}
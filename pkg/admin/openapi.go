@@ -0,0 +1,235 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// openAPIDocument describes the admin and metrics endpoints in OpenAPI 3. The version and security requirements
+// depend on how the adapter is run, so are set by NewOpenAPIHandler.
+const openAPIDocument = `{
+  "openapi": "3.0.0",
+  "info": {
+    "title": "3scale Istio Adapter admin API",
+    "description": "Operational endpoints of the 3scale Istio Adapter. The metrics endpoint is served on METRICS_PORT, while the others are served alongside it or on ADMIN_PORT when set."
+  },
+  "paths": {
+    "/config": {
+      "get": {
+        "summary": "Effective configuration, with credentials redacted",
+        "responses": {
+          "200": {"description": "Configuration sections by name", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    },
+    "/health": {
+      "get": {
+        "summary": "Result of the last self-test against 3scale. Only served when SELF_TEST_INTERVAL_SECONDS is set",
+        "responses": {
+          "200": {"description": "The last self-test succeeded", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "503": {"description": "The last self-test failed", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/healthz": {
+      "get": {
+        "summary": "Liveness of the adapter. Served on PROBE_PORT without authentication when set",
+        "responses": {
+          "200": {"description": "The gRPC server is serving or starting", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "503": {"description": "The gRPC server has stopped", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/readyz": {
+      "get": {
+        "summary": "Readiness of the adapter to authorize requests, including access to proxy configurations. Served on PROBE_PORT without authentication when set",
+        "responses": {
+          "200": {"description": "The adapter can authorize requests", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "503": {"description": "The failing checks", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/ready": {
+      "get": {
+        "summary": "Readiness of the adapter to serve authorization requests",
+        "responses": {
+          "200": {"description": "The adapter is ready", "content": {"text/plain": {"schema": {"type": "string"}}}},
+          "503": {"description": "The adapter is starting", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/logging/sampling": {
+      "get": {
+        "summary": "Sampling applied to identical log messages",
+        "responses": {"200": {"$ref": "#/components/responses/LogSampling"}}
+      },
+      "put": {
+        "summary": "Change the sampling applied to identical log messages, only accepted on an admin listener which authenticates clients",
+        "parameters": [
+          {"name": "first", "in": "query", "description": "Identical messages logged per interval before sampling", "schema": {"type": "integer"}},
+          {"name": "interval_seconds", "in": "query", "description": "Sampling interval, where zero disables sampling", "schema": {"type": "integer"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/LogSampling"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/tuning": {
+      "get": {
+        "summary": "Options which can be tuned at runtime",
+        "responses": {"200": {"$ref": "#/components/responses/Tuning"}}
+      },
+      "put": {
+        "summary": "Change the options which can be tuned at runtime, only accepted on an admin listener which authenticates clients",
+        "parameters": [
+          {"name": "cache_ttl_seconds", "in": "query", "description": "Period proxy configurations are cached for", "schema": {"type": "integer"}},
+          {"name": "cache_entries_max", "in": "query", "description": "Maximum number of proxy configurations cached", "schema": {"type": "integer"}},
+          {"name": "fail_open", "in": "query", "description": "Allow requests when 3scale cannot be reached", "schema": {"type": "boolean"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Tuning"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "403": {"$ref": "#/components/responses/Error"},
+          "409": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/endpoints": {
+      "get": {
+        "summary": "Health scores of the 3scale hosts requests have been sent to, from the success and latency of recent requests",
+        "responses": {
+          "200": {"description": "Health of each host, ordered by host", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/EndpointHealth"}}}}}
+        }
+      }
+    },
+    "/usage": {
+      "get": {
+        "summary": "Requests allowed, denied and limited by service and application. Only served when USAGE_REPORT_ENABLED is set",
+        "parameters": [
+          {"name": "minutes", "in": "query", "description": "Number of recent minutes to summarise, from 1 to 60, defaulting to 5", "schema": {"type": "integer"}}
+        ],
+        "responses": {
+          "200": {"description": "Usage over the window", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Usage"}}}},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/metrics": {
+      "get": {
+        "summary": "Prometheus metrics. Served on METRICS_PORT when REPORT_METRICS is set",
+        "security": [],
+        "responses": {
+          "200": {"description": "Metrics in the Prometheus text format", "content": {"text/plain": {"schema": {"type": "string"}}}}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "responses": {
+          "200": {"description": "OpenAPI document", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer", "description": "The token set by ADMIN_AUTH_TOKEN"}
+    },
+    "schemas": {
+      "LogSampling": {
+        "type": "object",
+        "properties": {
+          "first": {"type": "integer"},
+          "interval_seconds": {"type": "integer"}
+        }
+      },
+      "EndpointHealth": {
+        "type": "object",
+        "properties": {
+          "host": {"type": "string"},
+          "score": {"type": "number", "description": "Between 0 and 1, the success rate reduced in proportion to average latency above one second"},
+          "success_rate": {"type": "number"},
+          "latency_ms": {"type": "number"},
+          "requests": {"type": "integer"},
+          "last_failure": {"type": "string", "format": "date-time", "description": "Absent until a request has failed"}
+        }
+      },
+      "Usage": {
+        "type": "object",
+        "properties": {
+          "window_minutes": {"type": "integer"},
+          "usage": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "service_id": {"type": "string"},
+                "application": {"type": "string", "description": "Salted hash of the application credentials, empty when none were provided"},
+                "allowed": {"type": "integer"},
+                "denied": {"type": "integer"},
+                "limited": {"type": "integer"}
+              }
+            }
+          }
+        }
+      },
+      "Tuning": {
+        "type": "object",
+        "properties": {
+          "cache_ttl_seconds": {"type": "integer", "description": "Absent when the cache is owned by the authorizer"},
+          "cache_entries_max": {"type": "integer", "description": "Absent when the cache is owned by the authorizer"},
+          "fail_open": {"type": "boolean"}
+        }
+      }
+    },
+    "responses": {
+      "LogSampling": {
+        "description": "Sampling applied",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/LogSampling"}}}
+      },
+      "Tuning": {
+        "description": "Options applied",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Tuning"}}}
+      },
+      "Error": {
+        "description": "The request was rejected and nothing was changed",
+        "content": {"text/plain": {"schema": {"type": "string"}}}
+      }
+    }
+  }
+}`
+
+type openAPIHandler struct {
+	document []byte
+}
+
+// NewOpenAPIHandler returns a handler serving an OpenAPI description of the admin and metrics endpoints, so that
+// clients and dashboards can be generated for the adapter. The bearer security scheme is required when token is true.
+func NewOpenAPIHandler(version string, token bool) http.Handler {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(openAPIDocument), &doc); err != nil {
+		panic("invalid OpenAPI document - " + err.Error())
+	}
+
+	doc["info"].(map[string]interface{})["version"] = version
+	if token {
+		doc["security"] = []map[string][]string{{"bearer": {}}}
+	}
+
+	b, _ := json.MarshalIndent(doc, "", "  ")
+	return &openAPIHandler{document: b}
+}
+
+// ServeHTTP responds to GET requests with the OpenAPI document
+func (h *openAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(h.document)
+}
@@ -0,0 +1,40 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	for _, token := range []bool{false, true} {
+		w := httptest.NewRecorder()
+		NewOpenAPIHandler("1.2.3", token).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+		var doc struct {
+			Info struct {
+				Version string `json:"version"`
+			} `json:"info"`
+			Paths    map[string]interface{} `json:"paths"`
+			Security []interface{}          `json:"security"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+			t.Fatalf("unexpected error decoding document - %v", err)
+		}
+
+		if doc.Info.Version != "1.2.3" || doc.Paths["/tuning"] == nil || doc.Paths["/metrics"] == nil {
+			t.Errorf("unexpected document %+v", doc)
+		}
+
+		if (len(doc.Security) > 0) != token {
+			t.Errorf("expected bearer security to be required only with a token but got %v", doc.Security)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	NewOpenAPIHandler("1.2.3", false).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/openapi.json", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 but got %d", w.Code)
+	}
+}
@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Check is a named condition a probe endpoint depends on, which returns an error while it is not met
+type Check struct {
+	Name string
+	Fn   func() error
+}
+
+type probeHandler struct {
+	checks []Check
+}
+
+// NewProbeHandler returns a handler for Kubernetes liveness or readiness probes. It responds with 200 when every check
+// passes, and with 503 listing the failing checks otherwise.
+func NewProbeHandler(checks ...Check) http.Handler {
+	return &probeHandler{checks: checks}
+}
+
+// ServeHTTP runs the checks on each request, so the response reflects the current state
+func (h *probeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var failed []string
+	for _, check := range h.checks {
+		if err := check.Fn(); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, err.Error()))
+		}
+	}
+
+	if len(failed) > 0 {
+		http.Error(w, strings.Join(failed, "\n"), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,34 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeHandler(t *testing.T) {
+	var configErr error
+	h := NewProbeHandler(
+		Check{Name: "grpc", Fn: func() error { return nil }},
+		Check{Name: "config", Fn: func() error { return configErr }},
+	)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 when every check passes but got %d", w.Code)
+	}
+
+	configErr = errors.New("no proxy config fetched")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when a check fails but got %d", w.Code)
+	}
+
+	if body := w.Body.String(); !strings.Contains(body, "config: no proxy config fetched") || strings.Contains(body, "grpc") {
+		t.Errorf("expected only the failing check to be listed but got %q", body)
+	}
+}
@@ -0,0 +1,161 @@
+package admin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/secrets"
+)
+
+// ServerConfig configures a listener dedicated to the admin endpoints, so that they never share a port or certificate
+// with the gRPC server Mixer connects to or with the metrics endpoint.
+type ServerConfig struct {
+	// Port to listen on, where zero selects any free port
+	Port int
+	// CertFile and KeyFile are PEM encoded and enable TLS when both are provided
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is optional and requires clients to present a certificate signed by one of the PEM encoded CAs it
+	// contains. It requires TLS to be enabled.
+	ClientCAFile string
+	// Token is optional and requires clients to present it as a bearer token in the Authorization header
+	Token string
+}
+
+// Server serves the admin endpoints on their own listener
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewServer validates conf, loads any certificates and starts listening for handler.
+// Connections are not accepted until Serve is called.
+func NewServer(conf ServerConfig, handler http.Handler) (*Server, error) {
+	if (conf.CertFile == "") != (conf.KeyFile == "") {
+		return nil, fmt.Errorf("both a certificate and key are required to serve admin endpoints over TLS")
+	}
+
+	if conf.ClientCAFile != "" && conf.CertFile == "" {
+		return nil, fmt.Errorf("a certificate and key are required to verify admin client certificates")
+	}
+
+	tlsConfig, err := conf.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Token != "" {
+		handler = requireToken(conf.Token, handler)
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", conf.Port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for admin endpoints - %v", err)
+	}
+
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	return &Server{
+		listener: listener,
+		server:   &http.Server{Handler: handler},
+	}, nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve accepts connections until the server is closed, returning http.ErrServerClosed once closed
+func (s *Server) Serve() error {
+	return s.server.Serve(s.listener)
+}
+
+// Close stops the server immediately, closing the listener and any open connections
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// Authenticated reports whether clients are required to present a certificate or bearer token
+func (conf ServerConfig) Authenticated() bool {
+	return conf.ClientCAFile != "" || conf.Token != ""
+}
+
+// tlsConfig returns the TLS configuration for the listener, or nil if TLS is not enabled
+func (conf ServerConfig) tlsConfig() (*tls.Config, error) {
+	if conf.CertFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin certificate - %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if conf.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := ioutil.ReadFile(conf.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin client CA - %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in admin client CA file %s", conf.ClientCAFile)
+	}
+
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// requireToken rejects requests which do not present token as a bearer token
+func requireToken(token string, handler http.Handler) http.Handler {
+	const prefix = "Bearer "
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, prefix) || !secrets.Equal(strings.TrimPrefix(auth, prefix), token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// ReadOnly rejects requests which could change the state of the adapter, that is any but GET and HEAD requests, with
+// a 403. It guards endpoints which accept changes wherever they are served without authenticating clients.
+func ReadOnly(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "changes are only accepted on an admin listener which authenticates clients", http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Forbidden rejects every request with a 403. It replaces endpoints which expose the configuration or traffic of the
+// adapter wherever they would be served without authenticating clients.
+func Forbidden() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "only served on an admin listener which authenticates clients", http.StatusForbidden)
+	})
+}
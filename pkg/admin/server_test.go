@@ -0,0 +1,266 @@
+package admin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewServerInvalidConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir - %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, nil)
+	caFile := ca.writeCert(t, dir, "ca")
+
+	inputs := []struct {
+		name string
+		conf ServerConfig
+	}{
+		{
+			name: "Test certificate without key",
+			conf: ServerConfig{CertFile: caFile},
+		},
+		{
+			name: "Test client CA without TLS",
+			conf: ServerConfig{ClientCAFile: caFile},
+		},
+		{
+			name: "Test missing certificate file",
+			conf: ServerConfig{CertFile: filepath.Join(dir, "missing"), KeyFile: filepath.Join(dir, "missing")},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			if _, err := NewServer(input.conf, http.NotFoundHandler()); err == nil {
+				t.Errorf("expected error for invalid config")
+			}
+		})
+	}
+}
+
+func TestServerToken(t *testing.T) {
+	s, err := NewServer(ServerConfig{Token: "secret"}, okHandler())
+	if err != nil {
+		t.Fatalf("unexpected error creating server - %v", err)
+	}
+	go s.Serve()
+	defer s.Close()
+
+	inputs := map[string]int{
+		"":              http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	}
+
+	for auth, expectStatus := range inputs {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+s.Addr().String()+"/config", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error calling admin server - %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != expectStatus {
+			t.Errorf("unexpected status for authorization %q, wanted %d but got %d", auth, expectStatus, resp.StatusCode)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	handler := ReadOnly(okHandler())
+
+	inputs := map[string]int{
+		http.MethodGet:    http.StatusOK,
+		http.MethodHead:   http.StatusOK,
+		http.MethodPut:    http.StatusForbidden,
+		http.MethodPost:   http.StatusForbidden,
+		http.MethodDelete: http.StatusForbidden,
+	}
+
+	for method, expectStatus := range inputs {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, "/tuning?fail_open=true", nil))
+		if w.Code != expectStatus {
+			t.Errorf("unexpected status for %s, wanted %d but got %d", method, expectStatus, w.Code)
+		}
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	w := httptest.NewRecorder()
+	Forbidden().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("unexpected status, wanted %d but got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestServerClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp dir - %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, nil)
+	server := newTestCert(t, ca)
+	client := newTestCert(t, ca)
+
+	s, err := NewServer(ServerConfig{
+		CertFile:     server.writeCert(t, dir, "server"),
+		KeyFile:      server.writeKey(t, dir, "server"),
+		ClientCAFile: ca.writeCert(t, dir, "ca"),
+	}, okHandler())
+	if err != nil {
+		t.Fatalf("unexpected error creating server - %v", err)
+	}
+	go s.Serve()
+	defer s.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	inputs := []struct {
+		name         string
+		certificates []tls.Certificate
+		expectErr    bool
+	}{
+		{
+			name:      "Test client without certificate is rejected",
+			expectErr: true,
+		},
+		{
+			name:         "Test client with certificate signed by CA is accepted",
+			certificates: []tls.Certificate{client.tlsCertificate()},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			c := &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: input.certificates},
+				},
+			}
+
+			port := s.Addr().(*net.TCPAddr).Port
+			resp, err := c.Get(fmt.Sprintf("https://localhost:%d/config", port))
+			if input.expectErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Errorf("expected handshake to fail")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error calling admin server - %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("unexpected status %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+type testCert struct {
+	cert *x509.Certificate
+	der  []byte
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate for localhost signed by parent, or a self signed CA if parent is nil
+func newTestCert(t *testing.T, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key - %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate - %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unexpected error parsing certificate - %v", err)
+	}
+
+	return &testCert{cert: cert, der: der, key: key}
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func (c *testCert) writeCert(t *testing.T, dir, name string) string {
+	return writePEM(t, filepath.Join(dir, name+".crt"), "CERTIFICATE", c.der)
+}
+
+func (c *testCert) writeKey(t *testing.T, dir, name string) string {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("unexpected error marshalling key - %v", err)
+	}
+	return writePEM(t, filepath.Join(dir, name+".key"), "EC PRIVATE KEY", der)
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) string {
+	t.Helper()
+
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("unexpected error writing %s - %v", path, err)
+	}
+	return path
+}
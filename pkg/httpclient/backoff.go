@@ -0,0 +1,187 @@
+// Package httpclient provides the transports used by the HTTP client which calls the 3scale APIs
+
+package httpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// systemAPIPrefix is the path prefix shared by the 3scale system (Porta) APIs
+	systemAPIPrefix = "/admin/api/"
+
+	retryAfterHeader = "Retry-After"
+
+	// DefaultBackoff is applied when 3scale system rate limits us without providing a Retry-After header
+	DefaultBackoff = time.Minute
+	// DefaultMaxBackoff is the upper limit for the backoff requested by 3scale system
+	DefaultMaxBackoff = time.Minute * 10
+)
+
+// BackoffConfig controls how rate limiting responses from 3scale system are handled
+type BackoffConfig struct {
+	// DefaultBackoff is applied when a 429 response does not provide a valid Retry-After header
+	DefaultBackoff time.Duration
+	// MaxBackoff caps the backoff requested by 3scale system
+	MaxBackoff time.Duration
+	// ThrottledCB is called when a host enters and leaves the throttled state
+	ThrottledCB func(host string, throttled bool)
+}
+
+// BackoffTransport is a http.RoundTripper which honours rate limiting (429) responses from 3scale system.
+// Once a host has rate limited us, any further request to the system API of that host is short-circuited with a
+// synthetic 429 response until the requested backoff has expired. Since the transport is shared by every user of
+// the client, this includes the background refresh of the system cache.
+type BackoffTransport struct {
+	next           http.RoundTripper
+	conf           BackoffConfig
+	mutex          sync.RWMutex
+	throttledUntil map[string]time.Time
+	now            func() time.Time
+}
+
+// NewBackoffTransport wraps the provided http.RoundTripper with a BackoffTransport.
+// If next is nil, http.DefaultTransport is used.
+func NewBackoffTransport(next http.RoundTripper, conf BackoffConfig) *BackoffTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if conf.DefaultBackoff <= 0 {
+		conf.DefaultBackoff = DefaultBackoff
+	}
+
+	if conf.MaxBackoff <= 0 {
+		conf.MaxBackoff = DefaultMaxBackoff
+	}
+
+	return &BackoffTransport{
+		next:           next,
+		conf:           conf,
+		throttledUntil: make(map[string]time.Time),
+		now:            time.Now,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *BackoffTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isSystemRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	host := req.URL.Host
+	if remaining := t.remaining(host); remaining > 0 {
+		return throttledResponse(req, remaining), nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		return resp, err
+	}
+
+	t.throttle(host, t.backoffFrom(resp.Header.Get(retryAfterHeader)))
+	return resp, nil
+}
+
+// Throttled returns true if requests to the provided host are currently being held back
+func (t *BackoffTransport) Throttled(host string) bool {
+	return t.remaining(host) > 0
+}
+
+func (t *BackoffTransport) remaining(host string) time.Duration {
+	t.mutex.RLock()
+	until, ok := t.throttledUntil[host]
+	t.mutex.RUnlock()
+
+	if !ok {
+		return 0
+	}
+
+	remaining := until.Sub(t.now())
+	if remaining <= 0 {
+		t.release(host, until)
+		return 0
+	}
+	return remaining
+}
+
+func (t *BackoffTransport) throttle(host string, backoff time.Duration) {
+	until := t.now().Add(backoff)
+
+	t.mutex.Lock()
+	t.throttledUntil[host] = until
+	t.mutex.Unlock()
+
+	if t.conf.ThrottledCB != nil {
+		t.conf.ThrottledCB(host, true)
+	}
+
+	time.AfterFunc(backoff, func() {
+		t.release(host, until)
+	})
+}
+
+// release clears the throttled state for the host, unless it has since been throttled again
+func (t *BackoffTransport) release(host string, until time.Time) {
+	t.mutex.Lock()
+	current, ok := t.throttledUntil[host]
+	if !ok || !current.Equal(until) {
+		t.mutex.Unlock()
+		return
+	}
+	delete(t.throttledUntil, host)
+	t.mutex.Unlock()
+
+	if t.conf.ThrottledCB != nil {
+		t.conf.ThrottledCB(host, false)
+	}
+}
+
+// backoffFrom parses the value of a Retry-After header, which can be provided as
+// either a number of seconds or a http date, falling back to the default backoff
+func (t *BackoffTransport) backoffFrom(retryAfter string) time.Duration {
+	backoff := t.conf.DefaultBackoff
+
+	if retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+			backoff = time.Duration(seconds) * time.Second
+		} else if date, err := http.ParseTime(retryAfter); err == nil && date.After(t.now()) {
+			backoff = date.Sub(t.now())
+		}
+	}
+
+	if backoff > t.conf.MaxBackoff {
+		backoff = t.conf.MaxBackoff
+	}
+	return backoff
+}
+
+func isSystemRequest(req *http.Request) bool {
+	return req.URL != nil && strings.HasPrefix(req.URL.Path, systemAPIPrefix)
+}
+
+func throttledResponse(req *http.Request, remaining time.Duration) *http.Response {
+	retryAfter := int(remaining / time.Second)
+	if remaining%time.Second != 0 {
+		retryAfter++
+	}
+
+	header := make(http.Header)
+	header.Set(retryAfterHeader, strconv.Itoa(retryAfter))
+
+	return &http.Response{
+		Status:     "429 Too Many Requests",
+		StatusCode: http.StatusTooManyRequests,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+}
@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffTransport(t *testing.T) {
+	inputs := []struct {
+		name              string
+		path              string
+		retryAfter        string
+		expectThrottled   bool
+		expectServerCalls int32
+	}{
+		{
+			name:              "Test system 429 with Retry-After short circuits subsequent requests",
+			path:              "/admin/api/services/123/proxy/configs/production/latest.json",
+			retryAfter:        "30",
+			expectThrottled:   true,
+			expectServerCalls: 1,
+		},
+		{
+			name:              "Test system 429 without Retry-After applies default backoff",
+			path:              "/admin/api/services/123/proxy/configs/production/latest.json",
+			expectThrottled:   true,
+			expectServerCalls: 1,
+		},
+		{
+			name:              "Test backend 429 is not treated as system rate limiting",
+			path:              "/transactions/authrep.xml",
+			retryAfter:        "30",
+			expectThrottled:   false,
+			expectServerCalls: 2,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				if input.retryAfter != "" {
+					w.Header().Set(retryAfterHeader, input.retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+			}))
+			defer server.Close()
+
+			var throttledHost string
+			transport := NewBackoffTransport(nil, BackoffConfig{
+				ThrottledCB: func(host string, throttled bool) {
+					if throttled {
+						throttledHost = host
+					}
+				},
+			})
+			c := &http.Client{Transport: transport}
+
+			for i := 0; i < 2; i++ {
+				resp, err := c.Get(server.URL + input.path)
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusTooManyRequests {
+					t.Errorf("expected status code 429 but got %d", resp.StatusCode)
+				}
+			}
+
+			if calls != input.expectServerCalls {
+				t.Errorf("expected %d calls to server but got %d", input.expectServerCalls, calls)
+			}
+
+			host := server.Listener.Addr().String()
+			if transport.Throttled(host) != input.expectThrottled {
+				t.Errorf("unexpected throttled state for host %s", host)
+			}
+
+			if input.expectThrottled && throttledHost != host {
+				t.Errorf("expected callback for host %s but got %s", host, throttledHost)
+			}
+		})
+	}
+}
+
+func TestBackoffTransportRelease(t *testing.T) {
+	released := make(chan struct{})
+	transport := NewBackoffTransport(nil, BackoffConfig{
+		ThrottledCB: func(host string, throttled bool) {
+			if !throttled {
+				close(released)
+			}
+		},
+	})
+
+	transport.throttle("example.com", time.Millisecond*10)
+	if !transport.Throttled("example.com") {
+		t.Errorf("expected host to be throttled")
+	}
+
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatalf("expected host to be released after backoff expired")
+	}
+
+	if transport.Throttled("example.com") {
+		t.Errorf("expected host to no longer be throttled")
+	}
+}
+
+func TestBackoffFrom(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	transport := NewBackoffTransport(nil, BackoffConfig{
+		DefaultBackoff: time.Second * 5,
+		MaxBackoff:     time.Minute,
+	})
+	transport.now = func() time.Time {
+		return now
+	}
+
+	inputs := []struct {
+		retryAfter string
+		expect     time.Duration
+	}{
+		{retryAfter: "", expect: time.Second * 5},
+		{retryAfter: "invalid", expect: time.Second * 5},
+		{retryAfter: "20", expect: time.Second * 20},
+		{retryAfter: "3600", expect: time.Minute},
+		{retryAfter: now.Add(time.Second * 30).Format(http.TimeFormat), expect: time.Second * 30},
+		{retryAfter: now.Add(-time.Second * 30).Format(http.TimeFormat), expect: time.Second * 5},
+	}
+
+	for _, input := range inputs {
+		if backoff := transport.backoffFrom(input.retryAfter); backoff != input.expect {
+			t.Errorf("unexpected backoff for %q, wanted %s but got %s", input.retryAfter, input.expect, backoff)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Stage identifies the part of the authorization pipeline a request to 3scale is made for
+type Stage string
+
+const (
+	// SystemStage fetches configuration from 3scale system
+	SystemStage Stage = "system"
+	// BackendStage authorizes and reports usage against 3scale backend
+	BackendStage Stage = "backend"
+
+	// backendPathPrefix is shared by every 3scale backend endpoint used by the adapter
+	backendPathPrefix = "/transactions"
+)
+
+// BudgetConfig sets the latency budget for each stage of the pipeline. A zero budget leaves the stage bounded only
+// by the timeout of the http.Client.
+type BudgetConfig struct {
+	System  time.Duration
+	Backend time.Duration
+	// ExceededCB is optional and called each time a request is cancelled for exhausting the budget of its stage
+	ExceededCB func(stage Stage, host string)
+}
+
+// BudgetTransport is a http.RoundTripper which bounds each request to 3scale by the latency budget of its stage,
+// so that a slow system fetch or backend call fails within a known time rather than consuming the whole client timeout.
+// The budget covers any retries and hedges made by the transports it wraps, as well as reading the response body.
+type BudgetTransport struct {
+	next http.RoundTripper
+	conf BudgetConfig
+}
+
+// NewBudgetTransport wraps the provided http.RoundTripper with a BudgetTransport.
+// If next is nil, http.DefaultTransport is used.
+func NewBudgetTransport(next http.RoundTripper, conf BudgetConfig) *BudgetTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &BudgetTransport{
+		next: next,
+		conf: conf,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *BudgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	stage := stageOf(req)
+	budget := t.budget(stage)
+	if budget <= 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), budget)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+
+		// only attribute the failure to the budget where the caller had not already given up on the request
+		if ctx.Err() == context.DeadlineExceeded && req.Context().Err() == nil {
+			if t.conf.ExceededCB != nil {
+				t.conf.ExceededCB(stage, req.URL.Host)
+			}
+			return nil, fmt.Errorf("%s request exceeded its budget of %s - %v", stage, budget, err)
+		}
+		return nil, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *BudgetTransport) budget(stage Stage) time.Duration {
+	if stage == BackendStage {
+		return t.conf.Backend
+	}
+	return t.conf.System
+}
+
+// stageOf identifies the stage of a request from its path, since system and backend may share a host
+func stageOf(req *http.Request) Stage {
+	if strings.HasPrefix(req.URL.Path, backendPathPrefix) {
+		return BackendStage
+	}
+	return SystemStage
+}
@@ -0,0 +1,93 @@
+package httpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBudgetTransport(t *testing.T) {
+	inputs := []struct {
+		name          string
+		path          string
+		conf          BudgetConfig
+		expectErr     bool
+		expectStage   Stage
+		expectTimeout bool
+	}{
+		{
+			name:          "Test slow backend call exceeds its budget",
+			path:          "/transactions/authrep.xml",
+			conf:          BudgetConfig{System: time.Second * 5, Backend: time.Millisecond * 20},
+			expectErr:     true,
+			expectStage:   BackendStage,
+			expectTimeout: true,
+		},
+		{
+			name:          "Test slow system fetch exceeds its budget",
+			path:          "/admin/api/services/123/proxy/configs/production/latest.json",
+			conf:          BudgetConfig{System: time.Millisecond * 20, Backend: time.Second * 5},
+			expectErr:     true,
+			expectStage:   SystemStage,
+			expectTimeout: true,
+		},
+		{
+			name: "Test call within its budget succeeds",
+			path: "/transactions/authrep.xml",
+			conf: BudgetConfig{System: time.Millisecond * 20, Backend: time.Second * 5},
+		},
+		{
+			name: "Test stage without a budget is unbounded",
+			path: "/transactions/authrep.xml",
+			conf: BudgetConfig{System: time.Millisecond * 20},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Millisecond * 100):
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			var exceeded []Stage
+			input.conf.ExceededCB = func(stage Stage, host string) {
+				exceeded = append(exceeded, stage)
+			}
+
+			client := &http.Client{Transport: NewBudgetTransport(nil, input.conf)}
+			resp, err := client.Get(server.URL + input.path)
+
+			if input.expectErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatalf("expected request to be cancelled")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error - %v", err)
+				}
+
+				b, err := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil || string(b) != "ok" {
+					t.Errorf("expected body to be readable within the budget but got %q, %v", b, err)
+				}
+			}
+
+			if input.expectTimeout {
+				if len(exceeded) != 1 || exceeded[0] != input.expectStage {
+					t.Errorf("expected %s budget to be reported exceeded but got %v", input.expectStage, exceeded)
+				}
+			} else if len(exceeded) != 0 {
+				t.Errorf("unexpected report of exceeded budget %v", exceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// backendOptionsHeader is the header 3scale backend reads the extensions enabled for a request from
+const backendOptionsHeader = "3scale-options"
+
+type extensionsKey struct {
+	origin    string
+	serviceID string
+}
+
+// ExtensionsTransport is a http.RoundTripper which enables 3scale backend extensions for the requests of services
+// they have been set for, by sending the options header along with each request to the backend of the service.
+// Services are identified by the service_id query parameter, which 3scale backend requires of every request.
+type ExtensionsTransport struct {
+	next http.RoundTripper
+	*extensionsOptions
+}
+
+// extensionsOptions holds the options set for each service, which may be shared by several transports
+type extensionsOptions struct {
+	mutex   sync.RWMutex
+	options map[extensionsKey]string
+}
+
+// NewExtensionsTransport wraps the provided http.RoundTripper with an ExtensionsTransport which has no extensions set.
+// If next is nil, http.DefaultTransport is used.
+func NewExtensionsTransport(next http.RoundTripper) *ExtensionsTransport {
+	return (&ExtensionsTransport{
+		extensionsOptions: &extensionsOptions{options: make(map[extensionsKey]string)},
+	}).Wrap(next)
+}
+
+// Wrap returns an ExtensionsTransport wrapping the provided http.RoundTripper which shares the options of t, so that
+// the extensions set for a service apply to requests sent via either. If next is nil, http.DefaultTransport is used.
+func (t *ExtensionsTransport) Wrap(next http.RoundTripper) *ExtensionsTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &ExtensionsTransport{
+		next:              next,
+		extensionsOptions: t.extensionsOptions,
+	}
+}
+
+// SetOptions sets the options sent with subsequent requests for the service to the 3scale backend at endpoint,
+// replacing any set before. Empty options stop sending the header. It does not block for longer than it takes to
+// update the options, so may be called on every request.
+func (t *ExtensionsTransport) SetOptions(endpoint string, serviceID string, options string) error {
+	e, err := url.Parse(endpoint)
+	if err != nil || e.Host == "" {
+		return fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+
+	key := extensionsKey{origin: origin(e), serviceID: serviceID}
+	t.mutex.RLock()
+	current := t.options[key]
+	t.mutex.RUnlock()
+	if current == options {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if options == "" {
+		delete(t.options, key)
+		return nil
+	}
+	t.options[key] = options
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ExtensionsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mutex.RLock()
+	empty := len(t.options) == 0
+	t.mutex.RUnlock()
+	if empty {
+		return t.next.RoundTrip(req)
+	}
+
+	key := extensionsKey{origin: origin(req.URL), serviceID: req.URL.Query().Get("service_id")}
+	t.mutex.RLock()
+	options, ok := t.options[key]
+	t.mutex.RUnlock()
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request it was given
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+	r.Header.Set(backendOptionsHeader, options)
+	return t.next.RoundTrip(r)
+}
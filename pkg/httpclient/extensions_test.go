@@ -0,0 +1,64 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtensionsTransport(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(backendOptionsHeader))
+	}))
+	defer server.Close()
+
+	transport := NewExtensionsTransport(nil)
+	if err := transport.SetOptions(server.URL, "123", "limit_headers=1"); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if err := transport.SetOptions("not a url", "123", "limit_headers=1"); err == nil {
+		t.Errorf("expected error for an invalid endpoint")
+	}
+
+	c := &http.Client{Transport: transport}
+	get := func(serviceID string) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/transactions/authrep.xml?service_id="+serviceID, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+		resp.Body.Close()
+
+		if req.Header.Get(backendOptionsHeader) != "" {
+			t.Errorf("expected the request provided not to be modified")
+		}
+	}
+
+	get("123")
+	get("456")
+
+	transport.SetOptions(server.URL, "123", "")
+	get("123")
+
+	wrapped := &http.Client{Transport: transport.Wrap(nil)}
+	transport.SetOptions(server.URL, "456", "rejection_reason_header=1")
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/transactions/authrep.xml?service_id=456", nil)
+	resp, err := wrapped.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	resp.Body.Close()
+
+	expect := []string{"limit_headers=1", "", "", "rejection_reason_header=1"}
+	if len(received) != len(expect) {
+		t.Fatalf("expected %d requests but got %d", len(expect), len(received))
+	}
+
+	for i := range expect {
+		if received[i] != expect[i] {
+			t.Errorf("unexpected options for request %d, wanted %q but got %q", i, expect[i], received[i])
+		}
+	}
+}
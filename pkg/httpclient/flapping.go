@@ -0,0 +1,230 @@
+package httpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFlapThreshold is the number of consecutive changes between success and failure after which a service is degraded
+	DefaultFlapThreshold = 4
+	// DefaultFlapBackoff is the period fetches are first held back for once a service is degraded
+	DefaultFlapBackoff = time.Minute * 5
+	// DefaultFlapMaxBackoff is the upper limit for the period fetches are held back for
+	DefaultFlapMaxBackoff = time.Hour
+)
+
+// proxyConfigPath matches the 3scale system endpoint serving the proxy configuration of a service
+var proxyConfigPath = regexp.MustCompile(`^/admin/api/services/([^/]+)/proxy/configs/`)
+
+// FlapConfig controls how services whose proxy configuration fetches flap are handled
+type FlapConfig struct {
+	// Threshold is the number of consecutive changes in outcome after which a service is degraded
+	Threshold int
+	// Backoff is the period fetches are held back for when a service is first degraded.
+	// It doubles each time the service flaps again before it has recovered.
+	Backoff time.Duration
+	// MaxBackoff caps the period fetches are held back for
+	MaxBackoff time.Duration
+	// DegradedCB is optional and called when a service enters and leaves the degraded state
+	DegradedCB func(host string, serviceID string, degraded bool)
+}
+
+// DegradedService describes a service whose proxy configuration fetches have been flapping
+type DegradedService struct {
+	Host      string    `json:"host"`
+	ServiceID string    `json:"service_id"`
+	HeldUntil time.Time `json:"held_until"`
+}
+
+// FlapTransport is a http.RoundTripper which detects services whose proxy configuration fetches from 3scale system
+// alternate between success and failure. Once the outcome has changed Threshold times in a row, the service is
+// degraded and further fetches are short-circuited with a synthetic 503 response until the backoff has expired,
+// extending the refresh interval of the service rather than repeatedly logging and calling system. The service
+// recovers once two consecutive fetches succeed.
+type FlapTransport struct {
+	next     http.RoundTripper
+	conf     FlapConfig
+	mutex    sync.Mutex
+	services map[flapKey]*flapState
+	now      func() time.Time
+}
+
+type flapKey struct {
+	host      string
+	serviceID string
+}
+
+type flapState struct {
+	succeeded bool
+	changes   int
+	// backoff is the period fetches were last held back for, and is zero until the service is degraded
+	backoff   time.Duration
+	heldUntil time.Time
+}
+
+// degraded reports whether the service has been degraded and not yet recovered
+func (s *flapState) degraded() bool {
+	return s.backoff > 0
+}
+
+// NewFlapTransport wraps the provided http.RoundTripper with a FlapTransport.
+// If next is nil, http.DefaultTransport is used.
+func NewFlapTransport(next http.RoundTripper, conf FlapConfig) *FlapTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if conf.Threshold <= 0 {
+		conf.Threshold = DefaultFlapThreshold
+	}
+
+	if conf.Backoff <= 0 {
+		conf.Backoff = DefaultFlapBackoff
+	}
+
+	if conf.MaxBackoff <= 0 {
+		conf.MaxBackoff = DefaultFlapMaxBackoff
+	}
+
+	return &FlapTransport{
+		next:     next,
+		conf:     conf,
+		services: make(map[flapKey]*flapState),
+		now:      time.Now,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *FlapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	match := proxyConfigPath.FindStringSubmatch(req.URL.Path)
+	if match == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	key := flapKey{host: req.URL.Host, serviceID: match[1]}
+	if remaining := t.held(key); remaining > 0 {
+		return degradedResponse(req, remaining), nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	// rate limiting is handled by the BackoffTransport and says nothing about the health of the service
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		return resp, err
+	}
+
+	t.record(key, err == nil && resp.StatusCode < http.StatusInternalServerError)
+	return resp, err
+}
+
+// Degraded returns the services which are currently degraded, ordered by host and service ID
+func (t *FlapTransport) Degraded() []DegradedService {
+	t.mutex.Lock()
+	degraded := make([]DegradedService, 0)
+	for key, state := range t.services {
+		if state.degraded() {
+			degraded = append(degraded, DegradedService{Host: key.host, ServiceID: key.serviceID, HeldUntil: state.heldUntil})
+		}
+	}
+	t.mutex.Unlock()
+
+	sort.Slice(degraded, func(i, j int) bool {
+		if degraded[i].Host != degraded[j].Host {
+			return degraded[i].Host < degraded[j].Host
+		}
+		return degraded[i].ServiceID < degraded[j].ServiceID
+	})
+	return degraded
+}
+
+// held returns the remaining period fetches for the service are held back for
+func (t *FlapTransport) held(key flapKey) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	state, ok := t.services[key]
+	if !ok {
+		return 0
+	}
+	return state.heldUntil.Sub(t.now())
+}
+
+// record the outcome of a fetch for the service, degrading or recovering it as required
+func (t *FlapTransport) record(key flapKey, succeeded bool) {
+	t.mutex.Lock()
+
+	state, ok := t.services[key]
+	if !ok {
+		// services are only tracked once a fetch has failed
+		if succeeded {
+			t.mutex.Unlock()
+			return
+		}
+		state = &flapState{succeeded: true}
+		t.services[key] = state
+	}
+
+	var notify, degraded bool
+	switch {
+	case state.succeeded != succeeded:
+		state.changes++
+		if state.changes >= t.conf.Threshold {
+			notify, degraded = !state.degraded(), true
+			state.changes = 0
+			state.backoff = t.nextBackoff(state.backoff)
+			state.heldUntil = t.now().Add(state.backoff)
+		}
+	case succeeded:
+		// consecutive successes, the service is stable
+		notify = state.degraded()
+		delete(t.services, key)
+	default:
+		// consecutive failures are an outage rather than flapping, which is left to the system cache to handle
+		state.changes = 0
+	}
+	state.succeeded = succeeded
+	t.mutex.Unlock()
+
+	if notify && t.conf.DegradedCB != nil {
+		t.conf.DegradedCB(key.host, key.serviceID, degraded)
+	}
+}
+
+func (t *FlapTransport) nextBackoff(current time.Duration) time.Duration {
+	next := t.conf.Backoff
+	if current > 0 {
+		next = current * 2
+	}
+
+	if next > t.conf.MaxBackoff {
+		next = t.conf.MaxBackoff
+	}
+	return next
+}
+
+func degradedResponse(req *http.Request, remaining time.Duration) *http.Response {
+	retryAfter := int(remaining / time.Second)
+	if remaining%time.Second != 0 {
+		retryAfter++
+	}
+
+	header := make(http.Header)
+	header.Set(retryAfterHeader, strconv.Itoa(retryAfter))
+
+	return &http.Response{
+		Status:     "503 Service Unavailable",
+		StatusCode: http.StatusServiceUnavailable,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+}
@@ -0,0 +1,157 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlapTransport(t *testing.T) {
+	const path = "/admin/api/services/123/proxy/configs/production/latest.json"
+
+	inputs := []struct {
+		name              string
+		path              string
+		statuses          []int
+		expectDegraded    bool
+		expectServerCalls int32
+	}{
+		{
+			name:              "Test alternating fetches degrade the service and hold back further fetches",
+			path:              path,
+			statuses:          []int{500, 200, 500, 200, 500, 200},
+			expectDegraded:    true,
+			expectServerCalls: 4,
+		},
+		{
+			name:              "Test consecutive failures are not treated as flapping",
+			path:              path,
+			statuses:          []int{500, 500, 500, 500, 500, 500},
+			expectServerCalls: 6,
+		},
+		{
+			name:              "Test rate limiting is not treated as flapping",
+			path:              path,
+			statuses:          []int{429, 200, 429, 200, 429, 200},
+			expectServerCalls: 6,
+		},
+		{
+			name:              "Test other system endpoints are ignored",
+			path:              "/admin/api/services.json",
+			statuses:          []int{500, 200, 500, 200, 500, 200},
+			expectServerCalls: 6,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := atomic.AddInt32(&calls, 1) - 1
+				w.WriteHeader(input.statuses[i])
+			}))
+			defer server.Close()
+
+			var events []bool
+			transport := NewFlapTransport(nil, FlapConfig{
+				DegradedCB: func(host string, serviceID string, degraded bool) {
+					if serviceID != "123" {
+						t.Errorf("unexpected service %s", serviceID)
+					}
+					events = append(events, degraded)
+				},
+			})
+
+			c := &http.Client{Transport: transport}
+			for range input.statuses {
+				resp, err := c.Get(server.URL + input.path)
+				if err != nil {
+					t.Fatalf("unexpected error - %v", err)
+				}
+				resp.Body.Close()
+			}
+
+			if calls != input.expectServerCalls {
+				t.Errorf("unexpected number of calls to server, wanted %d but got %d", input.expectServerCalls, calls)
+			}
+
+			degraded := transport.Degraded()
+			if input.expectDegraded != (len(degraded) == 1) {
+				t.Errorf("unexpected degraded services %v", degraded)
+			}
+
+			if input.expectDegraded && (len(events) != 1 || !events[0]) {
+				t.Errorf("expected a single degraded event but got %v", events)
+			}
+		})
+	}
+}
+
+func TestFlapTransportRecovery(t *testing.T) {
+	var status int32 = http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	var events []bool
+	transport := NewFlapTransport(nil, FlapConfig{
+		Threshold:  2,
+		Backoff:    time.Minute,
+		MaxBackoff: time.Minute * 3,
+		DegradedCB: func(host string, serviceID string, degraded bool) {
+			events = append(events, degraded)
+		},
+	})
+	transport.now = func() time.Time { return now }
+
+	c := &http.Client{Transport: transport}
+	url := server.URL + "/admin/api/services/123/proxy/configs/production/latest.json"
+	fetch := func(s int) int {
+		atomic.StoreInt32(&status, int32(s))
+		resp, err := c.Get(url)
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	fetch(http.StatusInternalServerError)
+	fetch(http.StatusOK)
+
+	degraded := transport.Degraded()
+	if len(degraded) != 1 || !degraded[0].HeldUntil.Equal(now.Add(time.Minute)) {
+		t.Fatalf("expected service to be held back for the initial backoff but got %v", degraded)
+	}
+
+	if code := fetch(http.StatusOK); code != http.StatusServiceUnavailable {
+		t.Errorf("expected fetch to be short-circuited while held back but got %d", code)
+	}
+
+	// flapping again before recovery doubles the backoff
+	now = now.Add(time.Minute)
+	fetch(http.StatusInternalServerError)
+	fetch(http.StatusOK)
+
+	degraded = transport.Degraded()
+	if len(degraded) != 1 || !degraded[0].HeldUntil.Equal(now.Add(time.Minute*2)) {
+		t.Fatalf("expected backoff to double but got %v", degraded)
+	}
+
+	now = now.Add(time.Minute * 2)
+	if code := fetch(http.StatusOK); code != http.StatusOK {
+		t.Errorf("expected fetch once backoff has expired but got %d", code)
+	}
+
+	if len(transport.Degraded()) != 0 {
+		t.Errorf("expected service to recover after consecutive successes")
+	}
+
+	if len(events) != 2 || !events[0] || events[1] {
+		t.Errorf("expected degraded and recovered events but got %v", events)
+	}
+}
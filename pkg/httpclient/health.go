@@ -0,0 +1,149 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHealthWeight is the weight given to each request in the moving averages of an endpoint
+	DefaultHealthWeight = 0.1
+	// DefaultSlowLatency is the latency above which an endpoint's score is reduced in proportion
+	DefaultSlowLatency = time.Second
+)
+
+// HealthConfig controls how the health of each endpoint is scored
+type HealthConfig struct {
+	// Weight, between 0 and 1, is the weight given to each request in the exponentially weighted moving averages of
+	// success and latency, so that higher weights react faster and lower weights smooth out isolated failures
+	Weight float64
+	// SlowLatency is the average latency above which the score is reduced in proportion, so an endpoint averaging
+	// twice SlowLatency scores half of its success rate
+	SlowLatency time.Duration
+	// ScoreCB is optional and called with the score of an endpoint after each request to it
+	ScoreCB func(host string, score float64)
+}
+
+// EndpointHealth describes the health of an endpoint, as seen across requests to it
+type EndpointHealth struct {
+	Host string `json:"host"`
+	// Score, between 0 and 1, is the success rate reduced in proportion to latency above SlowLatency
+	Score       float64    `json:"score"`
+	SuccessRate float64    `json:"success_rate"`
+	LatencyMs   float64    `json:"latency_ms"`
+	Requests    int64      `json:"requests"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+}
+
+// HealthTransport is a http.RoundTripper which scores the health of each endpoint it sends requests to, from moving
+// averages of the success and latency of the requests. Requests fail on an error or a 5xx response, while rate limited
+// requests and requests cancelled by the caller, such as losing hedges, are not scored.
+type HealthTransport struct {
+	next      http.RoundTripper
+	conf      HealthConfig
+	mutex     sync.Mutex
+	endpoints map[string]*EndpointHealth
+	now       func() time.Time
+}
+
+// NewHealthTransport wraps the provided http.RoundTripper with a HealthTransport.
+// If next is nil, http.DefaultTransport is used.
+func NewHealthTransport(next http.RoundTripper, conf HealthConfig) *HealthTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if conf.Weight <= 0 || conf.Weight > 1 {
+		conf.Weight = DefaultHealthWeight
+	}
+
+	if conf.SlowLatency <= 0 {
+		conf.SlowLatency = DefaultSlowLatency
+	}
+
+	return &HealthTransport{
+		next:      next,
+		conf:      conf,
+		endpoints: make(map[string]*EndpointHealth),
+		now:       time.Now,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *HealthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := t.now()
+	resp, err := t.next.RoundTrip(req)
+
+	if req.Context().Err() != nil || (err == nil && resp.StatusCode == http.StatusTooManyRequests) {
+		return resp, err
+	}
+
+	t.record(req.URL.Host, err == nil && resp.StatusCode < http.StatusInternalServerError, t.now().Sub(start))
+	return resp, err
+}
+
+// record the outcome of a request to host, updating its score
+func (t *HealthTransport) record(host string, succeeded bool, latency time.Duration) {
+	outcome := 0.0
+	if succeeded {
+		outcome = 1
+	}
+	latencyMs := float64(latency) / float64(time.Millisecond)
+
+	t.mutex.Lock()
+	health, ok := t.endpoints[host]
+	if !ok {
+		// the first request sets the averages, rather than being weighed against an assumed history
+		health = &EndpointHealth{Host: host, SuccessRate: outcome, LatencyMs: latencyMs}
+		t.endpoints[host] = health
+	}
+
+	health.SuccessRate += t.conf.Weight * (outcome - health.SuccessRate)
+	health.LatencyMs += t.conf.Weight * (latencyMs - health.LatencyMs)
+	health.Requests++
+	if !succeeded {
+		now := t.now()
+		health.LastFailure = &now
+	}
+
+	health.Score = health.SuccessRate
+	slowMs := float64(t.conf.SlowLatency) / float64(time.Millisecond)
+	if health.LatencyMs > slowMs {
+		health.Score *= slowMs / health.LatencyMs
+	}
+	score := health.Score
+	t.mutex.Unlock()
+
+	if t.conf.ScoreCB != nil {
+		t.conf.ScoreCB(host, score)
+	}
+}
+
+// Scores returns the health of each endpoint requests have been sent to, ordered by host
+func (t *HealthTransport) Scores() []EndpointHealth {
+	t.mutex.Lock()
+	scores := make([]EndpointHealth, 0, len(t.endpoints))
+	for _, health := range t.endpoints {
+		scores = append(scores, *health)
+	}
+	t.mutex.Unlock()
+
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].Host < scores[j].Host
+	})
+	return scores
+}
+
+// ServeHTTP responds to GET requests with the health of each endpoint
+func (t *HealthTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t.Scores())
+}
@@ -0,0 +1,72 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestHealthTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	host, _ := url.Parse(server.URL)
+	scores := make(map[string]float64)
+	transport := NewHealthTransport(nil, HealthConfig{
+		Weight:      0.5,
+		SlowLatency: time.Second,
+		ScoreCB: func(host string, score float64) {
+			scores[host] = score
+		},
+	})
+
+	// each request takes 250ms
+	now := time.Now()
+	transport.now = func() time.Time {
+		now = now.Add(250 * time.Millisecond)
+		return now
+	}
+
+	client := &http.Client{Transport: transport}
+	for _, path := range []string{"/ok", "/fail", "/throttled"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ok", nil)
+	client.Do(req.WithContext(ctx))
+
+	health := transport.Scores()
+	if len(health) != 1 || health[0].Host != host.Host {
+		t.Fatalf("unexpected scores %+v", health)
+	}
+
+	if health[0].Requests != 2 || health[0].SuccessRate != 0.5 || health[0].Score != 0.5 || health[0].LastFailure == nil {
+		t.Errorf("expected only the successful and failed requests to be scored but got %+v", health[0])
+	}
+
+	if scores[host.Host] != 0.5 {
+		t.Errorf("expected score to be reported but got %v", scores)
+	}
+
+	// averaging 1.5s reduces the score by a third
+	transport.record(host.Host, true, 2750*time.Millisecond)
+	if health := transport.Scores()[0]; health.LatencyMs != 1500 || health.Score != 0.75*(1000.0/1500) {
+		t.Errorf("expected score to be reduced by latency but got %+v", health)
+	}
+}
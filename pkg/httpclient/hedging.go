@@ -0,0 +1,191 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHedgeMaxRatio is the share of eligible requests which may be hedged when no ratio is provided
+	DefaultHedgeMaxRatio = 0.05
+
+	// maxHedgeBudget bounds the number of hedges which can be accumulated while latency is healthy,
+	// limiting the burst of additional load placed on backend when it slows down
+	maxHedgeBudget = 10
+)
+
+// hedgeablePaths are the 3scale backend endpoints which do not report usage and can therefore be safely
+// sent more than once. Calls to authrep.xml and report.xml are never hedged, since each call is counted.
+var hedgeablePaths = map[string]bool{
+	"/transactions/authorize.xml":       true,
+	"/transactions/oauth_authorize.xml": true,
+}
+
+// HedgingConfig controls when requests to 3scale backend are hedged
+type HedgingConfig struct {
+	// Delay to wait for a response before sending the hedged request
+	Delay time.Duration
+	// MaxRatio caps the share of eligible requests which are hedged
+	MaxRatio float64
+	// HedgedCB is optional and called each time a hedged request is sent
+	HedgedCB func(host string)
+}
+
+// HedgingTransport is a http.RoundTripper which, when a request to 3scale backend has not returned within the
+// configured delay, sends a second identical request and returns whichever response arrives first.
+// This trades additional load on backend for reduced tail latency. Hedges are paid for from a budget which
+// grows by MaxRatio with each eligible request, so that a slow backend is not sent double the traffic.
+type HedgingTransport struct {
+	next   http.RoundTripper
+	conf   HedgingConfig
+	mutex  sync.Mutex
+	budget float64
+}
+
+type hedgeResult struct {
+	attempt int
+	resp    *http.Response
+	err     error
+}
+
+// cancelBody releases the context of the winning request once its body has been closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// NewHedgingTransport wraps the provided http.RoundTripper with a HedgingTransport.
+// If next is nil, http.DefaultTransport is used.
+func NewHedgingTransport(next http.RoundTripper, conf HedgingConfig) *HedgingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if conf.MaxRatio <= 0 {
+		conf.MaxRatio = DefaultHedgeMaxRatio
+	}
+
+	return &HedgingTransport{
+		next: next,
+		conf: conf,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *HedgingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isHedgeable(req) {
+		return t.next.RoundTrip(req)
+	}
+	t.deposit()
+
+	results := make(chan hedgeResult, 2)
+	cancels := []context.CancelFunc{t.send(req, 0, results)}
+
+	timer := time.NewTimer(t.conf.Delay)
+	defer timer.Stop()
+
+	pending := 1
+	var firstErr error
+
+	for {
+		select {
+		case <-timer.C:
+			if t.withdraw() {
+				cancels = append(cancels, t.send(req, 1, results))
+				pending++
+
+				if t.conf.HedgedCB != nil {
+					t.conf.HedgedCB(req.URL.Host)
+				}
+			}
+
+		case result := <-results:
+			pending--
+
+			if result.err == nil {
+				for i, cancel := range cancels {
+					if i != result.attempt {
+						cancel()
+					}
+				}
+				go discard(results, pending)
+
+				result.resp.Body = &cancelBody{ReadCloser: result.resp.Body, cancel: cancels[result.attempt]}
+				return result.resp, nil
+			}
+
+			if firstErr == nil {
+				firstErr = result.err
+			}
+
+			if pending == 0 {
+				for _, cancel := range cancels {
+					cancel()
+				}
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// send makes an attempt at the request in the background, returning the function which cancels it
+func (t *HedgingTransport) send(req *http.Request, attempt int, results chan<- hedgeResult) context.CancelFunc {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	go func() {
+		resp, err := t.next.RoundTrip(req.WithContext(ctx))
+		results <- hedgeResult{attempt: attempt, resp: resp, err: err}
+	}()
+
+	return cancel
+}
+
+// deposit adds to the budget for each eligible request
+func (t *HedgingTransport) deposit() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.budget += t.conf.MaxRatio
+	if t.budget > maxHedgeBudget {
+		t.budget = maxHedgeBudget
+	}
+}
+
+// withdraw returns true if the budget allows a hedge to be sent
+func (t *HedgingTransport) withdraw() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.budget < 1 {
+		return false
+	}
+	t.budget--
+	return true
+}
+
+// discard closes the responses to any attempts which lost the race
+func discard(results <-chan hedgeResult, pending int) {
+	for i := 0; i < pending; i++ {
+		if result := <-results; result.resp != nil {
+			result.resp.Body.Close()
+		}
+	}
+}
+
+// isHedgeable returns true for requests which have no body and do not report usage
+func isHedgeable(req *http.Request) bool {
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return req.URL != nil && method == http.MethodGet && hedgeablePaths[req.URL.Path]
+}
@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgingTransport(t *testing.T) {
+	inputs := []struct {
+		name              string
+		path              string
+		maxRatio          float64
+		requests          int
+		expectServerCalls int32
+		expectHedges      int32
+	}{
+		{
+			name:              "Test slow authorize is hedged",
+			path:              "/transactions/authorize.xml",
+			maxRatio:          1,
+			requests:          1,
+			expectServerCalls: 2,
+			expectHedges:      1,
+		},
+		{
+			name:              "Test authrep is never hedged",
+			path:              "/transactions/authrep.xml",
+			maxRatio:          1,
+			requests:          1,
+			expectServerCalls: 1,
+			expectHedges:      0,
+		},
+		{
+			name:              "Test hedges are capped by the max ratio",
+			path:              "/transactions/authorize.xml",
+			maxRatio:          0.5,
+			requests:          4,
+			expectServerCalls: 6,
+			expectHedges:      2,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var calls, inFlight int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				defer atomic.AddInt32(&inFlight, -1)
+
+				// the first attempt is slow, while a hedged attempt responds immediately
+				if atomic.AddInt32(&inFlight, 1) == 1 {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(time.Millisecond * 200):
+					}
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer server.Close()
+
+			var hedges int32
+			c := &http.Client{
+				Transport: NewHedgingTransport(nil, HedgingConfig{
+					Delay:    time.Millisecond * 20,
+					MaxRatio: input.maxRatio,
+					HedgedCB: func(host string) {
+						atomic.AddInt32(&hedges, 1)
+					},
+				}),
+			}
+
+			for i := 0; i < input.requests; i++ {
+				resp, err := c.Get(server.URL + input.path)
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+
+				body, _ := ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != "ok" {
+					t.Errorf("unexpected response body %s", body)
+				}
+
+				// wait for the server to observe the cancellation of the losing attempt
+				for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&inFlight) > 0 && time.Now().Before(deadline); {
+					time.Sleep(time.Millisecond)
+				}
+			}
+
+			if hedges != input.expectHedges {
+				t.Errorf("expected %d hedged requests but got %d", input.expectHedges, hedges)
+			}
+
+			if got := atomic.LoadInt32(&calls); got != input.expectServerCalls {
+				t.Errorf("expected %d calls to server but got %d", input.expectServerCalls, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultNegativeCacheTTL is the period a failed proxy configuration fetch is cached for
+const DefaultNegativeCacheTTL = time.Second * 5
+
+// NegativeCacheConfig controls how failed proxy configuration fetches are cached
+type NegativeCacheConfig struct {
+	// TTL is the period a failed fetch is cached for
+	TTL time.Duration
+	// CachedCB is optional and called when a failed fetch is cached
+	CachedCB func(host string, serviceID string)
+}
+
+// NegativeCacheTransport is a http.RoundTripper which caches failed proxy configuration fetches from 3scale system.
+// A fetch fails when system cannot be reached or responds with a server error. Further fetches of the same
+// configuration are short-circuited with a synthetic 503 response until the failure expires, so that an outage of
+// system is not made worse by every request retrying the fetch, and requests are consistently rejected as unavailable
+// in the meantime. Rate limiting is left to the BackoffTransport.
+type NegativeCacheTransport struct {
+	next        http.RoundTripper
+	conf        NegativeCacheConfig
+	mutex       sync.RWMutex
+	failedUntil map[string]time.Time
+	now         func() time.Time
+}
+
+// NewNegativeCacheTransport wraps the provided http.RoundTripper with a NegativeCacheTransport.
+// If next is nil, http.DefaultTransport is used.
+func NewNegativeCacheTransport(next http.RoundTripper, conf NegativeCacheConfig) *NegativeCacheTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	if conf.TTL <= 0 {
+		conf.TTL = DefaultNegativeCacheTTL
+	}
+
+	return &NegativeCacheTransport{
+		next:        next,
+		conf:        conf,
+		failedUntil: make(map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *NegativeCacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	match := proxyConfigPath.FindStringSubmatch(req.URL.Path)
+	if match == nil {
+		return t.next.RoundTrip(req)
+	}
+
+	// the path identifies the environment as well as the service, the query holds only credentials
+	key := req.URL.Host + req.URL.Path
+	if remaining := t.failed(key); remaining > 0 {
+		return degradedResponse(req, remaining), nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		t.record(key)
+		if t.conf.CachedCB != nil {
+			t.conf.CachedCB(req.URL.Host, match[1])
+		}
+	}
+	return resp, err
+}
+
+// failed returns the remaining period a failed fetch is cached for
+func (t *NegativeCacheTransport) failed(key string) time.Duration {
+	t.mutex.RLock()
+	until, ok := t.failedUntil[key]
+	t.mutex.RUnlock()
+
+	if !ok {
+		return 0
+	}
+
+	remaining := until.Sub(t.now())
+	if remaining <= 0 {
+		t.mutex.Lock()
+		if t.failedUntil[key].Equal(until) {
+			delete(t.failedUntil, key)
+		}
+		t.mutex.Unlock()
+	}
+	return remaining
+}
+
+func (t *NegativeCacheTransport) record(key string) {
+	t.mutex.Lock()
+	t.failedUntil[key] = t.now().Add(t.conf.TTL)
+	t.mutex.Unlock()
+}
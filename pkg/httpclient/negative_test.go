@@ -0,0 +1,79 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNegativeCacheTransport(t *testing.T) {
+	var status int32 = http.StatusInternalServerError
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	var cached []string
+	transport := NewNegativeCacheTransport(nil, NegativeCacheConfig{
+		TTL: time.Second * 10,
+		CachedCB: func(host string, serviceID string) {
+			cached = append(cached, serviceID)
+		},
+	})
+	transport.now = func() time.Time { return now }
+
+	c := &http.Client{Transport: transport}
+	fetch := func(path string, s int) *http.Response {
+		atomic.StoreInt32(&status, int32(s))
+		resp, err := c.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	const production = "/admin/api/services/123/proxy/configs/production/latest.json"
+	const staging = "/admin/api/services/123/proxy/configs/sandbox/latest.json"
+
+	fetch(production, http.StatusInternalServerError)
+	resp := fetch(production, http.StatusOK)
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get(retryAfterHeader) != "10" {
+		t.Errorf("expected cached failure to be returned but got %d with Retry-After %q", resp.StatusCode, resp.Header.Get(retryAfterHeader))
+	}
+
+	if code := fetch(staging, http.StatusOK).StatusCode; code != http.StatusOK {
+		t.Errorf("expected failure to be cached for the environment only but got %d", code)
+	}
+
+	if code := fetch("/admin/api/services.json", http.StatusInternalServerError).StatusCode; code != http.StatusInternalServerError {
+		t.Errorf("expected other system endpoints to be ignored but got %d", code)
+	}
+	fetch("/admin/api/services.json", http.StatusOK)
+
+	if code := fetch(staging, http.StatusTooManyRequests).StatusCode; code != http.StatusTooManyRequests {
+		t.Errorf("expected rate limiting to be passed through but got %d", code)
+	}
+
+	if code := fetch(staging, http.StatusOK).StatusCode; code != http.StatusOK {
+		t.Errorf("expected rate limiting not to be cached but got %d", code)
+	}
+
+	now = now.Add(time.Second * 10)
+	if code := fetch(production, http.StatusOK).StatusCode; code != http.StatusOK {
+		t.Errorf("expected fetch once the failure has expired but got %d", code)
+	}
+
+	if calls != 7 {
+		t.Errorf("expected cached failure not to call the server but got %d calls", calls)
+	}
+
+	if len(cached) != 1 || cached[0] != "123" {
+		t.Errorf("expected a single cached failure but got %v", cached)
+	}
+}
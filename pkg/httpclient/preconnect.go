@@ -0,0 +1,78 @@
+package httpclient
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultPreconnectRetryInterval is the period after which a host which could not be reached is preconnected again
+const DefaultPreconnectRetryInterval = time.Minute
+
+// Preconnector establishes a connection to each 3scale host ahead of the first request which needs it. The host is
+// resolved and any TLS handshake completed in the background by a HEAD request made with the client, leaving the
+// connection idle in the pool of the client's transport, ready for the request which follows.
+type Preconnector struct {
+	client *http.Client
+	mutex  sync.Mutex
+	// hosts maps each host seen to the time after which it may be preconnected again, or zero once connected
+	hosts map[string]time.Time
+	now   func() time.Time
+	// ErrorCB is optional and called when a host cannot be reached
+	ErrorCB func(host string, err error)
+}
+
+// NewPreconnector returns a Preconnector which connects using client. If client is nil, http.DefaultClient is used.
+// The client must share its transport with the client used to call 3scale for the connections to be reused.
+func NewPreconnector(client *http.Client) *Preconnector {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Preconnector{
+		client: client,
+		hosts:  make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Preconnect connects to the host of endpoint in the background, unless a connection has already been established
+// or attempted recently. It does not block, so may be called on every request.
+func (p *Preconnector) Preconnect(endpoint string) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return
+	}
+	origin := u.Scheme + "://" + u.Host
+
+	p.mutex.Lock()
+	retryAt, seen := p.hosts[origin]
+	if seen && (retryAt.IsZero() || p.now().Before(retryAt)) {
+		p.mutex.Unlock()
+		return
+	}
+	p.hosts[origin] = p.now().Add(DefaultPreconnectRetryInterval)
+	p.mutex.Unlock()
+
+	go p.connect(origin, u.Host)
+}
+
+func (p *Preconnector) connect(origin string, host string) {
+	resp, err := p.client.Head(origin + "/")
+	if err != nil {
+		if p.ErrorCB != nil {
+			p.ErrorCB(host, err)
+		}
+		return
+	}
+	// the body must be read to completion for the connection to be returned to the pool
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	p.mutex.Lock()
+	p.hosts[origin] = time.Time{}
+	p.mutex.Unlock()
+}
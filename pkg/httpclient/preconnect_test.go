@@ -0,0 +1,80 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPreconnector(t *testing.T) {
+	var conns, requests int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	client := server.Client()
+	p := NewPreconnector(client)
+	p.Preconnect(server.URL + "/admin/api/services/123/proxy/configs/production/latest.json")
+	p.Preconnect(server.URL + "/transactions/authrep.xml")
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&requests) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected host to be preconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, err := client.Get(server.URL + "/transactions/authrep.xml")
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	resp.Body.Close()
+
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("expected a single preconnect per host, got %d requests", got)
+	}
+
+	if got := atomic.LoadInt32(&conns); got != 1 {
+		t.Errorf("expected the preconnected connection to be reused, got %d connections", got)
+	}
+}
+
+func TestPreconnectorRetry(t *testing.T) {
+	errs := make(chan string, 10)
+	p := NewPreconnector(&http.Client{Timeout: time.Second})
+	p.ErrorCB = func(host string, err error) {
+		errs <- host
+	}
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	// nothing listens on the port of a closed server
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	p.Preconnect(server.URL)
+	<-errs
+
+	p.Preconnect(server.URL)
+	now = now.Add(DefaultPreconnectRetryInterval + time.Second)
+	p.Preconnect(server.URL)
+	<-errs
+
+	select {
+	case <-errs:
+		t.Errorf("expected unreachable host to be retried once per interval")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
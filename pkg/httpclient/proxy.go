@@ -0,0 +1,85 @@
+package httpclient
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// proxySchemes are the proxy URL schemes supported by http.Transport
+var proxySchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"socks5": true,
+}
+
+// HandlerProxy chooses the proxy each request of the client of a single handler is sent through, for use as the Proxy
+// of a http.Transport. Requests to the 3scale system of the handler use its system proxy, and every other request, which
+// is to 3scale backend, uses its backend proxy. Where the handler sets no proxy, the proxy set by the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables, if any, is used.
+type HandlerProxy struct {
+	// system is the origin of the 3scale system of the handler
+	system          string
+	systemProxy     *url.URL
+	backendProxy    *url.URL
+	fromEnvironment func(*http.Request) (*url.URL, error)
+}
+
+// NewHandlerProxy returns a HandlerProxy for the handler of systemURL. Either proxy URL may be empty.
+func NewHandlerProxy(systemURL string, systemProxyURL string, backendProxyURL string) (*HandlerProxy, error) {
+	s, err := url.Parse(systemURL)
+	if err != nil || s.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", systemURL)
+	}
+
+	p := &HandlerProxy{system: origin(s), fromEnvironment: http.ProxyFromEnvironment}
+	if systemProxyURL != "" {
+		if p.systemProxy, err = ParseProxyURL(systemProxyURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if backendProxyURL != "" {
+		if p.backendProxy, err = ParseProxyURL(backendProxyURL); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ParseProxyURL parses the URL of a proxy, which must be absolute and use a scheme supported by http.Transport
+func ParseProxyURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil || !proxySchemes[u.Scheme] || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q, expected an absolute http, https or socks5 URL", proxyURL)
+	}
+	return u, nil
+}
+
+// Proxy returns the proxy for the request, or nil if it should be sent directly
+func (p *HandlerProxy) Proxy(req *http.Request) (*url.URL, error) {
+	proxy := p.backendProxy
+	if origin(req.URL) == p.system {
+		proxy = p.systemProxy
+	}
+
+	if proxy != nil {
+		return proxy, nil
+	}
+	return p.fromEnvironment(req)
+}
+
+// origin returns the scheme, host and port of u, such that the default port of the scheme matches an omitted port
+func origin(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
@@ -0,0 +1,73 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHandlerProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	p, err := NewHandlerProxy("http://SYSTEM.example.com", proxy.URL, "")
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	envProxy, _ := url.Parse("http://env-proxy.example.com:3128")
+	p.fromEnvironment = func(req *http.Request) (*url.URL, error) {
+		if req.URL.Hostname() == "direct.example.com" {
+			return nil, nil
+		}
+		return envProxy, nil
+	}
+
+	client := &http.Client{Transport: &http.Transport{Proxy: p.Proxy}}
+	resp, err := client.Get("http://system.example.com:80/admin/api/services.json")
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+	resp.Body.Close()
+
+	if proxied != "http://system.example.com:80/admin/api/services.json" {
+		t.Errorf("expected request to be sent through the system proxy but got %q", proxied)
+	}
+
+	for endpoint, expect := range map[string]*url.URL{
+		"https://su1.3scale.net/transactions/authrep.xml": envProxy,
+		"http://direct.example.com/":                      nil,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, endpoint, nil)
+		if got, _ := p.Proxy(req); got != expect {
+			t.Errorf("unexpected proxy %v for %s", got, endpoint)
+		}
+	}
+
+	p, err = NewHandlerProxy("https://system.example.com", "", "socks5://backend-proxy.example.com:1080")
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://su1.3scale.net/transactions/authrep.xml", nil)
+	if got, _ := p.Proxy(req); got == nil || got.Host != "backend-proxy.example.com:1080" {
+		t.Errorf("expected request to backend to be sent through the backend proxy but got %v", got)
+	}
+
+	for _, invalid := range []string{"proxy.example.com:3128", "ftp://proxy.example.com"} {
+		if _, err := NewHandlerProxy("https://system.example.com", invalid, ""); err == nil {
+			t.Errorf("expected error for system proxy %q", invalid)
+		}
+		if _, err := NewHandlerProxy("https://system.example.com", "", invalid); err == nil {
+			t.Errorf("expected error for backend proxy %q", invalid)
+		}
+	}
+
+	if _, err := NewHandlerProxy("not a url", "", ""); err == nil {
+		t.Errorf("expected error for an invalid system URL")
+	}
+}
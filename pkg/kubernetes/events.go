@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventComponent is the source reported for events emitted by the adapter
+const eventComponent = "3scale-istio-adapter"
+
+// EventRecorder emits Kubernetes Events against the Pod the adapter is running in, so that critical conditions
+// are visible to cluster admins via kubectl describe. Repeated events are aggregated and rate limited by client-go.
+type EventRecorder struct {
+	recorder record.EventRecorder
+	pod      *corev1.ObjectReference
+}
+
+// NewPodEventRecorder returns an EventRecorder for the named Pod, which must exist in order
+// for the events to be associated with it
+func (c *K8sClient) NewPodEventRecorder(namespace, podName string) (*EventRecorder, error) {
+	if namespace == "" || podName == "" {
+		return nil, fmt.Errorf("namespace and pod name are required to emit events")
+	}
+
+	pod, err := c.cs.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get pod %s/%s - %v", namespace, podName, err)
+	}
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: c.cs.CoreV1().Events(namespace)})
+
+	return &EventRecorder{
+		recorder: broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent}),
+		pod: &corev1.ObjectReference{
+			Kind:       "Pod",
+			APIVersion: "v1",
+			Namespace:  pod.Namespace,
+			Name:       pod.Name,
+			UID:        pod.UID,
+		},
+	}, nil
+}
+
+// Warning emits a Warning event with the provided reason and message. Calling Warning on a nil EventRecorder is a no-op.
+func (r *EventRecorder) Warning(reason, messageFmt string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.recorder.Eventf(r.pod, corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// lockRecorder returns the recorder leader election reports changes of leadership to. The lock requires one, so events
+// are discarded when r is nil.
+func (r *EventRecorder) lockRecorder() record.EventRecorder {
+	if r == nil {
+		return discardEvents{}
+	}
+	return r.recorder
+}
+
+// discardEvents is a record.EventRecorder which discards every event
+type discardEvents struct{}
+
+func (discardEvents) Event(object runtime.Object, eventtype, reason, message string) {}
+
+func (discardEvents) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+}
+
+func (discardEvents) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+}
+
+func (discardEvents) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+}
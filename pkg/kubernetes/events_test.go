@@ -0,0 +1,54 @@
+package kubernetes
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPodEventRecorder(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "adapter-1", Namespace: "istio-system", UID: "1234"},
+	})
+	k8 := K8sClient{cs: client}
+
+	if _, err := k8.NewPodEventRecorder("istio-system", "missing"); err == nil {
+		t.Errorf("expected error when the pod does not exist")
+	}
+
+	recorder, err := k8.NewPodEventRecorder("istio-system", "adapter-1")
+	if err != nil {
+		t.Fatalf("unexpected error creating recorder - %v", err)
+	}
+
+	recorder.Warning("SystemUnreachable", "unable to reach %s", "system")
+
+	var events *corev1.EventList
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		events, err = client.CoreV1().Events("istio-system").List(metav1.ListOptions{})
+		if err == nil && len(events.Items) > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if events == nil || len(events.Items) != 1 {
+		t.Fatalf("expected a single event to be recorded")
+	}
+
+	event := events.Items[0]
+	if event.Type != corev1.EventTypeWarning || event.Reason != "SystemUnreachable" || event.Message != "unable to reach system" {
+		t.Errorf("unexpected event %s %s %q", event.Type, event.Reason, event.Message)
+	}
+
+	if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != "adapter-1" || event.InvolvedObject.UID != "1234" {
+		t.Errorf("expected event to involve the adapter pod but got %+v", event.InvolvedObject)
+	}
+
+	var nilRecorder *EventRecorder
+	nilRecorder.Warning("SystemUnreachable", "should not panic")
+}
@@ -73,29 +73,44 @@ func (c *K8sClient) GetSecret(name, namespace string, filterByLabels ...string)
 // capable of manipulating known custom resources handler, instance and rule.
 // It does not take care of creating the CRD for these extensions
 func (c *K8sClient) NewIstioClient() (*IstioClientImpl, error) {
-	s := runtime.NewScheme()
 	schemeGroupVersion := schema.GroupVersion{Group: istioObjGroupName, Version: istioObjGroupVersion}
 
+	cfg, rc, err := c.newCustomResourceClient(schemeGroupVersion, map[string]runtime.Object{
+		handlerKind:  &IstioResource{},
+		instanceKind: &IstioResource{},
+		ruleKind:     &IstioResource{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &IstioClientImpl{cfg, rc}, nil
+}
+
+// newCustomResourceClient returns a REST client for the provided kinds of custom resource in the group version
+func (c *K8sClient) newCustomResourceClient(gv schema.GroupVersion, kinds map[string]runtime.Object) (*rest.Config, rest.Interface, error) {
+	s := runtime.NewScheme()
+
 	addKnownTypes := func(scheme *runtime.Scheme) error {
-		scheme.AddKnownTypeWithName(getKnownGvk(handlerKind), &IstioResource{})
-		scheme.AddKnownTypeWithName(getKnownGvk(instanceKind), &IstioResource{})
-		scheme.AddKnownTypeWithName(getKnownGvk(ruleKind), &IstioResource{})
+		for kind, obj := range kinds {
+			scheme.AddKnownTypeWithName(gv.WithKind(kind), obj)
+		}
 
-		metav1.AddToGroupVersion(scheme, schemeGroupVersion)
+		metav1.AddToGroupVersion(scheme, gv)
 		return nil
 	}
 
 	schemeBuilder := runtime.NewSchemeBuilder(addKnownTypes)
 	err := schemeBuilder.AddToScheme(s)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	cfg := rest.Config{
 		Host:    c.conf.Host,
 		APIPath: "/apis",
 		ContentConfig: rest.ContentConfig{
-			GroupVersion:         &schemeGroupVersion,
+			GroupVersion:         &gv,
 			NegotiatedSerializer: serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(s)},
 		},
 		BearerToken:     c.conf.BearerToken,
@@ -105,10 +120,10 @@ func (c *K8sClient) NewIstioClient() (*IstioClientImpl, error) {
 
 	rc, err := rest.UnversionedRESTClientFor(&cfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return &IstioClientImpl{&cfg, rc}, nil
+	return &cfg, rc, nil
 }
 
 // getConfigFromConfPath returns k8 client config from provided path
@@ -131,14 +146,6 @@ func getClientSetFromConfig(conf *rest.Config) (*kubernetes.Clientset, error) {
 	return kubernetes.NewForConfig(conf)
 }
 
-func getKnownGvk(name string) schema.GroupVersionKind {
-	return schema.GroupVersionKind{
-		Group:   istioObjGroupName,
-		Version: istioObjGroupVersion,
-		Kind:    name,
-	}
-}
-
 func formatLabelFilter(input []string) string {
 	return strings.Join(input, ",")
 }
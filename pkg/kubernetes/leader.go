@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	defaultLeaseDuration = 15 * time.Second
+	defaultRenewDeadline = 10 * time.Second
+	defaultRetryPeriod   = 2 * time.Second
+)
+
+// LeaderElectionConfig configures leader election amongst replicas of the adapter
+type LeaderElectionConfig struct {
+	// Namespace and Name of the ConfigMap which holds the lease
+	Namespace string
+	Name      string
+	// Identity of this replica, which must be unique, typically the pod name
+	Identity string
+	// LeaseDuration, RenewDeadline and RetryPeriod are optional and default to the client-go recommended values
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+	// Events is optional and records changes of leadership against the ConfigMap, otherwise they are discarded
+	Events *EventRecorder
+}
+
+// RunLeaderElection competes for the lease described by conf and calls run while this replica holds it.
+// The stop channel passed to run is closed when the lease is lost, after which the replica competes for it again.
+// An error is returned if leader election cannot be set up, otherwise this function blocks indefinitely.
+func (c *K8sClient) RunLeaderElection(conf LeaderElectionConfig, run func(stop <-chan struct{})) error {
+	if conf.Namespace == "" || conf.Name == "" || conf.Identity == "" {
+		return fmt.Errorf("namespace, name and identity are required for leader election")
+	}
+
+	lock, err := resourcelock.New(
+		resourcelock.ConfigMapsResourceLock,
+		conf.Namespace,
+		conf.Name,
+		c.cs.CoreV1(),
+		resourcelock.ResourceLockConfig{
+			Identity:      conf.Identity,
+			EventRecorder: conf.Events.lockRecorder(),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: durationOrDefault(conf.LeaseDuration, defaultLeaseDuration),
+		RenewDeadline: durationOrDefault(conf.RenewDeadline, defaultRenewDeadline),
+		RetryPeriod:   durationOrDefault(conf.RetryPeriod, defaultRetryPeriod),
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: run,
+			OnStoppedLeading: func() {},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		// Run returns once the lease has been lost
+		elector.Run()
+	}
+}
+
+func durationOrDefault(d time.Duration, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
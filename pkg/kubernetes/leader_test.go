@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestRunLeaderElection(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	k8 := K8sClient{cs: client}
+
+	conf := LeaderElectionConfig{
+		Namespace:   "istio-system",
+		Name:        "3scale-istio-adapter",
+		Identity:    "replica-1",
+		RetryPeriod: time.Millisecond * 10,
+	}
+
+	leading := make(chan struct{})
+	go k8.RunLeaderElection(conf, func(stop <-chan struct{}) {
+		close(leading)
+	})
+
+	select {
+	case <-leading:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("expected replica to become leader")
+	}
+
+	cm, err := client.CoreV1().ConfigMaps(conf.Namespace).Get(conf.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected lease to be recorded - %v", err)
+	}
+
+	if !strings.Contains(cm.Annotations[resourcelock.LeaderElectionRecordAnnotationKey], conf.Identity) {
+		t.Errorf("expected lease to be held by %s", conf.Identity)
+	}
+}
+
+func TestRunLeaderElectionEvents(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "replica-1", Namespace: "istio-system"},
+	})
+	k8 := K8sClient{cs: client}
+
+	events, err := k8.NewPodEventRecorder("istio-system", "replica-1")
+	if err != nil {
+		t.Fatalf("unexpected error creating recorder - %v", err)
+	}
+
+	conf := LeaderElectionConfig{
+		Namespace:   "istio-system",
+		Name:        "3scale-istio-adapter",
+		Identity:    "replica-1",
+		RetryPeriod: time.Millisecond * 10,
+		Events:      events,
+	}
+
+	go k8.RunLeaderElection(conf, func(stop <-chan struct{}) {})
+
+	deadline := time.Now().Add(time.Second * 5)
+	for time.Now().Before(deadline) {
+		list, err := client.CoreV1().Events(conf.Namespace).List(metav1.ListOptions{})
+		if err == nil && len(list.Items) > 0 {
+			if event := list.Items[0]; event.Reason != "LeaderElection" || event.InvolvedObject.Name != conf.Name {
+				t.Errorf("unexpected event %s for %s", event.Reason, event.InvolvedObject.Name)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Errorf("expected change of leadership to be recorded")
+}
+
+func TestRunLeaderElectionInvalidConfig(t *testing.T) {
+	k8 := K8sClient{cs: fake.NewSimpleClientset()}
+
+	err := k8.RunLeaderElection(LeaderElectionConfig{Namespace: "istio-system"}, func(stop <-chan struct{}) {
+		t.Errorf("unexpected call to run")
+	})
+	if err == nil {
+		t.Errorf("expected error when name and identity are missing")
+	}
+}
@@ -0,0 +1,164 @@
+package kubernetes
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	runtimeConfigGroupName    = "istio.3scale.net"
+	runtimeConfigGroupVersion = "v1alpha1"
+
+	runtimeConfigKind   = "AdapterRuntimeConfig"
+	runtimeConfigPlural = "adapterruntimeconfigs"
+
+	// DefaultRuntimeConfigInterval is the period between reads of the runtime config when none is provided
+	DefaultRuntimeConfigInterval = 10 * time.Second
+)
+
+// AdapterRuntimeConfig is a namespaced custom resource holding adapter options which can be changed
+// without restarting the adapter
+type AdapterRuntimeConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              RuntimeConfigSpec `json:"spec"`
+}
+
+// RuntimeConfigSpec lists the options which can be changed at runtime.
+// Options which are not set keep the value provided via the environment.
+type RuntimeConfigSpec struct {
+	// LogLevel is one of debug, info, warn, error or none
+	LogLevel string `json:"logLevel,omitempty"`
+	// LogSamplingFirst is the number of identical messages logged per sampling interval, zero disables sampling
+	LogSamplingFirst *int `json:"logSamplingFirst,omitempty"`
+	// LogSamplingIntervalSeconds is the length of the sampling interval
+	LogSamplingIntervalSeconds *int `json:"logSamplingIntervalSeconds,omitempty"`
+}
+
+// RuntimeConfigControllerConfig identifies the runtime config to reconcile
+type RuntimeConfigControllerConfig struct {
+	// Namespace and Name of the AdapterRuntimeConfig resource
+	Namespace string
+	Name      string
+	// Interval between reads of the resource, defaults to DefaultRuntimeConfigInterval
+	Interval time.Duration
+	// ErrorCB is optional and called when the resource cannot be read
+	ErrorCB func(err error)
+}
+
+// RuntimeConfigClient provides read access to AdapterRuntimeConfig resources
+type RuntimeConfigClient struct {
+	rc rest.Interface
+}
+
+// NewRuntimeConfigClient creates a new client from an existing kubernetes client capable of reading
+// AdapterRuntimeConfig resources. It does not take care of creating the CRD.
+func (c *K8sClient) NewRuntimeConfigClient() (*RuntimeConfigClient, error) {
+	gv := schema.GroupVersion{Group: runtimeConfigGroupName, Version: runtimeConfigGroupVersion}
+
+	_, rc, err := c.newCustomResourceClient(gv, map[string]runtime.Object{
+		runtimeConfigKind: &AdapterRuntimeConfig{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RuntimeConfigClient{rc}, nil
+}
+
+// Get the named AdapterRuntimeConfig from the namespace
+func (c *RuntimeConfigClient) Get(name, namespace string) (*AdapterRuntimeConfig, error) {
+	result := &AdapterRuntimeConfig{}
+	err := c.rc.Get().Namespace(namespace).Resource(runtimeConfigPlural).Name(name).Do().Into(result)
+	return result, err
+}
+
+// RunController reads the runtime config described by conf at each interval and calls apply with its spec
+// whenever the resource changes. When the resource does not exist, apply is called with an empty spec, so that
+// deleting the resource reverts the adapter to its environment. This function blocks until stop is closed.
+func (c *RuntimeConfigClient) RunController(conf RuntimeConfigControllerConfig, apply func(spec RuntimeConfigSpec), stop <-chan struct{}) error {
+	if conf.Namespace == "" || conf.Name == "" {
+		return fmt.Errorf("namespace and name are required for the runtime config controller")
+	}
+
+	if conf.Interval <= 0 {
+		conf.Interval = DefaultRuntimeConfigInterval
+	}
+
+	ticker := time.NewTicker(conf.Interval)
+	defer ticker.Stop()
+
+	// the resource version last applied, where notFound indicates the environment alone has been applied
+	const notFound = "-"
+	var applied string
+
+	for {
+		resource, err := c.Get(conf.Name, conf.Namespace)
+		switch {
+		case errors.IsNotFound(err):
+			if applied != notFound {
+				apply(RuntimeConfigSpec{})
+				applied = notFound
+			}
+		case err != nil:
+			// the last applied config remains in place until the resource can be read
+			if conf.ErrorCB != nil {
+				conf.ErrorCB(err)
+			}
+		case resource.ResourceVersion != applied:
+			apply(resource.Spec)
+			applied = resource.ResourceVersion
+		}
+
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+/*
+ Receiver functions for AdapterRuntimeConfig required to implement the Kubernetes runtime.Object interface
+*/
+
+// DeepCopyInto copies all properties of this object into another object of the same type that is provided as a pointer. in must be non-nil.
+func (in *AdapterRuntimeConfig) DeepCopyInto(out *AdapterRuntimeConfig) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+
+	if in.Spec.LogSamplingFirst != nil {
+		first := *in.Spec.LogSamplingFirst
+		out.Spec.LogSamplingFirst = &first
+	}
+
+	if in.Spec.LogSamplingIntervalSeconds != nil {
+		interval := *in.Spec.LogSamplingIntervalSeconds
+		out.Spec.LogSamplingIntervalSeconds = &interval
+	}
+}
+
+// DeepCopy copies the receiver, creating a new AdapterRuntimeConfig.
+func (in *AdapterRuntimeConfig) DeepCopy() *AdapterRuntimeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AdapterRuntimeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the receiver, creating a new runtime.Object.
+func (in *AdapterRuntimeConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/rest/fake"
+)
+
+func TestNewRuntimeConfigClient(t *testing.T) {
+	k8, err := NewK8Client("", &rest.Config{Host: "fake"})
+	if err != nil {
+		t.Fatalf("unexpected error creating kubernetes client - %v", err)
+	}
+
+	if _, err := k8.NewRuntimeConfigClient(); err != nil {
+		t.Errorf("unexpected error creating runtime config client - %v", err)
+	}
+}
+
+func TestRuntimeConfigController(t *testing.T) {
+	first := 5
+	resource := func(version string, level string) *AdapterRuntimeConfig {
+		return &AdapterRuntimeConfig{
+			TypeMeta: v1.TypeMeta{
+				Kind:       runtimeConfigKind,
+				APIVersion: runtimeConfigGroupName + "/" + runtimeConfigGroupVersion,
+			},
+			ObjectMeta: v1.ObjectMeta{
+				Name:            "3scale-istio-adapter",
+				Namespace:       DefaultNamespace,
+				ResourceVersion: version,
+			},
+			Spec: RuntimeConfigSpec{LogLevel: level, LogSamplingFirst: &first},
+		}
+	}
+
+	// each read of the resource returns the next response, with the last repeated once exhausted
+	responses := []struct {
+		status   int
+		resource *AdapterRuntimeConfig
+	}{
+		{status: http.StatusNotFound},
+		{status: http.StatusOK, resource: resource("1", "debug")},
+		{status: http.StatusOK, resource: resource("1", "debug")},
+		{status: http.StatusInternalServerError},
+		{status: http.StatusOK, resource: resource("2", "warn")},
+		{status: http.StatusNotFound},
+	}
+
+	reads := make(chan struct{}, 100)
+	client := &RuntimeConfigClient{
+		rc: &fake.RESTClient{
+			GroupVersion:         schema.GroupVersion{Group: runtimeConfigGroupName, Version: runtimeConfigGroupVersion},
+			NegotiatedSerializer: serializer.DirectCodecFactory{CodecFactory: scheme.Codecs},
+			Client: fake.CreateHTTPClient(func(request *http.Request) (*http.Response, error) {
+				expectPath := "/namespaces/" + DefaultNamespace + "/" + runtimeConfigPlural + "/3scale-istio-adapter"
+				if request.URL.Path != expectPath {
+					t.Errorf("unexpected path %s", request.URL.Path)
+				}
+
+				i := len(reads)
+				reads <- struct{}{}
+				if i >= len(responses) {
+					i = len(responses) - 1
+				}
+
+				var body []byte
+				if responses[i].resource != nil {
+					body, _ = json.Marshal(responses[i].resource)
+				}
+				return &http.Response{StatusCode: responses[i].status, Header: defaultHeader(t), Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+			}),
+		},
+	}
+
+	var applied []string
+	var errs int
+	stop := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- client.RunController(RuntimeConfigControllerConfig{
+			Namespace: DefaultNamespace,
+			Name:      "3scale-istio-adapter",
+			Interval:  time.Millisecond,
+			ErrorCB: func(err error) {
+				errs++
+			},
+		}, func(spec RuntimeConfigSpec) {
+			applied = append(applied, spec.LogLevel)
+		}, stop)
+	}()
+
+	for len(reads) < len(responses)+2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error from controller - %v", err)
+	}
+
+	expect := []string{"", "debug", "warn", ""}
+	if len(applied) != len(expect) {
+		t.Fatalf("expected config to be applied once per change, wanted %v but got %v", expect, applied)
+	}
+
+	for i := range expect {
+		if applied[i] != expect[i] {
+			t.Errorf("unexpected config applied, wanted %v but got %v", expect, applied)
+		}
+	}
+
+	if errs != 1 {
+		t.Errorf("expected a single read error to be reported but got %d", errs)
+	}
+}
+
+func TestRuntimeConfigControllerInvalidConfig(t *testing.T) {
+	client := &RuntimeConfigClient{}
+
+	err := client.RunController(RuntimeConfigControllerConfig{Namespace: DefaultNamespace}, func(spec RuntimeConfigSpec) {
+		t.Errorf("unexpected call to apply")
+	}, nil)
+
+	if err == nil {
+		t.Errorf("expected error when name is not provided")
+	}
+}
+
+func TestAdapterRuntimeConfigDeepCopy(t *testing.T) {
+	first := 5
+	in := &AdapterRuntimeConfig{Spec: RuntimeConfigSpec{LogSamplingFirst: &first}}
+
+	out := in.DeepCopyObject().(*AdapterRuntimeConfig)
+	*out.Spec.LogSamplingFirst = 10
+
+	if *in.Spec.LogSamplingFirst != 5 {
+		t.Errorf("expected copy to be independent of the original")
+	}
+
+	var nilConfig *AdapterRuntimeConfig
+	if nilConfig.DeepCopy() != nil {
+		t.Errorf("expected nil copy of nil config")
+	}
+}
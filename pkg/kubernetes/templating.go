@@ -46,8 +46,11 @@ func NewConfigGenerator(name string, handler HandlerSpec, instance BaseInstance,
 func (cg *ConfigGenerator) OutputAll(w io.Writer) error {
 	buffer := bytes.Buffer{}
 
+	handler := getBaseResource(cg.name, cg.namespace, handlerKind).spec(cg.handler)
+	handler.Annotations = cg.handlerAnnotations
+
 	objs := []*IstioResource{
-		getBaseResource(cg.name, cg.namespace, handlerKind).spec(cg.handler),
+		handler,
 		getBaseResource(cg.name, cg.namespace, instanceKind).spec(cg.instance),
 		getBaseResource(cg.name, cg.namespace, ruleKind).spec(cg.rule),
 	}
@@ -70,6 +73,13 @@ func (cg *ConfigGenerator) SetNamespace(ns string) *ConfigGenerator {
 	return cg
 }
 
+// SetHandlerAnnotations to add to the metadata of the handler, such as to describe the 3scale tenant and service it
+// is configured for
+func (cg *ConfigGenerator) SetHandlerAnnotations(annotations map[string]string) *ConfigGenerator {
+	cg.handlerAnnotations = annotations
+	return cg
+}
+
 func (cg *ConfigGenerator) marshalIstioResource(obj *IstioResource) ([]byte, error) {
 	if cg.outputAs == YAML {
 		return yaml.Marshal(obj)
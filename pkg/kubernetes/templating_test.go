@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -105,3 +106,29 @@ func TestOutputAll(t *testing.T) {
 	}
 
 }
+
+func TestOutputAllHandlerAnnotations(t *testing.T) {
+	h, _ := NewThreescaleHandlerSpec("secret-token", "http://127.0.0.1:8090", "123")
+	cg, err := NewConfigGenerator("threescale", *h, *NewDefaultHybridInstance(), Rule{})
+	if err != nil {
+		t.Fatalf("unexpected error when creating config generator")
+	}
+
+	cg.SetHandlerAnnotations(map[string]string{"3scale.net/tenant": "Acme"})
+
+	var w bytes.Buffer
+	if err := cg.OutputAll(&w); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	manifests := strings.Split(w.String(), "---\n")
+	if !strings.Contains(manifests[0], "annotations:\n    3scale.net/tenant: Acme\n") {
+		t.Errorf("expected handler to be annotated but got %s", manifests[0])
+	}
+
+	for _, manifest := range manifests[1:] {
+		if strings.Contains(manifest, "annotations:") {
+			t.Errorf("expected only the handler to be annotated but got %s", manifest)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/3scale/3scale-istio-adapter/config"
 	"github.com/3scale/3scale-istio-adapter/pkg/threescale"
@@ -60,6 +61,14 @@ func NewThreescaleHandlerSpec(accessToken, systemURL, svcID string) (*HandlerSpe
 	}, nil
 }
 
+// SetTimeouts bounds the time the adapter waits on 3scale when authorizing requests for the handler, overall and when
+// calling 3scale system and backend respectively. A zero duration leaves the corresponding timeout unset.
+func (h *HandlerSpec) SetTimeouts(client, system, backend time.Duration) {
+	h.Params.ClientTimeout = formatTimeout(client)
+	h.Params.SystemTimeout = formatTimeout(system)
+	h.Params.BackendTimeout = formatTimeout(backend)
+}
+
 // NewDefaultHybridInstance - new base instance supporting all authentication methods with default values
 func NewDefaultHybridInstance() *BaseInstance {
 	return &BaseInstance{
@@ -171,6 +180,14 @@ func convertSecret(secret *v1.Secret) (*ThreescaleCredentials, bool) {
 	return &ThreescaleCredentials{string(systemURL), string(accessToken)}, true
 }
 
+// formatTimeout returns the handler param for a timeout, which is empty when unset
+func formatTimeout(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.String()
+}
+
 // parseURL parses a URL from a raw string. Returns error if parsing fails.
 func parseURL(uri string) (*url.URL, error) {
 	u, err := url.ParseRequestURI(uri)
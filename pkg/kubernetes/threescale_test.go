@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ghodss/yaml"
 
@@ -94,6 +95,18 @@ func TestNewThreescaleHandlerSpec(t *testing.T) {
 	}
 }
 
+func TestHandlerSpecSetTimeouts(t *testing.T) {
+	h, err := NewThreescaleHandlerSpec("token", "https://www.fake-system.3scale.net", "")
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	h.SetTimeouts(2*time.Second, 0, 500*time.Millisecond)
+	if h.Params.ClientTimeout != "2s" || h.Params.SystemTimeout != "" || h.Params.BackendTimeout != "500ms" {
+		t.Errorf("unexpected timeouts %q, %q and %q", h.Params.ClientTimeout, h.Params.SystemTimeout, h.Params.BackendTimeout)
+	}
+}
+
 func TestNewApiKeyInstance(t *testing.T) {
 	expect := `params:
   action:
@@ -101,4 +101,6 @@ type ConfigGenerator struct {
 	name      string
 	namespace string
 	outputAs  OutputFormat
+	// handlerAnnotations are added to the metadata of the handler
+	handlerAnnotations map[string]string
 }
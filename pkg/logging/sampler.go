@@ -0,0 +1,222 @@
+// Package logging provides helpers to keep the adapter log readable under sustained failure
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultInterval is the sampling window used when none is provided
+	DefaultInterval = time.Minute
+
+	// maxEntries bounds the number of distinct messages tracked. Tracking is reset once exceeded,
+	// which at worst allows a burst of messages through.
+	maxEntries = 1000
+)
+
+// Sampler limits how often identical messages are logged. Within each interval, the first occurrences of a message
+// are logged and the remainder suppressed, with the number suppressed reported when the message is next logged or,
+// for messages which do not recur, by Flush once their interval has ended.
+// Sampling can be reconfigured at runtime and is disabled while first is zero.
+type Sampler struct {
+	mutex    sync.Mutex
+	first    int
+	interval time.Duration
+	entries  map[string]*sampleEntry
+	// pending holds the suppressed counts of messages forgotten before they could be flushed
+	pending map[string]int
+	now     func() time.Time
+}
+
+type sampleEntry struct {
+	windowStart time.Time
+	count       int
+	suppressed  int
+}
+
+// SamplerConfig describes the sampling applied by a Sampler
+type SamplerConfig struct {
+	// First is the number of identical messages logged per interval. Zero disables sampling
+	First int `json:"first"`
+	// IntervalSeconds is the length of the sampling window
+	IntervalSeconds int `json:"interval_seconds"`
+}
+
+// NewSampler returns a Sampler which logs the first identical messages in each interval
+func NewSampler(first int, interval time.Duration) *Sampler {
+	s := &Sampler{
+		entries: make(map[string]*sampleEntry),
+		pending: make(map[string]int),
+		now:     time.Now,
+	}
+	s.Configure(first, interval)
+	return s
+}
+
+// Configure updates the sampling applied. Previously tracked messages are forgotten, other than the number suppressed,
+// which is left to be flushed.
+func (s *Sampler) Configure(first int, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	if first < 0 {
+		first = 0
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.first = first
+	s.interval = interval
+	s.reset()
+}
+
+// reset forgets every tracked message, keeping the number suppressed to be flushed. The mutex must be held.
+func (s *Sampler) reset() {
+	for msg, entry := range s.entries {
+		if entry.suppressed > 0 && len(s.pending) < maxEntries {
+			s.pending[msg] += entry.suppressed
+		}
+	}
+	s.entries = make(map[string]*sampleEntry)
+}
+
+// Config returns the sampling currently applied
+func (s *Sampler) Config() SamplerConfig {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return SamplerConfig{
+		First:           s.first,
+		IntervalSeconds: int(s.interval / time.Second),
+	}
+}
+
+// Sample reports whether msg should be logged and, if so, how many identical messages were suppressed since
+// it was last logged. A nil Sampler logs every message.
+func (s *Sampler) Sample(msg string) (bool, int) {
+	if s == nil {
+		return true, 0
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.first == 0 {
+		return true, 0
+	}
+
+	now := s.now()
+	entry, ok := s.entries[msg]
+	if !ok {
+		if len(s.entries) >= maxEntries {
+			s.reset()
+		}
+		entry = &sampleEntry{windowStart: now}
+		s.entries[msg] = entry
+	}
+
+	if now.Sub(entry.windowStart) >= s.interval {
+		entry.windowStart = now
+		entry.count = 0
+	}
+
+	entry.count++
+	if entry.count > s.first {
+		entry.suppressed++
+		return false, 0
+	}
+
+	suppressed := entry.suppressed
+	entry.suppressed = 0
+	return true, suppressed
+}
+
+// Flush returns the number of identical messages suppressed, by message, within intervals which have ended without the
+// message being logged again, and forgets those messages, so that suppressed messages which do not recur are still
+// accounted for. A nil Sampler suppresses nothing.
+func (s *Sampler) Flush() map[string]int {
+	if s == nil {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	flushed := s.pending
+	s.pending = make(map[string]int)
+
+	now := s.now()
+	for msg, entry := range s.entries {
+		if now.Sub(entry.windowStart) < s.interval {
+			continue
+		}
+
+		if entry.suppressed > 0 {
+			flushed[msg] += entry.suppressed
+		}
+		delete(s.entries, msg)
+	}
+	return flushed
+}
+
+// RunFlush calls report with each message returned by Flush at the end of every interval, as currently configured,
+// until stop is closed
+func (s *Sampler) RunFlush(report func(msg string, suppressed int), stop <-chan struct{}) {
+	for {
+		s.mutex.Lock()
+		interval := s.interval
+		s.mutex.Unlock()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+			for msg, suppressed := range s.Flush() {
+				report(msg, suppressed)
+			}
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// ServeHTTP responds with the current sampling configuration and, for PUT requests,
+// applies the "first" and "interval_seconds" query parameters beforehand. It does not authenticate clients, so must
+// only accept changes where the caller does, see admin.ReadOnly.
+func (s *Sampler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		conf := s.Config()
+		query := r.URL.Query()
+
+		var err error
+		if v := query.Get("first"); v != "" {
+			if conf.First, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "invalid value for first", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if v := query.Get("interval_seconds"); v != "" {
+			if conf.IntervalSeconds, err = strconv.Atoi(v); err != nil {
+				http.Error(w, "invalid value for interval_seconds", http.StatusBadRequest)
+				return
+			}
+		}
+
+		s.Configure(conf.First, time.Duration(conf.IntervalSeconds)*time.Second)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Config())
+}
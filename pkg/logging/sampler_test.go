@@ -0,0 +1,156 @@
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSampler(2, time.Minute)
+	s.now = func() time.Time {
+		return now
+	}
+
+	var logged int
+	for i := 0; i < 10; i++ {
+		if ok, _ := s.Sample("backend unavailable"); ok {
+			logged++
+		}
+	}
+
+	if logged != 2 {
+		t.Errorf("expected 2 messages to be logged within the interval but got %d", logged)
+	}
+
+	if ok, _ := s.Sample("another error"); !ok {
+		t.Errorf("expected distinct message to be logged")
+	}
+
+	now = now.Add(time.Minute)
+	ok, suppressed := s.Sample("backend unavailable")
+	if !ok || suppressed != 8 {
+		t.Errorf("expected message to be logged with 8 suppressed in the next interval but got %v, %d", ok, suppressed)
+	}
+
+	if _, suppressed = s.Sample("backend unavailable"); suppressed != 0 {
+		t.Errorf("expected suppressed count to be reset once reported but got %d", suppressed)
+	}
+}
+
+func TestSamplerFlush(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSampler(1, time.Minute)
+	s.now = func() time.Time {
+		return now
+	}
+
+	for i := 0; i < 4; i++ {
+		s.Sample("backend unavailable")
+	}
+	s.Sample("another error")
+
+	if flushed := s.Flush(); len(flushed) != 0 {
+		t.Errorf("expected nothing to be flushed within the interval but got %v", flushed)
+	}
+
+	now = now.Add(time.Minute)
+	flushed := s.Flush()
+	if len(flushed) != 1 || flushed["backend unavailable"] != 3 {
+		t.Errorf("expected 3 suppressed messages to be flushed once the interval ended but got %v", flushed)
+	}
+
+	if ok, suppressed := s.Sample("backend unavailable"); !ok || suppressed != 0 {
+		t.Errorf("expected flushed messages not to be reported again but got %v, %d", ok, suppressed)
+	}
+
+	s.Sample("backend unavailable")
+	s.Configure(1, time.Minute)
+	if flushed := s.Flush(); flushed["backend unavailable"] != 1 {
+		t.Errorf("expected messages suppressed before reconfiguring to be flushed but got %v", flushed)
+	}
+
+	var nilSampler *Sampler
+	if flushed := nilSampler.Flush(); len(flushed) != 0 {
+		t.Errorf("expected nil sampler to flush nothing but got %v", flushed)
+	}
+}
+
+func TestSamplerRunFlush(t *testing.T) {
+	s := NewSampler(1, 10*time.Millisecond)
+	s.Sample("backend unavailable")
+	s.Sample("backend unavailable")
+
+	reported := make(chan int, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.RunFlush(func(msg string, suppressed int) {
+		reported <- suppressed
+	}, stop)
+
+	select {
+	case suppressed := <-reported:
+		if suppressed != 1 {
+			t.Errorf("expected 1 suppressed message to be reported but got %d", suppressed)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected suppressed messages to be reported once the interval ended")
+	}
+}
+
+func TestSamplerDisabled(t *testing.T) {
+	var nilSampler *Sampler
+	if ok, _ := nilSampler.Sample("msg"); !ok {
+		t.Errorf("expected nil sampler to log every message")
+	}
+
+	s := NewSampler(0, time.Minute)
+	for i := 0; i < 10; i++ {
+		if ok, _ := s.Sample("msg"); !ok {
+			t.Errorf("expected every message to be logged when sampling is disabled")
+		}
+	}
+}
+
+func TestSamplerServeHTTP(t *testing.T) {
+	s := NewSampler(5, time.Minute)
+
+	inputs := []struct {
+		method       string
+		query        string
+		expectStatus int
+		expectConf   SamplerConfig
+	}{
+		{method: http.MethodGet, expectStatus: http.StatusOK, expectConf: SamplerConfig{First: 5, IntervalSeconds: 60}},
+		{method: http.MethodPut, query: "?first=1&interval_seconds=10", expectStatus: http.StatusOK, expectConf: SamplerConfig{First: 1, IntervalSeconds: 10}},
+		{method: http.MethodPut, query: "?first=0", expectStatus: http.StatusOK, expectConf: SamplerConfig{First: 0, IntervalSeconds: 10}},
+		{method: http.MethodPut, query: "?first=invalid", expectStatus: http.StatusBadRequest},
+		{method: http.MethodDelete, expectStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, input := range inputs {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(input.method, "/logging/sampling"+input.query, nil))
+
+		if rec.Code != input.expectStatus {
+			t.Errorf("unexpected status for %s %s - %d", input.method, input.query, rec.Code)
+			continue
+		}
+
+		if rec.Code != http.StatusOK {
+			continue
+		}
+
+		var conf SamplerConfig
+		if err := json.NewDecoder(rec.Body).Decode(&conf); err != nil {
+			t.Fatalf("unexpected error decoding response - %v", err)
+		}
+
+		if conf != input.expectConf {
+			t.Errorf("unexpected config for %s %s - %+v", input.method, input.query, conf)
+		}
+	}
+}
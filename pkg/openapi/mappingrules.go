@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// hitsMetric is the metric every mapping rule created is counted against
+	hitsMetric = "hits"
+
+	metricsEndpoint      = "/admin/api/services/%s/metrics.json"
+	mappingRulesEndpoint = "/admin/api/services/%s/proxy/mapping_rules.json"
+	mappingRuleEndpoint  = "/admin/api/services/%s/proxy/mapping_rules/%d.json"
+)
+
+// SyncResult describes the changes made to the mapping rules of a service
+type SyncResult struct {
+	Created   []Operation
+	Unchanged []Operation
+	// Deleted holds the method and pattern of each rule deleted
+	Deleted []string
+}
+
+type metricsResponse struct {
+	Metrics []struct {
+		Metric struct {
+			ID         int64  `json:"id"`
+			SystemName string `json:"system_name"`
+		} `json:"metric"`
+	} `json:"metrics"`
+}
+
+type mappingRulesResponse struct {
+	MappingRules []struct {
+		MappingRule struct {
+			ID         int64  `json:"id"`
+			HTTPMethod string `json:"http_method"`
+			Pattern    string `json:"pattern"`
+		} `json:"mapping_rule"`
+	} `json:"mapping_rules"`
+}
+
+// RuleSyncer creates the mapping rules for a set of operations via the 3scale Account Management API
+type RuleSyncer struct {
+	client      *http.Client
+	systemURL   string
+	accessToken string
+}
+
+// NewRuleSyncer returns a RuleSyncer for the provided 3scale admin portal. If client is nil, http.DefaultClient is used.
+func NewRuleSyncer(client *http.Client, systemURL string, accessToken string) *RuleSyncer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &RuleSyncer{
+		client:      client,
+		systemURL:   strings.TrimSuffix(systemURL, "/"),
+		accessToken: accessToken,
+	}
+}
+
+// Sync creates a mapping rule, counting one hit, for each operation which the service does not already have a rule for
+// with the same method and pattern. When prune is true, rules which match none of the operations are deleted.
+func (r *RuleSyncer) Sync(serviceID string, ops []Operation, prune bool) (SyncResult, error) {
+	var result SyncResult
+	service := url.PathEscape(serviceID)
+
+	metrics := &metricsResponse{}
+	if err := r.do(http.MethodGet, fmt.Sprintf(metricsEndpoint, service), nil, metrics); err != nil {
+		return result, fmt.Errorf("error fetching metrics - %v", err)
+	}
+
+	var metricID int64
+	for _, m := range metrics.Metrics {
+		if m.Metric.SystemName == hitsMetric {
+			metricID = m.Metric.ID
+		}
+	}
+	if metricID == 0 {
+		return result, fmt.Errorf("service %s has no %s metric", serviceID, hitsMetric)
+	}
+
+	rules := &mappingRulesResponse{}
+	if err := r.do(http.MethodGet, fmt.Sprintf(mappingRulesEndpoint, service), nil, rules); err != nil {
+		return result, fmt.Errorf("error fetching mapping rules - %v", err)
+	}
+
+	existing := make(map[string]bool)
+	for _, rule := range rules.MappingRules {
+		existing[ruleKey(rule.MappingRule.HTTPMethod, rule.MappingRule.Pattern)] = true
+	}
+
+	wanted := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		key := ruleKey(op.Method, op.Pattern())
+		wanted[key] = true
+		if existing[key] {
+			result.Unchanged = append(result.Unchanged, op)
+			continue
+		}
+
+		form := url.Values{}
+		form.Set("http_method", op.Method)
+		form.Set("pattern", op.Pattern())
+		form.Set("metric_id", strconv.FormatInt(metricID, 10))
+		form.Set("delta", "1")
+		if err := r.do(http.MethodPost, fmt.Sprintf(mappingRulesEndpoint, service), form, nil); err != nil {
+			return result, fmt.Errorf("error creating mapping rule %s - %v", key, err)
+		}
+		result.Created = append(result.Created, op)
+	}
+
+	if !prune {
+		return result, nil
+	}
+
+	for _, rule := range rules.MappingRules {
+		key := ruleKey(rule.MappingRule.HTTPMethod, rule.MappingRule.Pattern)
+		if wanted[key] {
+			continue
+		}
+
+		if err := r.do(http.MethodDelete, fmt.Sprintf(mappingRuleEndpoint, service, rule.MappingRule.ID), nil, nil); err != nil {
+			return result, fmt.Errorf("error deleting mapping rule %s - %v", key, err)
+		}
+		result.Deleted = append(result.Deleted, key)
+	}
+	return result, nil
+}
+
+// ruleKey identifies a mapping rule by its method and pattern
+func ruleKey(method string, pattern string) string {
+	return strings.ToUpper(method) + " " + pattern
+}
+
+// do calls the endpoint, sending the access token with any form values, and decodes the response into when provided
+func (r *RuleSyncer) do(method string, endpoint string, form url.Values, into interface{}) error {
+	if form == nil {
+		form = url.Values{}
+	}
+	form.Set("access_token", r.accessToken)
+
+	var body io.Reader
+	target := r.systemURL + endpoint
+	if method == http.MethodPost {
+		body = strings.NewReader(form.Encode())
+	} else {
+		target += "?" + form.Encode()
+	}
+
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	if into == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
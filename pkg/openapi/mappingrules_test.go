@@ -0,0 +1,100 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRuleSyncerSync(t *testing.T) {
+	const existingRules = `{"mapping_rules": [
+		{"mapping_rule": {"id": 1, "http_method": "GET", "pattern": "/pets$"}},
+		{"mapping_rule": {"id": 2, "http_method": "GET", "pattern": "/"}}
+	]}`
+
+	inputs := []struct {
+		name          string
+		prune         bool
+		metrics       string
+		expectCreated []string
+		expectDeleted []string
+		expectErr     bool
+	}{
+		{
+			name:          "Test missing rules are created",
+			metrics:       `{"metrics": [{"metric": {"id": 7, "system_name": "hits"}}]}`,
+			expectCreated: []string{"POST /pets$ 7", "GET /pets/{petId}$ 7"},
+		},
+		{
+			name:          "Test rules outside the document are pruned",
+			prune:         true,
+			metrics:       `{"metrics": [{"metric": {"id": 7, "system_name": "hits"}}]}`,
+			expectCreated: []string{"POST /pets$ 7", "GET /pets/{petId}$ 7"},
+			expectDeleted: []string{"/admin/api/services/123/proxy/mapping_rules/2.json"},
+		},
+		{
+			name:      "Test service without hits metric",
+			metrics:   `{"metrics": []}`,
+			expectErr: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var created, deleted []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil || r.Form.Get("access_token") != "token" {
+					t.Errorf("expected access token to be sent")
+				}
+
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/admin/api/services/123/metrics.json":
+					fmt.Fprint(w, input.metrics)
+				case r.Method == http.MethodGet && r.URL.Path == "/admin/api/services/123/proxy/mapping_rules.json":
+					fmt.Fprint(w, existingRules)
+				case r.Method == http.MethodPost && r.URL.Path == "/admin/api/services/123/proxy/mapping_rules.json":
+					created = append(created, fmt.Sprintf("%s %s %s", r.Form.Get("http_method"), r.Form.Get("pattern"), r.Form.Get("metric_id")))
+					w.WriteHeader(http.StatusCreated)
+				case r.Method == http.MethodDelete:
+					deleted = append(deleted, r.URL.Path)
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			ops := []Operation{
+				{Method: "GET", Path: "/pets"},
+				{Method: "POST", Path: "/pets"},
+				{Method: "GET", Path: "/pets/{petId}"},
+			}
+
+			result, err := NewRuleSyncer(server.Client(), server.URL+"/", "token").Sync("123", ops, input.prune)
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error syncing rules")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if !reflect.DeepEqual(created, input.expectCreated) {
+				t.Errorf("unexpected rules created, wanted %v but got %v", input.expectCreated, created)
+			}
+
+			if !reflect.DeepEqual(deleted, input.expectDeleted) {
+				t.Errorf("unexpected rules deleted, wanted %v but got %v", input.expectDeleted, deleted)
+			}
+
+			if len(result.Unchanged) != 1 || len(result.Created) != 2 || len(result.Deleted) != len(input.expectDeleted) {
+				t.Errorf("unexpected result %+v", result)
+			}
+		})
+	}
+}
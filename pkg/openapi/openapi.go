@@ -0,0 +1,135 @@
+// Package openapi derives 3scale mapping rules, and the Istio match conditions which route the same requests to the
+// adapter, from an OpenAPI document, so that accounting at the gateway and in the mesh follows the API contract.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+var (
+	errUnsupportedVersion = errors.New("document is neither Swagger 2.0 nor OpenAPI 3")
+	errNoOperations       = errors.New("document does not describe any operations")
+
+	// pathParam matches a templated segment, such as {petId}
+	pathParam = regexp.MustCompile(`{[^/{}]+}`)
+)
+
+// operationMethods are the keys of a path item which describe an operation, rather than shared fields
+var operationMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true, "options": true, "head": true, "patch": true, "trace": true,
+}
+
+// Operation is an HTTP method and path template described by the document, with the base path applied
+type Operation struct {
+	Method string
+	Path   string
+}
+
+// Document is the subset of an OpenAPI document required to derive its operations
+type Document struct {
+	Swagger  string `json:"swagger"`
+	OpenAPI  string `json:"openapi"`
+	BasePath string `json:"basePath"`
+	Servers  []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// Parse reads a Swagger 2.0 or OpenAPI 3 document, in either JSON or YAML
+func Parse(data []byte) (*Document, error) {
+	b, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse document - %v", err)
+	}
+
+	doc := &Document{}
+	if err := json.Unmarshal(b, doc); err != nil {
+		return nil, fmt.Errorf("unable to parse document - %v", err)
+	}
+
+	if doc.Swagger != "2.0" && !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, errUnsupportedVersion
+	}
+
+	if len(doc.Operations()) == 0 {
+		return nil, errNoOperations
+	}
+	return doc, nil
+}
+
+// basePath returns the path all operations are relative to, taken from basePath for Swagger 2.0 and from the
+// first server for OpenAPI 3, without a trailing slash
+func (d *Document) basePath() string {
+	base := d.BasePath
+	if d.OpenAPI != "" && len(d.Servers) > 0 {
+		if u, err := url.Parse(d.Servers[0].URL); err == nil {
+			base = u.Path
+		}
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// Operations returns the operations described by the document, ordered by path and then method
+func (d *Document) Operations() []Operation {
+	base := d.basePath()
+
+	var ops []Operation
+	for path, item := range d.Paths {
+		for method := range item {
+			if operationMethods[strings.ToLower(method)] {
+				ops = append(ops, Operation{Method: strings.ToUpper(method), Path: base + path})
+			}
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+	return ops
+}
+
+// Pattern returns the 3scale mapping rule pattern for the operation. Path parameters are kept as wildcards and the
+// pattern is anchored, so that a request is only counted against the operation it matches exactly.
+func (o Operation) Pattern() string {
+	return o.Path + "$"
+}
+
+// regexp returns an anchored regular expression matching request paths for the operation
+func (o Operation) regexp() string {
+	var expr strings.Builder
+	last := 0
+	for _, loc := range pathParam.FindAllStringIndex(o.Path, -1) {
+		expr.WriteString(regexp.QuoteMeta(o.Path[last:loc[0]]))
+		expr.WriteString("[^/]+")
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(o.Path[last:]))
+	return "^" + expr.String() + "$"
+}
+
+// MatchCondition returns an Istio match condition which holds for requests to any of the operations
+func MatchCondition(ops []Operation) string {
+	conditions := make([]string, 0, len(ops))
+	for _, op := range ops {
+		conditions = append(conditions, fmt.Sprintf(`(request.method == "%s" && %s.matches(request.url_path))`,
+			op.Method, quote(op.regexp())))
+	}
+	return "(" + strings.Join(conditions, " ||\n") + ")"
+}
+
+// quote quotes s as a string literal of the Istio expression language
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,102 @@
+package openapi
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	petstore, err := ioutil.ReadFile(filepath.Join("testdata", "petstore.yaml"))
+	if err != nil {
+		t.Fatalf("error reading testdata - %v", err)
+	}
+
+	inputs := []struct {
+		name      string
+		document  string
+		expect    []Operation
+		expectErr bool
+	}{
+		{
+			name:     "Test OpenAPI 3 document with server path",
+			document: string(petstore),
+			expect: []Operation{
+				{Method: "GET", Path: "/v1/pets"},
+				{Method: "POST", Path: "/v1/pets"},
+				{Method: "GET", Path: "/v1/pets/{petId}"},
+			},
+		},
+		{
+			name:     "Test Swagger 2.0 JSON document with base path",
+			document: `{"swagger": "2.0", "basePath": "/api/", "paths": {"/users": {"delete": {}, "parameters": []}}}`,
+			expect:   []Operation{{Method: "DELETE", Path: "/api/users"}},
+		},
+		{
+			name:      "Test unsupported version",
+			document:  `{"swagger": "1.2", "paths": {"/users": {"get": {}}}}`,
+			expectErr: true,
+		},
+		{
+			name:      "Test document without operations",
+			document:  `{"openapi": "3.0.1", "paths": {"/users": {"summary": "none"}}}`,
+			expectErr: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			doc, err := Parse([]byte(input.document))
+			if input.expectErr {
+				if err == nil {
+					t.Errorf("expected error parsing document")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if ops := doc.Operations(); !reflect.DeepEqual(ops, input.expect) {
+				t.Errorf("unexpected operations, wanted %v but got %v", input.expect, ops)
+			}
+		})
+	}
+}
+
+func TestOperationRegexp(t *testing.T) {
+	op := Operation{Method: "GET", Path: "/v1.0/pets/{petId}/toys/{toyId}"}
+	if op.Pattern() != "/v1.0/pets/{petId}/toys/{toyId}$" {
+		t.Errorf("unexpected pattern %s", op.Pattern())
+	}
+
+	expr := regexp.MustCompile(op.regexp())
+	for path, expect := range map[string]bool{
+		"/v1.0/pets/1/toys/ball":   true,
+		"/v1x0/pets/1/toys/ball":   false,
+		"/v1.0/pets/1/2/toys/ball": false,
+		"/v1.0/pets/1/toys/ball/x": false,
+		"/v1.0/pets//toys/ball":    false,
+	} {
+		if expr.MatchString(path) != expect {
+			t.Errorf("unexpected match of %s by %s, wanted %v", path, expr, expect)
+		}
+	}
+}
+
+func TestMatchCondition(t *testing.T) {
+	ops := []Operation{
+		{Method: "GET", Path: "/pets"},
+		{Method: "GET", Path: "/pets/{petId}"},
+	}
+
+	expect := `((request.method == "GET" && "^/pets$".matches(request.url_path)) ||
+(request.method == "GET" && "^/pets/[^/]+$".matches(request.url_path)))`
+
+	if got := MatchCondition(ops); got != expect {
+		t.Errorf("unexpected condition, wanted\n%s\nbut got\n%s", expect, got)
+	}
+}
@@ -0,0 +1,242 @@
+// Package redis provides a minimal client for the few Redis commands used to share cached state between replicas
+// of the adapter, speaking the RESP protocol directly over TCP.
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/pkg/secrets"
+)
+
+const (
+	// DefaultTimeout bounds each command, including dialing a new connection
+	DefaultTimeout = time.Second
+	// DefaultMaxIdle is the number of connections kept open between commands
+	DefaultMaxIdle = 4
+)
+
+var errUnexpectedReply = errors.New("unexpected reply from redis")
+
+// Config configures a Client
+type Config struct {
+	// Addr is the host and port of the Redis server
+	Addr string
+	// Password is optional and sent with AUTH on each new connection
+	Password string
+	// DB is the database selected on each new connection
+	DB int
+	// Timeout bounds each command, defaulting to DefaultTimeout
+	Timeout time.Duration
+	// MaxIdle is the number of connections kept open between commands, defaulting to DefaultMaxIdle
+	MaxIdle int
+}
+
+// Client runs commands against a Redis server, reusing idle connections
+type Client struct {
+	conf Config
+	idle chan *conn
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Error is an error reply from the Redis server
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewClient returns a Client for the configured server. Connections are made when first needed.
+func NewClient(conf Config) *Client {
+	if conf.Timeout <= 0 {
+		conf.Timeout = DefaultTimeout
+	}
+
+	if conf.MaxIdle <= 0 {
+		conf.MaxIdle = DefaultMaxIdle
+	}
+
+	return &Client{
+		conf: conf,
+		idle: make(chan *conn, conf.MaxIdle),
+	}
+}
+
+// Get returns the value of key, and false if it does not exist
+func (c *Client) Get(key string) ([]byte, bool, error) {
+	reply, err := c.do("GET", []byte(key))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if reply == nil {
+		return nil, false, nil
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, errUnexpectedReply
+	}
+	return value, true, nil
+}
+
+// Set stores value under key, expiring after ttl
+func (c *Client) Set(key string, value []byte, ttl time.Duration) error {
+	ms := int64(ttl / time.Millisecond)
+	if ms <= 0 {
+		return fmt.Errorf("invalid ttl %s", ttl)
+	}
+
+	_, err := c.do("SET", []byte(key), value, []byte("PX"), []byte(strconv.FormatInt(ms, 10)))
+	return err
+}
+
+// Ping checks that the server can be reached
+func (c *Client) Ping() error {
+	_, err := c.do("PING")
+	return err
+}
+
+// Close closes the idle connections
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do runs a command on an idle or new connection. The connection is discarded after a network or protocol
+// error, since its state is then unknown, but reused after an error reply.
+func (c *Client) do(cmd string, args ...[]byte) (interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(c.conf.Timeout, cmd, args...)
+	if _, ok := err.(Error); err != nil && !ok {
+		cn.Close()
+		return nil, err
+	}
+
+	c.put(cn)
+	return reply, err
+}
+
+func (c *Client) get() (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	nc, err := net.DialTimeout("tcp", c.conf.Addr, c.conf.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+
+	if c.conf.Password != "" {
+		if _, err := cn.do(c.conf.Timeout, "AUTH", []byte(c.conf.Password)); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+
+	if c.conf.DB != 0 {
+		if _, err := cn.do(c.conf.Timeout, "SELECT", []byte(strconv.Itoa(c.conf.DB))); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+func (cn *conn) do(timeout time.Duration, cmd string, args ...[]byte) (interface{}, error) {
+	if err := cn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	buf := []byte("*" + strconv.Itoa(len(args)+1) + "\r\n")
+	buf = appendBulk(buf, []byte(cmd))
+	for _, arg := range args {
+		buf = appendBulk(buf, arg)
+	}
+
+	if cmd == "AUTH" {
+		// the password is also held by the config, but need not be left in another buffer
+		defer secrets.Zero(buf)
+	}
+
+	if _, err := cn.Write(buf); err != nil {
+		return nil, err
+	}
+	return cn.readReply()
+}
+
+func appendBulk(buf []byte, b []byte) []byte {
+	buf = append(buf, '$')
+	buf = strconv.AppendInt(buf, int64(len(b)), 10)
+	buf = append(buf, '\r', '\n')
+	buf = append(buf, b...)
+	return append(buf, '\r', '\n')
+}
+
+// readReply reads a simple string, error, integer or bulk string reply. A missing bulk string is returned as nil.
+func (cn *conn) readReply() (interface{}, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errUnexpectedReply
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, errUnexpectedReply
+		}
+
+		if n < 0 {
+			return nil, nil
+		}
+
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(cn.r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+	return nil, errUnexpectedReply
+}
@@ -0,0 +1,160 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer implements enough of Redis to test the client, recording the commands received
+type fakeServer struct {
+	listener net.Listener
+	password string
+	mutex    sync.Mutex
+	values   map[string]string
+	commands []string
+}
+
+func newFakeServer(t *testing.T, password string) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	s := &fakeServer{listener: l, password: password, values: make(map[string]string)}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := s.password == ""
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			if args[1] != s.password {
+				reply = "-ERR invalid password\r\n"
+			} else {
+				authed = true
+				reply = "+OK\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "GET":
+			if v, ok := s.values[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case args[0] == "SET":
+			s.values[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case args[0] == "SELECT", args[0] == "PING":
+			reply = "+OK\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mutex.Unlock()
+
+		if _, err := io.WriteString(c, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func TestClient(t *testing.T) {
+	server := newFakeServer(t, "secret")
+	defer server.listener.Close()
+
+	c := NewClient(Config{Addr: server.listener.Addr().String(), Password: "secret", DB: 2})
+	defer c.Close()
+
+	if _, ok, err := c.Get("missing"); ok || err != nil {
+		t.Errorf("expected missing key not to be found - %v", err)
+	}
+
+	if err := c.Set("key", []byte("value\r\nwith newline"), time.Minute); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	value, ok, err := c.Get("key")
+	if !ok || err != nil || string(value) != "value\r\nwith newline" {
+		t.Errorf("unexpected value %q - %v", value, err)
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	expect := []string{"AUTH secret", "SELECT 2", "GET missing", "SET key value\r\nwith newline PX 60000", "GET key"}
+	if strings.Join(server.commands, "|") != strings.Join(expect, "|") {
+		t.Errorf("expected commands %q on a single connection but got %q", expect, server.commands)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server := newFakeServer(t, "secret")
+	defer server.listener.Close()
+
+	c := NewClient(Config{Addr: server.listener.Addr().String(), Password: "wrong"})
+	if err := c.Ping(); err == nil || !strings.Contains(err.Error(), "invalid password") {
+		t.Errorf("expected error for invalid password but got %v", err)
+	}
+
+	if err := c.Set("key", []byte("value"), 0); err == nil {
+		t.Errorf("expected error for invalid ttl")
+	}
+
+	server.listener.Close()
+	c = NewClient(Config{Addr: server.listener.Addr().String(), Timeout: 100 * time.Millisecond})
+	if _, _, err := c.Get("key"); err == nil {
+		t.Errorf("expected error when the server cannot be reached")
+	}
+}
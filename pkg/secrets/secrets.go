@@ -0,0 +1,41 @@
+// Package secrets provides helpers for handling credentials, such as access tokens and shared secrets, so that they
+// are not leaked by timing, left in memory or exposed in process arguments.
+package secrets
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+)
+
+// Equal reports whether a and b are equal in constant time. Both are hashed first, so that neither the content nor
+// the length of the expected secret can be learned from how long a comparison takes.
+func Equal(a string, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// Zero overwrites b, so that a credential copied into it does not linger in memory once used.
+// Strings are immutable, so only the copies of a credential held in byte slices can be zeroed.
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// InArgs reports whether any of the secrets appears in args, as would be visible to other users of the host
+// in the process list. Empty secrets are ignored.
+func InArgs(args []string, secrets ...string) bool {
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+
+		for _, arg := range args {
+			if strings.Contains(arg, secret) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package secrets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	inputs := []struct {
+		name   string
+		a      string
+		b      string
+		expect bool
+	}{
+		{name: "Test equal secrets", a: "secret", b: "secret", expect: true},
+		{name: "Test different secrets", a: "secret", b: "secreT"},
+		{name: "Test prefix of secret", a: "secret", b: "secre"},
+		{name: "Test empty secret", a: "", b: "secret"},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			if Equal(input.a, input.b) != input.expect {
+				t.Errorf("expected %v comparing %q and %q", input.expect, input.a, input.b)
+			}
+		})
+	}
+}
+
+func TestZero(t *testing.T) {
+	b := []byte("secret")
+	Zero(b)
+	if !bytes.Equal(b, make([]byte, 6)) {
+		t.Errorf("expected buffer to be zeroed but got %q", b)
+	}
+}
+
+func TestInArgs(t *testing.T) {
+	args := []string{"--url=https://system", "--token=secret"}
+
+	if !InArgs(args, "", "secret") {
+		t.Errorf("expected secret to be found in args")
+	}
+
+	if InArgs(args, "", "other") {
+		t.Errorf("expected no secret to be found in args")
+	}
+}
@@ -0,0 +1,233 @@
+// Package selftest periodically verifies that the adapter can reach, and authenticate against, 3scale
+package selftest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Target identifies the 3scale API being probed
+type Target string
+
+const (
+	// System is the 3scale Account Management API
+	System Target = "system"
+	// Backend is the 3scale Service Management API
+	Backend Target = "backend"
+
+	// DefaultInterval is the period between probes when none is provided
+	DefaultInterval = time.Minute * 5
+
+	proxyConfigEndpoint = "/admin/api/services/%s/proxy/configs/production/latest.json"
+	authorizeEndpoint   = "/transactions/authorize.xml"
+
+	// probeUserKey is a credential which is not expected to exist. A rejection which identifies the
+	// key as invalid proves the service credentials were accepted, without reporting any usage.
+	probeUserKey = "3scale-istio-adapter-self-test"
+)
+
+// backendCredentialErrors are the error codes returned by backend when the service credentials were accepted
+// but the probe user key is unknown
+var backendCredentialErrors = map[string]bool{
+	"user_key_invalid":      true,
+	"application_not_found": true,
+}
+
+// backendRejectedCredentials are the error codes returned by backend when the service credentials were rejected
+var backendRejectedCredentials = map[string]bool{
+	"service_token_invalid": true,
+	"provider_key_invalid":  true,
+	"access_denied":         true,
+}
+
+// ErrInvalidCredentials is returned when 3scale backend explicitly rejects the credentials configured for a service
+var ErrInvalidCredentials = errors.New("service credentials rejected by backend")
+
+// Config for the periodic self-test
+type Config struct {
+	// SystemURL, AccessToken and ServiceID identify the service used for the probe
+	SystemURL   string
+	AccessToken string
+	ServiceID   string
+	// Interval between probes
+	Interval time.Duration
+	// ResultCB is optional and called with the outcome of each probe
+	ResultCB func(target Target, err error)
+}
+
+// Prober runs a cheap authenticated call against 3scale system and backend at the configured interval
+// and records the outcome, which can be served as a health endpoint.
+type Prober struct {
+	client  *http.Client
+	conf    Config
+	mutex   sync.RWMutex
+	results map[Target]error
+}
+
+type proxyConfigResponse struct {
+	ProxyConfig struct {
+		Content struct {
+			BackendAuthenticationType  string `json:"backend_authentication_type"`
+			BackendAuthenticationValue string `json:"backend_authentication_value"`
+			Proxy                      struct {
+				Backend struct {
+					Endpoint string `json:"endpoint"`
+				} `json:"backend"`
+			} `json:"proxy"`
+		} `json:"content"`
+	} `json:"proxy_config"`
+}
+
+type backendError struct {
+	Code string `xml:"code,attr"`
+}
+
+// NewProber returns a Prober. Probes do not run until Start is called.
+func NewProber(client *http.Client, conf Config) *Prober {
+	if conf.Interval <= 0 {
+		conf.Interval = DefaultInterval
+	}
+
+	return &Prober{
+		client:  client,
+		conf:    conf,
+		results: make(map[Target]error),
+	}
+}
+
+// Start probes immediately and then at the configured interval, until stop is closed
+func (p *Prober) Start(stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(p.conf.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.Run()
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Run probes system and then backend, using the backend endpoint and credentials configured in system
+func (p *Prober) Run() {
+	conf, err := p.probeSystem()
+	p.record(System, err)
+
+	if err != nil {
+		p.record(Backend, fmt.Errorf("unable to determine backend from system - %s", err.Error()))
+		return
+	}
+
+	p.record(Backend, p.probeBackend(conf))
+}
+
+// Healthy returns an error describing each failed probe, or nil if the last probe of each target succeeded
+func (p *Prober) Healthy() error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var errMsgs []string
+	for _, target := range []Target{System, Backend} {
+		if err := p.results[target]; err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("%s: %s", target, err.Error()))
+		}
+	}
+
+	if len(errMsgs) > 0 {
+		return errors.New(strings.Join(errMsgs, ", "))
+	}
+	return nil
+}
+
+// ServeHTTP responds with 503 when the last probe failed
+func (p *Prober) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := p.Healthy(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (p *Prober) record(target Target, err error) {
+	p.mutex.Lock()
+	p.results[target] = err
+	p.mutex.Unlock()
+
+	if p.conf.ResultCB != nil {
+		p.conf.ResultCB(target, err)
+	}
+}
+
+func (p *Prober) probeSystem() (*proxyConfigResponse, error) {
+	query := url.Values{}
+	query.Set("access_token", p.conf.AccessToken)
+
+	endpoint := p.conf.SystemURL + fmt.Sprintf(proxyConfigEndpoint, url.PathEscape(p.conf.ServiceID))
+	resp, err := p.client.Get(endpoint + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	conf := &proxyConfigResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(conf); err != nil {
+		return nil, fmt.Errorf("failed to decode proxy config - %s", err.Error())
+	}
+	return conf, nil
+}
+
+// probeBackend verifies the backend credentials configured in system for the service
+func (p *Prober) probeBackend(conf *proxyConfigResponse) error {
+	content := conf.ProxyConfig.Content
+	return ValidateBackendCredentials(p.client, content.Proxy.Backend.Endpoint, p.conf.ServiceID,
+		content.BackendAuthenticationType, content.BackendAuthenticationValue)
+}
+
+// ValidateBackendCredentials verifies that 3scale backend accepts the credentials configured for a service, such as
+// a service token, by calling authorize, which does not report usage, with an unknown user key.
+// ErrInvalidCredentials is returned where backend explicitly rejects them.
+func ValidateBackendCredentials(client *http.Client, backendURL, serviceID, authType, authValue string) error {
+	query := url.Values{}
+	query.Set(authType, authValue)
+	query.Set("service_id", serviceID)
+	query.Set("user_key", probeUserKey)
+
+	resp, err := client.Get(backendURL + authorizeEndpoint + "?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	backendErr := &backendError{}
+	if err := xml.NewDecoder(resp.Body).Decode(backendErr); err != nil {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	if backendRejectedCredentials[backendErr.Code] {
+		return ErrInvalidCredentials
+	}
+
+	if !backendCredentialErrors[backendErr.Code] {
+		return fmt.Errorf("unexpected status code %d - %s", resp.StatusCode, backendErr.Code)
+	}
+	return nil
+}
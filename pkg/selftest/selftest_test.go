@@ -0,0 +1,138 @@
+package selftest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProber(t *testing.T) {
+	inputs := []struct {
+		name          string
+		accessToken   string
+		serviceToken  string
+		expectSystem  bool
+		expectBackend bool
+	}{
+		{
+			name:          "Test valid credentials",
+			accessToken:   "valid",
+			serviceToken:  "valid",
+			expectSystem:  true,
+			expectBackend: true,
+		},
+		{
+			name:          "Test expired access token",
+			accessToken:   "expired",
+			serviceToken:  "valid",
+			expectSystem:  false,
+			expectBackend: false,
+		},
+		{
+			name:          "Test invalid service token",
+			accessToken:   "valid",
+			serviceToken:  "invalid",
+			expectSystem:  true,
+			expectBackend: false,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != authorizeEndpoint || r.URL.Query().Get("usage") != "" {
+					t.Errorf("unexpected request to backend %s", r.URL.String())
+				}
+
+				w.WriteHeader(http.StatusForbidden)
+				if r.URL.Query().Get("service_token") != "valid" {
+					fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><error code="service_token_invalid">service token is invalid</error>`)
+					return
+				}
+				fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><error code="user_key_invalid">user key is invalid</error>`)
+			}))
+			defer backend.Close()
+
+			system := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != fmt.Sprintf(proxyConfigEndpoint, "123") || r.URL.Query().Get("access_token") != "valid" {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				fmt.Fprintf(w, `{"proxy_config":{"content":{"backend_authentication_type":"service_token",`+
+					`"backend_authentication_value":"%s","proxy":{"backend":{"endpoint":"%s"}}}}}`, input.serviceToken, backend.URL)
+			}))
+			defer system.Close()
+
+			reported := make(map[Target]bool)
+			p := NewProber(http.DefaultClient, Config{
+				SystemURL:   system.URL,
+				AccessToken: input.accessToken,
+				ServiceID:   "123",
+				ResultCB: func(target Target, err error) {
+					reported[target] = err == nil
+				},
+			})
+			p.Run()
+
+			if reported[System] != input.expectSystem || reported[Backend] != input.expectBackend {
+				t.Errorf("unexpected probe results %v", reported)
+			}
+
+			rec := httptest.NewRecorder()
+			p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			healthy := input.expectSystem && input.expectBackend
+			if healthy && rec.Code != http.StatusOK {
+				t.Errorf("expected healthy response but got %d - %s", rec.Code, rec.Body.String())
+			}
+
+			if !healthy {
+				if rec.Code != http.StatusServiceUnavailable {
+					t.Errorf("expected unhealthy response but got %d", rec.Code)
+				}
+
+				if !strings.Contains(rec.Body.String(), string(Backend)) {
+					t.Errorf("expected failed target in response body but got %s", rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBackendCredentials(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		switch r.URL.Query().Get("service_token") {
+		case "valid":
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><error code="user_key_invalid">user key is invalid</error>`)
+		case "invalid":
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><error code="service_token_invalid">service token is invalid</error>`)
+		default:
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><error code="unknown">unknown</error>`)
+		}
+	}))
+	defer backend.Close()
+
+	inputs := []struct {
+		token           string
+		expectErr       bool
+		expectRejection bool
+	}{
+		{token: "valid"},
+		{token: "invalid", expectErr: true, expectRejection: true},
+		{token: "other", expectErr: true},
+	}
+
+	for _, input := range inputs {
+		err := ValidateBackendCredentials(http.DefaultClient, backend.URL, "123", "service_token", input.token)
+		if (err != nil) != input.expectErr {
+			t.Errorf("unexpected result for token %s - %v", input.token, err)
+		}
+
+		if (err == ErrInvalidCredentials) != input.expectRejection {
+			t.Errorf("unexpected rejection for token %s - %v", input.token, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package statuses provides the rpc.Status returned for each authorization outcome, so that
+// codes and messages are consistent regardless of the protocol the decision is delivered over.
+package statuses
+
+import (
+	"net/http"
+
+	convert "github.com/3scale/3scale-go-client/threescale/http"
+	"github.com/gogo/googleapis/google/rpc"
+
+	"istio.io/istio/mixer/pkg/status"
+)
+
+// Constructor builds a status with the provided message
+type Constructor func(msg string) rpc.Status
+
+// OK is the status for an authorized request
+var OK = status.OK
+
+// LimitsExceeded is returned when the application has exceeded its usage limits - the equivalent of a 429
+func LimitsExceeded(msg string) rpc.Status {
+	return status.WithResourceExhausted(msg)
+}
+
+// InvalidCredentials is returned when 3scale rejects the credentials provided with the request
+func InvalidCredentials(msg string) rpc.Status {
+	return status.WithPermissionDenied(msg)
+}
+
+// MissingCredentials is returned when the request does not provide credentials in a supported location
+func MissingCredentials(msg string) rpc.Status {
+	return status.WithUnauthenticated(msg)
+}
+
+// MappingRuleMiss is returned when the request does not match any mapping rule
+func MappingRuleMiss(msg string) rpc.Status {
+	return status.WithNotFound(msg)
+}
+
+// MethodNotPermitted is returned when the application plan does not permit the request method
+func MethodNotPermitted(msg string) rpc.Status {
+	return status.WithPermissionDenied(msg)
+}
+
+// RequestTooLarge is returned when the request exceeds the maximum size permitted by the application plan
+func RequestTooLarge(msg string) rpc.Status {
+	return status.WithPermissionDenied(msg)
+}
+
+// MalformedRequest is returned when the request is missing headers required by the handler, or their values are invalid
+func MalformedRequest(msg string) rpc.Status {
+	return status.WithInvalidArgument(msg)
+}
+
+// Denied is returned when 3scale denies the request for a reason without a more specific status - the equivalent of a 403
+func Denied(msg string) rpc.Status {
+	return status.WithPermissionDenied(msg)
+}
+
+// InvalidConfig is returned when the handler or request is missing required configuration
+func InvalidConfig(msg string) rpc.Status {
+	return status.WithFailedPrecondition(msg)
+}
+
+// Internal is returned when the adapter fails to process the request
+func Internal(msg string) rpc.Status {
+	return status.WithInternal(msg)
+}
+
+// Unknown is returned when the cause of a failure cannot be determined
+func Unknown(msg string) rpc.Status {
+	return status.WithUnknown(msg)
+}
+
+// httpStatusToConstructor maps the HTTP status code returned by the 3scale APIs
+var httpStatusToConstructor = map[int]Constructor{
+	http.StatusInternalServerError: status.WithUnknown,
+	http.StatusBadRequest:          status.WithInvalidArgument,
+	http.StatusGatewayTimeout:      status.WithDeadlineExceeded,
+	http.StatusNotFound:            status.WithNotFound,
+	http.StatusForbidden:           status.WithPermissionDenied,
+	http.StatusUnauthorized:        status.WithUnauthenticated,
+	http.StatusTooManyRequests:     status.WithResourceExhausted,
+	http.StatusServiceUnavailable:  status.WithUnavailable,
+}
+
+// FromHTTPStatus returns the constructor for an HTTP status code returned by the 3scale APIs, or Unknown if unmapped
+func FromHTTPStatus(code int) Constructor {
+	if fn, ok := httpStatusToConstructor[code]; ok {
+		return fn
+	}
+	return Unknown
+}
+
+// FromBackendErrorCode returns the constructor for the error code provided by 3scale backend for a denied request
+func FromBackendErrorCode(threescaleErrorCode string) Constructor {
+	if threescaleErrorCode == "limits_exceeded" {
+		return LimitsExceeded
+	}
+
+	switch convert.CodeToStatusCode(threescaleErrorCode) {
+	//this should never occur unless we are passed an empty reason/code by backend
+	// or backend provides us with an unmapped code
+	case 0:
+		return Unknown
+	case http.StatusConflict:
+		return Denied
+	default:
+		// for all other cases that have reached backend return equiv of 403
+		return Denied
+	}
+}
@@ -0,0 +1,78 @@
+package statuses
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gogo/googleapis/google/rpc"
+)
+
+func TestConstructors(t *testing.T) {
+	inputs := []struct {
+		name   string
+		fn     Constructor
+		expect rpc.Code
+	}{
+		{name: "LimitsExceeded", fn: LimitsExceeded, expect: rpc.RESOURCE_EXHAUSTED},
+		{name: "InvalidCredentials", fn: InvalidCredentials, expect: rpc.PERMISSION_DENIED},
+		{name: "MissingCredentials", fn: MissingCredentials, expect: rpc.UNAUTHENTICATED},
+		{name: "MappingRuleMiss", fn: MappingRuleMiss, expect: rpc.NOT_FOUND},
+		{name: "MethodNotPermitted", fn: MethodNotPermitted, expect: rpc.PERMISSION_DENIED},
+		{name: "RequestTooLarge", fn: RequestTooLarge, expect: rpc.PERMISSION_DENIED},
+		{name: "MalformedRequest", fn: MalformedRequest, expect: rpc.INVALID_ARGUMENT},
+		{name: "Denied", fn: Denied, expect: rpc.PERMISSION_DENIED},
+		{name: "InvalidConfig", fn: InvalidConfig, expect: rpc.FAILED_PRECONDITION},
+		{name: "Internal", fn: Internal, expect: rpc.INTERNAL},
+		{name: "Unknown", fn: Unknown, expect: rpc.UNKNOWN},
+	}
+
+	for _, input := range inputs {
+		st := input.fn("message")
+		if st.Code != int32(input.expect) {
+			t.Errorf("unexpected code for %s, wanted %v but got %v", input.name, input.expect, st.Code)
+		}
+
+		if st.Message != "message" {
+			t.Errorf("unexpected message for %s - %s", input.name, st.Message)
+		}
+	}
+
+	if OK.Code != int32(rpc.OK) {
+		t.Errorf("unexpected code for OK - %v", OK.Code)
+	}
+}
+
+func TestFromHTTPStatus(t *testing.T) {
+	inputs := map[int]rpc.Code{
+		http.StatusInternalServerError: rpc.UNKNOWN,
+		http.StatusBadRequest:          rpc.INVALID_ARGUMENT,
+		http.StatusGatewayTimeout:      rpc.DEADLINE_EXCEEDED,
+		http.StatusNotFound:            rpc.NOT_FOUND,
+		http.StatusForbidden:           rpc.PERMISSION_DENIED,
+		http.StatusUnauthorized:        rpc.UNAUTHENTICATED,
+		http.StatusTooManyRequests:     rpc.RESOURCE_EXHAUSTED,
+		http.StatusServiceUnavailable:  rpc.UNAVAILABLE,
+		http.StatusTeapot:              rpc.UNKNOWN,
+	}
+
+	for code, expect := range inputs {
+		if st := FromHTTPStatus(code)(""); st.Code != int32(expect) {
+			t.Errorf("unexpected code for http status %d, wanted %v but got %v", code, expect, st.Code)
+		}
+	}
+}
+
+func TestFromBackendErrorCode(t *testing.T) {
+	inputs := map[string]rpc.Code{
+		"limits_exceeded":        rpc.RESOURCE_EXHAUSTED,
+		"user_key_invalid":       rpc.PERMISSION_DENIED,
+		"application_not_active": rpc.PERMISSION_DENIED,
+		"":                       rpc.UNKNOWN,
+	}
+
+	for code, expect := range inputs {
+		if st := FromBackendErrorCode(code)(code); st.Code != int32(expect) {
+			t.Errorf("unexpected code for backend error %q, wanted %v but got %v", code, expect, st.Code)
+		}
+	}
+}
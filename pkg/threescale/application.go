@@ -0,0 +1,37 @@
+package threescale
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/pkg/secrets"
+)
+
+// applicationHashBytes is the length of the hash reported for an application, which is ample to avoid collisions
+// between the applications of a service while keeping metric labels short
+const applicationHashBytes = 8
+
+// hashApplication returns a salted hash of the credentials identifying the application making a request, so that usage
+// can be broken down by application without exporting API keys or client IDs to telemetry systems.
+// An empty string is returned where the request carries no credentials.
+func hashApplication(salt string, params authorizer.BackendParams) string {
+	var identifier string
+	switch {
+	case params.AppID != "":
+		identifier = AppIDAttributeKey + ":" + params.AppID
+	case params.UserKey != "":
+		identifier = "user_key:" + params.UserKey
+	default:
+		return ""
+	}
+
+	key, data := []byte(salt), []byte(identifier)
+	defer secrets.Zero(key)
+	defer secrets.Zero(data)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)[:applicationHashBytes])
+}
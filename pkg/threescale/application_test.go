@@ -0,0 +1,63 @@
+package threescale
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+)
+
+func TestHashApplication(t *testing.T) {
+	userKey := authorizer.BackendParams{UserKey: "secret-key"}
+	appID := authorizer.BackendParams{AppID: "secret-key", AppKey: "app-key"}
+
+	hash := hashApplication("salt", userKey)
+	if len(hash) != applicationHashBytes*2 {
+		t.Errorf("unexpected hash length for %q", hash)
+	}
+
+	if strings.Contains(hash, userKey.UserKey) {
+		t.Errorf("expected raw credentials not to be exposed in %q", hash)
+	}
+
+	if hashApplication("salt", userKey) != hash {
+		t.Errorf("expected hash to be consistent for the same salt")
+	}
+
+	if hashApplication("other", userKey) == hash {
+		t.Errorf("expected hash to depend on the salt")
+	}
+
+	if hashApplication("salt", appID) == hash {
+		t.Errorf("expected app id and user key with the same value to be distinguished")
+	}
+
+	if hashApplication("salt", authorizer.BackendParams{AppID: "secret-key", AppKey: "other"}) != hashApplication("salt", appID) {
+		t.Errorf("expected application to be identified by its app id alone")
+	}
+
+	if hashApplication("salt", authorizer.BackendParams{}) != "" {
+		t.Errorf("expected no hash for a request without credentials")
+	}
+}
+
+func TestMetricsReporterApplicationRequest(t *testing.T) {
+	var reported []string
+	reporter := &MetricsReporter{
+		ApplicationCB: func(serviceID string, application string) {
+			reported = append(reported, serviceID+"/"+application)
+		},
+		ApplicationSalt: "salt",
+	}
+
+	reporter.applicationRequest("123", authorizer.BackendParams{UserKey: "secret-key"})
+	reporter.applicationRequest("123", authorizer.BackendParams{})
+
+	expect := "123/" + hashApplication("salt", authorizer.BackendParams{UserKey: "secret-key"})
+	if len(reported) != 1 || reported[0] != expect {
+		t.Errorf("expected a single report of %s but got %v", expect, reported)
+	}
+
+	var nilReporter *MetricsReporter
+	nilReporter.applicationRequest("123", authorizer.BackendParams{UserKey: "secret-key"})
+}
@@ -0,0 +1,31 @@
+package threescale
+
+import (
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	system "github.com/3scale/3scale-porta-go-client/client"
+)
+
+// backendAuthTypes are the credential types accepted by 3scale backend
+var backendAuthTypes = map[string]bool{
+	"service_token": true,
+	"provider_key":  true,
+}
+
+// backendAuth returns the credentials used to authenticate against 3scale backend. Those advertised in the proxy config
+// are overridden by the handler, so that tenants migrating credentials are not blocked while 3scale system lags behind.
+func backendAuth(conf system.ProxyConfig, cfg config.Params) authorizer.BackendAuth {
+	auth := authorizer.BackendAuth{
+		Type:  conf.Content.BackendAuthenticationType,
+		Value: conf.Content.BackendAuthenticationValue,
+	}
+
+	if cfg.BackendAuthType != "" {
+		auth.Type = cfg.BackendAuthType
+	}
+
+	if cfg.BackendAuthValue != "" {
+		auth.Value = cfg.BackendAuthValue
+	}
+	return auth
+}
@@ -0,0 +1,51 @@
+package threescale
+
+import (
+	"testing"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+func TestBackendAuth(t *testing.T) {
+	conf := client.ProxyConfig{
+		Content: client.Content{
+			BackendAuthenticationType:  "service_token",
+			BackendAuthenticationValue: "token",
+		},
+	}
+
+	inputs := []struct {
+		name        string
+		params      config.Params
+		expectType  string
+		expectValue string
+	}{
+		{
+			name:        "Test proxy config used by default",
+			expectType:  "service_token",
+			expectValue: "token",
+		},
+		{
+			name:        "Test value overridden alone",
+			params:      config.Params{BackendAuthValue: "rotated-token"},
+			expectType:  "service_token",
+			expectValue: "rotated-token",
+		},
+		{
+			name:        "Test type and value overridden",
+			params:      config.Params{BackendAuthType: "provider_key", BackendAuthValue: "key"},
+			expectType:  "provider_key",
+			expectValue: "key",
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			auth := backendAuth(conf, input.params)
+			if auth.Type != input.expectType || auth.Value != input.expectValue {
+				t.Errorf("expected %s=%s but got %s=%s", input.expectType, input.expectValue, auth.Type, auth.Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package threescale
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+)
+
+// Classes of BackendError, as reported to the MetricsReporter
+const (
+	BackendErrorTimeout  = "timeout"
+	BackendErrorAuth     = "auth"
+	BackendErrorLimit    = "limit"
+	BackendErrorProtocol = "protocol"
+)
+
+// Classes of the responses received from 3scale backend, as reported to the MetricsReporter
+const (
+	BackendResponseSuccess     = "2xx"
+	BackendResponseRedirect    = "3xx"
+	BackendResponseClientError = "4xx"
+	BackendResponseServerError = "5xx"
+	// BackendResponseCached is the class of responses served by the Authorizer from its cache
+	BackendResponseCached = "cached"
+	// BackendResponseNone is the class of requests which did not receive a response, such as on a timeout
+	BackendResponseNone = "none"
+)
+
+// BackendError is implemented by each error returned when a request could not be authorized by 3scale backend, so that
+// callers can branch on the class of failure rather than on the cause, which depends on the Authorizer in use
+type BackendError interface {
+	error
+	// Class returns one of the BackendError classes
+	Class() string
+}
+
+// TimeoutError is returned when 3scale backend did not respond within the timeout of the handler, or of the client
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string { return e.Err.Error() }
+
+// Class implements BackendError
+func (e *TimeoutError) Class() string { return BackendErrorTimeout }
+
+// Timeout reports that the error is a timeout, matching net.Error
+func (e *TimeoutError) Timeout() bool { return true }
+
+// AuthError is returned when 3scale backend rejected the request as unauthenticated or forbidden without providing
+// a response which could be parsed, typically as it was rejected in front of 3scale backend
+type AuthError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *AuthError) Error() string { return e.Err.Error() }
+
+// Class implements BackendError
+func (e *AuthError) Class() string { return BackendErrorAuth }
+
+// LimitError is returned when 3scale backend rate limited the adapter itself, as opposed to denying the request
+// because the limits of the application have been exceeded, which is not an error
+type LimitError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *LimitError) Error() string { return e.Err.Error() }
+
+// Class implements BackendError
+func (e *LimitError) Class() string { return BackendErrorLimit }
+
+// ProtocolError is returned for any other failure, when 3scale backend could not be reached or its response was not
+// understood. StatusCode is zero when no response was received.
+type ProtocolError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *ProtocolError) Error() string { return e.Err.Error() }
+
+// Class implements BackendError
+func (e *ProtocolError) Class() string { return BackendErrorProtocol }
+
+// newBackendError classifies err, returned by the Authorizer along with any response it managed to get
+func newBackendError(resp *authorizer.BackendResponse, err error) BackendError {
+	if err == nil {
+		return nil
+	}
+
+	if backendErr, ok := err.(BackendError); ok {
+		return backendErr
+	}
+
+	if netErr, ok := err.(net.Error); err == context.DeadlineExceeded || ok && netErr.Timeout() {
+		return &TimeoutError{Err: err}
+	}
+
+	statusCode := responseStatusCode(resp)
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &AuthError{StatusCode: statusCode, Err: err}
+	case http.StatusTooManyRequests:
+		return &LimitError{StatusCode: statusCode, Err: err}
+	case http.StatusGatewayTimeout:
+		return &TimeoutError{Err: err}
+	default:
+		return &ProtocolError{StatusCode: statusCode, Err: err}
+	}
+}
+
+// backendError classifies err as a BackendError and reports its class for the service
+func (s *Threescale) backendError(serviceID string, resp *authorizer.BackendResponse, err error) BackendError {
+	backendErr := newBackendError(resp, err)
+	s.conf.MetricsReporter.backendError(serviceID, backendErr.Class())
+	return backendErr
+}
+
+// responseStatusCode returns the status code of the response received from 3scale backend, or zero if there is none
+func responseStatusCode(resp *authorizer.BackendResponse) int {
+	if resp != nil && resp.RawResponse != nil {
+		if raw, ok := resp.RawResponse.(*http.Response); ok {
+			return raw.StatusCode
+		}
+	}
+	return 0
+}
+
+// backendResponseClass classifies the response received from 3scale backend by its status code. A response without
+// a status code is taken to be served from the cache of the Authorizer, unless it comes with an error.
+func backendResponseClass(resp *authorizer.BackendResponse, err error) string {
+	switch statusCode := responseStatusCode(resp); {
+	case statusCode >= 200 && statusCode < 300:
+		return BackendResponseSuccess
+	case statusCode >= 300 && statusCode < 400:
+		return BackendResponseRedirect
+	case statusCode >= 400 && statusCode < 500:
+		return BackendResponseClientError
+	case statusCode >= 500:
+		return BackendResponseServerError
+	case resp != nil && err == nil:
+		return BackendResponseCached
+	default:
+		return BackendResponseNone
+	}
+}
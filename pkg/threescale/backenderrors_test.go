@@ -0,0 +1,135 @@
+package threescale
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+)
+
+func TestNewBackendError(t *testing.T) {
+	withStatus := func(code int) *authorizer.BackendResponse {
+		return &authorizer.BackendResponse{RawResponse: &http.Response{StatusCode: code}}
+	}
+
+	inputs := []struct {
+		name        string
+		resp        *authorizer.BackendResponse
+		err         error
+		expectClass string
+	}{
+		{
+			name:        "Test context deadline is a timeout",
+			err:         context.DeadlineExceeded,
+			expectClass: BackendErrorTimeout,
+		},
+		{
+			name:        "Test gateway timeout is a timeout",
+			resp:        withStatus(http.StatusGatewayTimeout),
+			err:         errors.New("unexpected status"),
+			expectClass: BackendErrorTimeout,
+		},
+		{
+			name:        "Test forbidden response is an auth error",
+			resp:        withStatus(http.StatusForbidden),
+			err:         errors.New("unexpected status"),
+			expectClass: BackendErrorAuth,
+		},
+		{
+			name:        "Test rate limiting is a limit error",
+			resp:        withStatus(http.StatusTooManyRequests),
+			err:         errors.New("unexpected status"),
+			expectClass: BackendErrorLimit,
+		},
+		{
+			name:        "Test server error is a protocol error",
+			resp:        withStatus(http.StatusInternalServerError),
+			err:         errors.New("unexpected status"),
+			expectClass: BackendErrorProtocol,
+		},
+		{
+			name:        "Test error without a response is a protocol error",
+			err:         errors.New("connection refused"),
+			expectClass: BackendErrorProtocol,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			err := newBackendError(input.resp, input.err)
+			if err.Class() != input.expectClass {
+				t.Errorf("expected class %s but got %s", input.expectClass, err.Class())
+			}
+
+			if err.Error() != input.err.Error() {
+				t.Errorf("expected the message of the cause but got %s", err.Error())
+			}
+		})
+	}
+}
+
+func TestAuthRepBackendError(t *testing.T) {
+	var classes []string
+	reporter := &MetricsReporter{
+		BackendErrorCB: func(serviceID string, class string) {
+			classes = append(classes, class)
+		},
+	}
+
+	request := authorizer.BackendRequest{Service: "123", Transactions: []authorizer.BackendTransaction{{}}}
+
+	failing := &Threescale{conf: &AdapterConfig{
+		Authorizer: mockAuthorizer{
+			withAuthResponse: &authorizer.BackendResponse{RawResponse: &http.Response{StatusCode: http.StatusTooManyRequests}},
+			withBackendErr:   errors.New("unexpected status"),
+			t:                t,
+		},
+		MetricsReporter: reporter,
+	}}
+
+	_, err := failing.authRep(context.Background(), "", request, handlerTimeouts{})
+	if _, ok := err.(*LimitError); !ok {
+		t.Errorf("expected a LimitError but got %T", err)
+	}
+
+	slow := &Threescale{conf: &AdapterConfig{
+		Authorizer:      slowAuthorizer{mockAuthorizer: mockAuthorizer{t: t}, backendDelay: time.Millisecond * 50},
+		MetricsReporter: reporter,
+	}}
+
+	_, err = slow.authRep(context.Background(), "", request, handlerTimeouts{backend: time.Millisecond})
+	if _, ok := err.(*TimeoutError); !ok {
+		t.Errorf("expected a TimeoutError but got %T", err)
+	}
+
+	if len(classes) != 2 || classes[0] != BackendErrorLimit || classes[1] != BackendErrorTimeout {
+		t.Errorf("expected limit and timeout errors to be reported but got %v", classes)
+	}
+}
+
+func TestBackendResponseClass(t *testing.T) {
+	withStatus := func(code int) *authorizer.BackendResponse {
+		return &authorizer.BackendResponse{RawResponse: &http.Response{StatusCode: code}}
+	}
+
+	inputs := []struct {
+		resp        *authorizer.BackendResponse
+		err         error
+		expectClass string
+	}{
+		{resp: withStatus(http.StatusOK), expectClass: BackendResponseSuccess},
+		{resp: withStatus(http.StatusConflict), expectClass: BackendResponseClientError},
+		{resp: withStatus(http.StatusBadGateway), err: errors.New("unexpected status"), expectClass: BackendResponseServerError},
+		{resp: &authorizer.BackendResponse{Authorized: true}, expectClass: BackendResponseCached},
+		{err: errors.New("connection refused"), expectClass: BackendResponseNone},
+	}
+
+	for _, input := range inputs {
+		if class := backendResponseClass(input.resp, input.err); class != input.expectClass {
+			t.Errorf("expected class %s but got %s", input.expectClass, class)
+		}
+	}
+}
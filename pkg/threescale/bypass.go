@@ -0,0 +1,56 @@
+package threescale
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+
+	"istio.io/istio/mixer/template/authorization"
+	"istio.io/istio/pkg/log"
+)
+
+// CacheBypassAttributeKey is the subject property holding the token which makes a request bypass the caches
+const CacheBypassAttributeKey = "cache_bypass"
+
+type cacheBypassKey struct{}
+
+// withCacheBypass returns ctx marked to bypass the caches when the request presents the cache bypass token of the
+// handler. Requests presenting any other value are authorized as usual.
+func withCacheBypass(ctx context.Context, instance authorization.InstanceMsg, cfg config.Params) context.Context {
+	if cfg.CacheBypassToken == "" || instance.Subject == nil {
+		return ctx
+	}
+
+	token := instance.Subject.Properties[CacheBypassAttributeKey].GetStringValue()
+	if token == "" {
+		return ctx
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.CacheBypassToken)) != 1 {
+		log.Debugf("ignoring cache bypass for service %s as the token presented is invalid", cfg.ServiceId)
+		return ctx
+	}
+
+	log.Infof("bypassing caches for request to service %s as requested by the cache_bypass subject property", cfg.ServiceId)
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+// cacheBypassed reports whether the request of ctx bypasses the caches
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
+}
+
+// authorizerFor returns the Authorizer for the request of ctx. That of the handler's own client takes precedence, since
+// requests must be sent as the handler configures, followed by the UncachedAuthorizer when the request bypasses the caches.
+func (s *Threescale) authorizerFor(ctx context.Context) Authorizer {
+	if auth, ok := handlerAuthorizer(ctx); ok {
+		return auth
+	}
+
+	if s.conf.UncachedAuthorizer != nil && cacheBypassed(ctx) {
+		return s.conf.UncachedAuthorizer
+	}
+	return s.conf.Authorizer
+}
@@ -0,0 +1,80 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestHandleAuthorizationCacheBypass(t *testing.T) {
+	c := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer: mockAuthorizer{withSystemErr: errConfigNotCached, t: t},
+			UncachedAuthorizer: mockAuthorizer{
+				withConfig: client.ProxyConfig{
+					Content: client.Content{
+						Proxy: client.ContentProxy{
+							Backend: client.Backend{Endpoint: internalBackend},
+							ProxyRules: []client.ProxyRule{
+								{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+							},
+						},
+					},
+				},
+				withAuthResponse: &authorizer.BackendResponse{},
+				t:                t,
+			},
+		},
+	}
+
+	params := config.Params{
+		ServiceId:        "123",
+		SystemUrl:        "https://www.fake-system.3scale.net",
+		AccessToken:      "any",
+		CacheBypassToken: "bypass-token",
+	}
+	b, _ := params.Marshal()
+
+	inputs := []struct {
+		name   string
+		token  string
+		expect int32
+	}{
+		{name: "Test valid token bypasses caches", token: "bypass-token", expect: int32(rpc.OK)},
+		{name: "Test invalid token is ignored", token: "guess", expect: int32(rpc.UNAVAILABLE)},
+		{name: "Test no token uses caches", expect: int32(rpc.UNAVAILABLE)},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "VALID",
+						Properties: map[string]*v1beta1.Value{
+							CacheBypassAttributeKey: {Value: &v1beta1.Value_StringValue{StringValue: input.token}},
+						},
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != input.expect {
+				t.Errorf("expected status %d but got %v", input.expect, result.Status)
+			}
+		})
+	}
+}
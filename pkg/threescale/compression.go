@@ -0,0 +1,478 @@
+package threescale
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+// CompressedConfigCache is an Authorizer which caches proxy configs gzip compressed, trading CPU for a large reduction
+// in memory when thousands of services are cached, since proxy configs with many mapping rules compress well.
+// The wrapped Authorizer should not cache system configuration itself. Only the mapping rules are held compressed, the
+// rest of each config being small, and requests authorized by the adapter only decode them when the config version has
+// not been compiled yet, so that serving a request from the cache does not decode anything. Get and
+// GetSystemConfiguration return the whole config, so decode the rules on each call.
+// Expired configs are retained for a further ttl, so that they may be served stale while being revalidated.
+// The ttl, background refresh and retries of fetches may be overridden for each request by ConfigCacheOptions.
+// Configs in use can also be refreshed ahead of expiry by RunRefresh. Once maxEntries are held, the least recently
+// used config is evicted to make room for another, so memory is bounded however many services are requested.
+// Expired configs may also be served when they cannot be refreshed, as enabled by ServeStaleIfError.
+type CompressedConfigCache struct {
+	Authorizer
+	ttl        time.Duration
+	maxEntries int
+	mutex      sync.RWMutex
+	entries    map[string]compressedConfig
+	// fetches holds the configs being fetched, so that concurrent requests for a config share a single fetch
+	fetches map[string]*configFetch
+	// staleIfError is the period past expiry a config may be served for when it cannot be refreshed
+	staleIfError time.Duration
+	// staleServedCB is optional and called each time a config is served stale as it could not be refreshed
+	staleServedCB func(serviceID string, staleness time.Duration)
+	metrics       *MetricsReporter
+	now           func() time.Time
+}
+
+type compressedConfig struct {
+	// conf is the config without its mapping rules, which are only held compressed in data
+	conf    client.ProxyConfig
+	data    []byte
+	fetched time.Time
+	expires time.Time
+	// ttl is the period the config is held for, which is also the period it may be served stale once expired
+	ttl time.Duration
+	// systemURL, request and opts are those the config was fetched with, so that it can be refreshed
+	systemURL string
+	request   authorizer.SystemRequest
+	opts      ConfigCacheOptions
+	// used is set to 1 by requests using the config, so that only configs in use are refreshed by RunRefresh
+	used *int32
+	// lastUsed is the time, in nanoseconds since the epoch, the config was last stored or used by a request
+	lastUsed *int64
+}
+
+// configFetch is a fetch of a config from the wrapped Authorizer. done is closed once conf and err are set.
+type configFetch struct {
+	done chan struct{}
+	conf client.ProxyConfig
+	err  error
+}
+
+// NewCompressedConfigCache returns a CompressedConfigCache which fetches proxy configs via next, holding up to
+// maxEntries of them for ttl. A maxEntries of zero or less disables caching.
+func NewCompressedConfigCache(next Authorizer, ttl time.Duration, maxEntries int) *CompressedConfigCache {
+	return &CompressedConfigCache{
+		Authorizer: next,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]compressedConfig),
+		fetches:    make(map[string]*configFetch),
+		now:        time.Now,
+	}
+}
+
+// Configure changes the period configs are held for and the maximum number held. Cached configs keep the expiry
+// they were stored with, and the least recently used are evicted to meet a reduced maximum.
+func (c *CompressedConfigCache) Configure(ttl time.Duration, maxEntries int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.ttl = ttl
+	c.maxEntries = maxEntries
+	c.purgeExpired()
+	c.evict(len(c.entries) - maxEntries)
+	c.metrics.configCacheEntries(len(c.entries))
+}
+
+// Config returns the period configs are held for and the maximum number held
+func (c *CompressedConfigCache) Config() (time.Duration, int) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.ttl, c.maxEntries
+}
+
+// ServeStaleIfError enables serving a config for up to grace past its expiry when it cannot be refreshed, rather than
+// failing the request, so that transient outages of 3scale system do not cause requests to be rejected. This applies
+// to requests which wait for a fetch, which the ConfigFailFast strategy never does. servedCB is optional and called
+// each time a config is served stale due to an error, with how long the config has been expired.
+func (c *CompressedConfigCache) ServeStaleIfError(grace time.Duration, servedCB func(serviceID string, staleness time.Duration)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.staleIfError = grace
+	c.staleServedCB = servedCB
+}
+
+// SetMetricsReporter reports hits, misses, failed fetches, evictions and the number of configs held to m
+func (c *CompressedConfigCache) SetMetricsReporter(m *MetricsReporter) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.metrics = m
+}
+
+// Len returns the number of configs held, including those being served stale
+func (c *CompressedConfigCache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return len(c.entries)
+}
+
+// GetSystemConfiguration implements Authorizer, waiting for a fresh config
+func (c *CompressedConfigCache) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	return c.Get(context.Background(), systemURL, request, ConfigCacheOptions{Strategy: ConfigWaitForFresh})
+}
+
+// Get implements ConfigCache. A fresh config is returned as is, unless it was fetched longer than the refresh interval
+// ago, in which case it is also refreshed in the background. An expired or missing config is handled as determined by
+// the strategy. Waiting for a fetch is abandoned when ctx is done, but the fetch completes in the background and its
+// config is cached for later requests. An expired config is returned in place of a failed fetch where enabled by
+// ServeStaleIfError.
+func (c *CompressedConfigCache) Get(ctx context.Context, systemURL string, request authorizer.SystemRequest, opts ConfigCacheOptions) (client.ProxyConfig, error) {
+	conf, rules, err := c.getWithoutRules(ctx, systemURL, request, opts)
+	if err != nil {
+		return conf, err
+	}
+
+	conf.Content.Proxy.ProxyRules, err = rules()
+	return conf, err
+}
+
+// getWithoutRules is as Get, but cached configs are returned without their mapping rules, which are only decoded when
+// the returned proxyRulesFunc is called
+func (c *CompressedConfigCache) getWithoutRules(ctx context.Context, systemURL string, request authorizer.SystemRequest, opts ConfigCacheOptions) (client.ProxyConfig, proxyRulesFunc, error) {
+	key := configKey(systemURL, request)
+
+	c.mutex.RLock()
+	entry, ok := c.entries[key]
+	metrics := c.metrics
+	c.mutex.RUnlock()
+
+	if ok {
+		if entry.request.AccessToken == "" {
+			entry = c.adoptRequest(key, request)
+		}
+
+		now := c.now()
+		atomic.StoreInt64(entry.lastUsed, now.UnixNano())
+		if now.Before(entry.expires) {
+			atomic.StoreInt32(entry.used, 1)
+			if opts.RefreshInterval > 0 && now.Sub(entry.fetched) >= opts.RefreshInterval {
+				c.fetch(key, systemURL, request, opts)
+			}
+			metrics.configCacheHit(now.Sub(entry.fetched))
+			return entry.conf, entry.rules, nil
+		}
+
+		if opts.Strategy == ConfigServeStale && now.Before(entry.expires.Add(entry.ttl)) {
+			c.fetch(key, systemURL, request, opts)
+			metrics.configCacheHit(now.Sub(entry.fetched))
+			return entry.conf, entry.rules, nil
+		}
+	}
+	metrics.configCacheMiss()
+
+	fetch := c.fetch(key, systemURL, request, opts)
+	if opts.Strategy == ConfigFailFast {
+		return client.ProxyConfig{}, nil, errConfigNotCached
+	}
+
+	select {
+	case <-fetch.done:
+		if fetch.err != nil && ok && c.serveStale(entry) {
+			return entry.conf, entry.rules, nil
+		}
+		return fetch.conf, decodedRules(fetch.conf), fetch.err
+	case <-ctx.Done():
+		return client.ProxyConfig{}, nil, ctx.Err()
+	}
+}
+
+// serveStale reports whether the config of entry has not been expired for longer than it may be served stale in place
+// of a config which could not be fetched
+func (c *CompressedConfigCache) serveStale(entry compressedConfig) bool {
+	c.mutex.RLock()
+	grace, servedCB := c.staleIfError, c.staleServedCB
+	c.mutex.RUnlock()
+
+	staleness := c.now().Sub(entry.expires)
+	if staleness >= grace {
+		return false
+	}
+
+	if servedCB != nil {
+		servedCB(entry.request.ServiceID, staleness)
+	}
+	return true
+}
+
+// rules decodes the mapping rules of the config
+func (e compressedConfig) rules() ([]client.ProxyRule, error) {
+	return decompressRules(e.data)
+}
+
+// adoptRequest sets the request of the entry under key, which was restored from a snapshot without its access token,
+// so that it can be refreshed like any other once a request has presented the token. It returns the entry.
+func (c *CompressedConfigCache) adoptRequest(key string, request authorizer.SystemRequest) compressedConfig {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := c.entries[key]
+	if entry.request.AccessToken == "" {
+		entry.request = request
+		c.entries[key] = entry
+	}
+	return entry
+}
+
+// configKey returns the key the config fetched with systemURL and request is cached under. The access token is hashed
+// along with the rest of the request, so that it is not exposed by the key.
+func configKey(systemURL string, request authorizer.SystemRequest) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{systemURL, request.ServiceID, request.Environment, request.AccessToken}, "|")))
+	return hex.EncodeToString(sum[:])
+}
+
+// fetch starts fetching the config via the wrapped Authorizer, unless it is already being fetched. A failed fetch is
+// retried immediately up to the maximum retries of opts.
+func (c *CompressedConfigCache) fetch(key string, systemURL string, request authorizer.SystemRequest, opts ConfigCacheOptions) *configFetch {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if fetch, ok := c.fetches[key]; ok {
+		return fetch
+	}
+
+	fetch := &configFetch{done: make(chan struct{})}
+	c.fetches[key] = fetch
+
+	go func() {
+		fetch.conf, fetch.err = c.Authorizer.GetSystemConfiguration(systemURL, request)
+		for retries := opts.MaxRetries; fetch.err != nil && retries > 0; retries-- {
+			fetch.conf, fetch.err = c.Authorizer.GetSystemConfiguration(systemURL, request)
+		}
+
+		if fetch.err == nil {
+			c.store(key, systemURL, request, opts, fetch.conf)
+		}
+
+		c.mutex.Lock()
+		delete(c.fetches, key)
+		if fetch.err != nil {
+			c.metrics.configFetchFailed(request.ServiceID)
+		}
+		c.mutex.Unlock()
+		close(fetch.done)
+	}()
+	return fetch
+}
+
+// store caches conf under key for the ttl of opts, or the ttl of the cache when zero, evicting other configs as required
+func (c *CompressedConfigCache) store(key string, systemURL string, request authorizer.SystemRequest, opts ConfigCacheOptions, conf client.ProxyConfig) {
+	data, err := compressConfig(conf)
+	if err != nil {
+		// the config is still usable, it just can't be cached
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = c.ttl
+	}
+
+	if c.maxEntries > 0 {
+		c.purgeExpired()
+		if _, ok := c.entries[key]; !ok {
+			c.evict(len(c.entries) - c.maxEntries + 1)
+		}
+		now := c.now()
+		lastUsed := now.UnixNano()
+		conf.Content.Proxy.ProxyRules = nil
+		c.entries[key] = compressedConfig{
+			conf:      conf,
+			data:      data,
+			fetched:   now,
+			expires:   now.Add(ttl),
+			ttl:       ttl,
+			systemURL: systemURL,
+			request:   request,
+			opts:      opts,
+			used:      new(int32),
+			lastUsed:  &lastUsed,
+		}
+		c.metrics.configCacheEntries(len(c.entries))
+	}
+}
+
+// RunRefresh refreshes cached configs every interval until stop is closed, so that requests for services in use do not
+// wait on 3scale system once their config has been cached. A config is refreshed once it was fetched at least interval
+// ago, or the refresh interval it was fetched with where set, provided it has been used since. Configs which are not in
+// use are left to expire. interval must be positive.
+func (c *CompressedConfigCache) RunRefresh(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.refresh(interval, stop)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// refresh fetches each config due to be refreshed in turn, so that refreshing many configs does not burst 3scale system
+func (c *CompressedConfigCache) refresh(interval time.Duration, stop <-chan struct{}) {
+	now := c.now()
+
+	var due []string
+	c.mutex.RLock()
+	for key, entry := range c.entries {
+		entryInterval := interval
+		if entry.opts.RefreshInterval > 0 {
+			entryInterval = entry.opts.RefreshInterval
+		}
+
+		if now.Before(entry.expires) && now.Sub(entry.fetched) >= entryInterval && atomic.LoadInt32(entry.used) == 1 {
+			due = append(due, key)
+		}
+	}
+	c.mutex.RUnlock()
+
+	for _, key := range due {
+		c.mutex.RLock()
+		entry, ok := c.entries[key]
+		c.mutex.RUnlock()
+		if !ok {
+			continue
+		}
+
+		select {
+		case <-c.fetch(key, entry.systemURL, entry.request, entry.opts).done:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// purgeExpired removes entries which have been expired for longer than they may be served stale, either while being
+// revalidated or in place of a failed fetch, so that services which are no longer requested do not hold memory.
+// The caller must hold the write lock.
+func (c *CompressedConfigCache) purgeExpired() {
+	now := c.now()
+	for key, entry := range c.entries {
+		retain := entry.ttl
+		if c.staleIfError > retain {
+			retain = c.staleIfError
+		}
+
+		if !now.Before(entry.expires.Add(retain)) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// evict removes the n least recently used entries. The caller must hold the write lock.
+func (c *CompressedConfigCache) evict(n int) {
+	for ; n > 0 && len(c.entries) > 0; n-- {
+		var oldest string
+		var oldestUsed int64
+		for key, entry := range c.entries {
+			if used := atomic.LoadInt64(entry.lastUsed); oldest == "" || used < oldestUsed {
+				oldest, oldestUsed = key, used
+			}
+		}
+		delete(c.entries, oldest)
+		c.metrics.configEvicted()
+	}
+}
+
+// compressConfig encodes the config without its mapping rules, followed by each rule as a separate JSON value, so that
+// decompressConfig can decode services with thousands of rules one rule at a time
+func compressConfig(conf client.ProxyConfig) ([]byte, error) {
+	rules := conf.Content.Proxy.ProxyRules
+	conf.Content.Proxy.ProxyRules = nil
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	if err := enc.Encode(conf); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		if err := enc.Encode(rule); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressConfig decodes a config encoded by compressConfig. Mapping rules are streamed from the decompressed data, so
+// that only a single rule is buffered at a time rather than the whole document alongside the decoded config.
+func decompressConfig(data []byte) (client.ProxyConfig, error) {
+	var conf client.ProxyConfig
+	rules, err := decodeCompressed(data, &conf)
+	conf.Content.Proxy.ProxyRules = append(conf.Content.Proxy.ProxyRules, rules...)
+	return conf, err
+}
+
+// decompressRules decodes only the mapping rules of a config encoded by compressConfig, skipping the rest of the config
+func decompressRules(data []byte) ([]client.ProxyRule, error) {
+	var conf json.RawMessage
+	return decodeCompressed(data, &conf)
+}
+
+// decodeCompressed decodes the config encoded by compressConfig into conf, and returns the mapping rules which follow it
+func decodeCompressed(data []byte, conf interface{}) ([]client.ProxyRule, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	dec := json.NewDecoder(zr)
+	if err := dec.Decode(conf); err != nil {
+		return nil, err
+	}
+
+	var rules []client.ProxyRule
+	for dec.More() {
+		var rule client.ProxyRule
+		if err := dec.Decode(&rule); err != nil {
+			return rules, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// proxyRulesFunc returns the mapping rules of a proxy config which were omitted from it, decoding them where required
+type proxyRulesFunc func() ([]client.ProxyRule, error)
+
+// decodedRules returns a proxyRulesFunc for the mapping rules already decoded in conf
+func decodedRules(conf client.ProxyConfig) proxyRulesFunc {
+	rules := conf.Content.Proxy.ProxyRules
+	return func() ([]client.ProxyRule, error) {
+		return rules, nil
+	}
+}
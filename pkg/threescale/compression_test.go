@@ -0,0 +1,475 @@
+package threescale
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+func TestCompressedConfigCache(t *testing.T) {
+	conf := client.ProxyConfig{
+		ID:      1,
+		Version: 2,
+		Content: client.Content{
+			BackendAuthenticationType:  "service_token",
+			BackendAuthenticationValue: "token",
+			Proxy: client.ContentProxy{
+				Backend: client.Backend{Endpoint: "https://su1.3scale.net"},
+			},
+		},
+	}
+	for i := 0; i < 100; i++ {
+		conf.Content.Proxy.ProxyRules = append(conf.Content.Proxy.ProxyRules, client.ProxyRule{
+			HTTPMethod:       http.MethodGet,
+			Pattern:          fmt.Sprintf("/resource/%d", i),
+			MetricSystemName: "hits",
+			Delta:            1,
+			Position:         i,
+		})
+	}
+
+	next := &configAuthorizer{conf: conf}
+	now := time.Now()
+	cache := NewCompressedConfigCache(next, time.Minute, 10)
+	cache.now = func() time.Time {
+		return now
+	}
+
+	request := authorizer.SystemRequest{ServiceID: "123", AccessToken: "any", Environment: "production"}
+	for i := 0; i < 3; i++ {
+		got, err := cache.GetSystemConfiguration("https://system", request)
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+
+		if !reflect.DeepEqual(got, conf) {
+			t.Errorf("expected decoded config to match original")
+		}
+	}
+
+	if next.calls != 1 {
+		t.Errorf("expected config to be fetched once but got %d calls", next.calls)
+	}
+
+	raw, _ := json.Marshal(conf)
+	for _, entry := range cache.entries {
+		if len(entry.data) >= len(raw) {
+			t.Errorf("expected cached config to be compressed, %d bytes from %d", len(entry.data), len(raw))
+		}
+	}
+
+	got, rules, err := cache.getWithoutRules(context.Background(), "https://system", request, ConfigCacheOptions{})
+	if err != nil || got.ID != conf.ID || got.Content.Proxy.Backend.Endpoint != conf.Content.Proxy.Backend.Endpoint || len(got.Content.Proxy.ProxyRules) != 0 {
+		t.Errorf("expected cached config to be returned without decoding its mapping rules but got %v - %v", got, err)
+	}
+
+	if decoded, err := rules(); err != nil || !reflect.DeepEqual(decoded, conf.Content.Proxy.ProxyRules) {
+		t.Errorf("expected mapping rules to be decoded on demand but got %v - %v", decoded, err)
+	}
+
+	now = now.Add(time.Minute)
+	cache.GetSystemConfiguration("https://system", request)
+	if next.calls != 2 {
+		t.Errorf("expected expired config to be fetched again but got %d calls", next.calls)
+	}
+}
+
+func TestCompressConfig(t *testing.T) {
+	conf := client.ProxyConfig{ID: 1, Version: 2}
+	for i := 0; i < 3; i++ {
+		conf.Content.Proxy.ProxyRules = append(conf.Content.Proxy.ProxyRules, client.ProxyRule{
+			HTTPMethod: http.MethodGet,
+			Pattern:    fmt.Sprintf("/resource/%d", i),
+			Delta:      1,
+			Position:   i,
+		})
+	}
+
+	data, err := compressConfig(conf)
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if len(conf.Content.Proxy.ProxyRules) != 3 {
+		t.Errorf("expected the config provided not to be modified")
+	}
+
+	zr, _ := gzip.NewReader(bytes.NewReader(data))
+	raw, _ := ioutil.ReadAll(zr)
+	if lines := strings.Count(string(raw), "\n"); lines != 4 {
+		t.Errorf("expected the config and each mapping rule to be encoded separately but got %d values", lines)
+	}
+
+	got, err := decompressConfig(data)
+	if err != nil || !reflect.DeepEqual(got, conf) {
+		t.Errorf("expected decoded config to match original but got %v - %v", got, err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(raw[:len(raw)-10])
+	zw.Close()
+
+	if _, err := decompressConfig(buf.Bytes()); err == nil {
+		t.Errorf("expected error for a truncated mapping rule")
+	}
+}
+
+type configAuthorizer struct {
+	mockAuthorizer
+	conf  client.ProxyConfig
+	calls int
+}
+
+func (c *configAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	c.calls++
+	return c.conf, nil
+}
+
+func TestCompressedConfigCacheConfigure(t *testing.T) {
+	next := &configAuthorizer{conf: client.ProxyConfig{ID: 1}}
+	now := time.Now()
+	cache := NewCompressedConfigCache(next, time.Minute, 2)
+	cache.now = func() time.Time {
+		return now
+	}
+
+	fetch := func(serviceID string) {
+		now = now.Add(time.Second)
+		request := authorizer.SystemRequest{ServiceID: serviceID, AccessToken: "any", Environment: "production"}
+		if _, err := cache.GetSystemConfiguration("https://system", request); err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+	}
+
+	fetch("1")
+	fetch("2")
+	fetch("1")
+	fetch("3")
+	if len(cache.entries) != 2 {
+		t.Errorf("expected cache to hold at most 2 entries but got %d", len(cache.entries))
+	}
+
+	if _, ok := cache.entries["https://system|2|production|any"]; ok {
+		t.Errorf("expected the least recently used entry to be evicted but got %v", cache.entries)
+	}
+
+	cache.Configure(time.Hour, 1)
+	if ttl, max := cache.Config(); ttl != time.Hour || max != 1 {
+		t.Errorf("unexpected config %s %d", ttl, max)
+	}
+
+	if _, ok := cache.entries["https://system|3|production|any"]; len(cache.entries) != 1 || !ok {
+		t.Errorf("expected the most recently used entry to be kept but got %v", cache.entries)
+	}
+
+	cache.Configure(time.Hour, 0)
+	calls := next.calls
+	fetch("3")
+	fetch("3")
+	if next.calls != calls+2 {
+		t.Errorf("expected every request to be fetched once caching is disabled")
+	}
+}
+
+// blockingAuthorizer returns a config with the next version once released
+type blockingAuthorizer struct {
+	mockAuthorizer
+	release chan struct{}
+	version int
+}
+
+func (b *blockingAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	<-b.release
+	b.version++
+	return client.ProxyConfig{Version: b.version}, nil
+}
+
+func TestCompressedConfigCacheStrategies(t *testing.T) {
+	next := &blockingAuthorizer{release: make(chan struct{})}
+	now := time.Now()
+	cache := NewCompressedConfigCache(next, time.Minute, 10)
+	cache.now = func() time.Time {
+		return now
+	}
+
+	request := authorizer.SystemRequest{ServiceID: "123", AccessToken: "any", Environment: "production"}
+	get := func(ctx context.Context, strategy ConfigStrategy) (client.ProxyConfig, error) {
+		return cache.Get(ctx, "https://system", request, ConfigCacheOptions{Strategy: strategy})
+	}
+
+	if _, err := get(context.Background(), ConfigFailFast); err != errConfigNotCached {
+		t.Errorf("expected missing config to fail fast but got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := get(ctx, ConfigWaitForFresh); err != context.Canceled {
+		t.Errorf("expected cancelled request to stop waiting but got %v", err)
+	}
+
+	// the fetch started by the first request completes for later requests
+	next.release <- struct{}{}
+	conf, err := get(context.Background(), ConfigWaitForFresh)
+	if err != nil || conf.Version != 1 {
+		t.Fatalf("expected shared fetch to be cached but got version %d - %v", conf.Version, err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := get(context.Background(), ConfigFailFast); err != errConfigNotCached {
+		t.Errorf("expected expired config to fail fast but got %v", err)
+	}
+
+	conf, err = get(context.Background(), ConfigServeStale)
+	if err != nil || conf.Version != 1 {
+		t.Errorf("expected stale config to be served but got version %d - %v", conf.Version, err)
+	}
+
+	next.release <- struct{}{}
+	conf, err = get(context.Background(), ConfigWaitForFresh)
+	if err != nil || conf.Version != 2 {
+		t.Errorf("expected config to be revalidated but got version %d - %v", conf.Version, err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	go func() {
+		next.release <- struct{}{}
+	}()
+	conf, err = get(context.Background(), ConfigServeStale)
+	if err != nil || conf.Version != 3 {
+		t.Errorf("expected config expired beyond its stale period to be fetched but got version %d - %v", conf.Version, err)
+	}
+}
+
+// failingAuthorizer fails the first failures fetches, returning the number of calls as the version of the config
+type failingAuthorizer struct {
+	mockAuthorizer
+	failures int
+	calls    int
+}
+
+func (f *failingAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return client.ProxyConfig{}, fmt.Errorf("fetch %d failed", f.calls)
+	}
+	return client.ProxyConfig{Version: f.calls}, nil
+}
+
+func TestCompressedConfigCacheOptions(t *testing.T) {
+	next := &failingAuthorizer{failures: 2}
+	now := time.Now()
+	cache := NewCompressedConfigCache(next, time.Minute, 10)
+	cache.now = func() time.Time {
+		return now
+	}
+
+	request := authorizer.SystemRequest{ServiceID: "123", AccessToken: "any", Environment: "production"}
+	opts := ConfigCacheOptions{Strategy: ConfigFailFast, TTL: time.Hour, RefreshInterval: 10 * time.Minute, MaxRetries: 2}
+	get := func(strategy ConfigStrategy) (client.ProxyConfig, error) {
+		opts.Strategy = strategy
+		return cache.Get(context.Background(), "https://system", request, opts)
+	}
+
+	conf, err := get(ConfigWaitForFresh)
+	if err != nil || conf.Version != 3 {
+		t.Fatalf("expected failed fetches to be retried but got version %d - %v", conf.Version, err)
+	}
+
+	// the config outlives the ttl of the cache, and is refreshed in the background once past the refresh interval
+	now = now.Add(30 * time.Minute)
+	conf, err = get(ConfigFailFast)
+	if err != nil || conf.Version != 3 {
+		t.Fatalf("expected config to be held for the ttl of the request but got version %d - %v", conf.Version, err)
+	}
+
+	var fetches []*configFetch
+	cache.mutex.RLock()
+	for _, fetch := range cache.fetches {
+		fetches = append(fetches, fetch)
+	}
+	cache.mutex.RUnlock()
+	for _, fetch := range fetches {
+		<-fetch.done
+	}
+
+	conf, err = get(ConfigFailFast)
+	if err != nil || conf.Version != 4 {
+		t.Errorf("expected config to be refreshed but got version %d - %v", conf.Version, err)
+	}
+
+	next.failures = 10
+	request.ServiceID = "456"
+	opts.MaxRetries = 1
+	if _, err := get(ConfigWaitForFresh); err == nil || next.calls != 6 {
+		t.Errorf("expected fetch to fail after a single retry but got %d calls - %v", next.calls, err)
+	}
+}
+
+func TestParseCacheOptions(t *testing.T) {
+	opts, errMsgs := parseCacheOptions(&config.Params{SystemCacheTtl: "5m", SystemCacheMaxRetries: 3})
+	if len(errMsgs) != 0 {
+		t.Errorf("unexpected errors %v", errMsgs)
+	}
+
+	expect := ConfigCacheOptions{TTL: 5 * time.Minute, MaxRetries: 3}
+	if opts != expect {
+		t.Errorf("expected %+v but got %+v", expect, opts)
+	}
+
+	_, errMsgs = parseCacheOptions(&config.Params{SystemCacheTtl: "5", SystemCacheRefreshInterval: "-1m", SystemCacheMaxRetries: -1})
+	if len(errMsgs) != 3 {
+		t.Fatalf("expected an error for each invalid option but got %v", errMsgs)
+	}
+
+	for i, name := range []string{"system_cache_ttl", "system_cache_refresh_interval", "system_cache_max_retries"} {
+		if !strings.HasPrefix(errMsgs[i], name) {
+			t.Errorf("expected error for %s but got %q", name, errMsgs[i])
+		}
+	}
+}
+
+func TestCompressedConfigCacheRefresh(t *testing.T) {
+	next := &failingAuthorizer{}
+	now := time.Now()
+	cache := NewCompressedConfigCache(next, 10*time.Minute, 10)
+	cache.now = func() time.Time {
+		return now
+	}
+
+	get := func(serviceID string, strategy ConfigStrategy) (client.ProxyConfig, error) {
+		request := authorizer.SystemRequest{ServiceID: serviceID, AccessToken: "any", Environment: "production"}
+		return cache.Get(context.Background(), "https://system", request, ConfigCacheOptions{Strategy: strategy})
+	}
+
+	for _, serviceID := range []string{"used", "unused"} {
+		if _, err := get(serviceID, ConfigWaitForFresh); err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+	}
+	get("used", ConfigFailFast)
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	now = now.Add(5 * time.Minute)
+	cache.refresh(time.Minute, stop)
+	if next.calls != 3 {
+		t.Fatalf("expected only the config in use to be refreshed but got %d calls", next.calls)
+	}
+
+	conf, err := get("used", ConfigFailFast)
+	if err != nil || conf.Version != 3 {
+		t.Errorf("expected refreshed config but got version %d - %v", conf.Version, err)
+	}
+
+	cache.refresh(time.Minute, stop)
+	if next.calls != 3 {
+		t.Errorf("expected config fetched within the interval not to be refreshed but got %d calls", next.calls)
+	}
+}
+
+func TestCompressedConfigCacheStaleIfError(t *testing.T) {
+	next := &failingAuthorizer{}
+	now := time.Now()
+	cache := NewCompressedConfigCache(next, time.Minute, 10)
+	cache.now = func() time.Time {
+		return now
+	}
+
+	var served []time.Duration
+	cache.ServeStaleIfError(5*time.Minute, func(serviceID string, staleness time.Duration) {
+		served = append(served, staleness)
+	})
+
+	request := authorizer.SystemRequest{ServiceID: "123", AccessToken: "any", Environment: "production"}
+	if _, err := cache.GetSystemConfiguration("https://system", request); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	// 3scale system cannot be reached once the config has expired
+	next.failures = 10
+	now = now.Add(2 * time.Minute)
+	conf, err := cache.GetSystemConfiguration("https://system", request)
+	if err != nil || conf.Version != 1 {
+		t.Errorf("expected expired config to be served when it cannot be refreshed but got version %d - %v", conf.Version, err)
+	}
+
+	now = now.Add(5 * time.Minute)
+	if _, err := cache.GetSystemConfiguration("https://system", request); err == nil {
+		t.Errorf("expected error once the config has been expired for longer than the grace period")
+	}
+
+	if len(served) != 1 || served[0] != time.Minute {
+		t.Errorf("expected the staleness of the config served to be reported but got %v", served)
+	}
+}
+
+func TestCompressedConfigCacheMetrics(t *testing.T) {
+	next := &failingAuthorizer{failures: 1}
+	now := time.Now()
+	cache := NewCompressedConfigCache(next, time.Minute, 1)
+	cache.now = func() time.Time {
+		return now
+	}
+
+	var hits, misses, evicted, entries int
+	var failed []string
+	var ages []time.Duration
+	cache.SetMetricsReporter(&MetricsReporter{
+		ConfigCacheHitCB: func(age time.Duration) {
+			hits++
+			ages = append(ages, age)
+		},
+		ConfigCacheMissCB:    func() { misses++ },
+		ConfigFetchFailedCB:  func(serviceID string) { failed = append(failed, serviceID) },
+		ConfigEvictedCB:      func() { evicted++ },
+		ConfigCacheEntriesCB: func(n int) { entries = n },
+	})
+
+	get := func(serviceID string) error {
+		request := authorizer.SystemRequest{ServiceID: serviceID, AccessToken: "any", Environment: "production"}
+		_, err := cache.GetSystemConfiguration("https://system", request)
+		return err
+	}
+
+	if err := get("1"); err == nil {
+		t.Fatalf("expected first fetch to fail")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := get("1"); err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	// the cache holds a single config, so fetching another evicts the first
+	if err := get("2"); err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if hits != 1 || misses != 3 || evicted != 1 || entries != 1 {
+		t.Errorf("unexpected hits %d, misses %d, evictions %d or entries %d", hits, misses, evicted, entries)
+	}
+
+	if len(ages) != 1 || ages[0] != 10*time.Second {
+		t.Errorf("expected the age of the config served to be reported but got %v", ages)
+	}
+
+	if len(failed) != 1 || failed[0] != "1" {
+		t.Errorf("expected the failed fetch to be reported but got %v", failed)
+	}
+}
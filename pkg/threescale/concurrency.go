@@ -0,0 +1,173 @@
+package threescale
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// PriorityClass determines the share of the concurrency limit available to the requests of a service
+type PriorityClass string
+
+const (
+	// PriorityCritical requests may use every slot, so are only shed once the adapter is at its limit
+	PriorityCritical PriorityClass = "critical"
+	// PriorityNormal is the class of services which are not assigned one
+	PriorityNormal PriorityClass = "normal"
+	// PriorityLow requests are the first to be shed as the adapter approaches its limit
+	PriorityLow PriorityClass = "low"
+
+	// DefaultPriorityNormalShare is the share of the concurrency limit available to normal priority requests
+	DefaultPriorityNormalShare = 0.8
+	// DefaultPriorityLowShare is the share of the concurrency limit available to low priority requests
+	DefaultPriorityLowShare = 0.5
+)
+
+// PriorityConfig assigns services to priority classes, so that under saturation the requests of low priority
+// services are shed first, leaving the remaining slots to business critical services
+type PriorityConfig struct {
+	// Services maps a service ID to its class. Services which are not listed are PriorityNormal.
+	Services map[string]PriorityClass
+	// Shares maps a class to the share, between 0 and 1, of the concurrency limit its requests may occupy.
+	// Classes which are not listed use their default share, and critical requests always have the full limit.
+	Shares map[PriorityClass]float64
+}
+
+// ParsePriorityClass returns the PriorityClass named by s
+func ParsePriorityClass(s string) (PriorityClass, error) {
+	switch class := PriorityClass(s); class {
+	case PriorityCritical, PriorityNormal, PriorityLow:
+		return class, nil
+	}
+	return "", fmt.Errorf("unknown priority class %q", s)
+}
+
+// concurrencyLimiter bounds the number of authorization requests in flight. Once the limit is reached, further
+// requests are rejected immediately with RESOURCE_EXHAUSTED rather than queued, so that a saturated adapter fails
+// fast and Mixer applies its failure policy predictably instead of every request slowing down.
+// When priorities are configured, requests are rejected once the requests in flight reach the share of the limit
+// available to the class of their service.
+type concurrencyLimiter struct {
+	limit    int
+	mutex    sync.Mutex
+	inFlight int
+	// thresholds holds the number of requests in flight at which each class is shed
+	thresholds map[PriorityClass]int
+	services   map[string]PriorityClass
+	// serviceID determines the service of a request, and is only set when priorities are configured
+	serviceID func(req interface{}) string
+	reporter  *MetricsReporter
+}
+
+// newConcurrencyLimiter returns nil unless the limit is positive
+func newConcurrencyLimiter(limit int, reporter *MetricsReporter) *concurrencyLimiter {
+	if limit <= 0 {
+		return nil
+	}
+
+	return &concurrencyLimiter{
+		limit:    limit,
+		reporter: reporter,
+	}
+}
+
+// withPriorities sheds the requests of each class once the requests in flight reach its share of the limit
+func (l *concurrencyLimiter) withPriorities(conf *PriorityConfig, serviceID func(req interface{}) string) *concurrencyLimiter {
+	if l == nil || conf == nil {
+		return l
+	}
+
+	shares := map[PriorityClass]float64{
+		PriorityNormal: DefaultPriorityNormalShare,
+		PriorityLow:    DefaultPriorityLowShare,
+	}
+	for class, share := range conf.Shares {
+		if class != PriorityCritical {
+			shares[class] = math.Min(math.Max(share, 0), 1)
+		}
+	}
+
+	l.thresholds = map[PriorityClass]int{PriorityCritical: l.limit}
+	for class, share := range shares {
+		l.thresholds[class] = int(math.Ceil(share * float64(l.limit)))
+	}
+
+	l.services = conf.Services
+	l.serviceID = serviceID
+	return l
+}
+
+// threshold returns the number of requests in flight at which the request is rejected
+func (l *concurrencyLimiter) threshold(req interface{}) int {
+	if l.serviceID == nil {
+		return l.limit
+	}
+
+	class, ok := l.services[l.serviceID(req)]
+	if !ok {
+		class = PriorityNormal
+	}
+
+	if threshold, ok := l.thresholds[class]; ok {
+		return threshold
+	}
+	return l.limit
+}
+
+// unaryInterceptor implements grpc.UnaryServerInterceptor
+func (l *concurrencyLimiter) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	threshold := l.threshold(req)
+
+	l.mutex.Lock()
+	if l.inFlight >= threshold {
+		l.mutex.Unlock()
+		l.reporter.concurrencyLimited()
+		return nil, status.Error(codes.ResourceExhausted, errConcurrencyLimit.Error())
+	}
+	l.inFlight++
+	l.mutex.Unlock()
+
+	defer func() {
+		l.mutex.Lock()
+		l.inFlight--
+		l.mutex.Unlock()
+	}()
+	return handler(ctx, req)
+}
+
+// requestServiceID returns the service an authorization request is for, taken from the handler params or the
+// request itself, without reporting any failure to parse the params, which is left to the handler
+func (s *Threescale) requestServiceID(req interface{}) string {
+	r, ok := req.(*authorization.HandleAuthorizationRequest)
+	if !ok || r.AdapterConfig == nil {
+		return ""
+	}
+
+	value := r.AdapterConfig.Value
+	if id, ok := s.sessionID(r.AdapterConfig); ok {
+		stored, ok := s.sessions.Load(id)
+		if !ok {
+			return ""
+		}
+		value = stored.([]byte)
+	}
+
+	cfg := &config.Params{}
+	if err := cfg.Unmarshal(value); err == nil && cfg.ServiceId != "" {
+		return cfg.ServiceId
+	}
+
+	if r.Instance != nil && r.Instance.Action != nil {
+		return r.Instance.Action.Service
+	}
+	return ""
+}
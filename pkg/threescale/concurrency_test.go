@@ -0,0 +1,114 @@
+package threescale
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	if newConcurrencyLimiter(0, nil) != nil {
+		t.Errorf("expected no limiter when the limit is not set")
+	}
+
+	var rejected int
+	limiter := newConcurrencyLimiter(1, &MetricsReporter{
+		ConcurrencyLimitedCB: func() {
+			rejected++
+		},
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		_, err := limiter.unaryInterceptor(context.TODO(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+		done <- err
+	}()
+	<-started
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	_, err := limiter.unaryInterceptor(context.TODO(), nil, &grpc.UnaryServerInfo{}, handler)
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected request beyond the limit to be rejected but got %v", err)
+	}
+
+	if rejected != 1 {
+		t.Errorf("expected rejection to be reported once but got %d", rejected)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error for request within the limit - %v", err)
+	}
+
+	resp, err := limiter.unaryInterceptor(context.TODO(), nil, &grpc.UnaryServerInfo{}, handler)
+	if err != nil || resp != "ok" {
+		t.Errorf("expected request to be handled once capacity is released but got %v, %v", resp, err)
+	}
+}
+
+func TestConcurrencyLimiterPriorities(t *testing.T) {
+	limiter := newConcurrencyLimiter(10, nil).withPriorities(&PriorityConfig{
+		Services: map[string]PriorityClass{
+			"critical": PriorityCritical,
+			"low":      PriorityLow,
+		},
+		Shares: map[PriorityClass]float64{
+			PriorityNormal:   0.7,
+			PriorityCritical: 0.1,
+		},
+	}, func(req interface{}) string {
+		return req.(string)
+	})
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	inputs := []struct {
+		inFlight   int
+		service    string
+		expectShed bool
+	}{
+		{inFlight: 4, service: "low"},
+		{inFlight: 5, service: "low", expectShed: true},
+		{inFlight: 5, service: "unassigned"},
+		{inFlight: 7, service: "unassigned", expectShed: true},
+		{inFlight: 9, service: "critical"},
+		{inFlight: 10, service: "critical", expectShed: true},
+	}
+
+	for _, input := range inputs {
+		limiter.inFlight = input.inFlight
+		_, err := limiter.unaryInterceptor(context.TODO(), input.service, &grpc.UnaryServerInfo{}, handler)
+		if shed := status.Code(err) == codes.ResourceExhausted; shed != input.expectShed {
+			t.Errorf("unexpected result for %s service with %d requests in flight - %v", input.service, input.inFlight, err)
+		}
+
+		if limiter.inFlight != input.inFlight {
+			t.Errorf("expected requests in flight to be restored to %d but got %d", input.inFlight, limiter.inFlight)
+		}
+	}
+}
+
+func TestParsePriorityClass(t *testing.T) {
+	if class, err := ParsePriorityClass("low"); err != nil || class != PriorityLow {
+		t.Errorf("unexpected result parsing low priority - %v, %v", class, err)
+	}
+
+	if _, err := ParsePriorityClass("urgent"); err == nil {
+		t.Errorf("expected error for unknown priority class")
+	}
+}
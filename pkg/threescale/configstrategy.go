@@ -0,0 +1,140 @@
+package threescale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+// ConfigStrategy determines how a request is handled when the proxy config of its service is expired or missing
+// from the cache, trading the latency of the request against the staleness of the config used to authorize it
+type ConfigStrategy string
+
+const (
+	// ConfigWaitForFresh waits for the config to be fetched from 3scale system, or for the request to be cancelled
+	ConfigWaitForFresh ConfigStrategy = "wait-for-fresh"
+	// ConfigServeStale serves an expired config immediately, fetching a fresh config in the background.
+	// Requests wait as with ConfigWaitForFresh when no config is cached.
+	ConfigServeStale ConfigStrategy = "serve-stale"
+	// ConfigFailFast rejects the request immediately unless a fresh config is cached, fetching the config in the
+	// background for subsequent requests
+	ConfigFailFast ConfigStrategy = "fail-fast"
+)
+
+var errConfigNotCached = errors.New("proxy config is not cached")
+
+// ConfigCache is implemented by Authorizers which cache proxy configs and allow the caller to determine how an
+// expired or missing config is handled
+type ConfigCache interface {
+	Get(ctx context.Context, systemURL string, request authorizer.SystemRequest, opts ConfigCacheOptions) (client.ProxyConfig, error)
+}
+
+// ConfigCacheOptions determine how a ConfigCache handles the proxy config of a request. Zero values use the defaults
+// of the cache.
+type ConfigCacheOptions struct {
+	Strategy ConfigStrategy
+	// TTL is the period a fetched config is cached for
+	TTL time.Duration
+	// RefreshInterval is the period after which a fresh config is refreshed in the background
+	RefreshInterval time.Duration
+	// MaxRetries is the number of times a failed fetch is retried
+	MaxRetries int
+}
+
+// parseCacheOptions returns the cache options set by the handler, along with a message for each which is invalid
+func parseCacheOptions(cfg *config.Params) (ConfigCacheOptions, []string) {
+	var opts ConfigCacheOptions
+	var errMsgs []string
+
+	for _, period := range []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{
+		{"system_cache_ttl", cfg.SystemCacheTtl, &opts.TTL},
+		{"system_cache_refresh_interval", cfg.SystemCacheRefreshInterval, &opts.RefreshInterval},
+	} {
+		if period.value == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(period.value)
+		if err != nil || d <= 0 {
+			errMsgs = append(errMsgs, fmt.Sprintf("%s must be a positive duration such as 30s or 5m", period.name))
+			continue
+		}
+		*period.into = d
+	}
+
+	if cfg.SystemCacheMaxRetries < 0 {
+		errMsgs = append(errMsgs, "system_cache_max_retries must not be negative")
+	} else {
+		opts.MaxRetries = int(cfg.SystemCacheMaxRetries)
+	}
+	return opts, errMsgs
+}
+
+// ParseConfigStrategy returns the ConfigStrategy named by s
+func ParseConfigStrategy(s string) (ConfigStrategy, error) {
+	switch strategy := ConfigStrategy(s); strategy {
+	case ConfigWaitForFresh, ConfigServeStale, ConfigFailFast:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown config strategy %q", s)
+}
+
+// getSystemConfiguration fetches the proxy config for the handler, recording whether 3scale system could be reached
+// for health checks. The mapping rules may be omitted from the config, and are returned by the proxyRulesFunc.
+func (s *Threescale) getSystemConfiguration(ctx context.Context, cfg *config.Params) (client.ProxyConfig, proxyRulesFunc, error) {
+	proxyConf, rules, err := s.fetchSystemConfiguration(ctx, cfg)
+	s.systemHealth.record(err, time.Now())
+	return proxyConf, rules, err
+}
+
+// fetchSystemConfiguration uses the cache options of the handler, along with the strategy configured for the priority
+// class of the service, when the Authorizer for the request is a ConfigCache. The mapping rules of configs cached by a
+// CompressedConfigCache are left compressed until they are needed.
+func (s *Threescale) fetchSystemConfiguration(ctx context.Context, cfg *config.Params) (client.ProxyConfig, proxyRulesFunc, error) {
+	request := s.systemRequestFromHandlerConfig(cfg)
+
+	auth := s.authorizerFor(ctx)
+	cache, ok := auth.(ConfigCache)
+	if !ok {
+		proxyConf, err := auth.GetSystemConfiguration(cfg.SystemUrl, request)
+		return proxyConf, decodedRules(proxyConf), err
+	}
+
+	opts, _ := parseCacheOptions(cfg)
+	opts.Strategy = s.configStrategy(cfg.ServiceId)
+	if compressed, ok := cache.(*CompressedConfigCache); ok {
+		return compressed.getWithoutRules(ctx, cfg.SystemUrl, request, opts)
+	}
+
+	proxyConf, err := cache.Get(ctx, cfg.SystemUrl, request, opts)
+	return proxyConf, decodedRules(proxyConf), err
+}
+
+// configStrategy returns the strategy configured for the priority class of the service
+func (s *Threescale) configStrategy(serviceID string) ConfigStrategy {
+	if len(s.conf.ConfigStrategies) == 0 {
+		return ConfigWaitForFresh
+	}
+
+	class := PriorityNormal
+	if s.conf.Priorities != nil {
+		if c, ok := s.conf.Priorities.Services[serviceID]; ok {
+			class = c
+		}
+	}
+
+	strategy, ok := s.conf.ConfigStrategies[class]
+	if !ok {
+		return ConfigWaitForFresh
+	}
+	return strategy
+}
@@ -0,0 +1,206 @@
+package threescale
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	convert "github.com/3scale/3scale-go-client/threescale/http"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// The contract tests pin down the parts of the 3scale client libraries which the adapter depends on.
+// They run against the versions locked in Gopkg.lock, so that bumping a client library surfaces
+// any breaking change as a failing test here rather than at runtime.
+
+// the adapter is built around the authorizer provided by 3scale-authorizer
+var _ Authorizer = (*authorizer.Manager)(nil)
+
+// contractAuthorizer records the requests made by the adapter
+type contractAuthorizer struct {
+	conf        client.ProxyConfig
+	backendURL  string
+	authRequest authorizer.BackendRequest
+}
+
+func (c *contractAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	return c.conf, nil
+}
+
+func (c *contractAuthorizer) AuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	c.backendURL = backendURL
+	c.authRequest = request
+	return &authorizer.BackendResponse{Authorized: true}, nil
+}
+
+func (c *contractAuthorizer) Shutdown() {}
+
+// loadContractProxyConfig decodes a response from the 3scale latest.json proxy config endpoint
+func loadContractProxyConfig(t *testing.T) client.ProxyConfig {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "contract", "proxy_config.json"))
+	if err != nil {
+		t.Fatalf("unable to read fixture - %v", err)
+	}
+
+	var resp struct {
+		ProxyConfig client.ProxyConfig `json:"proxy_config"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil {
+		t.Fatalf("unable to decode fixture - %v", err)
+	}
+	return resp.ProxyConfig
+}
+
+func TestContractProxyConfig(t *testing.T) {
+	conf := loadContractProxyConfig(t)
+
+	if conf.ID != 42 || conf.Version != 3 {
+		t.Errorf("unexpected id or version - %d, %d", conf.ID, conf.Version)
+	}
+
+	if conf.Content.BackendVersion != "1" {
+		t.Errorf("unexpected backend version - %s", conf.Content.BackendVersion)
+	}
+
+	if conf.Content.BackendAuthenticationType != "service_token" || conf.Content.BackendAuthenticationValue != "service-token-value" {
+		t.Errorf("unexpected backend authentication - %s, %s", conf.Content.BackendAuthenticationType, conf.Content.BackendAuthenticationValue)
+	}
+
+	if conf.Content.Proxy.Backend.Endpoint != "https://su1.3scale.net" {
+		t.Errorf("unexpected backend endpoint - %s", conf.Content.Proxy.Backend.Endpoint)
+	}
+
+	expectRules := []client.ProxyRule{
+		{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1, Position: 1},
+		{HTTPMethod: http.MethodPost, Pattern: "/orders$", MetricSystemName: "orders", Delta: 2, Position: 2, Last: true},
+	}
+
+	rules := conf.Content.Proxy.ProxyRules
+	if len(rules) != len(expectRules) {
+		t.Fatalf("expected %d proxy rules but got %d", len(expectRules), len(rules))
+	}
+
+	for i, expect := range expectRules {
+		got := rules[i]
+		if got.HTTPMethod != expect.HTTPMethod || got.Pattern != expect.Pattern || got.MetricSystemName != expect.MetricSystemName ||
+			got.Delta != expect.Delta || got.Position != expect.Position || got.Last != expect.Last {
+			t.Errorf("unexpected proxy rule at %d - %+v", i, got)
+		}
+	}
+}
+
+func TestContractBackendErrorCodes(t *testing.T) {
+	// error codes returned by backend which the adapter maps to a status and decision reason
+	codes := []string{
+		"user_key_invalid",
+		"application_not_found",
+		"application_key_invalid",
+		"limits_exceeded",
+	}
+
+	for _, code := range codes {
+		if convert.CodeToStatusCode(code) == 0 {
+			t.Errorf("expected error code %s to be mapped to a status code", code)
+		}
+	}
+}
+
+func TestContractHandleAuthorization(t *testing.T) {
+	inputs := []struct {
+		name          string
+		method        string
+		path          string
+		expectMetrics api.Metrics
+	}{
+		{
+			name:          "Test rule matching on method and pattern",
+			method:        "get",
+			path:          "/anything",
+			expectMetrics: api.Metrics{"hits": 1},
+		},
+		{
+			name:          "Test last rule with delta",
+			method:        "post",
+			path:          "/orders",
+			expectMetrics: api.Metrics{"orders": 2},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			fake := &contractAuthorizer{conf: loadContractProxyConfig(t)}
+
+			params := config.Params{
+				ServiceId:   "123",
+				SystemUrl:   "https://www.fake-system.3scale.net",
+				AccessToken: "token",
+			}
+			b, _ := params.Marshal()
+
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer:      fake,
+					KeepAliveMaxAge: time.Second,
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: input.method,
+						Path:   input.path,
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "secret",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != int32(rpc.OK) {
+				t.Fatalf("expected request to be authorized - %s", result.Status.Message)
+			}
+
+			if fake.backendURL != "https://su1.3scale.net" {
+				t.Errorf("expected backend endpoint from proxy config but got %s", fake.backendURL)
+			}
+
+			auth := fake.authRequest.Auth
+			if auth.Type != "service_token" || auth.Value != "service-token-value" {
+				t.Errorf("unexpected backend auth - %+v", auth)
+			}
+
+			if len(fake.authRequest.Transactions) != 1 {
+				t.Fatalf("expected a single transaction but got %d", len(fake.authRequest.Transactions))
+			}
+
+			transaction := fake.authRequest.Transactions[0]
+			if transaction.Params.UserKey != "secret" {
+				t.Errorf("unexpected user key - %s", transaction.Params.UserKey)
+			}
+
+			if len(transaction.Metrics) != len(input.expectMetrics) {
+				t.Fatalf("unexpected metrics - %v", transaction.Metrics)
+			}
+
+			for metric, delta := range input.expectMetrics {
+				if transaction.Metrics[metric] != delta {
+					t.Errorf("unexpected delta for %s - %v", metric, transaction.Metrics)
+				}
+			}
+		})
+	}
+}
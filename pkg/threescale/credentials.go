@@ -0,0 +1,53 @@
+package threescale
+
+import (
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/selftest"
+
+	"istio.io/istio/pkg/log"
+)
+
+// credentialsRetryInterval is the period after which a check which could not reach a conclusion is repeated
+const credentialsRetryInterval = time.Minute
+
+// CredentialsValidator verifies that 3scale backend accepts the credentials, such as a service token, configured
+// for a service. selftest.ErrInvalidCredentials should be returned where backend explicitly rejects them.
+type CredentialsValidator func(backendURL, serviceID, authType, authValue string) error
+
+// validateServiceCredentials checks, the first time a handler is used for a service, that backend accepts the
+// credentials used for the service. An invalid service token is otherwise only visible as an opaque
+// denial from backend. The check runs in the background so that it does not add latency to the request.
+func (s *Threescale) validateServiceCredentials(rawConfig []byte, cfg *config.Params, auth authorizer.BackendAuth) {
+	if s.conf.CredentialsValidator == nil {
+		return
+	}
+
+	key := handlerFingerprint(rawConfig) + "|" + cfg.ServiceId
+	if val, seen := s.credentialsChecked.Load(key); seen {
+		if retryAt := val.(time.Time); retryAt.IsZero() || time.Now().Before(retryAt) {
+			return
+		}
+	}
+	s.credentialsChecked.Store(key, time.Now().Add(credentialsRetryInterval))
+
+	backendURL, serviceID := cfg.BackendUrl, cfg.ServiceId
+	authType, authValue := auth.Type, auth.Value
+
+	go func() {
+		err := s.conf.CredentialsValidator(backendURL, serviceID, authType, authValue)
+		switch err {
+		case nil:
+			s.credentialsChecked.Store(key, time.Time{})
+		case selftest.ErrInvalidCredentials:
+			s.credentialsChecked.Store(key, time.Time{})
+			log.Errorf("invalid %s for service %s - requests will be denied by 3scale backend", authType, serviceID)
+			s.conf.MetricsReporter.invalidCredentials(serviceID)
+		default:
+			// retried once the interval has passed, so that an unreachable backend is not called on every request
+			log.Warnf("unable to validate %s for service %s - %v", authType, serviceID, err)
+		}
+	}()
+}
@@ -0,0 +1,101 @@
+package threescale
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/selftest"
+)
+
+func TestValidateServiceCredentials(t *testing.T) {
+	inputs := []struct {
+		name          string
+		validatorErr  error
+		expectInvalid bool
+		expectRetry   bool
+	}{
+		{
+			name: "Test valid credentials are checked once",
+		},
+		{
+			name:          "Test invalid credentials are reported once",
+			validatorErr:  selftest.ErrInvalidCredentials,
+			expectInvalid: true,
+		},
+		{
+			name:         "Test inconclusive check is retried after the interval",
+			validatorErr: errors.New("backend unavailable"),
+			expectRetry:  true,
+		},
+	}
+
+	auth := authorizer.BackendAuth{Type: "service_token", Value: "token"}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			calls := make(chan string, 10)
+			invalid := make(chan string, 10)
+
+			s := &Threescale{
+				conf: &AdapterConfig{
+					CredentialsValidator: func(backendURL, serviceID, authType, authValue string) error {
+						if authType != "service_token" || authValue != "token" {
+							t.Errorf("unexpected credentials %s=%s", authType, authValue)
+						}
+						calls <- serviceID
+						return input.validatorErr
+					},
+					MetricsReporter: &MetricsReporter{
+						InvalidCredentialsCB: func(serviceID string) {
+							invalid <- serviceID
+						},
+					},
+				},
+			}
+
+			cfg := &config.Params{ServiceId: "123", BackendUrl: "https://su1.3scale.net"}
+			s.validateServiceCredentials([]byte("handler"), cfg, auth)
+			waitFor(t, calls, "123")
+
+			if input.expectInvalid {
+				waitFor(t, invalid, "123")
+			}
+
+			s.validateServiceCredentials([]byte("handler"), cfg, auth)
+
+			if input.expectRetry {
+				// bring the retry forward rather than waiting out the interval
+				s.credentialsChecked.Range(func(key, val interface{}) bool {
+					s.credentialsChecked.Store(key, time.Now().Add(-time.Second))
+					return true
+				})
+				s.validateServiceCredentials([]byte("handler"), cfg, auth)
+				waitFor(t, calls, "123")
+			}
+
+			select {
+			case <-calls:
+				t.Errorf("unexpected additional validation")
+			case <-invalid:
+				t.Errorf("unexpected additional report of invalid credentials")
+			case <-time.After(time.Millisecond * 50):
+			}
+		})
+	}
+}
+
+func waitFor(t *testing.T, ch <-chan string, expect string) {
+	t.Helper()
+
+	select {
+	case got := <-ch:
+		if got != expect {
+			t.Errorf("expected %s but got %s", expect, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %s", expect)
+	}
+}
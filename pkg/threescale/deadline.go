@@ -0,0 +1,39 @@
+package threescale
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// deadlineInterceptor implements grpc.UnaryServerInterceptor, reporting how much of the deadline set by the caller,
+// such as the check timeout of Mixer, remained when each request arrived and how much of it was consumed handling the
+// request. Requests without a deadline are not reported.
+func (m *MetricsReporter) deadlineInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok || m == nil || m.DeadlineCB == nil {
+		return handler(ctx, req)
+	}
+
+	arrived := time.Now()
+	resp, err := handler(ctx, req)
+	m.DeadlineCB(path.Base(info.FullMethod), deadline.Sub(arrived), time.Since(arrived))
+	return resp, err
+}
+
+// chainUnaryInterceptors returns a grpc.UnaryServerInterceptor calling each of interceptors in turn, the first being
+// the outermost, since a grpc.Server accepts a single interceptor
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,74 @@
+package threescale
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestDeadlineInterceptor(t *testing.T) {
+	var method string
+	var remaining, consumed time.Duration
+	reporter := &MetricsReporter{
+		DeadlineCB: func(m string, r time.Duration, c time.Duration) {
+			method, remaining, consumed = m, r, c
+		},
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/authorization.HandleAuthorizationService/HandleAuthorization"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		time.Sleep(10 * time.Millisecond)
+		return "ok", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := reporter.deadlineInterceptor(ctx, nil, info, handler)
+	if err != nil || resp != "ok" {
+		t.Fatalf("expected request to be handled but got %v, %v", resp, err)
+	}
+
+	if method != "HandleAuthorization" {
+		t.Errorf("unexpected method %q", method)
+	}
+
+	if remaining <= 900*time.Millisecond || remaining > time.Second {
+		t.Errorf("unexpected deadline remaining on arrival %s", remaining)
+	}
+
+	if consumed < 10*time.Millisecond || consumed >= remaining {
+		t.Errorf("unexpected deadline consumed %s", consumed)
+	}
+
+	method = ""
+	if _, err := reporter.deadlineInterceptor(context.Background(), nil, info, handler); err != nil || method != "" {
+		t.Errorf("expected request without a deadline not to be reported but got %q - %v", method, err)
+	}
+}
+
+func TestChainUnaryInterceptors(t *testing.T) {
+	var calls []string
+	interceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+
+	chain := chainUnaryInterceptors([]grpc.UnaryServerInterceptor{interceptor("outer"), interceptor("inner")})
+	resp, err := chain(context.TODO(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return "ok", nil
+	})
+
+	if err != nil || resp != "ok" {
+		t.Errorf("unexpected response %v, %v", resp, err)
+	}
+
+	if expect := []string{"outer", "inner", "handler"}; !reflect.DeepEqual(calls, expect) {
+		t.Errorf("expected calls %v but got %v", expect, calls)
+	}
+}
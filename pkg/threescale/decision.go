@@ -0,0 +1,108 @@
+package threescale
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+	"istio.io/api/mixer/adapter/model/v1beta1"
+	"istio.io/istio/pkg/log"
+)
+
+// DecisionReason describes why the adapter reached an authorization decision
+type DecisionReason string
+
+const (
+	// DecisionReasonAttribute is the key used to expose the decision reason to Mixer
+	DecisionReasonAttribute = "threescale.decision_reason"
+
+	// ReasonOK indicates the request was authorized by 3scale
+	ReasonOK DecisionReason = "ok"
+	// ReasonRuleMiss indicates the request did not match any mapping rule
+	ReasonRuleMiss DecisionReason = "rule_miss"
+	// ReasonNoCredentials indicates the request did not provide credentials in the expected location
+	ReasonNoCredentials DecisionReason = "no_credentials"
+	// ReasonInvalidKey indicates 3scale did not recognise the provided credentials
+	ReasonInvalidKey DecisionReason = "invalid_key"
+	// ReasonLimits indicates the application has exceeded its usage limits
+	ReasonLimits DecisionReason = "limits"
+	// ReasonMethodNotPermitted indicates the application plan does not permit the request method
+	ReasonMethodNotPermitted DecisionReason = "method_not_permitted"
+	// ReasonRequestTooLarge indicates the request exceeds the maximum size permitted by the application plan
+	ReasonRequestTooLarge DecisionReason = "request_too_large"
+	// ReasonMalformedRequest indicates the request was missing a header required by the handler, or its value was invalid
+	ReasonMalformedRequest DecisionReason = "malformed_request"
+	// ReasonDenied indicates 3scale denied the request for a reason not covered above
+	ReasonDenied DecisionReason = "denied"
+	// ReasonHookDenied indicates the request was denied by a DecisionHook
+	ReasonHookDenied DecisionReason = "hook_denied"
+	// ReasonOnboarding indicates the request would have been denied but was allowed as the service is onboarding
+	ReasonOnboarding DecisionReason = "onboarding"
+	// ReasonFailOpen indicates 3scale could not be reached but the request was allowed as failing open is enabled
+	ReasonFailOpen DecisionReason = "fail_open"
+	// ReasonInvalidConfig indicates the handler or request did not provide the required configuration
+	ReasonInvalidConfig DecisionReason = "invalid_config"
+	// ReasonUpstreamError indicates 3scale could not be reached or returned an unexpected response
+	ReasonUpstreamError DecisionReason = "upstream_error"
+)
+
+// invalidKeyErrorCodes are the 3scale backend error codes caused by unknown or mismatched credentials
+var invalidKeyErrorCodes = map[string]bool{
+	"user_key_invalid":        true,
+	"application_not_found":   true,
+	"application_key_invalid": true,
+	"user_key_invalid_format": true,
+}
+
+// reasonFromErrorCode maps the error code from a denied 3scale backend response to a DecisionReason
+func reasonFromErrorCode(threescaleErrorCode string) DecisionReason {
+	if threescaleErrorCode == "limits_exceeded" {
+		return ReasonLimits
+	}
+
+	if invalidKeyErrorCodes[threescaleErrorCode] {
+		return ReasonInvalidKey
+	}
+	return ReasonDenied
+}
+
+// requestErrorToReason maps the errors returned when validating the backend request to a DecisionReason
+func requestErrorToReason(err error) DecisionReason {
+	switch err {
+	case errNoMappingRule:
+		return ReasonRuleMiss
+	case errNoCredentials:
+		return ReasonNoCredentials
+	default:
+		return ReasonInvalidConfig
+	}
+}
+
+// decide reports the decision reached for the service of the handler, which is unknown when its config could not be
+// parsed, and logs it along with the fingerprint of the proxy config it was made against, where there is one.
+// Requests are reported as allowed whenever the result is OK, including those allowed while onboarding or failing open.
+// The reason is then added to the status message, followed by any other attributes, with withAttributes.
+func (s *Threescale) decide(cfg *config.Params, result *v1beta1.CheckResult, reason DecisionReason, configVersion string, attributes ...string) *v1beta1.CheckResult {
+	var serviceID string
+	if cfg != nil {
+		serviceID = cfg.ServiceId
+	}
+
+	s.conf.MetricsReporter.decision(serviceID, result.Status.Code == int32(rpc.OK), reason)
+	withAttributes(result, append([]string{fmt.Sprintf("%s=%s", DecisionReasonAttribute, reason)}, attributes...))
+
+	log.Debugf("authorization decision reached for service %s with reason %s using config version %q", serviceID, reason, configVersion)
+	return result
+}
+
+// withAttributes appends the attributes, in the form "threescale.<name>=<value>", to the status message of the result,
+// so that they are available to Mixer telemetry via the check.error_message attribute, which Envoy also includes in
+// access logs. They follow any message already set, so that the text describing a denial is still at its start.
+func withAttributes(result *v1beta1.CheckResult, attributes []string) {
+	if result.Status.Message == "" {
+		result.Status.Message = strings.Join(attributes, " ")
+	} else {
+		result.Status.Message = fmt.Sprintf("%s - %s", result.Status.Message, strings.Join(attributes, " "))
+	}
+}
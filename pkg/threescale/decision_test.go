@@ -0,0 +1,158 @@
+package threescale
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestHandleAuthorizationDecisionReason(t *testing.T) {
+	proxyConf := client.ProxyConfig{
+		Content: client.Content{
+			Proxy: client.ContentProxy{
+				ProxyRules: []client.ProxyRule{
+					{HTTPMethod: http.MethodGet, Pattern: "/test", MetricSystemName: "hits", Delta: 1},
+				},
+			},
+		},
+	}
+
+	inputs := []struct {
+		name         string
+		path         string
+		user         string
+		authResponse *authorizer.BackendResponse
+		expectReason DecisionReason
+		expectAllow  bool
+	}{
+		{
+			name:         "Test authorized request",
+			path:         "/test",
+			user:         "secret",
+			authResponse: &authorizer.BackendResponse{Authorized: true},
+			expectReason: ReasonOK,
+			expectAllow:  true,
+		},
+		{
+			name:         "Test no matching mapping rule",
+			path:         "/other",
+			user:         "secret",
+			expectReason: ReasonRuleMiss,
+		},
+		{
+			name:         "Test missing credentials",
+			path:         "/test",
+			expectReason: ReasonNoCredentials,
+		},
+		{
+			name:         "Test invalid key",
+			path:         "/test",
+			user:         "secret",
+			authResponse: &authorizer.BackendResponse{ErrorCode: "user_key_invalid"},
+			expectReason: ReasonInvalidKey,
+		},
+		{
+			name:         "Test limits exceeded",
+			path:         "/test",
+			user:         "secret",
+			authResponse: &authorizer.BackendResponse{ErrorCode: "limits_exceeded"},
+			expectReason: ReasonLimits,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := config.Params{
+				ServiceId:   "123",
+				SystemUrl:   "https://www.fake-system.3scale.net",
+				AccessToken: "any",
+			}
+			b, _ := params.Marshal()
+
+			r := &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   input.path,
+					},
+					Subject: &authorization.SubjectMsg{
+						User: input.user,
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			}
+
+			var decisions []string
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig:       proxyConf,
+						withAuthResponse: input.authResponse,
+					},
+					MetricsReporter: &MetricsReporter{
+						DecisionCB: func(serviceID string, allowed bool, reason string) {
+							decisions = append(decisions, fmt.Sprintf("%s %t %s", serviceID, allowed, reason))
+						},
+					},
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), r)
+			expect := DecisionReasonAttribute + "=" + string(input.expectReason)
+			if input.expectAllow && result.Status.Message != expect {
+				t.Errorf("expected message %s for an allowed request but got %s", expect, result.Status.Message)
+			}
+
+			if !input.expectAllow && !strings.HasSuffix(result.Status.Message, " - "+expect) {
+				t.Errorf("expected message to end with %s but got %s", expect, result.Status.Message)
+			}
+
+			if strings.Contains(result.Status.Message, configFingerprint(proxyConf.Version, proxyConf.Content.Proxy.ProxyRules)) {
+				t.Errorf("expected message not to contain the config version but got %s", result.Status.Message)
+			}
+
+			expectDecision := fmt.Sprintf("123 %t %s", input.expectAllow, input.expectReason)
+			if len(decisions) != 1 || decisions[0] != expectDecision {
+				t.Errorf("expected decision %q to be reported but got %v", expectDecision, decisions)
+			}
+		})
+	}
+}
+
+func TestReasonFromErrorCode(t *testing.T) {
+	inputs := map[string]DecisionReason{
+		"limits_exceeded":         ReasonLimits,
+		"user_key_invalid":        ReasonInvalidKey,
+		"application_not_found":   ReasonInvalidKey,
+		"application_key_invalid": ReasonInvalidKey,
+		"application_not_active":  ReasonDenied,
+		"":                        ReasonDenied,
+	}
+
+	for code, expect := range inputs {
+		if reason := reasonFromErrorCode(code); reason != expect {
+			t.Errorf("unexpected reason for code %q, wanted %s but got %s", code, expect, reason)
+		}
+	}
+}
+
+// recordReason sets the DecisionCB of m, or of a new MetricsReporter if m is nil, to store the reason of the last
+// decision reported in reason
+func recordReason(m *MetricsReporter, reason *DecisionReason) *MetricsReporter {
+	if m == nil {
+		m = &MetricsReporter{}
+	}
+	m.DecisionCB = func(serviceID string, allowed bool, r string) {
+		*reason = DecisionReason(r)
+	}
+	return m
+}
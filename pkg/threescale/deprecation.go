@@ -0,0 +1,115 @@
+package threescale
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"go.uber.org/zap"
+
+	"istio.io/istio/pkg/log"
+)
+
+// deprecation describes a Params field, or combination of fields, which is still supported but
+// is scheduled for removal. Entries should be added here as the configuration evolves, so users
+// are warned, and can opt in to rejection via strict mode, before support is dropped.
+type deprecation struct {
+	// field identifies the deprecated field or combination of fields in the handler params
+	field string
+	// inUse reports whether the provided params rely on the deprecated configuration
+	inUse func(cfg *config.Params) bool
+	// migration describes what should be configured instead
+	migration string
+}
+
+// deprecations lists the handler configuration which is currently deprecated. No params are deprecated at present.
+var deprecations []deprecation
+
+// maxDeprecationsSeen bounds the number of handlers remembered as already warned about. Once reached they are
+// forgotten, so handlers which are still in use are warned about again rather than growing the set without limit.
+const maxDeprecationsSeen = 1000
+
+// handlerSet tracks the handlers which have already been warned about deprecated params
+type handlerSet struct {
+	mutex    sync.Mutex
+	handlers map[string]struct{}
+}
+
+// add records the handler, returning false if it had already been recorded
+func (h *handlerSet) add(handler string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, seen := h.handlers[handler]; seen {
+		return false
+	}
+
+	if h.handlers == nil || len(h.handlers) >= maxDeprecationsSeen {
+		h.handlers = make(map[string]struct{})
+	}
+	h.handlers[handler] = struct{}{}
+	return true
+}
+
+// deprecatedParamsInUse returns the deprecations which apply to the provided params
+func deprecatedParamsInUse(cfg *config.Params) []deprecation {
+	var inUse []deprecation
+	for _, d := range deprecations {
+		if d.inUse(cfg) {
+			inUse = append(inUse, d)
+		}
+	}
+	return inUse
+}
+
+// rejectDeprecatedParams returns an error describing the deprecated configuration in use when the adapter is running
+// in strict mode, so that the handler is rejected when Mixer validates it
+func (s *Threescale) rejectDeprecatedParams(cfg *config.Params) error {
+	if !s.conf.StrictConfig {
+		return nil
+	}
+
+	inUse := deprecatedParamsInUse(cfg)
+	if len(inUse) == 0 {
+		return nil
+	}
+
+	var errMsgs []string
+	for _, d := range inUse {
+		errMsgs = append(errMsgs, fmt.Sprintf("%s is deprecated - %s", d.field, d.migration))
+	}
+	return errors.New(errDeprecatedConfig.Error() + ": " + strings.Join(errMsgs, ", "))
+}
+
+// warnDeprecatedParams warns, once per handler, about deprecated configuration in use
+func (s *Threescale) warnDeprecatedParams(rawConfig []byte, cfg *config.Params) {
+	inUse := deprecatedParamsInUse(cfg)
+	if len(inUse) == 0 {
+		return
+	}
+
+	handler := handlerFingerprint(rawConfig)
+	if !s.deprecationsSeen.add(handler) {
+		return
+	}
+
+	for _, d := range inUse {
+		log.Warn("handler configuration uses deprecated params",
+			zap.String("handler", handler),
+			zap.String("system_url", cfg.SystemUrl),
+			zap.String("field", d.field),
+			zap.String("migration", d.migration),
+		)
+		s.conf.MetricsReporter.deprecatedConfig(d.field)
+	}
+}
+
+// handlerFingerprint provides a stable identifier for a handler based on its raw configuration
+func handlerFingerprint(rawConfig []byte) string {
+	sum := sha256.Sum256(rawConfig)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,144 @@
+package threescale
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+)
+
+func TestWarnDeprecatedParams(t *testing.T) {
+	defer func(original []deprecation) {
+		deprecations = original
+	}(deprecations)
+
+	deprecations = []deprecation{
+		{
+			field: "backend_url",
+			inUse: func(cfg *config.Params) bool {
+				return cfg.BackendUrl != ""
+			},
+			migration: "remove it",
+		},
+	}
+
+	deprecated := &config.Params{BackendUrl: "http://backend.example.com"}
+	current := &config.Params{SystemUrl: "http://system.example.com"}
+
+	var reported []string
+	s := &Threescale{
+		conf: &AdapterConfig{
+			// strict mode must not affect requests, which are only warned about
+			StrictConfig: true,
+			MetricsReporter: &MetricsReporter{
+				DeprecatedConfigCB: func(field string) {
+					reported = append(reported, field)
+				},
+			},
+		},
+	}
+
+	s.warnDeprecatedParams([]byte("current"), current)
+	for i := 0; i < 2; i++ {
+		s.warnDeprecatedParams([]byte("deprecated"), deprecated)
+	}
+
+	if len(reported) != 1 || reported[0] != "backend_url" {
+		t.Errorf("expected deprecated field to be reported once per handler but got %v", reported)
+	}
+
+	s.warnDeprecatedParams([]byte("another-handler"), deprecated)
+	if len(reported) != 2 {
+		t.Errorf("expected deprecated field to be reported for new handler but got %v", reported)
+	}
+}
+
+func TestWarnDeprecatedParamsWithoutReporter(t *testing.T) {
+	defer func(original []deprecation) {
+		deprecations = original
+	}(deprecations)
+
+	deprecations = []deprecation{
+		{
+			field: "service_id",
+			inUse: func(cfg *config.Params) bool {
+				return true
+			},
+		},
+	}
+
+	s := &Threescale{conf: &AdapterConfig{}}
+	s.warnDeprecatedParams(nil, &config.Params{})
+}
+
+func TestValidateStrictConfig(t *testing.T) {
+	defer func(original []deprecation) {
+		deprecations = original
+	}(deprecations)
+
+	deprecations = []deprecation{
+		{
+			field: "backend_url",
+			inUse: func(cfg *config.Params) bool {
+				return cfg.BackendUrl != ""
+			},
+			migration: "remove it",
+		},
+	}
+
+	adapterConfig, err := types.MarshalAny(&config.Params{
+		SystemUrl:   "https://www.fake-system.3scale.net",
+		AccessToken: "any",
+		BackendUrl:  "http://backend.example.com",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error marshalling params - %v", err)
+	}
+
+	validate := func(strict bool) *v1beta1.ValidateResponse {
+		s := &Threescale{conf: &AdapterConfig{StrictConfig: strict}}
+		resp, err := s.Validate(context.TODO(), &v1beta1.ValidateRequest{AdapterConfig: adapterConfig})
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+		return resp
+	}
+
+	if resp := validate(false); resp.Status.Code != int32(rpc.OK) {
+		t.Errorf("expected deprecated params to be valid outside of strict mode but got %s", resp.Status.Message)
+	}
+
+	resp := validate(true)
+	if resp.Status.Code != int32(rpc.FAILED_PRECONDITION) {
+		t.Fatalf("expected deprecated params to be rejected in strict mode but got %d", resp.Status.Code)
+	}
+
+	if !strings.Contains(resp.Status.Message, "backend_url is deprecated - remove it") {
+		t.Errorf("unexpected error message %s", resp.Status.Message)
+	}
+}
+
+func TestHandlerSet(t *testing.T) {
+	var h handlerSet
+	if !h.add("first") || h.add("first") {
+		t.Errorf("expected handler to be recorded once")
+	}
+
+	for i := 0; i < maxDeprecationsSeen; i++ {
+		h.add(fmt.Sprintf("handler-%d", i))
+	}
+
+	if len(h.handlers) > maxDeprecationsSeen {
+		t.Errorf("expected at most %d handlers but got %d", maxDeprecationsSeen, len(h.handlers))
+	}
+
+	if !h.add("first") {
+		t.Errorf("expected handlers to be forgotten once the limit was reached")
+	}
+}
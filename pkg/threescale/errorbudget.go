@@ -0,0 +1,162 @@
+package threescale
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// DefaultErrorBudgetWindow is the period over which the error rate of a service is measured
+	DefaultErrorBudgetWindow = time.Minute
+	// DefaultErrorBudgetMinRequests is the number of requests a window must hold before a service can fail open
+	DefaultErrorBudgetMinRequests = 20
+)
+
+// ErrorBudgetConfig configures the automatic switch of a service to fail-open when its requests to 3scale backend
+// keep failing, and back to enforcement once they recover
+type ErrorBudgetConfig struct {
+	// Threshold is the ratio of requests failing to reach 3scale backend, between 0 and 1, above which a service fails
+	// open. A service switches back to enforcement after a window with a ratio at or below the threshold.
+	Threshold float64
+	// Window is the period over which the ratio must be sustained before a service switches
+	Window time.Duration
+	// MinRequests is the number of requests a window must hold before a service switches to fail-open
+	MinRequests int
+	// FailOpenCB is optional and called when a service switches to or from failing open
+	FailOpenCB func(serviceID string, failingOpen bool)
+}
+
+// FailingOpenService describes a service which is currently failing open
+type FailingOpenService struct {
+	ServiceID string    `json:"service_id"`
+	Since     time.Time `json:"since"`
+}
+
+// ErrorBudget tracks the rate of errors reaching 3scale backend per service, over consecutive windows, to decide
+// which services should fail open. Requests for a service which is failing open are still sent to 3scale, so that
+// its recovery is noticed.
+type ErrorBudget struct {
+	conf     ErrorBudgetConfig
+	mutex    sync.Mutex
+	services map[string]*serviceBudget
+}
+
+type serviceBudget struct {
+	windowStart time.Time
+	requests    int
+	errors      int
+	failingOpen bool
+	since       time.Time
+}
+
+// NewErrorBudget returns an ErrorBudget, applying defaults to any unset window or minimum number of requests
+func NewErrorBudget(conf ErrorBudgetConfig) *ErrorBudget {
+	if conf.Window <= 0 {
+		conf.Window = DefaultErrorBudgetWindow
+	}
+
+	if conf.MinRequests <= 0 {
+		conf.MinRequests = DefaultErrorBudgetMinRequests
+	}
+
+	return &ErrorBudget{
+		conf:     conf,
+		services: make(map[string]*serviceBudget),
+	}
+}
+
+// Record counts a request for the service which reached, or failed to reach, 3scale backend. Once the current window
+// of the service has elapsed, its error rate is evaluated before a new window is started. A nil ErrorBudget ignores it.
+func (e *ErrorBudget) Record(serviceID string, failed bool, now time.Time) {
+	if e == nil {
+		return
+	}
+
+	e.mutex.Lock()
+	b, ok := e.services[serviceID]
+	if !ok {
+		b = &serviceBudget{windowStart: now}
+		e.services[serviceID] = b
+	}
+
+	var switched bool
+	if now.Sub(b.windowStart) >= e.conf.Window {
+		switched = e.evaluate(b, now)
+		b.windowStart, b.requests, b.errors = now, 0, 0
+	}
+
+	b.requests++
+	if failed {
+		b.errors++
+	}
+	failingOpen := b.failingOpen
+	e.mutex.Unlock()
+
+	if !switched {
+		return
+	}
+
+	if failingOpen {
+		log.Warnf("service %s exceeded its error budget reaching 3scale backend - failing open", serviceID)
+	} else {
+		log.Infof("service %s recovered its error budget reaching 3scale backend - enforcing", serviceID)
+	}
+
+	if e.conf.FailOpenCB != nil {
+		e.conf.FailOpenCB(serviceID, failingOpen)
+	}
+}
+
+// evaluate switches the service according to the error rate of the window which has elapsed, reporting whether it did
+func (e *ErrorBudget) evaluate(b *serviceBudget, now time.Time) bool {
+	if b.requests == 0 {
+		return false
+	}
+	exceeded := float64(b.errors)/float64(b.requests) > e.conf.Threshold
+
+	switch {
+	case !b.failingOpen && exceeded && b.requests >= e.conf.MinRequests:
+		b.failingOpen, b.since = true, now
+		return true
+	case b.failingOpen && !exceeded:
+		b.failingOpen, b.since = false, time.Time{}
+		return true
+	}
+	return false
+}
+
+// FailOpen reports whether the service has exceeded its error budget and should fail open. A nil ErrorBudget never does.
+func (e *ErrorBudget) FailOpen(serviceID string) bool {
+	if e == nil {
+		return false
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	b, ok := e.services[serviceID]
+	return ok && b.failingOpen
+}
+
+// FailingOpen returns the services currently failing open, ordered by service id
+func (e *ErrorBudget) FailingOpen() []FailingOpenService {
+	services := []FailingOpenService{}
+	if e == nil {
+		return services
+	}
+
+	e.mutex.Lock()
+	for serviceID, b := range e.services {
+		if b.failingOpen {
+			services = append(services, FailingOpenService{ServiceID: serviceID, Since: b.since})
+		}
+	}
+	e.mutex.Unlock()
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ServiceID < services[j].ServiceID
+	})
+	return services
+}
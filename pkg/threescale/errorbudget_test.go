@@ -0,0 +1,88 @@
+package threescale
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErrorBudget(t *testing.T) {
+	type window struct {
+		requests int
+		errors   int
+	}
+
+	inputs := []struct {
+		name         string
+		windows      []window
+		expectOpen   bool
+		expectEvents []bool
+	}{
+		{
+			name:         "Test sustained errors fail the service open",
+			windows:      []window{{requests: 20, errors: 15}},
+			expectOpen:   true,
+			expectEvents: []bool{true},
+		},
+		{
+			name:    "Test errors below the threshold are enforced",
+			windows: []window{{requests: 20, errors: 5}},
+		},
+		{
+			name:    "Test too few requests are not enough to fail open",
+			windows: []window{{requests: 5, errors: 5}},
+		},
+		{
+			name:         "Test service is enforced again on recovery",
+			windows:      []window{{requests: 20, errors: 20}, {requests: 20, errors: 1}},
+			expectEvents: []bool{true, false},
+		},
+		{
+			name:         "Test service keeps failing open while errors continue",
+			windows:      []window{{requests: 20, errors: 20}, {requests: 3, errors: 3}},
+			expectOpen:   true,
+			expectEvents: []bool{true},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var events []bool
+			budget := NewErrorBudget(ErrorBudgetConfig{
+				Threshold: 0.5,
+				FailOpenCB: func(serviceID string, failingOpen bool) {
+					if serviceID != "123" {
+						t.Errorf("unexpected service %s", serviceID)
+					}
+					events = append(events, failingOpen)
+				},
+			})
+
+			now := time.Now()
+			for _, w := range input.windows {
+				for i := 0; i < w.requests; i++ {
+					budget.Record("123", i < w.errors, now)
+				}
+				now = now.Add(DefaultErrorBudgetWindow)
+			}
+			// the last window is evaluated by the first request after it
+			budget.Record("123", false, now)
+
+			if got := budget.FailOpen("123"); got != input.expectOpen {
+				t.Errorf("unexpected fail open, wanted %v but got %v", input.expectOpen, got)
+			}
+
+			if budget.FailOpen("456") {
+				t.Errorf("expected other services to be enforced")
+			}
+
+			if !reflect.DeepEqual(events, input.expectEvents) {
+				t.Errorf("unexpected events, wanted %v but got %v", input.expectEvents, events)
+			}
+
+			if failing := budget.FailingOpen(); (len(failing) == 1) != input.expectOpen {
+				t.Errorf("unexpected services failing open %v", failing)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package threescale
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+)
+
+// 3scale backend extensions handlers can enable with backend_extensions
+const (
+	// ExtensionRejectionReason reports why 3scale backend denied a request
+	ExtensionRejectionReason = "rejection_reason_header"
+	// ExtensionLimitHeaders reports the remaining usage of the most constrained limit of the application
+	ExtensionLimitHeaders = "limit_headers"
+	// extensionNoBody asks 3scale backend to omit the response body, which the adapter needs to authorize requests
+	extensionNoBody = "no_body"
+)
+
+// Keys used to expose the data reported by 3scale backend extensions to Mixer
+const (
+	// RejectionReasonAttribute is the reason 3scale backend gave for denying a request
+	RejectionReasonAttribute = "threescale.rejection_reason"
+	// LimitRemainingAttribute is the remaining usage of the most constrained limit, where -1 is unlimited
+	LimitRemainingAttribute = "threescale.limit_remaining"
+	// LimitResetAttribute is the number of seconds until the most constrained limit resets, where -1 is never
+	LimitResetAttribute = "threescale.limit_reset"
+	// LimitMaxAttribute is the maximum usage permitted by the most constrained limit
+	LimitMaxAttribute = "threescale.limit_max"
+)
+
+// extensionHeaders maps the response headers of 3scale backend extensions to the attributes they are exposed as
+var extensionHeaders = []struct {
+	header    string
+	attribute string
+}{
+	{"3scale-rejection-reason", RejectionReasonAttribute},
+	{"3scale-limit-remaining", LimitRemainingAttribute},
+	{"3scale-limit-reset", LimitResetAttribute},
+	{"3scale-limit-max-value", LimitMaxAttribute},
+}
+
+// ExtensionsRouter is called with each 3scale backend endpoint a request is about to use, the service it is for and
+// the extensions its handler enables, encoded as expected by 3scale backend, so that the HTTP client can request them.
+// Options are empty when the handler enables no extensions. It must not block.
+type ExtensionsRouter func(endpoint string, serviceID string, options string)
+
+// backendExtensionsErrors returns a message for each backend extension of the handler which is not supported
+func backendExtensionsErrors(cfg *config.Params) []string {
+	var errMsgs []string
+	for _, extension := range cfg.BackendExtensions {
+		switch extension {
+		case ExtensionRejectionReason, ExtensionLimitHeaders:
+		case extensionNoBody:
+			errMsgs = append(errMsgs, "backend_extensions cannot include no_body, as the response body is required to authorize requests")
+		default:
+			errMsgs = append(errMsgs, fmt.Sprintf("backend_extensions must be any of %s or %s, not %q",
+				ExtensionRejectionReason, ExtensionLimitHeaders, extension))
+		}
+	}
+	return errMsgs
+}
+
+// backendOptions encodes the backend extensions of the handler as expected by 3scale backend
+func backendOptions(cfg *config.Params) string {
+	options := url.Values{}
+	for _, extension := range cfg.BackendExtensions {
+		options.Set(extension, "1")
+	}
+	return options.Encode()
+}
+
+// routeExtensions requests the backend extensions of the handler for the service from the 3scale backend at
+// backendURL. Since extensions are kept by endpoint and service, they apply to every handler of the same service using
+// the same backend. It must be called once the backend is known, which may only be after the proxy config has been fetched.
+func (s *Threescale) routeExtensions(cfg *config.Params, backendURL string) {
+	if s.conf.ExtensionsRouter == nil || backendURL == "" {
+		return
+	}
+	s.conf.ExtensionsRouter(backendURL, cfg.ServiceId, backendOptions(cfg))
+}
+
+// backendExtensionAttributes returns the data reported by the backend extensions of the handler, in the form
+// "threescale.limit_remaining=<value>", to be added to the status message after the decision reason.
+// Nothing is returned unless the response was received from 3scale backend, rather than from a cache.
+func backendExtensionAttributes(cfg *config.Params, resp *authorizer.BackendResponse) []string {
+	if len(cfg.BackendExtensions) == 0 || resp == nil {
+		return nil
+	}
+
+	raw, ok := resp.RawResponse.(*http.Response)
+	if !ok || raw == nil {
+		return nil
+	}
+
+	var attributes []string
+	for _, extension := range extensionHeaders {
+		if value := raw.Header.Get(extension.header); value != "" {
+			attributes = append(attributes, fmt.Sprintf("%s=%s", extension.attribute, value))
+		}
+	}
+	return attributes
+}
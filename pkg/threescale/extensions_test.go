@@ -0,0 +1,96 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestBackendExtensionsErrors(t *testing.T) {
+	errMsgs := backendExtensionsErrors(&config.Params{
+		BackendExtensions: []string{ExtensionLimitHeaders, "no_body", "unknown"},
+	})
+
+	if len(errMsgs) != 2 {
+		t.Fatalf("expected two errors but got %v", errMsgs)
+	}
+
+	if !strings.Contains(errMsgs[0], "no_body") || !strings.Contains(errMsgs[1], `"unknown"`) {
+		t.Errorf("unexpected errors %v", errMsgs)
+	}
+}
+
+func TestHandleAuthorizationBackendExtensions(t *testing.T) {
+	header := http.Header{}
+	header.Set("3scale-rejection-reason", "limits_exceeded")
+	header.Set("3scale-limit-remaining", "0")
+	header.Set("3scale-limit-reset", "30")
+
+	var routed []string
+	c := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer: mockAuthorizer{
+				withConfig: client.ProxyConfig{
+					Content: client.Content{
+						Proxy: client.ContentProxy{
+							Backend: client.Backend{Endpoint: internalBackend},
+							ProxyRules: []client.ProxyRule{
+								{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+							},
+						},
+					},
+				},
+				withAuthResponse: &authorizer.BackendResponse{
+					ErrorCode:   "limits_exceeded",
+					RawResponse: &http.Response{StatusCode: http.StatusConflict, Header: header},
+				},
+				t: t,
+			},
+			ExtensionsRouter: func(endpoint string, serviceID string, options string) {
+				routed = append(routed, endpoint, serviceID, options)
+			},
+		},
+	}
+
+	params := config.Params{
+		ServiceId:         "123",
+		SystemUrl:         "https://www.fake-system.3scale.net",
+		AccessToken:       "any",
+		BackendExtensions: []string{ExtensionRejectionReason, ExtensionLimitHeaders},
+	}
+	b, _ := params.Marshal()
+
+	result, err := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Action: &authorization.ActionMsg{
+				Method: http.MethodGet,
+				Path:   "/test",
+			},
+			Subject: &authorization.SubjectMsg{
+				User: "exceeded",
+			},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	expectRoute := []string{internalBackend, "123", "limit_headers=1&rejection_reason_header=1"}
+	if strings.Join(routed, " ") != strings.Join(expectRoute, " ") {
+		t.Errorf("expected extensions to be routed as %v but got %v", expectRoute, routed)
+	}
+
+	expectAttributes := "threescale.rejection_reason=limits_exceeded threescale.limit_remaining=0 threescale.limit_reset=30"
+	if !strings.HasSuffix(result.Status.Message, " - threescale.decision_reason=limits "+expectAttributes) {
+		t.Errorf("expected status message to end with %q but got %q", expectAttributes, result.Status.Message)
+	}
+}
@@ -0,0 +1,165 @@
+package threescale
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// The fixture tests replay requests against proxy configs snapshotted by cmd/fixturegen, under
+// testdata/fixtures/<name>, and compare the decisions reached with those recorded in decisions.golden.json.
+// Run with -update-fixtures to record the decisions after adding a fixture or intentionally changing behaviour.
+var updateFixtures = flag.Bool("update-fixtures", false, "record the decisions of the fixture tests")
+
+// fixtureRequest is a request in the requests.json file of a fixture
+type fixtureRequest struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	UserKey  string `json:"user_key,omitempty"`
+	AppID    string `json:"app_id,omitempty"`
+	AppKey   string `json:"app_key,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// fixtureDecision is the decision recorded for a fixtureRequest
+type fixtureDecision struct {
+	Method  string         `json:"method"`
+	Path    string         `json:"path"`
+	Code    string         `json:"code"`
+	Reason  DecisionReason `json:"reason"`
+	Metrics api.Metrics    `json:"metrics,omitempty"`
+}
+
+func TestFixtures(t *testing.T) {
+	dirs, _ := filepath.Glob(filepath.Join("testdata", "fixtures", "*"))
+	for _, dir := range dirs {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			conf := loadFixtureProxyConfig(t, filepath.Join(dir, "proxy_config.json"))
+
+			var requests []fixtureRequest
+			readFixtureJSON(t, filepath.Join(dir, "requests.json"), &requests)
+
+			decisions := make([]fixtureDecision, 0, len(requests))
+			for _, request := range requests {
+				decisions = append(decisions, fixtureDecisionFor(t, conf, request))
+			}
+
+			golden := filepath.Join(dir, "decisions.golden.json")
+			got, _ := json.MarshalIndent(decisions, "", "  ")
+			got = append(got, '\n')
+
+			if *updateFixtures {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatalf("unable to record decisions - %v", err)
+				}
+				return
+			}
+
+			expect, err := ioutil.ReadFile(golden)
+			if os.IsNotExist(err) {
+				t.Fatalf("no decisions recorded for fixture, run with -update-fixtures to record them")
+			} else if err != nil {
+				t.Fatalf("unable to read decisions - %v", err)
+			}
+
+			if string(got) != string(expect) {
+				t.Errorf("decisions differ from %s, run with -update-fixtures if the change is intended\n%s", golden, got)
+			}
+		})
+	}
+}
+
+// fixtureDecisionFor authorizes the request against the proxy config, using an authorizer which authorizes every
+// request it is called with, so that decisions reflect the mapping rules and credentials rather than 3scale backend
+func fixtureDecisionFor(t *testing.T, conf client.ProxyConfig, request fixtureRequest) fixtureDecision {
+	fake := &contractAuthorizer{conf: conf}
+	var reason DecisionReason
+	c := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer:      fake,
+			KeepAliveMaxAge: time.Second,
+			MetricsReporter: recordReason(nil, &reason),
+		},
+	}
+
+	params := config.Params{
+		ServiceId:   "fixture",
+		SystemUrl:   "https://www.fake-system.3scale.net",
+		AccessToken: "token",
+	}
+	b, _ := params.Marshal()
+
+	properties := make(map[string]*v1beta1.Value)
+	for key, value := range map[string]string{
+		AppIDAttributeKey:  request.AppID,
+		AppKeyAttributeKey: request.AppKey,
+		OIDCAttributeKey:   request.ClientID,
+	} {
+		if value != "" {
+			properties[key] = &v1beta1.Value{Value: &v1beta1.Value_StringValue{StringValue: value}}
+		}
+	}
+
+	result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Action: &authorization.ActionMsg{
+				Method: request.Method,
+				Path:   request.Path,
+			},
+			Subject: &authorization.SubjectMsg{
+				User:       request.UserKey,
+				Properties: properties,
+			},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	})
+
+	decision := fixtureDecision{
+		Method: request.Method,
+		Path:   request.Path,
+		Code:   rpc.Code(result.Status.Code).String(),
+		Reason: reason,
+	}
+
+	if len(fake.authRequest.Transactions) > 0 {
+		decision.Metrics = fake.authRequest.Transactions[0].Metrics
+	}
+	return decision
+}
+
+func loadFixtureProxyConfig(t *testing.T, path string) client.ProxyConfig {
+	t.Helper()
+
+	var resp struct {
+		ProxyConfig client.ProxyConfig `json:"proxy_config"`
+	}
+	readFixtureJSON(t, path, &resp)
+	return resp.ProxyConfig
+}
+
+func readFixtureJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read fixture - %v", err)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatalf("unable to decode fixture %s - %v", path, err)
+	}
+}
@@ -0,0 +1,50 @@
+package threescale
+
+import (
+	"sync/atomic"
+	"time"
+
+	"istio.io/istio/pkg/log"
+)
+
+// StartupGrace fails open for a period after the adapter starts, allowing requests which cannot be authorized because
+// 3scale cannot be reached. While its caches are cold every request needs 3scale, so without it an adapter restarting
+// alongside Mixer, or crash looping, would deny requests across the whole mesh until 3scale had been reached.
+type StartupGrace struct {
+	// Window is the period, from when the adapter is ready, during which it fails open
+	Window time.Duration
+	// UntilWarm ends the window early, once a proxy config has been fetched from 3scale system
+	UntilWarm bool
+}
+
+// inStartupGrace reports whether the adapter fails open at now as it started recently. Once the window has ended it
+// does not start again, even should the cache be emptied.
+func (s *Threescale) inStartupGrace(now time.Time) bool {
+	grace := s.conf.StartupGrace
+	if grace == nil || grace.Window <= 0 || atomic.LoadInt32(&s.graceEnded) == 1 {
+		return false
+	}
+
+	select {
+	case <-s.ready:
+	default:
+		return false
+	}
+
+	if now.Sub(s.readyAt) < grace.Window && !(grace.UntilWarm && s.configWarm()) {
+		return true
+	}
+
+	if atomic.CompareAndSwapInt32(&s.graceEnded, 0, 1) {
+		log.Infof("startup grace window has ended, requests which cannot be authorized are no longer allowed")
+	}
+	return false
+}
+
+// configWarm reports whether a proxy config has been fetched from 3scale system, or is cached by the Authorizer
+func (s *Threescale) configWarm() bool {
+	if cache, ok := s.conf.Authorizer.(interface{ Len() int }); ok && cache.Len() > 0 {
+		return true
+	}
+	return s.systemHealth.hasFetched()
+}
@@ -0,0 +1,56 @@
+package threescale
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInStartupGrace(t *testing.T) {
+	readyAt := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	newThreescale := func(grace *StartupGrace) *Threescale {
+		s := &Threescale{
+			conf:    &AdapterConfig{Authorizer: mockAuthorizer{}, StartupGrace: grace},
+			ready:   make(chan struct{}),
+			readyAt: readyAt,
+		}
+		close(s.ready)
+		return s
+	}
+
+	s := newThreescale(nil)
+	if s.inStartupGrace(readyAt) {
+		t.Errorf("expected no grace window unless configured")
+	}
+
+	s = newThreescale(&StartupGrace{Window: 30 * time.Second})
+	if !s.inStartupGrace(readyAt.Add(10 * time.Second)) {
+		t.Errorf("expected to be within the grace window")
+	}
+
+	if s.inStartupGrace(readyAt.Add(30 * time.Second)) {
+		t.Errorf("expected the grace window to have ended")
+	}
+
+	if s.inStartupGrace(readyAt.Add(10 * time.Second)) {
+		t.Errorf("expected the grace window not to start again once ended")
+	}
+
+	s = newThreescale(&StartupGrace{Window: 30 * time.Second, UntilWarm: true})
+	s.systemHealth.record(errors.New("connection refused"), readyAt)
+	if !s.inStartupGrace(readyAt.Add(time.Second)) {
+		t.Errorf("expected to be within the grace window while no config has been fetched")
+	}
+
+	s.systemHealth.record(nil, readyAt.Add(2*time.Second))
+	if s.inStartupGrace(readyAt.Add(3 * time.Second)) {
+		t.Errorf("expected the grace window to end once a config has been fetched")
+	}
+
+	s = newThreescale(&StartupGrace{Window: 30 * time.Second})
+	s.ready = make(chan struct{})
+	if s.inStartupGrace(readyAt) {
+		t.Errorf("expected no grace window before the adapter is ready")
+	}
+}
@@ -0,0 +1,105 @@
+package threescale
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+var (
+	errRequiredHeaders        = errors.New("required_headers keys must be a header name, optionally preceded by an HTTP method and a space")
+	errRequiredHeadersPattern = errors.New("required_headers values must be valid regular expressions")
+)
+
+// headerCheck is an entry of the required_headers param, which applies to every method unless one is set
+type headerCheck struct {
+	method  string
+	header  string
+	pattern string
+}
+
+// parseHeaderCheck splits a required_headers entry into the method it applies to and the header it checks
+func parseHeaderCheck(key string, pattern string) (headerCheck, error) {
+	fields := strings.Fields(key)
+	switch len(fields) {
+	case 1:
+		return headerCheck{header: strings.ToLower(fields[0]), pattern: pattern}, nil
+	case 2:
+		return headerCheck{method: strings.ToUpper(fields[0]), header: strings.ToLower(fields[1]), pattern: pattern}, nil
+	}
+	return headerCheck{}, errRequiredHeaders
+}
+
+// requiredHeadersErrors validates the required_headers param
+func (s *Threescale) requiredHeadersErrors(requiredHeaders map[string]string) []string {
+	var errMsgs []string
+	for key, pattern := range requiredHeaders {
+		if _, err := parseHeaderCheck(key, pattern); err != nil {
+			errMsgs = append(errMsgs, err.Error())
+			break
+		}
+	}
+
+	for _, pattern := range requiredHeaders {
+		if _, err := s.headerPatterns.compile(pattern); err != nil {
+			errMsgs = append(errMsgs, errRequiredHeadersPattern.Error())
+			break
+		}
+	}
+	return errMsgs
+}
+
+// checkRequiredHeaders returns an error describing the first header required by the handler which the request is
+// missing or whose value does not match, so that malformed requests are denied without calling 3scale.
+// Headers are checked in order of their key, so that the error is consistent.
+func (s *Threescale) checkRequiredHeaders(cfg *config.Params, action *authorization.ActionMsg) error {
+	if len(cfg.RequiredHeaders) == 0 {
+		return nil
+	}
+
+	headers := make(map[string]string)
+	for name, value := range action.Properties[RequestHeadersAttributeKey].GetStringMapValue().GetValue() {
+		headers[strings.ToLower(name)] = value
+	}
+
+	keys := make([]string, 0, len(cfg.RequiredHeaders))
+	for key := range cfg.RequiredHeaders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		check, err := parseHeaderCheck(key, cfg.RequiredHeaders[key])
+		if err != nil {
+			return err
+		}
+
+		if check.method != "" && check.method != strings.ToUpper(action.Method) {
+			continue
+		}
+
+		value := headers[check.header]
+		if value == "" {
+			return fmt.Errorf("required header %s is missing", check.header)
+		}
+
+		if check.pattern == "" {
+			continue
+		}
+
+		re, err := s.headerPatterns.compile(check.pattern)
+		if err != nil {
+			return errRequiredHeadersPattern
+		}
+
+		if !re.MatchString(value) {
+			return fmt.Errorf("header %s does not match the expected format", check.header)
+		}
+	}
+	return nil
+}
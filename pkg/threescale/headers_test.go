@@ -0,0 +1,165 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestCheckRequiredHeaders(t *testing.T) {
+	required := map[string]string{
+		"x-request-id":      "",
+		"POST Content-Type": "^application/json",
+	}
+
+	inputs := []struct {
+		name      string
+		method    string
+		headers   map[string]string
+		expectErr string
+	}{
+		{
+			name:    "Test present headers pass",
+			method:  http.MethodPost,
+			headers: map[string]string{"x-request-id": "abc", "content-type": "application/json; charset=utf-8"},
+		},
+		{
+			name:    "Test header names are case insensitive",
+			method:  http.MethodPost,
+			headers: map[string]string{"X-Request-ID": "abc", "Content-Type": "application/json"},
+		},
+		{
+			name:    "Test method specific header is not required for other methods",
+			method:  http.MethodGet,
+			headers: map[string]string{"x-request-id": "abc"},
+		},
+		{
+			name:      "Test missing header fails",
+			method:    http.MethodGet,
+			expectErr: "required header x-request-id is missing",
+		},
+		{
+			name:      "Test empty header fails",
+			method:    http.MethodGet,
+			headers:   map[string]string{"x-request-id": ""},
+			expectErr: "required header x-request-id is missing",
+		},
+		{
+			name:      "Test header not matching its pattern fails",
+			method:    http.MethodPost,
+			headers:   map[string]string{"x-request-id": "abc", "content-type": "text/plain"},
+			expectErr: "header content-type does not match the expected format",
+		},
+	}
+
+	s := &Threescale{}
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			err := s.checkRequiredHeaders(&config.Params{RequiredHeaders: required}, &authorization.ActionMsg{
+				Method: input.method,
+				Properties: map[string]*v1beta1.Value{
+					RequestHeadersAttributeKey: {
+						Value: &v1beta1.Value_StringMapValue{StringMapValue: &v1beta1.StringMap{Value: input.headers}},
+					},
+				},
+			})
+
+			if input.expectErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != input.expectErr {
+				t.Errorf("expected error %q but got %v", input.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestRequiredHeadersErrors(t *testing.T) {
+	inputs := map[string]struct {
+		headers     map[string]string
+		expectValid bool
+	}{
+		"Test valid entries":       {headers: map[string]string{"x-request-id": "", "POST content-type": "^application/json$"}, expectValid: true},
+		"Test invalid key":         {headers: map[string]string{"POST content type": ""}},
+		"Test invalid pattern":     {headers: map[string]string{"x-request-id": "["}},
+		"Test no required headers": {expectValid: true},
+	}
+
+	s := &Threescale{}
+	for name, input := range inputs {
+		if errMsgs := s.requiredHeadersErrors(input.headers); (len(errMsgs) == 0) != input.expectValid {
+			t.Errorf("%s - unexpected validation result %v", name, errMsgs)
+		}
+	}
+}
+
+func TestHandleAuthorizationRequiredHeaders(t *testing.T) {
+	params := config.Params{
+		ServiceId:       "123",
+		SystemUrl:       "https://www.fake-system.3scale.net",
+		AccessToken:     "any",
+		BackendUrl:      internalBackend,
+		RequiredHeaders: map[string]string{"x-request-id": ""},
+	}
+	b, _ := params.Marshal()
+
+	var reason DecisionReason
+	c := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer: mockAuthorizer{
+				withConfig: client.ProxyConfig{
+					Content: client.Content{
+						Proxy: client.ContentProxy{
+							ProxyRules: []client.ProxyRule{
+								{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+							},
+						},
+					},
+				},
+				withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+					t.Errorf("unexpected call to 3scale for a malformed request")
+				},
+				t: t,
+			},
+			MetricsReporter: recordReason(nil, &reason),
+		},
+	}
+
+	result, err := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Action: &authorization.ActionMsg{
+				Method: http.MethodGet,
+				Path:   "/test",
+			},
+			Subject: &authorization.SubjectMsg{
+				User: "VALID",
+			},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if result.Status.Code != int32(rpc.INVALID_ARGUMENT) {
+		t.Errorf("expected status %d but got %d - %s", rpc.INVALID_ARGUMENT, result.Status.Code, result.Status.Message)
+	}
+
+	if reason != ReasonMalformedRequest {
+		t.Errorf("expected decision reason %s but got %s", ReasonMalformedRequest, reason)
+	}
+}
@@ -0,0 +1,169 @@
+package threescale
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/3scale/3scale-porta-go-client/client"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// healthCheckInterval is the period between updates of the status served by the gRPC health checking protocol
+	healthCheckInterval = time.Second * 10
+	// systemFailureWindow is how long a failure to fetch a proxy config from 3scale system is reported as not serving,
+	// unless a later fetch succeeds. The failure expires, so that a replica removed from load balancing is tried again
+	// rather than remaining unhealthy for lack of requests.
+	systemFailureWindow = time.Second * 30
+)
+
+var (
+	errSystemUnreachable = errors.New("3scale system is unreachable")
+	errNoConfigFetched   = errors.New("no proxy config has been fetched from 3scale system")
+	errNotServing        = errors.New("gRPC server is not serving")
+)
+
+// systemHealth records whether the most recent proxy config fetch from 3scale system failed, and when
+type systemHealth struct {
+	mutex    sync.Mutex
+	failedAt time.Time
+	// fetched is set once any fetch has succeeded
+	fetched bool
+}
+
+// record the outcome of a proxy config fetch. Errors caused by the handler, such as an invalid access token, by the
+// request being cancelled or by a config strategy declining to wait do not make the adapter unhealthy.
+func (h *systemHealth) record(err error, now time.Time) {
+	if err != nil && !isSystemUnavailable(err) {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if err != nil {
+		h.failedAt = now
+	} else {
+		h.failedAt = time.Time{}
+		h.fetched = true
+	}
+}
+
+// hasFetched reports whether any fetch has succeeded
+func (h *systemHealth) hasFetched() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.fetched
+}
+
+// check returns errSystemUnreachable while the last fetch failed within systemFailureWindow
+func (h *systemHealth) check(now time.Time) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.failedAt.IsZero() && now.Sub(h.failedAt) < systemFailureWindow {
+		return errSystemUnreachable
+	}
+	return nil
+}
+
+// checkFetched returns errNoConfigFetched while no fetch has succeeded and the last fetch failed within
+// systemFailureWindow. A replica which has not attempted a fetch yet passes, since it cannot fetch before receiving a
+// request, and the failure expires for the same reason.
+func (h *systemHealth) checkFetched(now time.Time) error {
+	h.mutex.Lock()
+	fetched := h.fetched
+	h.mutex.Unlock()
+
+	if !fetched && h.check(now) != nil {
+		return errNoConfigFetched
+	}
+	return nil
+}
+
+func isSystemUnavailable(err error) bool {
+	if err == context.Canceled || err == errConfigNotCached {
+		return false
+	}
+
+	if e, ok := err.(client.ApiErr); ok {
+		return e.Code() >= 500
+	}
+	return true
+}
+
+// Serving returns an error unless the gRPC server has started serving and has not stopped
+func (s *Threescale) Serving() error {
+	if atomic.LoadInt32(&s.serving) == 0 {
+		return errNotServing
+	}
+	return nil
+}
+
+// ConfigReady returns an error while the adapter cannot authorize any request, as it holds no proxy config and its
+// attempts to fetch one from 3scale system have recently failed. Authorizers which cache proxy configs may implement
+// Len to report the number cached, which are counted as fetched.
+func (s *Threescale) ConfigReady() error {
+	if cache, ok := s.conf.Authorizer.(interface{ Len() int }); ok && cache.Len() > 0 {
+		return nil
+	}
+	return s.systemHealth.checkFetched(time.Now())
+}
+
+// servingStatus returns the status served by the gRPC health checking protocol. The adapter is not serving until it
+// is ready, while 3scale system is unreachable and while the optional HealthCheck fails.
+func (s *Threescale) servingStatus(now time.Time) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	select {
+	case <-s.ready:
+	default:
+		return healthpb.HealthCheckResponse_NOT_SERVING, errors.New("adapter is starting")
+	}
+
+	err := s.systemHealth.check(now)
+	if err == nil && s.conf.HealthCheck != nil {
+		err = s.conf.HealthCheck()
+	}
+
+	if err != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING, err
+	}
+	return healthpb.HealthCheckResponse_SERVING, nil
+}
+
+// updateHealth sets the status served for the adapter as a whole, logging changes
+func (s *Threescale) updateHealth() {
+	status, err := s.servingStatus(time.Now())
+
+	s.healthMutex.Lock()
+	changed := status != s.healthStatus
+	s.healthStatus = status
+	s.healthMutex.Unlock()
+
+	if changed {
+		if err != nil {
+			log.Warnf("reporting gRPC health as %s - %v", status, err)
+		} else {
+			log.Infof("reporting gRPC health as %s", status)
+		}
+	}
+	s.health.SetServingStatus("", status)
+}
+
+// runHealthUpdates updates the served status at healthCheckInterval until stop is closed
+func (s *Threescale) runHealthUpdates(stop <-chan struct{}) {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.updateHealth()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
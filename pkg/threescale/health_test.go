@@ -0,0 +1,108 @@
+package threescale
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestSystemHealth(t *testing.T) {
+	now := time.Now()
+	h := &systemHealth{}
+
+	h.record(context.Canceled, now)
+	h.record(errConfigNotCached, now)
+	if err := h.check(now); err != nil {
+		t.Errorf("unexpected error for failures not caused by 3scale system - %v", err)
+	}
+
+	h.record(errors.New("connection refused"), now)
+	if err := h.check(now.Add(time.Second)); err != errSystemUnreachable {
+		t.Errorf("expected system to be unreachable after a failed fetch but got %v", err)
+	}
+
+	if err := h.check(now.Add(systemFailureWindow)); err != nil {
+		t.Errorf("expected failure to expire but got %v", err)
+	}
+
+	h.record(nil, now.Add(time.Second))
+	if err := h.check(now.Add(time.Second)); err != nil {
+		t.Errorf("expected successful fetch to clear the failure but got %v", err)
+	}
+}
+
+func TestHealthCheckingProtocol(t *testing.T) {
+	var failing int32
+	server, err := NewThreescale("0", &AdapterConfig{
+		KeepAliveMaxAge: time.Minute,
+		HealthCheck: func() error {
+			if atomic.LoadInt32(&failing) == 1 {
+				return errors.New("self-test failing")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating server - %v", err)
+	}
+	defer server.Close()
+	s := server.(*Threescale)
+
+	addr := strings.Replace(s.Addr(), "[::]", "localhost", 1)
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("unexpected error dialling server - %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	check := func(expect healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.FailFast(false))
+		if err != nil {
+			t.Fatalf("unexpected error checking health - %v", err)
+		}
+
+		if resp.Status != expect {
+			t.Errorf("expected status %s but got %s", expect, resp.Status)
+		}
+	}
+
+	shutdown := make(chan error, 1)
+	go s.Run(shutdown)
+	<-s.Ready()
+
+	s.updateHealth()
+	check(healthpb.HealthCheckResponse_SERVING)
+
+	atomic.StoreInt32(&failing, 1)
+	s.updateHealth()
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func TestConfigReady(t *testing.T) {
+	s := &Threescale{conf: &AdapterConfig{Authorizer: mockAuthorizer{}}}
+	if err := s.ConfigReady(); err != nil {
+		t.Errorf("expected replica which has not fetched a config to be ready but got %v", err)
+	}
+
+	s.systemHealth.record(errors.New("connection refused"), time.Now())
+	if err := s.ConfigReady(); err != errNoConfigFetched {
+		t.Errorf("expected replica whose fetches failed to not be ready but got %v", err)
+	}
+
+	s.systemHealth.record(nil, time.Now())
+	s.systemHealth.record(errors.New("connection refused"), time.Now())
+	if err := s.ConfigReady(); err != nil {
+		t.Errorf("expected replica which has fetched a config to remain ready but got %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package threescale
+
+import (
+	"context"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// DecisionHook allows custom logic, such as additional header checks or notifications, to be compiled into the adapter
+// around the authorization of each request by 3scale without forking the request pipeline.
+// Hooks are provided via AdapterConfig and called in order. Implementations must be safe for concurrent use.
+type DecisionHook interface {
+	// BeforeAuthorization is called once a request has passed the checks made by the adapter itself, immediately
+	// before it is authorized by 3scale. Returning a non-nil status denies the request with that status,
+	// in which case 3scale and any remaining hooks are not called.
+	BeforeAuthorization(ctx context.Context, request AuthorizationRequest) *rpc.Status
+	// AfterAuthorization is called with the decision before it is returned to Mixer,
+	// including where the request was denied by a hook.
+	AfterAuthorization(ctx context.Context, request AuthorizationRequest, decision Decision)
+}
+
+// AuthorizationRequest describes the request being authorized. Hooks must not modify it.
+type AuthorizationRequest struct {
+	// Instance is the authorization instance received from Mixer
+	Instance *authorization.InstanceMsg
+	// Params is the handler configuration, with the backend url resolved
+	Params config.Params
+	// BackendRequest is the request made to 3scale backend
+	BackendRequest authorizer.BackendRequest
+}
+
+// Decision is the outcome of an authorization
+type Decision struct {
+	// Status returned to Mixer, before the decision reason and backend extension data are added to the message
+	Status rpc.Status
+	// Reason the decision was reached
+	Reason DecisionReason
+	// ConfigVersion is the fingerprint of the proxy config used to reach the decision
+	ConfigVersion string
+}
+
+// beforeAuthorization calls each hook in turn, returning the status of the first to deny the request
+func (s *Threescale) beforeAuthorization(ctx context.Context, request AuthorizationRequest) *rpc.Status {
+	for _, hook := range s.conf.DecisionHooks {
+		if status := hook.BeforeAuthorization(ctx, request); status != nil {
+			return status
+		}
+	}
+	return nil
+}
+
+func (s *Threescale) afterAuthorization(ctx context.Context, request AuthorizationRequest, decision Decision) {
+	for _, hook := range s.conf.DecisionHooks {
+		hook.AfterAuthorization(ctx, request, decision)
+	}
+}
@@ -0,0 +1,129 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/statuses"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// propertyHook denies requests without the required action property and records each decision
+type propertyHook struct {
+	property  string
+	decisions []Decision
+}
+
+func (h *propertyHook) BeforeAuthorization(ctx context.Context, request AuthorizationRequest) *rpc.Status {
+	if _, ok := request.Instance.Action.Properties[h.property]; !ok {
+		status := statuses.Denied("missing property " + h.property)
+		return &status
+	}
+	return nil
+}
+
+func (h *propertyHook) AfterAuthorization(ctx context.Context, request AuthorizationRequest, decision Decision) {
+	h.decisions = append(h.decisions, decision)
+}
+
+func TestHandleAuthorizationDecisionHooks(t *testing.T) {
+	inputs := []struct {
+		name             string
+		hookProperty     string
+		expectStatus     int32
+		expectReason     DecisionReason
+		expectAuthorized bool
+	}{
+		{
+			name:             "Test request permitted by hook is authorized by 3scale",
+			hookProperty:     LocalityAttributeKey,
+			expectStatus:     int32(rpc.OK),
+			expectReason:     ReasonOK,
+			expectAuthorized: true,
+		},
+		{
+			name:         "Test request denied by hook is not sent to 3scale",
+			hookProperty: "x-required",
+			expectStatus: int32(rpc.PERMISSION_DENIED),
+			expectReason: ReasonHookDenied,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := config.Params{
+				ServiceId:   "123",
+				SystemUrl:   "https://www.fake-system.3scale.net",
+				AccessToken: "any",
+				BackendUrl:  internalBackend,
+			}
+			b, _ := params.Marshal()
+
+			hook := &propertyHook{property: input.hookProperty}
+			var authorized bool
+			var reason DecisionReason
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								Proxy: client.ContentProxy{
+									ProxyRules: []client.ProxyRule{
+										{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+									},
+								},
+							},
+						},
+						withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+							authorized = true
+						},
+						withAuthResponse: &authorizer.BackendResponse{Authorized: true},
+						t:                t,
+					},
+					DecisionHooks:   []DecisionHook{hook},
+					MetricsReporter: recordReason(nil, &reason),
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/test",
+						Properties: map[string]*v1beta1.Value{
+							LocalityAttributeKey: {Value: &v1beta1.Value_StringValue{StringValue: "us-east1"}},
+						},
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "secret",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != input.expectStatus {
+				t.Errorf("expected status %d but got %d - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+
+			if reason != input.expectReason {
+				t.Errorf("expected decision reason %s but got %s", input.expectReason, reason)
+			}
+
+			if authorized != input.expectAuthorized {
+				t.Errorf("unexpected call to 3scale backend, expected %v", input.expectAuthorized)
+			}
+
+			if len(hook.decisions) != 1 || hook.decisions[0].Reason != input.expectReason {
+				t.Errorf("expected hook to observe a single decision with reason %s but got %+v", input.expectReason, hook.decisions)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package threescale
+
+import "strings"
+
+// backendForLocality returns the preferred backend url for the workload locality, in the form region/zone/subzone.
+// The most specific entry takes precedence, so a zone may be routed separately from the rest of its region.
+// An empty string is returned when no entry matches the locality.
+func backendForLocality(backends map[string]string, locality string) string {
+	locality = strings.Trim(locality, "/")
+	for locality != "" {
+		if backend, ok := backends[locality]; ok {
+			return backend
+		}
+
+		i := strings.LastIndex(locality, "/")
+		if i < 0 {
+			break
+		}
+		locality = locality[:i]
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestBackendForLocality(t *testing.T) {
+	backends := map[string]string{
+		"us-east1":         "https://backend-us-east.example.com",
+		"us-east1/zone-b":  "https://backend-us-east-b.example.com",
+		"eu-west1/zone-a/": "https://unreachable.example.com",
+	}
+
+	inputs := []struct {
+		locality string
+		expect   string
+	}{
+		{locality: "us-east1", expect: "https://backend-us-east.example.com"},
+		{locality: "us-east1/zone-a", expect: "https://backend-us-east.example.com"},
+		{locality: "us-east1/zone-b", expect: "https://backend-us-east-b.example.com"},
+		{locality: "us-east1/zone-b/subzone", expect: "https://backend-us-east-b.example.com"},
+		{locality: "/us-east1/zone-b/", expect: "https://backend-us-east-b.example.com"},
+		{locality: "eu-west1", expect: ""},
+		{locality: "", expect: ""},
+	}
+
+	for _, input := range inputs {
+		if backend := backendForLocality(backends, input.locality); backend != input.expect {
+			t.Errorf("unexpected backend for locality %q, wanted %q but got %q", input.locality, input.expect, backend)
+		}
+	}
+
+	if backend := backendForLocality(nil, "us-east1"); backend != "" {
+		t.Errorf("expected no backend when locality routing is not configured but got %s", backend)
+	}
+}
+
+func TestHandleAuthorizationLocalityRouting(t *testing.T) {
+	params := config.Params{
+		ServiceId:   "123",
+		SystemUrl:   "https://www.fake-system.3scale.net",
+		AccessToken: "any",
+		BackendUrlByLocality: map[string]string{
+			"us-east1": internalBackend,
+		},
+	}
+	b, _ := params.Marshal()
+
+	r := &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Action: &authorization.ActionMsg{
+				Method: http.MethodGet,
+				Path:   "/test",
+				Properties: map[string]*v1beta1.Value{
+					LocalityAttributeKey: {Value: &v1beta1.Value_StringValue{StringValue: "us-east1/zone-a"}},
+				},
+			},
+			Subject: &authorization.SubjectMsg{
+				User: "secret",
+			},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	}
+
+	var calledBackend string
+	c := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer: mockAuthorizer{
+				withConfig: client.ProxyConfig{
+					Content: client.Content{
+						Proxy: client.ContentProxy{
+							Backend: client.Backend{
+								Endpoint: "https://su1.3scale.net",
+							},
+							ProxyRules: []client.ProxyRule{
+								{HTTPMethod: http.MethodGet, Pattern: "/test", MetricSystemName: "hits", Delta: 1},
+							},
+						},
+					},
+				},
+				withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+					calledBackend = backendURL
+				},
+				withAuthResponse: &authorizer.BackendResponse{Authorized: true},
+				t:                t,
+			},
+		},
+	}
+
+	c.HandleAuthorization(context.TODO(), r)
+	if calledBackend != internalBackend {
+		t.Errorf("expected backend for locality %s but got %s", internalBackend, calledBackend)
+	}
+}
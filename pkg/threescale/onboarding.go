@@ -0,0 +1,64 @@
+package threescale
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/statuses"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+)
+
+// onboardingReasons are the denials which are logged rather than enforced while a service is onboarding.
+// Denials by decision hooks, invalid configuration and upstream errors are always enforced.
+var onboardingReasons = map[DecisionReason]bool{
+	ReasonRuleMiss:           true,
+	ReasonNoCredentials:      true,
+	ReasonInvalidKey:         true,
+	ReasonLimits:             true,
+	ReasonMethodNotPermitted: true,
+	ReasonDenied:             true,
+}
+
+// isOnboarding reports whether the service is still within the onboarding period set by the handler.
+// The period is an absolute time so that it is shared by every replica and survives restarts.
+func isOnboarding(cfg *config.Params, now time.Time) bool {
+	if cfg.OnboardingUntil == "" {
+		return false
+	}
+
+	until, err := time.Parse(time.RFC3339, cfg.OnboardingUntil)
+	return err == nil && now.Before(until)
+}
+
+// withShadowService replaces the service of the handler with its shadow service while onboarding, so that usage is
+// authorized and reported against the shadow service, leaving the analytics of the production service untouched
+// until the adapter starts enforcing.
+func withShadowService(cfg *config.Params, now time.Time) {
+	if cfg.ShadowServiceId != "" && isOnboarding(cfg, now) {
+		cfg.ServiceId = cfg.ShadowServiceId
+	}
+}
+
+// withOnboarding allows a request which would otherwise have been denied while its service is onboarding, so that
+// existing traffic can be migrated onto 3scale plans without disruption. The denial is logged and reported instead,
+// and the reason returned is ReasonOnboarding. Otherwise the result and reason are left unchanged.
+func (s *Threescale) withOnboarding(cfg *config.Params, result *v1beta1.CheckResult, reason DecisionReason) DecisionReason {
+	if !onboardingReasons[reason] || !isOnboarding(cfg, time.Now()) {
+		return reason
+	}
+
+	s.logSampledWarning(fmt.Sprintf("allowing request for service %s which would be denied with reason %s while onboarding",
+		cfg.ServiceId, reason))
+	s.conf.MetricsReporter.onboardingWouldDeny(cfg.ServiceId, reason)
+
+	message := fmt.Sprintf("would deny with reason %s", reason)
+	if result.Status.Message != "" {
+		message = fmt.Sprintf("%s - %s", message, result.Status.Message)
+	}
+
+	result.Status = statuses.OK
+	result.Status.Message = message
+	return ReasonOnboarding
+}
@@ -0,0 +1,244 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestIsOnboarding(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	inputs := map[string]bool{
+		"":                     false,
+		"2020-10-02T00:00:00Z": true,
+		"2020-10-01T00:00:00Z": false,
+		"not a time":           false,
+	}
+
+	for until, expect := range inputs {
+		if got := isOnboarding(&config.Params{OnboardingUntil: until}, now); got != expect {
+			t.Errorf("unexpected result for onboarding until %q, wanted %v but got %v", until, expect, got)
+		}
+	}
+}
+
+func TestHandleAuthorizationOnboarding(t *testing.T) {
+	inputs := []struct {
+		name            string
+		path            string
+		onboardingUntil string
+		authResponse    *authorizer.BackendResponse
+		expectStatus    int32
+		expectReason    DecisionReason
+		expectReported  string
+	}{
+		{
+			name:            "Test limits are not enforced while onboarding",
+			path:            "/test",
+			onboardingUntil: time.Now().Add(time.Hour).Format(time.RFC3339),
+			authResponse:    &authorizer.BackendResponse{ErrorCode: "limits_exceeded"},
+			expectStatus:    int32(rpc.OK),
+			expectReason:    ReasonOnboarding,
+			expectReported:  string(ReasonLimits),
+		},
+		{
+			name:            "Test mapping rule miss is not enforced while onboarding",
+			path:            "/other",
+			onboardingUntil: time.Now().Add(time.Hour).Format(time.RFC3339),
+			expectStatus:    int32(rpc.OK),
+			expectReason:    ReasonOnboarding,
+			expectReported:  string(ReasonRuleMiss),
+		},
+		{
+			name:            "Test limits are enforced once onboarding has ended",
+			path:            "/test",
+			onboardingUntil: time.Now().Add(-time.Hour).Format(time.RFC3339),
+			authResponse:    &authorizer.BackendResponse{ErrorCode: "limits_exceeded"},
+			expectStatus:    int32(rpc.RESOURCE_EXHAUSTED),
+			expectReason:    ReasonLimits,
+		},
+		{
+			name:            "Test authorized request is unaffected while onboarding",
+			path:            "/test",
+			onboardingUntil: time.Now().Add(time.Hour).Format(time.RFC3339),
+			authResponse:    &authorizer.BackendResponse{Authorized: true},
+			expectStatus:    int32(rpc.OK),
+			expectReason:    ReasonOK,
+		},
+		{
+			name:            "Test invalid onboarding time is rejected",
+			path:            "/test",
+			onboardingUntil: "tomorrow",
+			expectStatus:    int32(rpc.FAILED_PRECONDITION),
+			expectReason:    ReasonInvalidConfig,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := config.Params{
+				ServiceId:       "123",
+				SystemUrl:       "https://www.fake-system.3scale.net",
+				AccessToken:     "any",
+				BackendUrl:      internalBackend,
+				OnboardingUntil: input.onboardingUntil,
+			}
+			b, _ := params.Marshal()
+
+			var reported []string
+			var reason DecisionReason
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								Proxy: client.ContentProxy{
+									ProxyRules: []client.ProxyRule{
+										{HTTPMethod: http.MethodGet, Pattern: "/test", MetricSystemName: "hits", Delta: 1},
+									},
+								},
+							},
+						},
+						withAuthResponse: input.authResponse,
+						t:                t,
+					},
+					MetricsReporter: recordReason(&MetricsReporter{
+						OnboardingCB: func(serviceID string, reason string) {
+							reported = append(reported, reason)
+						},
+					}, &reason),
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   input.path,
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "secret",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != input.expectStatus {
+				t.Errorf("expected status %d but got %d - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+
+			if reason != input.expectReason {
+				t.Errorf("expected decision reason %s but got %s", input.expectReason, reason)
+			}
+
+			if input.expectReported == "" {
+				if len(reported) != 0 {
+					t.Errorf("unexpected report of request allowed while onboarding %v", reported)
+				}
+				return
+			}
+
+			if len(reported) != 1 || reported[0] != input.expectReported {
+				t.Errorf("expected request which would be denied with reason %s to be reported but got %v", input.expectReported, reported)
+			}
+
+			if !strings.Contains(result.Status.Message, "would deny with reason "+input.expectReported) {
+				t.Errorf("expected message to describe the denial but got %q", result.Status.Message)
+			}
+		})
+	}
+}
+
+func TestHandleAuthorizationShadowService(t *testing.T) {
+	inputs := []struct {
+		name            string
+		onboardingUntil string
+		expectStatus    int32
+		expectService   string
+	}{
+		{
+			name:            "Test shadow service is used while onboarding",
+			onboardingUntil: time.Now().Add(time.Hour).Format(time.RFC3339),
+			expectStatus:    int32(rpc.OK),
+			expectService:   "456",
+		},
+		{
+			name:            "Test production service is used once onboarding has ended",
+			onboardingUntil: time.Now().Add(-time.Hour).Format(time.RFC3339),
+			expectStatus:    int32(rpc.OK),
+			expectService:   "123",
+		},
+		{
+			name:         "Test shadow service without onboarding is rejected",
+			expectStatus: int32(rpc.FAILED_PRECONDITION),
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := config.Params{
+				ServiceId:       "123",
+				SystemUrl:       "https://www.fake-system.3scale.net",
+				AccessToken:     "any",
+				BackendUrl:      internalBackend,
+				OnboardingUntil: input.onboardingUntil,
+				ShadowServiceId: "456",
+			}
+			b, _ := params.Marshal()
+
+			var reportedService string
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								Proxy: client.ContentProxy{
+									ProxyRules: []client.ProxyRule{
+										{HTTPMethod: http.MethodGet, Pattern: "/test", MetricSystemName: "hits", Delta: 1},
+									},
+								},
+							},
+						},
+						withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+							reportedService = request.Service
+						},
+						withAuthResponse: &authorizer.BackendResponse{},
+						t:                t,
+					},
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "VALID",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != input.expectStatus {
+				t.Errorf("expected status %d but got %d - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+
+			if reportedService != input.expectService {
+				t.Errorf("expected usage to be reported to service %q but got %q", input.expectService, reportedService)
+			}
+		})
+	}
+}
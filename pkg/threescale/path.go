@@ -0,0 +1,29 @@
+package threescale
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// normalizePath decodes and normalizes the request path in the same way as NGINX, and therefore APIcast, before
+// mapping rules are matched against it. Percent-encoded octets are decoded, so UTF-8 paths match regardless of how
+// they were encoded by the client, duplicate slashes are merged and dot segments resolved. A trailing slash is kept.
+// Paths with invalid percent-encoding are returned unmodified.
+func normalizePath(p string) string {
+	decoded, err := url.PathUnescape(p)
+	if err != nil || decoded == "" {
+		return p
+	}
+
+	normalized := path.Clean("/" + decoded)
+	if normalized != "/" && hasTrailingSlash(decoded) {
+		normalized += "/"
+	}
+	return normalized
+}
+
+// hasTrailingSlash reports whether the path refers to a directory, including where it ends in a dot segment
+func hasTrailingSlash(p string) bool {
+	return strings.HasSuffix(p, "/") || strings.HasSuffix(p, "/.") || strings.HasSuffix(p, "/..")
+}
@@ -0,0 +1,93 @@
+package threescale
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestNormalizePath(t *testing.T) {
+	inputs := []struct {
+		path   string
+		expect string
+	}{
+		{path: "/test", expect: "/test"},
+		{path: "/test/", expect: "/test/"},
+		{path: "/", expect: "/"},
+		{path: "//test//path", expect: "/test/path"},
+		{path: "/a/./b/../c", expect: "/a/c"},
+		{path: "/a/b/..", expect: "/a/"},
+		{path: "/../a", expect: "/a"},
+		{path: "/caf%C3%A9", expect: "/café"},
+		{path: "/café", expect: "/café"},
+		{path: "/%E6%97%A5%E6%9C%AC/%e8%aa%9e", expect: "/日本/語"},
+		{path: "/with%20space", expect: "/with space"},
+		{path: "/a%2Fb", expect: "/a/b"},
+		{path: "/a%2E%2E/b", expect: "/a../b"},
+		{path: "/%2E%2E/b", expect: "/b"},
+		{path: "/invalid%zz", expect: "/invalid%zz"},
+	}
+
+	for _, input := range inputs {
+		if normalized := normalizePath(input.path); normalized != input.expect {
+			t.Errorf("unexpected normalized path for %q, wanted %q but got %q", input.path, input.expect, normalized)
+		}
+	}
+}
+
+// generatedPath produces paths built from segments likely to require normalization, including non-ASCII characters
+type generatedPath string
+
+func (generatedPath) Generate(r *rand.Rand, size int) reflect.Value {
+	parts := []string{"/", "//", ".", "..", "a", "Z", "0", "-", "_", "~", " ", "é", "日本", "ü", "🙂", "+", ";", "="}
+	var b strings.Builder
+	b.WriteString("/")
+	for i := 0; i < r.Intn(size+1); i++ {
+		b.WriteString(parts[r.Intn(len(parts))])
+	}
+	return reflect.ValueOf(generatedPath(b.String()))
+}
+
+// percentEncode encodes every byte of the path, as a client may do for non-ASCII characters
+func percentEncode(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		fmt.Fprintf(&b, "%%%02X", p[i])
+	}
+	return b.String()
+}
+
+func TestNormalizePathEncodingIndependent(t *testing.T) {
+	property := func(p generatedPath) bool {
+		return normalizePath(percentEncode(string(p))) == normalizePath(string(p))
+	}
+
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNormalizePathIsCanonical(t *testing.T) {
+	property := func(p generatedPath) bool {
+		normalized := normalizePath(string(p))
+		if !strings.HasPrefix(normalized, "/") || strings.Contains(normalized, "//") {
+			return false
+		}
+
+		for _, segment := range strings.Split(normalized, "/") {
+			if segment == "." || segment == ".." {
+				return false
+			}
+		}
+
+		// normalizing a decoded path without further escapes has no effect
+		return normalizePath(normalized) == normalized
+	}
+
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,294 @@
+package threescale
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/statuses"
+
+	"istio.io/istio/pkg/log"
+)
+
+const (
+	// DefaultPlanMethodsAttribute is the plan custom attribute read to determine the HTTP methods an application may use
+	DefaultPlanMethodsAttribute = "allowed_methods"
+	// DefaultPlanSizeAttribute is the plan custom attribute read to determine the maximum request size, in bytes
+	DefaultPlanSizeAttribute = "max_request_size"
+	// DefaultPlanCacheEntriesMax is the number of applications the restrictions are cached for
+	DefaultPlanCacheEntriesMax = 10000
+
+	// planErrorTTL is the longest failures to determine the restrictions of an application are cached for, so that
+	// requests with unknown credentials do not each cost two requests to 3scale system
+	planErrorTTL = 30 * time.Second
+
+	findApplicationEndpoint = "/admin/api/applications/find.json"
+	applicationPlanEndpoint = "/admin/api/services/%s/application_plans/%d.json"
+)
+
+// PlanRestrictions determines the HTTP methods that the application identified by the provided credentials
+// is permitted to use, and the maximum size of its requests, based on its application plan.
+// A nil slice indicates that the plan does not restrict methods and a zero size that it does not restrict size.
+type PlanRestrictions interface {
+	AllowedMethods(systemURL, accessToken, serviceID string, params authorizer.BackendParams) ([]string, error)
+	MaxRequestSize(systemURL, accessToken, serviceID string, params authorizer.BackendParams) (int64, error)
+}
+
+// PlanMethodRestrictions reads the restrictions from custom attributes on the application plan.
+// The methods attribute is expected to hold a comma separated list of methods, for example "GET,HEAD" for a
+// read-only plan, and the size attribute a number of bytes. Results are cached per application for the configured TTL,
+// and failures for up to planErrorTTL, with the least recently used of DefaultPlanCacheEntriesMax applications evicted.
+type PlanMethodRestrictions struct {
+	client        *http.Client
+	attribute     string
+	sizeAttribute string
+	ttl           time.Duration
+	maxEntries    int
+	mutex         sync.Mutex
+	cache         map[planKey]*list.Element
+	// order holds the cached entries, most recently used first
+	order *list.List
+}
+
+// planKey identifies an application by a hash of its credentials, so that they are not held by the cache
+type planKey [sha256.Size]byte
+
+type planEntry struct {
+	key     planKey
+	methods []string
+	maxSize int64
+	err     error
+	expires time.Time
+}
+
+type applicationResponse struct {
+	Application struct {
+		PlanID int64 `json:"plan_id"`
+	} `json:"application"`
+}
+
+type applicationPlanResponse struct {
+	ApplicationPlan map[string]interface{} `json:"application_plan"`
+}
+
+// NewPlanMethodRestrictions returns a PlanMethodRestrictions which reads the provided plan attributes.
+// If attribute is empty, DefaultPlanMethodsAttribute is used and if sizeAttribute is empty, DefaultPlanSizeAttribute.
+func NewPlanMethodRestrictions(client *http.Client, attribute string, sizeAttribute string, ttl time.Duration) *PlanMethodRestrictions {
+	if attribute == "" {
+		attribute = DefaultPlanMethodsAttribute
+	}
+
+	if sizeAttribute == "" {
+		sizeAttribute = DefaultPlanSizeAttribute
+	}
+
+	return &PlanMethodRestrictions{
+		client:        client,
+		attribute:     attribute,
+		sizeAttribute: sizeAttribute,
+		ttl:           ttl,
+		maxEntries:    DefaultPlanCacheEntriesMax,
+		cache:         make(map[planKey]*list.Element),
+		order:         list.New(),
+	}
+}
+
+// AllowedMethods implements PlanRestrictions
+func (p *PlanMethodRestrictions) AllowedMethods(systemURL, accessToken, serviceID string, params authorizer.BackendParams) ([]string, error) {
+	entry, err := p.restrictions(systemURL, accessToken, serviceID, params)
+	return entry.methods, err
+}
+
+// MaxRequestSize implements PlanRestrictions
+func (p *PlanMethodRestrictions) MaxRequestSize(systemURL, accessToken, serviceID string, params authorizer.BackendParams) (int64, error) {
+	entry, err := p.restrictions(systemURL, accessToken, serviceID, params)
+	return entry.maxSize, err
+}
+
+func (p *PlanMethodRestrictions) restrictions(systemURL, accessToken, serviceID string, params authorizer.BackendParams) (planEntry, error) {
+	key := planKey(sha256.Sum256([]byte(strings.Join([]string{systemURL, serviceID, params.UserKey, params.AppID}, "|"))))
+	if entry, ok := p.cached(key); ok {
+		return entry, entry.err
+	}
+
+	ttl := p.ttl
+	entry, err := p.fetchRestrictions(systemURL, accessToken, serviceID, params)
+	if err != nil {
+		entry = planEntry{err: err}
+		if ttl > planErrorTTL {
+			ttl = planErrorTTL
+		}
+	}
+	entry.key = key
+	entry.expires = time.Now().Add(ttl)
+	p.store(entry)
+
+	return entry, err
+}
+
+// cached returns the entry cached under key, provided it has not expired, marking it as the most recently used
+func (p *PlanMethodRestrictions) cached(key planKey) (planEntry, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	elem, ok := p.cache[key]
+	if !ok {
+		return planEntry{}, false
+	}
+
+	entry := elem.Value.(planEntry)
+	if !time.Now().Before(entry.expires) {
+		return planEntry{}, false
+	}
+
+	p.order.MoveToFront(elem)
+	return entry, true
+}
+
+// store caches entry, evicting the least recently used entries once more than maxEntries are held
+func (p *PlanMethodRestrictions) store(entry planEntry) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if elem, ok := p.cache[entry.key]; ok {
+		elem.Value = entry
+		p.order.MoveToFront(elem)
+		return
+	}
+
+	p.cache[entry.key] = p.order.PushFront(entry)
+	for p.order.Len() > p.maxEntries {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.cache, oldest.Value.(planEntry).key)
+	}
+}
+
+func (p *PlanMethodRestrictions) fetchRestrictions(systemURL, accessToken, serviceID string, params authorizer.BackendParams) (planEntry, error) {
+	var entry planEntry
+
+	query := url.Values{}
+	query.Set("access_token", accessToken)
+	query.Set("service_id", serviceID)
+	if params.UserKey != "" {
+		query.Set("user_key", params.UserKey)
+	} else {
+		query.Set("app_id", params.AppID)
+	}
+
+	app := &applicationResponse{}
+	if err := p.get(systemURL+findApplicationEndpoint, query, app); err != nil {
+		return entry, fmt.Errorf("error finding application - %s", err.Error())
+	}
+
+	query = url.Values{}
+	query.Set("access_token", accessToken)
+
+	plan := &applicationPlanResponse{}
+	planEndpoint := fmt.Sprintf(applicationPlanEndpoint, url.PathEscape(serviceID), app.Application.PlanID)
+	if err := p.get(systemURL+planEndpoint, query, plan); err != nil {
+		return entry, fmt.Errorf("error fetching application plan - %s", err.Error())
+	}
+
+	if value, ok := plan.ApplicationPlan[p.attribute].(string); ok {
+		for _, method := range strings.Split(value, ",") {
+			if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+				entry.methods = append(entry.methods, method)
+			}
+		}
+	}
+
+	// custom attributes are usually strings, but a number is accepted too. An invalid size is ignored rather than
+	// failing the lookup, so that the methods of the plan are still enforced.
+	switch value := plan.ApplicationPlan[p.sizeAttribute].(type) {
+	case string:
+		if value = strings.TrimSpace(value); value != "" {
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				log.Warnf("ignoring invalid value %q for attribute %s of application plan %d of service %s",
+					value, p.sizeAttribute, app.Application.PlanID, serviceID)
+				break
+			}
+			entry.maxSize = size
+		}
+	case float64:
+		if value < 0 {
+			log.Warnf("ignoring invalid value %v for attribute %s of application plan %d of service %s",
+				value, p.sizeAttribute, app.Application.PlanID, serviceID)
+			break
+		}
+		entry.maxSize = int64(value)
+	}
+	return entry, nil
+}
+
+func (p *PlanMethodRestrictions) get(endpoint string, query url.Values, into interface{}) error {
+	resp, err := p.client.Get(endpoint + "?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(into)
+}
+
+// checkPlanRestrictions returns an error, along with the status and reason to deny the request with, if the request
+// method or size is not permitted by the application plan. A size of zero is not checked, since it is unknown.
+// Failing to determine the restrictions is logged but does not deny the request, since it is still authorized by backend.
+func (s *Threescale) checkPlanRestrictions(cfg *config.Params, method string, size int64, request authorizer.BackendRequest) (statuses.Constructor, DecisionReason, error) {
+	for _, transaction := range request.Transactions {
+		allowed, err := s.conf.PlanRestrictions.AllowedMethods(cfg.SystemUrl, cfg.AccessToken, cfg.ServiceId, transaction.Params)
+		if err != nil {
+			s.logSampledWarning(fmt.Sprintf("unable to determine plan restrictions for service %s - %v", cfg.ServiceId, err))
+			continue
+		}
+
+		if !methodPermitted(method, allowed) {
+			return statuses.MethodNotPermitted, ReasonMethodNotPermitted,
+				fmt.Errorf("%s - %s", errMethodNotPermitted.Error(), strings.ToUpper(method))
+		}
+
+		if size <= 0 {
+			continue
+		}
+
+		maxSize, err := s.conf.PlanRestrictions.MaxRequestSize(cfg.SystemUrl, cfg.AccessToken, cfg.ServiceId, transaction.Params)
+		if err != nil {
+			s.logSampledWarning(fmt.Sprintf("unable to determine plan restrictions for service %s - %v", cfg.ServiceId, err))
+			continue
+		}
+
+		if maxSize > 0 && size > maxSize {
+			return statuses.RequestTooLarge, ReasonRequestTooLarge,
+				fmt.Errorf("%s - %d bytes exceeds the maximum of %d bytes", errRequestTooLarge.Error(), size, maxSize)
+		}
+	}
+	return nil, "", nil
+}
+
+// methodPermitted returns true if method is included in the allowed methods, or if no restriction applies
+func methodPermitted(method string, allowed []string) bool {
+	if allowed == nil {
+		return true
+	}
+
+	for _, m := range allowed {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
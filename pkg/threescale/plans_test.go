@@ -0,0 +1,267 @@
+package threescale
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestPlanMethodRestrictions(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("access_token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case findApplicationEndpoint:
+			planID := 1
+			switch r.URL.Query().Get("user_key") {
+			case "trial":
+				planID = 2
+			case "misconfigured":
+				planID = 3
+			}
+			fmt.Fprintf(w, `{"application":{"id":10,"plan_id":%d}}`, planID)
+		case fmt.Sprintf(applicationPlanEndpoint, "123", 1):
+			fmt.Fprint(w, `{"application_plan":{"id":1,"name":"unlimited"}}`)
+		case fmt.Sprintf(applicationPlanEndpoint, "123", 2):
+			fmt.Fprint(w, `{"application_plan":{"id":2,"name":"trial","allowed_methods":"get, head","max_request_size":"1024"}}`)
+		case fmt.Sprintf(applicationPlanEndpoint, "123", 3):
+			fmt.Fprint(w, `{"application_plan":{"id":3,"name":"misconfigured","allowed_methods":"GET","max_request_size":"1kb"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	restrictions := NewPlanMethodRestrictions(server.Client(), "", "", time.Minute)
+
+	methods, err := restrictions.AllowedMethods(server.URL, "token", "123", authorizer.BackendParams{UserKey: "trial"})
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if len(methods) != 2 || methods[0] != http.MethodGet || methods[1] != http.MethodHead {
+		t.Errorf("unexpected allowed methods %v", methods)
+	}
+
+	size, err := restrictions.MaxRequestSize(server.URL, "token", "123", authorizer.BackendParams{UserKey: "trial"})
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if size != 1024 {
+		t.Errorf("unexpected max request size %d", size)
+	}
+
+	if calls != 2 {
+		t.Errorf("expected restrictions to be cached but got %d calls to system", calls)
+	}
+
+	methods, err = restrictions.AllowedMethods(server.URL, "token", "123", authorizer.BackendParams{AppID: "unrestricted"})
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if methods != nil {
+		t.Errorf("expected no restrictions for plan without attribute but got %v", methods)
+	}
+
+	if size, _ = restrictions.MaxRequestSize(server.URL, "token", "123", authorizer.BackendParams{AppID: "unrestricted"}); size != 0 {
+		t.Errorf("expected no size restriction for plan without attribute but got %d", size)
+	}
+
+	misconfigured := authorizer.BackendParams{UserKey: "misconfigured"}
+	if methods, err = restrictions.AllowedMethods(server.URL, "token", "123", misconfigured); err != nil || len(methods) != 1 {
+		t.Errorf("expected methods to be restricted despite an invalid size but got %v - %v", methods, err)
+	}
+
+	if size, err = restrictions.MaxRequestSize(server.URL, "token", "123", misconfigured); err != nil || size != 0 {
+		t.Errorf("expected invalid size to be ignored but got %d - %v", size, err)
+	}
+
+	calls = 0
+	for i := 0; i < 2; i++ {
+		_, err = restrictions.AllowedMethods(server.URL, "invalid", "123", authorizer.BackendParams{AppID: "any"})
+		if err == nil {
+			t.Errorf("expected error when system rejects the request")
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected failure to be cached but got %d calls to system", calls)
+	}
+}
+
+func TestPlanMethodRestrictionsEviction(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case findApplicationEndpoint:
+			fmt.Fprint(w, `{"application":{"id":10,"plan_id":1}}`)
+		default:
+			fmt.Fprint(w, `{"application_plan":{"id":1,"allowed_methods":"GET"}}`)
+		}
+	}))
+	defer server.Close()
+
+	restrictions := NewPlanMethodRestrictions(server.Client(), "", "", time.Minute)
+	restrictions.maxEntries = 2
+
+	for _, key := range []string{"a", "b", "a", "c", "a"} {
+		if _, err := restrictions.AllowedMethods(server.URL, "token", "123", authorizer.BackendParams{UserKey: key}); err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+	}
+
+	if calls != 6 {
+		t.Errorf("expected the most recently used applications to be cached but got %d calls to system", calls)
+	}
+
+	if len(restrictions.cache) != 2 || restrictions.order.Len() != 2 {
+		t.Errorf("expected cache to hold 2 entries but got %d", len(restrictions.cache))
+	}
+
+	if _, err := restrictions.AllowedMethods(server.URL, "token", "123", authorizer.BackendParams{UserKey: "b"}); err != nil || calls != 8 {
+		t.Errorf("expected evicted application to be fetched again but got %d calls to system - %v", calls, err)
+	}
+}
+
+func TestHandleAuthorizationPlanRestrictions(t *testing.T) {
+	inputs := []struct {
+		name         string
+		method       string
+		size         int64
+		restrictions PlanRestrictions
+		expectStatus int32
+		expectReason DecisionReason
+	}{
+		{
+			name:         "Test read-only plan denies POST",
+			method:       http.MethodPost,
+			restrictions: mockPlanRestrictions{methods: []string{http.MethodGet}},
+			expectStatus: int32(rpc.PERMISSION_DENIED),
+			expectReason: ReasonMethodNotPermitted,
+		},
+		{
+			name:         "Test read-only plan allows GET",
+			method:       http.MethodGet,
+			restrictions: mockPlanRestrictions{methods: []string{http.MethodGet}},
+			expectStatus: int32(rpc.OK),
+		},
+		{
+			name:         "Test oversized request is denied",
+			method:       http.MethodPost,
+			size:         2048,
+			restrictions: mockPlanRestrictions{maxSize: 1024},
+			expectStatus: int32(rpc.PERMISSION_DENIED),
+			expectReason: ReasonRequestTooLarge,
+		},
+		{
+			name:         "Test request within the size limit is allowed",
+			method:       http.MethodPost,
+			size:         1024,
+			restrictions: mockPlanRestrictions{maxSize: 1024},
+			expectStatus: int32(rpc.OK),
+		},
+		{
+			name:         "Test request of unknown size is allowed",
+			method:       http.MethodPost,
+			restrictions: mockPlanRestrictions{maxSize: 1024},
+			expectStatus: int32(rpc.OK),
+		},
+		{
+			name:         "Test failure to fetch restrictions does not deny request",
+			method:       http.MethodPost,
+			restrictions: mockPlanRestrictions{err: fmt.Errorf("system unavailable")},
+			expectStatus: int32(rpc.OK),
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := config.Params{
+				ServiceId:   "123",
+				SystemUrl:   "https://www.fake-system.3scale.net",
+				AccessToken: "any",
+			}
+			b, _ := params.Marshal()
+
+			r := &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: input.method,
+						Path:   "/test",
+						Properties: map[string]*v1beta1.Value{
+							RequestSizeAttributeKey: {Value: &v1beta1.Value_Int64Value{Int64Value: input.size}},
+						},
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "secret",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			}
+
+			var reason DecisionReason
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								Proxy: client.ContentProxy{
+									ProxyRules: []client.ProxyRule{
+										{HTTPMethod: http.MethodGet, Pattern: "/test", MetricSystemName: "hits", Delta: 1},
+										{HTTPMethod: http.MethodPost, Pattern: "/test", MetricSystemName: "hits", Delta: 1},
+									},
+								},
+							},
+						},
+						withAuthResponse: &authorizer.BackendResponse{Authorized: true},
+					},
+					PlanRestrictions: input.restrictions,
+					MetricsReporter:  recordReason(nil, &reason),
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), r)
+			if result.Status.Code != input.expectStatus {
+				t.Errorf("expected %v got %v - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+
+			if input.expectStatus != int32(rpc.OK) && reason != input.expectReason {
+				t.Errorf("expected decision reason %s but got %s", input.expectReason, reason)
+			}
+		})
+	}
+}
+
+type mockPlanRestrictions struct {
+	methods []string
+	maxSize int64
+	err     error
+}
+
+func (m mockPlanRestrictions) AllowedMethods(systemURL, accessToken, serviceID string, params authorizer.BackendParams) ([]string, error) {
+	return m.methods, m.err
+}
+
+func (m mockPlanRestrictions) MaxRequestSize(systemURL, accessToken, serviceID string, params authorizer.BackendParams) (int64, error) {
+	return m.maxSize, m.err
+}
@@ -0,0 +1,43 @@
+package threescale
+
+import (
+	"context"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// Preconnector is called with each 3scale endpoint a request is about to use, so that DNS resolution and the TLS
+// handshake can be completed ahead of the first request to it. It must not block.
+type Preconnector func(endpoint string)
+
+// preconnect warms the connections to the endpoints named by the handler config. The backend connection is established
+// while the proxy config is fetched from system, so the first authorization for a handler does not pay for it.
+// A backend endpoint only known from the proxy config is used straight after it has been fetched, so is not preconnected.
+// Neither are the endpoints of handlers with a client of their own, which the shared connections would not be used for.
+func (s *Threescale) preconnect(ctx context.Context, cfg *config.Params, instance *authorization.InstanceMsg) {
+	if s.conf.Preconnector == nil {
+		return
+	}
+
+	if _, ok := handlerAuthorizer(ctx); ok {
+		return
+	}
+
+	s.conf.Preconnector(cfg.SystemUrl)
+
+	if backendURL := handlerBackendURL(cfg, instance); backendURL != "" {
+		s.conf.Preconnector(backendURL)
+	}
+}
+
+// handlerBackendURL returns the backend the handler config sends the request to, which is empty when the backend is
+// only known from the proxy config
+func handlerBackendURL(cfg *config.Params, instance *authorization.InstanceMsg) string {
+	locality := instance.Action.Properties[LocalityAttributeKey].GetStringValue()
+	if localityURL := backendForLocality(cfg.BackendUrlByLocality, locality); localityURL != "" {
+		return localityURL
+	}
+	return cfg.BackendUrl
+}
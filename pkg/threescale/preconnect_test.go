@@ -0,0 +1,67 @@
+package threescale
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestPreconnect(t *testing.T) {
+	inputs := []struct {
+		name     string
+		params   config.Params
+		locality string
+		expect   []string
+	}{
+		{
+			name:   "Test system and backend from the handler are preconnected",
+			params: config.Params{SystemUrl: "https://system.example.com", BackendUrl: "https://backend.example.com"},
+			expect: []string{"https://system.example.com", "https://backend.example.com"},
+		},
+		{
+			name: "Test backend for the locality is preconnected",
+			params: config.Params{
+				SystemUrl:            "https://system.example.com",
+				BackendUrl:           "https://backend.example.com",
+				BackendUrlByLocality: map[string]string{"us-east-1": "https://east.backend.example.com"},
+			},
+			locality: "us-east-1/us-east-1a",
+			expect:   []string{"https://system.example.com", "https://east.backend.example.com"},
+		},
+		{
+			name:   "Test backend from the proxy config is not preconnected",
+			params: config.Params{SystemUrl: "https://system.example.com"},
+			expect: []string{"https://system.example.com"},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var endpoints []string
+			s := &Threescale{
+				conf: &AdapterConfig{
+					Preconnector: func(endpoint string) {
+						endpoints = append(endpoints, endpoint)
+					},
+				},
+			}
+
+			s.preconnect(context.Background(), &input.params, &authorization.InstanceMsg{
+				Action: &authorization.ActionMsg{
+					Properties: map[string]*v1beta1.Value{
+						LocalityAttributeKey: {Value: &v1beta1.Value_StringValue{StringValue: input.locality}},
+					},
+				},
+			})
+
+			if !reflect.DeepEqual(endpoints, input.expect) {
+				t.Errorf("unexpected endpoints preconnected, wanted %v but got %v", input.expect, endpoints)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package threescale
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	"github.com/3scale/3scale-go-client/threescale/api"
+	system "github.com/3scale/3scale-porta-go-client/client"
+)
+
+// prefixMatcher is the RuleMatcher of PrefixRuleMatcher. As by 3scale, patterns are anchored at the start of the path.
+// Literal patterns, optionally ending with $ to match the path exactly, are held in a trie for each method, which is
+// walked along the path so that the cost of a match depends on its length rather than the number of rules. Rules
+// using any other syntax are evaluated as anchored regular expressions.
+type prefixMatcher struct {
+	tries   map[string]*prefixNode
+	regexps map[string][]prefixRule
+}
+
+type prefixNode struct {
+	children map[byte]*prefixNode
+	// rules whose literal pattern ends at this node
+	rules []prefixRule
+}
+
+type prefixRule struct {
+	// order is the index of the rule once sorted by position, which breaks ties between equal positions
+	order   int
+	exact   bool
+	pattern *regexp.Regexp
+	metric  string
+	delta   int
+	last    bool
+}
+
+// newPrefixMatcher is the RuleMatcherEngine of PrefixRuleMatcher. Rules with a pattern which is rejected by
+// compilePattern are skipped as they can never match.
+func newPrefixMatcher(configID int, proxyRules []system.ProxyRule) RuleMatcher {
+	m := &prefixMatcher{
+		tries:   make(map[string]*prefixNode),
+		regexps: make(map[string][]prefixRule),
+	}
+
+	for i, pr := range proxyRules {
+		method := strings.ToUpper(pr.HTTPMethod)
+		rule := prefixRule{order: i, metric: pr.MetricSystemName, delta: int(pr.Delta), last: pr.Last}
+
+		if literal, exact, ok := literalPattern(pr.Pattern); ok {
+			rule.exact = exact
+			m.insert(method, literal, rule)
+			continue
+		}
+
+		pattern, err := compilePattern("^(?:" + pr.Pattern + ")")
+		if err != nil {
+			logSkippedRule(configID, pr, err)
+			continue
+		}
+		rule.pattern = pattern
+		m.regexps[method] = append(m.regexps[method], rule)
+	}
+	return m
+}
+
+func (m *prefixMatcher) insert(method string, literal string, rule prefixRule) {
+	node, ok := m.tries[method]
+	if !ok {
+		node = &prefixNode{}
+		m.tries[method] = node
+	}
+
+	for i := 0; i < len(literal); i++ {
+		child, ok := node.children[literal[i]]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[byte]*prefixNode)
+			}
+			child = &prefixNode{}
+			node.children[literal[i]] = child
+		}
+		node = child
+	}
+	node.rules = append(node.rules, rule)
+}
+
+// Metrics implements RuleMatcher
+func (m *prefixMatcher) Metrics(path string, method string) api.Metrics {
+	method = strings.ToUpper(method)
+
+	var matched []prefixRule
+	node := m.tries[method]
+	for i := 0; node != nil; i++ {
+		for _, rule := range node.rules {
+			if !rule.exact || i == len(path) {
+				matched = append(matched, rule)
+			}
+		}
+
+		if i == len(path) {
+			break
+		}
+		node = node.children[path[i]]
+	}
+
+	for _, rule := range m.regexps[method] {
+		if rule.pattern.MatchString(path) {
+			matched = append(matched, rule)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].order < matched[j].order
+	})
+
+	metrics := make(api.Metrics)
+	for _, rule := range matched {
+		metrics.Add(rule.metric, rule.delta)
+		// stop matching if this rule has been marked as Last
+		if rule.last {
+			break
+		}
+	}
+	return metrics
+}
+
+// literalPattern returns the literal a pattern matches from the start of the path, and whether it must match the
+// whole path, or false when the pattern uses syntax other than literals and anchors
+func literalPattern(pattern string) (string, bool, bool) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", false, false
+	}
+
+	var subs []*syntax.Regexp
+	switch re.Op {
+	case syntax.OpConcat:
+		subs = re.Sub
+	case syntax.OpEmptyMatch:
+	default:
+		subs = []*syntax.Regexp{re}
+	}
+
+	if len(subs) > 0 && subs[0].Op == syntax.OpBeginText {
+		subs = subs[1:]
+	}
+
+	var exact bool
+	if n := len(subs); n > 0 && subs[n-1].Op == syntax.OpEndText {
+		exact = true
+		subs = subs[:n-1]
+	}
+
+	var literal strings.Builder
+	for _, sub := range subs {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			return "", false, false
+		}
+		literal.WriteString(string(sub.Rune))
+	}
+	return literal.String(), exact, true
+}
@@ -0,0 +1,67 @@
+package threescale
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+func TestPrefixMatcherMetrics(t *testing.T) {
+	matcher := newPrefixMatcher(1, []client.ProxyRule{
+		{HTTPMethod: http.MethodGet, Pattern: "/", Position: 1, MetricSystemName: "hits", Delta: 1},
+		{HTTPMethod: http.MethodGet, Pattern: "/orders/new$", Position: 2, MetricSystemName: "new", Delta: 1, Last: true},
+		{HTTPMethod: http.MethodGet, Pattern: `^/orders/[0-9]+\.json$`, Position: 3, MetricSystemName: "order", Delta: 2},
+		{HTTPMethod: http.MethodGet, Pattern: "/orders", Position: 4, MetricSystemName: "orders", Delta: 1},
+		{HTTPMethod: http.MethodPost, Pattern: "/orders$", Position: 5, MetricSystemName: "create", Delta: 1},
+		{HTTPMethod: http.MethodGet, Pattern: "/invalid/(", Position: 6, MetricSystemName: "invalid", Delta: 1},
+	})
+
+	inputs := []struct {
+		path   string
+		method string
+		expect map[string]int
+	}{
+		{path: "/orders", method: http.MethodGet, expect: map[string]int{"hits": 1, "orders": 1}},
+		{path: "/orders/new", method: "get", expect: map[string]int{"hits": 1, "new": 1}},
+		{path: "/orders/new/items", method: http.MethodGet, expect: map[string]int{"hits": 1, "orders": 1}},
+		{path: "/orders/12.json", method: http.MethodGet, expect: map[string]int{"hits": 1, "order": 2, "orders": 1}},
+		{path: "/v1/orders", method: http.MethodGet, expect: map[string]int{"hits": 1}},
+		{path: "/orders", method: http.MethodPost, expect: map[string]int{"create": 1}},
+		{path: "/orders/12", method: http.MethodPost, expect: map[string]int{}},
+		{path: "/invalid/(", method: http.MethodGet, expect: map[string]int{"hits": 1}},
+	}
+
+	for _, input := range inputs {
+		metrics := matcher.Metrics(input.path, input.method)
+		if !reflect.DeepEqual(map[string]int(metrics), input.expect) {
+			t.Errorf("unexpected metrics for %s %s, wanted %v but got %v", input.method, input.path, input.expect, metrics)
+		}
+	}
+}
+
+func TestLiteralPattern(t *testing.T) {
+	inputs := []struct {
+		pattern       string
+		expectLiteral string
+		expectExact   bool
+		expectOK      bool
+	}{
+		{pattern: "/orders", expectLiteral: "/orders", expectOK: true},
+		{pattern: "^/orders/new$", expectLiteral: "/orders/new", expectExact: true, expectOK: true},
+		{pattern: `/orders\.json`, expectLiteral: "/orders.json", expectOK: true},
+		{pattern: "", expectOK: true},
+		{pattern: "/orders.json"},
+		{pattern: "/orders/[0-9]+"},
+		{pattern: "(?i)/orders"},
+		{pattern: "/invalid/("},
+	}
+
+	for _, input := range inputs {
+		literal, exact, ok := literalPattern(input.pattern)
+		if literal != input.expectLiteral || exact != input.expectExact || ok != input.expectOK {
+			t.Errorf("unexpected result for %q - got %q, %t, %t", input.pattern, literal, exact, ok)
+		}
+	}
+}
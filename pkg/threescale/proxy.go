@@ -0,0 +1,128 @@
+package threescale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/httpclient"
+)
+
+// HandlerClient holds the params of a handler which change how requests to 3scale are sent. Handlers which set any of
+// them are authorized via an Authorizer of their own, so that their settings never apply to the requests of other
+// handlers, even those sharing the same 3scale endpoints.
+type HandlerClient struct {
+	// SystemURL is the 3scale system the handler fetches its proxy config from
+	SystemURL string
+	// SystemProxyURL is the proxy requests to 3scale system are sent through, if any
+	SystemProxyURL string
+	// BackendProxyURL is the proxy requests to 3scale backend are sent through, if any
+	BackendProxyURL string
+	// InsecureSkipVerify stops verifying the TLS certificates of 3scale system and backend
+	InsecureSkipVerify bool
+}
+
+// HandlerAuthorizerBuilder builds the Authorizer used by the handlers with the client. It is called once for each
+// distinct client, and the Authorizer built is kept until the adapter is closed.
+type HandlerAuthorizerBuilder func(client HandlerClient) (Authorizer, error)
+
+var errNoHandlerAuthorizer = errors.New("system_proxy_url, backend_proxy_url and insecure_skip_verify are not supported by this adapter")
+
+type handlerAuthorizerKey struct{}
+
+// proxyURLErrors returns a message for each proxy URL of the handler which is invalid
+func proxyURLErrors(cfg *config.Params) []string {
+	var errMsgs []string
+	for _, proxy := range []struct {
+		name string
+		url  string
+	}{
+		{"system_proxy_url", cfg.SystemProxyUrl},
+		{"backend_proxy_url", cfg.BackendProxyUrl},
+	} {
+		if proxy.url == "" {
+			continue
+		}
+
+		if _, err := httpclient.ParseProxyURL(proxy.url); err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("%s is invalid - %v", proxy.name, err))
+		}
+	}
+	return errMsgs
+}
+
+// handlerClient returns the client of the handler, reporting false when the handler uses the shared Authorizer
+func handlerClient(cfg *config.Params) (HandlerClient, bool) {
+	client := HandlerClient{
+		SystemURL:          cfg.SystemUrl,
+		SystemProxyURL:     cfg.SystemProxyUrl,
+		BackendProxyURL:    cfg.BackendProxyUrl,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+	return client, client != HandlerClient{SystemURL: cfg.SystemUrl}
+}
+
+// handlerAuthorizers holds the Authorizer built for each distinct HandlerClient. The zero value is ready to use.
+type handlerAuthorizers struct {
+	mutex       sync.Mutex
+	authorizers map[HandlerClient]Authorizer
+}
+
+// get returns the Authorizer for the client, building it with build on first use
+func (h *handlerAuthorizers) get(client HandlerClient, build HandlerAuthorizerBuilder) (Authorizer, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if auth, ok := h.authorizers[client]; ok {
+		return auth, nil
+	}
+
+	if build == nil {
+		return nil, errNoHandlerAuthorizer
+	}
+
+	auth, err := build(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.authorizers == nil {
+		h.authorizers = make(map[HandlerClient]Authorizer)
+	}
+	h.authorizers[client] = auth
+	return auth, nil
+}
+
+// shutdown shuts down each Authorizer built, so that any usage they hold is flushed to 3scale backend
+func (h *handlerAuthorizers) shutdown() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for client, auth := range h.authorizers {
+		auth.Shutdown()
+		delete(h.authorizers, client)
+	}
+}
+
+// withHandlerAuthorizer returns ctx carrying the Authorizer of the handler's own client, when the handler requires one,
+// so that requests to 3scale made for it are sent as the handler configures
+func (s *Threescale) withHandlerAuthorizer(ctx context.Context, cfg *config.Params) (context.Context, error) {
+	client, ok := handlerClient(cfg)
+	if !ok {
+		return ctx, nil
+	}
+
+	auth, err := s.handlerAuthorizers.get(client, s.conf.HandlerAuthorizers)
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, handlerAuthorizerKey{}, auth), nil
+}
+
+// handlerAuthorizer returns the Authorizer of the handler's own client for the request of ctx, if any
+func handlerAuthorizer(ctx context.Context) (Authorizer, bool) {
+	auth, ok := ctx.Value(handlerAuthorizerKey{}).(Authorizer)
+	return auth, ok
+}
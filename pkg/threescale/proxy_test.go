@@ -0,0 +1,133 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestProxyURLErrors(t *testing.T) {
+	errMsgs := proxyURLErrors(&config.Params{
+		SystemProxyUrl:  "http://proxy.example.com:3128",
+		BackendProxyUrl: "proxy.example.com",
+	})
+
+	if len(errMsgs) != 1 {
+		t.Fatalf("expected a single error but got %v", errMsgs)
+	}
+
+	if !strings.HasPrefix(errMsgs[0], "backend_proxy_url is invalid") {
+		t.Errorf("unexpected error %q", errMsgs[0])
+	}
+}
+
+func TestHandleAuthorizationHandlerAuthorizers(t *testing.T) {
+	proxyConf := client.ProxyConfig{
+		Content: client.Content{
+			Proxy: client.ContentProxy{
+				Backend: client.Backend{Endpoint: internalBackend},
+				ProxyRules: []client.ProxyRule{
+					{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+				},
+			},
+		},
+	}
+
+	var built []HandlerClient
+	var sharedCalls, handlerCalls int
+	c := &Threescale{
+		conf: &AdapterConfig{
+			Authorizer: mockAuthorizer{
+				withConfig:       proxyConf,
+				withAuthResponse: &authorizer.BackendResponse{},
+				withAuthRepCallback: func(string, authorizer.BackendRequest, *testing.T) {
+					sharedCalls++
+				},
+				t: t,
+			},
+			HandlerAuthorizers: func(client HandlerClient) (Authorizer, error) {
+				built = append(built, client)
+				return mockAuthorizer{
+					withConfig:       proxyConf,
+					withAuthResponse: &authorizer.BackendResponse{},
+					withAuthRepCallback: func(string, authorizer.BackendRequest, *testing.T) {
+						handlerCalls++
+					},
+					t: t,
+				}, nil
+			},
+		},
+	}
+
+	authorize := func(params config.Params) *v1beta1.CheckResult {
+		b, _ := params.Marshal()
+		result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+			Instance: &authorization.InstanceMsg{
+				Action: &authorization.ActionMsg{
+					Method: http.MethodGet,
+					Path:   "/test",
+				},
+				Subject: &authorization.SubjectMsg{
+					User: "VALID",
+				},
+			},
+			AdapterConfig: &types.Any{Value: b},
+		})
+		return result
+	}
+
+	proxied := config.Params{
+		ServiceId:       "123",
+		SystemUrl:       "https://www.fake-system.3scale.net",
+		AccessToken:     "any",
+		SystemProxyUrl:  "http://system-proxy.example.com:3128",
+		BackendProxyUrl: "http://backend-proxy.example.com:3128",
+	}
+	authorize(proxied)
+	authorize(proxied)
+
+	expect := []HandlerClient{{
+		SystemURL:       "https://www.fake-system.3scale.net",
+		SystemProxyURL:  "http://system-proxy.example.com:3128",
+		BackendProxyURL: "http://backend-proxy.example.com:3128",
+	}}
+	if !reflect.DeepEqual(built, expect) {
+		t.Errorf("expected a single client to be built as %v but got %v", expect, built)
+	}
+
+	if handlerCalls != 2 || sharedCalls != 0 {
+		t.Errorf("expected requests of the handler to use its own client but got %d and %d shared", handlerCalls, sharedCalls)
+	}
+
+	direct := proxied
+	direct.SystemProxyUrl, direct.BackendProxyUrl = "", ""
+	authorize(direct)
+	if len(built) != 1 || sharedCalls != 1 {
+		t.Errorf("expected handlers without proxies to use the shared client")
+	}
+
+	insecure := direct
+	insecure.InsecureSkipVerify = true
+	authorize(insecure)
+	if len(built) != 2 || !built[1].InsecureSkipVerify || built[1].SystemProxyURL != "" || sharedCalls != 1 {
+		t.Errorf("expected handlers skipping TLS verification to have a client of their own but got %v", built)
+	}
+
+	c.conf.HandlerAuthorizers = nil
+	proxied.BackendProxyUrl = "http://another-proxy.example.com:3128"
+	result := authorize(proxied)
+	if result.Status.Code != int32(rpc.FAILED_PRECONDITION) {
+		t.Errorf("expected handlers with proxies to be rejected without a builder but got %v", result.Status)
+	}
+}
@@ -0,0 +1,184 @@
+package threescale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/ratelimit"
+	rls "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v2"
+	"github.com/ghodss/yaml"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"istio.io/istio/pkg/log"
+)
+
+// Descriptor entry keys read by the rate limit service. Envoy rate limit actions, such as request_headers, should
+// produce entries with these keys.
+const (
+	// RateLimitUserKey is the descriptor entry holding the user key of the application
+	RateLimitUserKey = "user_key"
+	// RateLimitAppID is the descriptor entry holding the application ID
+	RateLimitAppID = "app_id"
+	// RateLimitAppKey is the descriptor entry holding the optional application key
+	RateLimitAppKey = "app_key"
+	// RateLimitMetric is the descriptor entry naming the 3scale metric to increment, which defaults to hits
+	RateLimitMetric = "metric"
+
+	defaultRateLimitMetric = "hits"
+)
+
+var errNoRateLimitDescriptors = errors.New("no descriptors provided")
+
+// RateLimitConfig maps the domains of Envoy rate limit requests to the 3scale services they are limited by
+type RateLimitConfig struct {
+	Domains map[string]RateLimitDomain `json:"domains"`
+}
+
+// RateLimitDomain configures the 3scale service which limits the requests of a domain
+type RateLimitDomain struct {
+	SystemURL   string `json:"system_url"`
+	AccessToken string `json:"access_token"`
+	ServiceID   string `json:"service_id"`
+	// BackendURL is optional and overrides the 3scale backend taken from the service's proxy config
+	BackendURL string `json:"backend_url,omitempty"`
+}
+
+// ParseRateLimitConfig parses a YAML or JSON rate limit config, returning an error if it is invalid
+func ParseRateLimitConfig(data []byte) (*RateLimitConfig, error) {
+	conf := &RateLimitConfig{}
+	if err := yaml.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("unable to parse rate limit config - %v", err)
+	}
+	return conf, conf.Validate()
+}
+
+// Validate returns an error if any domain is missing the settings required to reach 3scale
+func (c *RateLimitConfig) Validate() error {
+	for name, domain := range c.Domains {
+		var errMsgs []string
+		if domain.AccessToken == "" {
+			errMsgs = append(errMsgs, errAccessToken.Error())
+		}
+
+		if domain.SystemURL == "" {
+			errMsgs = append(errMsgs, errSystemURL.Error())
+		}
+
+		if domain.ServiceID == "" {
+			errMsgs = append(errMsgs, errServiceID.Error())
+		}
+
+		if err := joinErrors(errMsgs); err != nil {
+			return fmt.Errorf("invalid rate limit domain %q - %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (d RateLimitDomain) params() config.Params {
+	return config.Params{
+		SystemUrl:   d.SystemURL,
+		AccessToken: d.AccessToken,
+		ServiceId:   d.ServiceID,
+		BackendUrl:  d.BackendURL,
+	}
+}
+
+// Implement Envoy's rate limit service, so that 3scale limits can be enforced by the Envoy rate limit filter
+var _ rls.RateLimitServiceServer = &Threescale{}
+
+// ShouldRateLimit implements RateLimitServiceServer. Each descriptor is authorized and reported to 3scale as a
+// separate request, incrementing its metric by the hits addend, and is over limit if 3scale denies it for any reason.
+// Errors reaching 3scale are returned as UNAVAILABLE, so that Envoy applies the failure mode of its filter.
+func (s *Threescale) ShouldRateLimit(ctx context.Context, r *rls.RateLimitRequest) (*rls.RateLimitResponse, error) {
+	if s.conf.RateLimit == nil {
+		return nil, status.Error(codes.Unimplemented, "rate limit service is not configured")
+	}
+
+	domain, ok := s.conf.RateLimit.Domains[r.Domain]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown rate limit domain %q", r.Domain)
+	}
+
+	if len(r.Descriptors) == 0 {
+		return nil, status.Error(codes.InvalidArgument, errNoRateLimitDescriptors.Error())
+	}
+
+	cfg := domain.params()
+	proxyConf, _, err := s.getSystemConfiguration(ctx, &cfg)
+	if err != nil {
+		_, err = s.rpcStatusErrorHandler("error fetching config from 3scale", systemErrorToRpcStatus(err), err)
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	if cfg.BackendUrl == "" {
+		cfg.BackendUrl = proxyConf.Content.Proxy.Backend.Endpoint
+	}
+
+	hits := int(r.HitsAddend)
+	if hits == 0 {
+		hits = 1
+	}
+
+	response := &rls.RateLimitResponse{OverallCode: rls.RateLimitResponse_OK}
+	for _, descriptor := range r.Descriptors {
+		backendReq := rateLimitBackendRequest(descriptor, hits)
+		backendReq.Auth = backendAuth(proxyConf, cfg)
+		backendReq.Service = cfg.ServiceId
+
+		code := rls.RateLimitResponse_OVER_LIMIT
+		if _, err := s.validateBackendRequest(backendReq); err == nil {
+			resp, err := s.conf.Authorizer.AuthRep(cfg.BackendUrl, backendReq)
+			if err != nil {
+				_, err = s.rpcStatusErrorHandler("request authorization failed", backendResponseToRpcStatus(resp), err)
+				return nil, status.Error(codes.Unavailable, err.Error())
+			}
+
+			if resp.Authorized {
+				code = rls.RateLimitResponse_OK
+			} else {
+				log.Debugf("rate limit descriptor denied by 3scale for service %s - %s", cfg.ServiceId, resp.ErrorCode)
+			}
+		}
+
+		if code == rls.RateLimitResponse_OVER_LIMIT {
+			response.OverallCode = code
+		}
+		response.Statuses = append(response.Statuses, &rls.RateLimitResponse_DescriptorStatus{Code: code})
+	}
+	return response, nil
+}
+
+// rateLimitBackendRequest returns the request to 3scale backend for a descriptor, without service or auth
+func rateLimitBackendRequest(descriptor *ratelimit.RateLimitDescriptor, hits int) authorizer.BackendRequest {
+	var params authorizer.BackendParams
+	metric := defaultRateLimitMetric
+
+	for _, entry := range descriptor.GetEntries() {
+		switch entry.Key {
+		case RateLimitUserKey:
+			params.UserKey = entry.Value
+		case RateLimitAppID:
+			params.AppID = entry.Value
+		case RateLimitAppKey:
+			params.AppKey = entry.Value
+		case RateLimitMetric:
+			metric = entry.Value
+		}
+	}
+
+	return authorizer.BackendRequest{
+		Transactions: []authorizer.BackendTransaction{
+			{
+				Metrics: api.Metrics{metric: hits},
+				Params:  params,
+			},
+		},
+	}
+}
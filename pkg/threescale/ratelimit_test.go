@@ -0,0 +1,167 @@
+package threescale
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/envoyproxy/go-control-plane/envoy/api/v2/ratelimit"
+	rls "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v2"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseRateLimitConfig(t *testing.T) {
+	inputs := []struct {
+		name      string
+		data      string
+		expectErr bool
+	}{
+		{
+			name: "Test valid config",
+			data: "domains:\n  petstore:\n    system_url: https://system\n    access_token: any\n    service_id: \"123\"\n",
+		},
+		{
+			name:      "Test domain without service",
+			data:      "domains:\n  petstore:\n    system_url: https://system\n    access_token: any\n",
+			expectErr: true,
+		},
+		{
+			name:      "Test invalid YAML",
+			data:      "domains: [",
+			expectErr: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			_, err := ParseRateLimitConfig([]byte(input.data))
+			if (err != nil) != input.expectErr {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestShouldRateLimit(t *testing.T) {
+	descriptor := func(entries ...string) *ratelimit.RateLimitDescriptor {
+		d := &ratelimit.RateLimitDescriptor{}
+		for i := 0; i < len(entries); i += 2 {
+			d.Entries = append(d.Entries, &ratelimit.RateLimitDescriptor_Entry{Key: entries[i], Value: entries[i+1]})
+		}
+		return d
+	}
+
+	inputs := []struct {
+		name          string
+		request       *rls.RateLimitRequest
+		backendErr    error
+		expectCode    codes.Code
+		expectOverall rls.RateLimitResponse_Code
+		expectMetrics map[string]int
+	}{
+		{
+			name: "Test authorized descriptor is OK",
+			request: &rls.RateLimitRequest{
+				Domain:      "petstore",
+				Descriptors: []*ratelimit.RateLimitDescriptor{descriptor(RateLimitUserKey, "VALID")},
+			},
+			expectOverall: rls.RateLimitResponse_OK,
+			expectMetrics: map[string]int{"hits": 1},
+		},
+		{
+			name: "Test metric and hits addend are reported",
+			request: &rls.RateLimitRequest{
+				Domain:      "petstore",
+				Descriptors: []*ratelimit.RateLimitDescriptor{descriptor(RateLimitAppID, "VALID", RateLimitMetric, "orders")},
+				HitsAddend:  3,
+			},
+			expectOverall: rls.RateLimitResponse_OK,
+			expectMetrics: map[string]int{"orders": 3},
+		},
+		{
+			name: "Test denied descriptor is over limit",
+			request: &rls.RateLimitRequest{
+				Domain: "petstore",
+				Descriptors: []*ratelimit.RateLimitDescriptor{
+					descriptor(RateLimitUserKey, "INVALID"),
+					descriptor(RateLimitUserKey, "VALID"),
+				},
+			},
+			expectOverall: rls.RateLimitResponse_OVER_LIMIT,
+		},
+		{
+			name: "Test descriptor without credentials is over limit",
+			request: &rls.RateLimitRequest{
+				Domain:      "petstore",
+				Descriptors: []*ratelimit.RateLimitDescriptor{descriptor("remote_address", "10.0.0.1")},
+			},
+			expectOverall: rls.RateLimitResponse_OVER_LIMIT,
+		},
+		{
+			name:       "Test unknown domain",
+			request:    &rls.RateLimitRequest{Domain: "unknown"},
+			expectCode: codes.NotFound,
+		},
+		{
+			name: "Test backend errors are unavailable",
+			request: &rls.RateLimitRequest{
+				Domain:      "petstore",
+				Descriptors: []*ratelimit.RateLimitDescriptor{descriptor(RateLimitUserKey, "VALID")},
+			},
+			backendErr: errors.New("unreachable"),
+			expectCode: codes.Unavailable,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			s := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								Proxy: client.ContentProxy{Backend: client.Backend{Endpoint: internalBackend}},
+							},
+						},
+						withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+							if request.Service != "123" {
+								t.Errorf("expected request for service 123 but got %s", request.Service)
+							}
+
+							for metric, value := range input.expectMetrics {
+								if request.Transactions[0].Metrics[metric] != value {
+									t.Errorf("expected %s to be reported as %d but got %v", metric, value, request.Transactions[0].Metrics)
+								}
+							}
+						},
+						withAuthResponse: &authorizer.BackendResponse{},
+						withBackendErr:   input.backendErr,
+						t:                t,
+					},
+					RateLimit: &RateLimitConfig{
+						Domains: map[string]RateLimitDomain{
+							"petstore": {SystemURL: "https://system", AccessToken: "any", ServiceID: "123"},
+						},
+					},
+				},
+			}
+
+			resp, err := s.ShouldRateLimit(context.TODO(), input.request)
+			if status.Code(err) != input.expectCode {
+				t.Fatalf("expected code %s but got %v", input.expectCode, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if resp.OverallCode != input.expectOverall || len(resp.Statuses) != len(input.request.Descriptors) {
+				t.Errorf("expected %s for %d descriptors but got %+v", input.expectOverall, len(input.request.Descriptors), resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package threescale
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/3scale/3scale-go-client/threescale/api"
+	system "github.com/3scale/3scale-porta-go-client/client"
+
+	"istio.io/istio/pkg/log"
+)
+
+// maxPatternInstructions bounds the size of a compiled mapping rule pattern. Patterns are matched by Go's RE2 based
+// regexp package, which does not backtrack, so matching runs in time linear in the length of the path and the size of
+// the pattern. Bounding the pattern therefore bounds the cost of each match, which otherwise a pattern with large
+// repetitions, such as "[a-z]{1000}" repeated, could inflate for every request to the service.
+const maxPatternInstructions = 5000
+
+const (
+	// RegexRuleMatcher matches mapping rule patterns as regular expressions, evaluating each rule in position order
+	RegexRuleMatcher = "regex"
+	// PrefixRuleMatcher matches mapping rule patterns anchored at the start of the path, finding literal patterns
+	// via a trie
+	PrefixRuleMatcher = "prefix"
+)
+
+// RuleMatcher matches requests against the mapping rules it was built from
+type RuleMatcher interface {
+	// Metrics returns the metrics to report for a request with the path and method
+	Metrics(path string, method string) api.Metrics
+}
+
+// RuleMatcherEngine builds a RuleMatcher from the mapping rules of the proxy config identified by configID, sorted by
+// position. Rules which can never match may be skipped.
+type RuleMatcherEngine func(configID int, rules []system.ProxyRule) RuleMatcher
+
+// ruleMatcherEngines are the engines which handlers can select with mapping_rule_matcher
+var ruleMatcherEngines = map[string]RuleMatcherEngine{
+	RegexRuleMatcher:  newRegexMatcher,
+	PrefixRuleMatcher: newPrefixMatcher,
+}
+
+// ruleMatcherEngine returns the named engine, which defaults to RegexRuleMatcher. Engines set in the AdapterConfig take
+// precedence over those built in.
+func (s *Threescale) ruleMatcherEngine(name string) (RuleMatcherEngine, error) {
+	if name == "" {
+		name = RegexRuleMatcher
+	}
+
+	if engine, ok := s.conf.RuleMatcherEngines[name]; ok {
+		return engine, nil
+	}
+
+	if engine, ok := ruleMatcherEngines[name]; ok {
+		return engine, nil
+	}
+
+	var names []string
+	for name := range ruleMatcherEngines {
+		names = append(names, name)
+	}
+	for name := range s.conf.RuleMatcherEngines {
+		if _, ok := ruleMatcherEngines[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("mapping_rule_matcher must be one of %s", strings.Join(names, ", "))
+}
+
+// mappingRules is a compact representation of the mapping rules in a proxy config.
+// Rules are sorted by position and built into a RuleMatcher once per config version,
+// rather than compiled once per rule on every request, which is costly for services with thousands of rules.
+type mappingRules struct {
+	// id and version identify the proxy config the rules were compiled from
+	id      int
+	version int
+	// fingerprint identifies the exact ruleset, see configFingerprint
+	fingerprint string
+	matcher     RuleMatcher
+}
+
+// regexMatcher is the default RuleMatcher, evaluating the pattern of each rule for the method of the request in order.
+// Rules are indexed by method, so that rules for other methods are not scanned.
+type regexMatcher struct {
+	rules map[string][]mappingRule
+}
+
+type mappingRule struct {
+	position int
+	pattern  *regexp.Regexp
+	metric   string
+	delta    int
+	last     bool
+}
+
+// ruleEvaluation records the outcome of evaluating a single mapping rule against a request
+type ruleEvaluation struct {
+	position int
+	pattern  string
+	matched  bool
+	elapsed  time.Duration
+}
+
+// ConfigVersion identifies the proxy config whose mapping rules evaluate the requests of a service
+type ConfigVersion struct {
+	SystemURL string `json:"system_url"`
+	ServiceID string `json:"service_id"`
+	// Version is the fingerprint of the proxy config, made up of its version and a hash of its mapping rules
+	Version string `json:"version"`
+}
+
+// ruleKey identifies the compiled mapping rules of a service built by an engine
+type ruleKey struct {
+	systemURL string
+	serviceID string
+	engine    string
+}
+
+// ruleCache holds the compiled mapping rules for each service and engine. The zero value is ready to use.
+type ruleCache struct {
+	mutex    sync.RWMutex
+	services map[ruleKey]*mappingRules
+}
+
+// get returns the rules for the proxy config built by the named engine, compiling them if the config has changed since
+// last seen. The mapping rules of the config are taken from proxyRules, which is only called when they need to be
+// compiled. The fingerprint of each new ruleset is logged, so that the ruleset which evaluated a request can be
+// identified from the time it was made.
+func (c *ruleCache) get(systemURL, serviceID string, engineName string, engine RuleMatcherEngine, conf system.ProxyConfig, proxyRules proxyRulesFunc) (*mappingRules, error) {
+	key := ruleKey{systemURL: systemURL, serviceID: serviceID, engine: engineName}
+
+	c.mutex.RLock()
+	previous, ok := c.services[key]
+	c.mutex.RUnlock()
+	if ok && previous.id == conf.ID && previous.version == conf.Version {
+		return previous, nil
+	}
+
+	var err error
+	conf.Content.Proxy.ProxyRules, err = proxyRules()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := compileRules(conf, engine)
+	if !ok || previous.fingerprint != rules.fingerprint {
+		log.Infof("mapping rules of service %s from %s are now evaluated using config version %s",
+			serviceID, systemURL, rules.fingerprint)
+	}
+
+	c.mutex.Lock()
+	if c.services == nil {
+		c.services = make(map[ruleKey]*mappingRules)
+	}
+	c.services[key] = rules
+	c.mutex.Unlock()
+
+	return rules, nil
+}
+
+// versions returns the version of the rules held for each service, sorted by system URL and service
+func (c *ruleCache) versions() []ConfigVersion {
+	c.mutex.RLock()
+	seen := make(map[ConfigVersion]bool, len(c.services))
+	versions := make([]ConfigVersion, 0, len(c.services))
+	for key, rules := range c.services {
+		version := ConfigVersion{SystemURL: key.systemURL, ServiceID: key.serviceID, Version: rules.fingerprint}
+		if !seen[version] {
+			seen[version] = true
+			versions = append(versions, version)
+		}
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(versions, func(i, j int) bool {
+		if versions[i].SystemURL != versions[j].SystemURL {
+			return versions[i].SystemURL < versions[j].SystemURL
+		}
+		if versions[i].ServiceID != versions[j].ServiceID {
+			return versions[i].ServiceID < versions[j].ServiceID
+		}
+		return versions[i].Version < versions[j].Version
+	})
+	return versions
+}
+
+// ConfigVersions returns the version of the proxy config whose mapping rules currently evaluate the requests of each
+// service, so that the ruleset in use can be confirmed without the version being sent with every decision
+func (s *Threescale) ConfigVersions() []ConfigVersion {
+	return s.rules.versions()
+}
+
+// compileRules builds mappingRules from the proxy config with engine. The config itself is not modified, since it may be
+// shared with the cache.
+func compileRules(conf system.ProxyConfig, engine RuleMatcherEngine) *mappingRules {
+	proxyRules := make([]system.ProxyRule, len(conf.Content.Proxy.ProxyRules))
+	copy(proxyRules, conf.Content.Proxy.ProxyRules)
+
+	// sort proxy rules based on Position field to establish priority
+	sort.SliceStable(proxyRules, func(i, j int) bool {
+		return proxyRules[i].Position < proxyRules[j].Position
+	})
+
+	return &mappingRules{
+		id:          conf.ID,
+		version:     conf.Version,
+		fingerprint: configFingerprint(conf.Version, proxyRules),
+		matcher:     engine(conf.ID, proxyRules),
+	}
+}
+
+// newRegexMatcher is the RuleMatcherEngine of RegexRuleMatcher. Rules with a pattern which is rejected by
+// compilePattern are skipped as they can never match.
+func newRegexMatcher(configID int, proxyRules []system.ProxyRule) RuleMatcher {
+	m := &regexMatcher{rules: make(map[string][]mappingRule)}
+	for _, pr := range proxyRules {
+		pattern, err := compilePattern(pr.Pattern)
+		if err != nil {
+			logSkippedRule(configID, pr, err)
+			continue
+		}
+
+		method := strings.ToUpper(pr.HTTPMethod)
+		m.rules[method] = append(m.rules[method], mappingRule{
+			position: int(pr.Position),
+			pattern:  pattern,
+			metric:   pr.MetricSystemName,
+			delta:    int(pr.Delta),
+			last:     pr.Last,
+		})
+	}
+	return m
+}
+
+func logSkippedRule(configID int, pr system.ProxyRule, err error) {
+	log.Warnf("skipping mapping rule at position %d with pattern %q for proxy config %d - %v",
+		pr.Position, pr.Pattern, configID, err)
+}
+
+// compilePattern compiles a mapping rule pattern, rejecting syntax unsupported by RE2, such as backreferences
+// and lookarounds, and patterns which exceed maxPatternInstructions
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prog.Inst) > maxPatternInstructions {
+		return nil, fmt.Errorf("pattern too complex, compiles to %d instructions exceeding the limit of %d",
+			len(prog.Inst), maxPatternInstructions)
+	}
+
+	return regexp.Compile(pattern)
+}
+
+// metrics returns the metrics to report for a request matching the rules
+func (m *mappingRules) metrics(path string, method string) api.Metrics {
+	return m.matcher.Metrics(path, method)
+}
+
+// Metrics implements RuleMatcher. At debug level, the time taken to evaluate each rule is logged so that patterns which
+// dominate CPU can be identified.
+func (m *regexMatcher) Metrics(path string, method string) api.Metrics {
+	if !log.DebugEnabled() {
+		return m.match(path, method, nil)
+	}
+
+	var trace []ruleEvaluation
+	start := time.Now()
+	metrics := m.match(path, method, &trace)
+	logRuleTrace(method, path, trace, time.Since(start))
+	return metrics
+}
+
+// match evaluates the rules against the request, appending the outcome of each pattern evaluated to trace if non-nil
+func (m *regexMatcher) match(path string, method string, trace *[]ruleEvaluation) api.Metrics {
+	metrics := make(api.Metrics)
+
+	for _, rule := range m.rules[strings.ToUpper(method)] {
+		var start time.Time
+		if trace != nil {
+			start = time.Now()
+		}
+
+		matched := rule.pattern.MatchString(path)
+
+		if trace != nil {
+			*trace = append(*trace, ruleEvaluation{
+				position: rule.position,
+				pattern:  rule.pattern.String(),
+				matched:  matched,
+				elapsed:  time.Since(start),
+			})
+		}
+
+		if matched {
+			metrics.Add(rule.metric, rule.delta)
+			// stop matching if this rule has been marked as Last
+			if rule.last {
+				break
+			}
+		}
+	}
+	return metrics
+}
+
+// logRuleTrace logs the evaluation of each rule, followed by a summary identifying the slowest
+func logRuleTrace(method, path string, trace []ruleEvaluation, total time.Duration) {
+	var slowest ruleEvaluation
+	for _, eval := range trace {
+		log.Debugf("mapping rule at position %d with pattern %q evaluated against %s %s in %s - matched: %t",
+			eval.position, eval.pattern, method, path, eval.elapsed, eval.matched)
+
+		if eval.elapsed > slowest.elapsed {
+			slowest = eval
+		}
+	}
+
+	log.Debugf("evaluated %d mapping rules against %s %s in %s - slowest at position %d with pattern %q took %s",
+		len(trace), method, path, total, slowest.position, slowest.pattern, slowest.elapsed)
+}
+
+// configFingerprint identifies the proxy config which evaluated a request. The version assigned by 3scale is combined
+// with a hash of the mapping rules, so that the exact ruleset can be identified during post-incident analysis.
+// Rules must already be sorted by position for the fingerprint to be deterministic.
+func configFingerprint(version int, rules []system.ProxyRule) string {
+	b, _ := json.Marshal(rules)
+	sum := sha256.Sum256(b)
+	return fmt.Sprintf("v%d-%s", version, hex.EncodeToString(sum[:4]))
+}
@@ -0,0 +1,268 @@
+package threescale
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+func TestMappingRulesMetrics(t *testing.T) {
+	conf := client.ProxyConfig{
+		Content: client.Content{
+			Proxy: client.ContentProxy{
+				ProxyRules: []client.ProxyRule{
+					{HTTPMethod: http.MethodGet, Pattern: "/anything/bar/", Position: 2, MetricSystemName: "bar", Delta: 1},
+					{HTTPMethod: http.MethodGet, Pattern: "/anything/bar/123", Position: 1, MetricSystemName: "bar_id", Delta: 2, Last: true},
+					{HTTPMethod: http.MethodPost, Pattern: "/anything", Position: 3, MetricSystemName: "create", Delta: 1},
+					{HTTPMethod: http.MethodGet, Pattern: "/invalid/(", Position: 4, MetricSystemName: "invalid", Delta: 1},
+				},
+			},
+		},
+	}
+
+	rules := compileRules(conf, newRegexMatcher)
+	compiled := rules.matcher.(*regexMatcher).rules
+	if len(compiled[http.MethodGet]) != 2 || len(compiled[http.MethodPost]) != 1 {
+		t.Errorf("expected rules to be indexed by method, skipping the invalid pattern, but got %v", compiled)
+	}
+
+	if conf.Content.Proxy.ProxyRules[0].Position != 2 {
+		t.Errorf("expected proxy config to be left unmodified")
+	}
+
+	inputs := []struct {
+		path   string
+		method string
+		expect map[string]int
+	}{
+		{path: "/anything/bar/123", method: "get", expect: map[string]int{"bar_id": 2}},
+		{path: "/anything/bar/", method: http.MethodGet, expect: map[string]int{"bar": 1}},
+		{path: "/anything", method: http.MethodPost, expect: map[string]int{"create": 1}},
+		{path: "/anything", method: http.MethodGet, expect: map[string]int{}},
+		{path: "/invalid/(", method: http.MethodGet, expect: map[string]int{}},
+	}
+
+	for _, input := range inputs {
+		metrics := rules.metrics(input.path, input.method)
+		if len(metrics) != len(input.expect) {
+			t.Errorf("unexpected metrics for %s %s - %v", input.method, input.path, metrics)
+			continue
+		}
+
+		for metric, delta := range input.expect {
+			if metrics[metric] != delta {
+				t.Errorf("unexpected delta for %s, wanted %d but got %d", metric, delta, metrics[metric])
+			}
+		}
+	}
+}
+
+func TestMappingRulesTrace(t *testing.T) {
+	rules := compileRules(client.ProxyConfig{
+		Content: client.Content{
+			Proxy: client.ContentProxy{
+				ProxyRules: []client.ProxyRule{
+					{HTTPMethod: http.MethodGet, Pattern: "/other", Position: 1, MetricSystemName: "other", Delta: 1},
+					{HTTPMethod: http.MethodPost, Pattern: "/anything", Position: 2, MetricSystemName: "create", Delta: 1},
+					{HTTPMethod: http.MethodGet, Pattern: "/anything", Position: 3, MetricSystemName: "hits", Delta: 1, Last: true},
+					{HTTPMethod: http.MethodGet, Pattern: "/", Position: 4, MetricSystemName: "root", Delta: 1},
+				},
+			},
+		},
+	}, newRegexMatcher)
+
+	var trace []ruleEvaluation
+	metrics := rules.matcher.(*regexMatcher).match("/anything", http.MethodGet, &trace)
+	if len(metrics) != 1 || metrics["hits"] != 1 {
+		t.Errorf("unexpected metrics %v", metrics)
+	}
+
+	// rules for other methods are not evaluated, nor are rules following a matching rule marked as last
+	expect := []ruleEvaluation{
+		{position: 1, pattern: "/other", matched: false},
+		{position: 3, pattern: "/anything", matched: true},
+	}
+
+	if len(trace) != len(expect) {
+		t.Fatalf("expected %d rules to be traced but got %+v", len(expect), trace)
+	}
+
+	for i, eval := range trace {
+		if eval.position != expect[i].position || eval.pattern != expect[i].pattern || eval.matched != expect[i].matched {
+			t.Errorf("unexpected evaluation, wanted %+v but got %+v", expect[i], eval)
+		}
+	}
+}
+
+func TestCompilePattern(t *testing.T) {
+	inputs := []struct {
+		name        string
+		pattern     string
+		expectError bool
+	}{
+		{name: "Test simple pattern", pattern: "/anything/bar/123"},
+		{name: "Test pattern with repetition", pattern: "^/v[0-9]+/orders/[^/]{1,64}$"},
+		{name: "Test backreference is rejected", pattern: `/(\w+)/\1`, expectError: true},
+		{name: "Test lookahead is rejected", pattern: "/(?=orders)", expectError: true},
+		{name: "Test pattern exceeding the instruction limit is rejected", pattern: strings.Repeat("[a-z]{1000}", 6), expectError: true},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			re, err := compilePattern(input.pattern)
+			if input.expectError {
+				if err == nil {
+					t.Errorf("expected pattern %q to be rejected", input.pattern)
+				}
+				return
+			}
+
+			if err != nil || re.String() != input.pattern {
+				t.Errorf("unexpected result compiling %q - %v", input.pattern, err)
+			}
+		})
+	}
+}
+
+func TestRuleCache(t *testing.T) {
+	newConf := func(version int, pattern string) client.ProxyConfig {
+		return client.ProxyConfig{
+			ID:      1,
+			Version: version,
+			Content: client.Content{
+				Proxy: client.ContentProxy{
+					ProxyRules: []client.ProxyRule{
+						{HTTPMethod: http.MethodGet, Pattern: pattern, MetricSystemName: "hits", Delta: 1},
+					},
+				},
+			},
+		}
+	}
+
+	cache := &ruleCache{}
+	var decoded int
+	get := func(serviceID string, engineName string, engine RuleMatcherEngine, conf client.ProxyConfig) *mappingRules {
+		rules, err := cache.get("https://system", serviceID, engineName, engine, conf, func() ([]client.ProxyRule, error) {
+			decoded++
+			return conf.Content.Proxy.ProxyRules, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+		return rules
+	}
+
+	first := get("123", RegexRuleMatcher, newRegexMatcher, newConf(1, "/test"))
+	if get("123", RegexRuleMatcher, newRegexMatcher, newConf(1, "/test")) != first {
+		t.Errorf("expected compiled rules to be reused for the same config version")
+	}
+
+	if decoded != 1 {
+		t.Errorf("expected mapping rules to be decoded only when compiled but were decoded %d times", decoded)
+	}
+
+	if get("456", RegexRuleMatcher, newRegexMatcher, newConf(1, "/test")) == first {
+		t.Errorf("expected compiled rules to be held per service")
+	}
+
+	if get("123", PrefixRuleMatcher, newPrefixMatcher, newConf(1, "/test")) == first {
+		t.Errorf("expected compiled rules to be held per engine")
+	}
+
+	updated := get("123", RegexRuleMatcher, newRegexMatcher, newConf(2, "/other"))
+	if updated == first {
+		t.Errorf("expected rules to be recompiled for a new config version")
+	}
+
+	if len(updated.metrics("/other", http.MethodGet)) != 1 {
+		t.Errorf("expected updated rules to be used")
+	}
+}
+
+func TestRuleCacheVersions(t *testing.T) {
+	newConf := func(version int, pattern string) client.ProxyConfig {
+		return client.ProxyConfig{
+			ID:      1,
+			Version: version,
+			Content: client.Content{
+				Proxy: client.ContentProxy{
+					ProxyRules: []client.ProxyRule{
+						{HTTPMethod: http.MethodGet, Pattern: pattern, MetricSystemName: "hits", Delta: 1},
+					},
+				},
+			},
+		}
+	}
+
+	s := &Threescale{}
+	s.rules.get("https://system", "456", RegexRuleMatcher, newRegexMatcher, newConf(1, "/test"), decodedRules(newConf(1, "/test")))
+	s.rules.get("https://system", "123", RegexRuleMatcher, newRegexMatcher, newConf(1, "/test"), decodedRules(newConf(1, "/test")))
+	s.rules.get("https://system", "123", PrefixRuleMatcher, newPrefixMatcher, newConf(1, "/test"), decodedRules(newConf(1, "/test")))
+	s.rules.get("https://system", "123", RegexRuleMatcher, newRegexMatcher, newConf(2, "/other"), decodedRules(newConf(2, "/other")))
+
+	expect := []ConfigVersion{
+		{SystemURL: "https://system", ServiceID: "123", Version: configFingerprint(1, newConf(1, "/test").Content.Proxy.ProxyRules)},
+		{SystemURL: "https://system", ServiceID: "123", Version: configFingerprint(2, newConf(2, "/other").Content.Proxy.ProxyRules)},
+		{SystemURL: "https://system", ServiceID: "456", Version: configFingerprint(1, newConf(1, "/test").Content.Proxy.ProxyRules)},
+	}
+	if versions := s.ConfigVersions(); !reflect.DeepEqual(versions, expect) {
+		t.Errorf("expected versions %v but got %v", expect, versions)
+	}
+}
+
+func TestConfigFingerprint(t *testing.T) {
+	newRules := func(pattern string) []client.ProxyRule {
+		return []client.ProxyRule{
+			{HTTPMethod: http.MethodGet, Pattern: pattern, MetricSystemName: "hits", Delta: 1},
+		}
+	}
+
+	fingerprint := configFingerprint(3, newRules("/test"))
+	if !strings.HasPrefix(fingerprint, "v3-") {
+		t.Errorf("expected fingerprint to include the config version but got %s", fingerprint)
+	}
+
+	if fingerprint != configFingerprint(3, newRules("/test")) {
+		t.Errorf("expected fingerprint to be deterministic")
+	}
+
+	if fingerprint == configFingerprint(3, newRules("/other")) {
+		t.Errorf("expected fingerprint to change when mapping rules change")
+	}
+
+	if fingerprint == configFingerprint(4, newRules("/test")) {
+		t.Errorf("expected fingerprint to change when version changes")
+	}
+}
+
+func BenchmarkMappingRulesMetrics(b *testing.B) {
+	benchmarkMappingRulesMetrics(b, newRegexMatcher)
+}
+
+func BenchmarkMappingRulesMetricsPrefix(b *testing.B) {
+	benchmarkMappingRulesMetrics(b, newPrefixMatcher)
+}
+
+func benchmarkMappingRulesMetrics(b *testing.B, engine RuleMatcherEngine) {
+	var proxyRules []client.ProxyRule
+	for i := 0; i < 2000; i++ {
+		proxyRules = append(proxyRules, client.ProxyRule{
+			HTTPMethod:       http.MethodGet,
+			Pattern:          fmt.Sprintf("/resource/%d$", i),
+			Position:         i,
+			MetricSystemName: "hits",
+			Delta:            1,
+		})
+	}
+
+	rules := compileRules(client.ProxyConfig{Content: client.Content{Proxy: client.ContentProxy{ProxyRules: proxyRules}}}, engine)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rules.metrics("/resource/1999", http.MethodGet)
+	}
+}
@@ -0,0 +1,120 @@
+package threescale
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/statuses"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+	"istio.io/istio/pkg/log"
+)
+
+// sessionTypeURL is the type of the adapter config Mixer sends with each request, that of the handler params. When the
+// adapter is session based, its value is the session ID returned by CreateSession in place of the params.
+const sessionTypeURL = "type.googleapis.com/adapter.threescale.config.Params"
+
+var (
+	errNilSessionConfig = errors.New("adapter config must be provided to create a session")
+	errUnknownSession   = errors.New("unknown session - the adapter may have restarted since it was created")
+	errSessionsDisabled = errors.New("session based configuration is not enabled for this adapter")
+)
+
+// Implement the optional interface, so that Mixer can validate handler config when it is applied
+var _ v1beta1.InfrastructureBackendServer = &Threescale{}
+
+// Validate implements InfrastructureBackendServer, rejecting handler config which would otherwise only be reported
+// as FAILED_PRECONDITION once requests are made. The service ID is not required, since requests can provide it.
+// In strict mode, handler config relying on deprecated params is rejected here too.
+func (s *Threescale) Validate(ctx context.Context, r *v1beta1.ValidateRequest) (*v1beta1.ValidateResponse, error) {
+	cfg, err := s.validateSessionConfig(r.AdapterConfig)
+	if err == nil {
+		err = s.rejectDeprecatedParams(cfg)
+	}
+
+	if err != nil {
+		return &v1beta1.ValidateResponse{Status: statuses.InvalidConfig(err.Error())}, nil
+	}
+	return &v1beta1.ValidateResponse{Status: statuses.OK}, nil
+}
+
+// CreateSession implements InfrastructureBackendServer, validating and holding the handler config for the requests
+// Mixer makes with the returned session ID
+func (s *Threescale) CreateSession(ctx context.Context, r *v1beta1.CreateSessionRequest) (*v1beta1.CreateSessionResponse, error) {
+	if !s.conf.SessionBased {
+		return &v1beta1.CreateSessionResponse{Status: statuses.InvalidConfig(errSessionsDisabled.Error())}, nil
+	}
+
+	cfg, err := s.validateSessionConfig(r.AdapterConfig)
+	if err != nil {
+		return &v1beta1.CreateSessionResponse{Status: statuses.InvalidConfig(err.Error())}, nil
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return &v1beta1.CreateSessionResponse{Status: statuses.Internal(err.Error())}, nil
+	}
+
+	s.sessions.Store(id, r.AdapterConfig.Value)
+	log.Infof("created session %s for service %q", id, cfg.ServiceId)
+	return &v1beta1.CreateSessionResponse{SessionId: id, Status: statuses.OK}, nil
+}
+
+// CloseSession implements InfrastructureBackendServer, releasing the handler config held for the session
+func (s *Threescale) CloseSession(ctx context.Context, r *v1beta1.CloseSessionRequest) (*v1beta1.CloseSessionResponse, error) {
+	s.sessions.Delete(r.SessionId)
+	log.Infof("closed session %s", r.SessionId)
+	return &v1beta1.CloseSessionResponse{Status: statuses.OK}, nil
+}
+
+// validateSessionConfig parses and validates the handler config sent outside of a request
+func (s *Threescale) validateSessionConfig(adapterConfig *types.Any) (*config.Params, error) {
+	if adapterConfig == nil {
+		return nil, errNilSessionConfig
+	}
+
+	cfg := &config.Params{}
+	if err := cfg.Unmarshal(adapterConfig.Value); err != nil {
+		return nil, errUnmarshalConfig
+	}
+	return cfg, joinErrors(s.configParamsErrors(cfg))
+}
+
+// sessionID returns the session ID sent as the adapter config of a request, which is only the case when the adapter is
+// session based
+func (s *Threescale) sessionID(adapterConfig *types.Any) (string, bool) {
+	if !s.conf.SessionBased || adapterConfig == nil || adapterConfig.TypeUrl != sessionTypeURL {
+		return "", false
+	}
+	return string(adapterConfig.Value), true
+}
+
+// withSessionConfig replaces the session ID sent as the adapter config of a request with the handler config held for
+// the session, so that the request is handled exactly as if the handler config had been sent with it
+func (s *Threescale) withSessionConfig(r *authorization.HandleAuthorizationRequest) error {
+	id, ok := s.sessionID(r.AdapterConfig)
+	if !ok {
+		return nil
+	}
+
+	value, ok := s.sessions.Load(id)
+	if !ok {
+		return errUnknownSession
+	}
+
+	r.AdapterConfig = &types.Any{Value: value.([]byte)}
+	return nil
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
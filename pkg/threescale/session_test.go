@@ -0,0 +1,173 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestValidate(t *testing.T) {
+	inputs := []struct {
+		name         string
+		params       *config.Params
+		expectStatus int32
+	}{
+		{
+			name:         "Test missing adapter config is invalid",
+			expectStatus: int32(rpc.FAILED_PRECONDITION),
+		},
+		{
+			name: "Test valid config without service ID",
+			params: &config.Params{
+				SystemUrl:   "https://www.fake-system.3scale.net",
+				AccessToken: "any",
+			},
+			expectStatus: int32(rpc.OK),
+		},
+		{
+			name: "Test config without access token is invalid",
+			params: &config.Params{
+				ServiceId: "123",
+				SystemUrl: "https://www.fake-system.3scale.net",
+			},
+			expectStatus: int32(rpc.FAILED_PRECONDITION),
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var adapterConfig *types.Any
+			if input.params != nil {
+				var err error
+				adapterConfig, err = types.MarshalAny(input.params)
+				if err != nil {
+					t.Fatalf("unexpected error marshalling params - %v", err)
+				}
+				if adapterConfig.TypeUrl != sessionTypeURL {
+					t.Fatalf("expected params to have type URL %s but got %s", sessionTypeURL, adapterConfig.TypeUrl)
+				}
+			}
+
+			c := &Threescale{conf: &AdapterConfig{}}
+			resp, err := c.Validate(context.TODO(), &v1beta1.ValidateRequest{AdapterConfig: adapterConfig})
+			if err != nil {
+				t.Fatalf("unexpected error - %v", err)
+			}
+
+			if resp.Status.Code != input.expectStatus {
+				t.Errorf("expected status %d but got %d - %s", input.expectStatus, resp.Status.Code, resp.Status.Message)
+			}
+		})
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	params := config.Params{
+		ServiceId:   "123",
+		SystemUrl:   "https://www.fake-system.3scale.net",
+		AccessToken: "any",
+		BackendUrl:  internalBackend,
+	}
+	b, _ := params.Marshal()
+
+	c := &Threescale{
+		conf: &AdapterConfig{
+			SessionBased: true,
+			Authorizer: mockAuthorizer{
+				withConfig: client.ProxyConfig{
+					Content: client.Content{
+						Proxy: client.ContentProxy{
+							ProxyRules: []client.ProxyRule{
+								{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+							},
+						},
+					},
+				},
+				withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {},
+				withAuthResponse:    &authorizer.BackendResponse{Authorized: true},
+				t:                   t,
+			},
+		},
+	}
+
+	created, err := c.CreateSession(context.TODO(), &v1beta1.CreateSessionRequest{
+		AdapterConfig: &types.Any{TypeUrl: sessionTypeURL, Value: b},
+	})
+	if err != nil || created.Status.Code != int32(rpc.OK) || created.SessionId == "" {
+		t.Fatalf("expected session to be created but got %+v - %v", created, err)
+	}
+
+	request := func() *v1beta1.CheckResult {
+		result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+			Instance: &authorization.InstanceMsg{
+				Action: &authorization.ActionMsg{
+					Method: http.MethodGet,
+					Path:   "/test",
+				},
+				Subject: &authorization.SubjectMsg{
+					User: "secret",
+				},
+			},
+			AdapterConfig: &types.Any{TypeUrl: sessionTypeURL, Value: []byte(created.SessionId)},
+		})
+		return result
+	}
+
+	if result := request(); result.Status.Code != int32(rpc.OK) {
+		t.Errorf("expected request with session to be authorized but got %d - %s", result.Status.Code, result.Status.Message)
+	}
+
+	if _, err := c.CloseSession(context.TODO(), &v1beta1.CloseSessionRequest{SessionId: created.SessionId}); err != nil {
+		t.Fatalf("unexpected error closing session - %v", err)
+	}
+
+	if result := request(); result.Status.Code != int32(rpc.INTERNAL) {
+		t.Errorf("expected request with closed session to fail but got %d - %s", result.Status.Code, result.Status.Message)
+	}
+}
+
+func TestCreateSessionInvalidConfig(t *testing.T) {
+	params := config.Params{ServiceId: "123"}
+	b, _ := params.Marshal()
+
+	c := &Threescale{conf: &AdapterConfig{SessionBased: true}}
+	resp, err := c.CreateSession(context.TODO(), &v1beta1.CreateSessionRequest{
+		AdapterConfig: &types.Any{TypeUrl: sessionTypeURL, Value: b},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if resp.Status.Code != int32(rpc.FAILED_PRECONDITION) || resp.SessionId != "" {
+		t.Errorf("expected invalid config to be rejected without a session but got %+v", resp)
+	}
+}
+
+func TestCreateSessionDisabled(t *testing.T) {
+	params := config.Params{
+		SystemUrl:   "https://www.fake-system.3scale.net",
+		AccessToken: "any",
+	}
+	b, _ := params.Marshal()
+
+	c := &Threescale{conf: &AdapterConfig{}}
+	resp, err := c.CreateSession(context.TODO(), &v1beta1.CreateSessionRequest{
+		AdapterConfig: &types.Any{TypeUrl: sessionTypeURL, Value: b},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	if resp.Status.Code != int32(rpc.FAILED_PRECONDITION) || resp.SessionId != "" {
+		t.Errorf("expected session to be refused when sessions are disabled but got %+v", resp)
+	}
+}
@@ -0,0 +1,90 @@
+package threescale
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+// ShardedAuthorizer splits responsibility for caching services across replicas of the adapter.
+// Each service is owned by exactly one shard, determined by rendezvous hashing on the system url and service id,
+// so only a small share of services move between shards when the shard count changes.
+// Configuration for owned services is fetched via the caching Authorizer, while the remaining services are
+// still served on demand via an Authorizer which does not cache, keeping memory bounded for very large tenants.
+type ShardedAuthorizer struct {
+	owned    Authorizer
+	onDemand Authorizer
+	index    int
+	count    int
+}
+
+// NewShardedAuthorizer returns a ShardedAuthorizer for the shard at index of count total shards.
+// Reports to backend are always made via the owned Authorizer.
+func NewShardedAuthorizer(owned Authorizer, onDemand Authorizer, index int, count int) (*ShardedAuthorizer, error) {
+	if count < 1 || index < 0 || index >= count {
+		return nil, fmt.Errorf("invalid shard %d of %d", index, count)
+	}
+
+	return &ShardedAuthorizer{
+		owned:    owned,
+		onDemand: onDemand,
+		index:    index,
+		count:    count,
+	}, nil
+}
+
+// GetSystemConfiguration implements Authorizer
+func (s *ShardedAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	if s.Owns(systemURL, request.ServiceID) {
+		return s.owned.GetSystemConfiguration(systemURL, request)
+	}
+	return s.onDemand.GetSystemConfiguration(systemURL, request)
+}
+
+// AuthRep implements Authorizer
+func (s *ShardedAuthorizer) AuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	return s.owned.AuthRep(backendURL, request)
+}
+
+// Shutdown implements Authorizer
+func (s *ShardedAuthorizer) Shutdown() {
+	s.owned.Shutdown()
+	s.onDemand.Shutdown()
+}
+
+// Owns returns true if this shard is responsible for caching the service
+func (s *ShardedAuthorizer) Owns(systemURL string, serviceID string) bool {
+	return shardFor(systemURL+"|"+serviceID, s.count) == s.index
+}
+
+// shardFor returns the shard with the highest weight for the key
+func shardFor(key string, count int) int {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+
+	var (
+		owner     int
+		maxWeight uint64
+	)
+
+	for i := 0; i < count; i++ {
+		if weight := mix64(sum ^ uint64(i+1)*0x9e3779b97f4a7c15); i == 0 || weight > maxWeight {
+			owner = i
+			maxWeight = weight
+		}
+	}
+	return owner
+}
+
+// mix64 is the splitmix64 finalizer, spreading the weights of each shard for the same key
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
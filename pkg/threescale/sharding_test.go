@@ -0,0 +1,92 @@
+package threescale
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+func TestShardFor(t *testing.T) {
+	const services = 1000
+
+	counts := make(map[int]int)
+	moved := 0
+	for i := 0; i < services; i++ {
+		key := fmt.Sprintf("https://system|%d", i)
+		shard := shardFor(key, 4)
+		if shard != shardFor(key, 4) {
+			t.Fatalf("expected shard to be deterministic")
+		}
+		counts[shard]++
+
+		if shardFor(key, 5) != shard {
+			moved++
+		}
+	}
+
+	for shard := 0; shard < 4; shard++ {
+		if counts[shard] < services/8 {
+			t.Errorf("expected services to be spread across shards but got %v", counts)
+		}
+	}
+
+	// adding a fifth shard should move roughly a fifth of services
+	if moved > services/3 {
+		t.Errorf("expected few services to move when adding a shard but %d moved", moved)
+	}
+}
+
+func TestShardedAuthorizer(t *testing.T) {
+	if _, err := NewShardedAuthorizer(nil, nil, 2, 2); err == nil {
+		t.Errorf("expected error for out of range shard")
+	}
+
+	owned, onDemand := &countingAuthorizer{}, &countingAuthorizer{}
+	shards := make([]*ShardedAuthorizer, 3)
+	for i := range shards {
+		shards[i], _ = NewShardedAuthorizer(owned, onDemand, i, 3)
+	}
+
+	for i := 0; i < 30; i++ {
+		serviceID := fmt.Sprintf("%d", i)
+		var owners int
+		for _, shard := range shards {
+			if shard.Owns("https://system", serviceID) {
+				owners++
+			}
+			shard.GetSystemConfiguration("https://system", authorizer.SystemRequest{ServiceID: serviceID})
+		}
+
+		if owners != 1 {
+			t.Errorf("expected service %s to be owned by exactly one shard but got %d", serviceID, owners)
+		}
+	}
+
+	if owned.systemCalls != 30 || onDemand.systemCalls != 60 {
+		t.Errorf("unexpected routing of system calls, owned %d, on demand %d", owned.systemCalls, onDemand.systemCalls)
+	}
+
+	shards[0].AuthRep("", authorizer.BackendRequest{})
+	if owned.backendCalls != 1 || onDemand.backendCalls != 0 {
+		t.Errorf("expected backend calls to be made via the owned authorizer")
+	}
+}
+
+type countingAuthorizer struct {
+	systemCalls  int
+	backendCalls int
+}
+
+func (c *countingAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	c.systemCalls++
+	return client.ProxyConfig{}, nil
+}
+
+func (c *countingAuthorizer) AuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	c.backendCalls++
+	return &authorizer.BackendResponse{}, nil
+}
+
+func (c *countingAuthorizer) Shutdown() {}
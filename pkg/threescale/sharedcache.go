@@ -0,0 +1,77 @@
+package threescale
+
+import (
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+
+	"istio.io/istio/pkg/log"
+)
+
+// sharedConfigKeyPrefix namespaces the keys of proxy configs in a shared store
+const sharedConfigKeyPrefix = "3scale-istio-adapter:proxy-config:"
+
+// ConfigStore holds proxy configs shared between replicas of the adapter, such as a Redis server
+type ConfigStore interface {
+	Get(key string) ([]byte, bool, error)
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+// SharedConfigCache is an Authorizer which shares the proxy configs fetched from 3scale system with other replicas
+// via a ConfigStore, so that a service is fetched once per TTL across all replicas rather than once per replica.
+// It should sit beneath a local cache, since the store is consulted on every call. Failures of the store are logged
+// and the config is fetched from 3scale system as usual.
+type SharedConfigCache struct {
+	Authorizer
+	store ConfigStore
+	ttl   time.Duration
+}
+
+// NewSharedConfigCache returns a SharedConfigCache which fetches proxy configs missing from the store via next and
+// stores them for ttl
+func NewSharedConfigCache(next Authorizer, store ConfigStore, ttl time.Duration) *SharedConfigCache {
+	return &SharedConfigCache{
+		Authorizer: next,
+		store:      store,
+		ttl:        ttl,
+	}
+}
+
+// GetSystemConfiguration implements Authorizer
+func (c *SharedConfigCache) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	key := sharedConfigKey(systemURL, request)
+
+	data, ok, err := c.store.Get(key)
+	if err != nil {
+		log.Warnf("unable to read proxy config of service %s from shared cache - %v", request.ServiceID, err)
+	}
+
+	if ok {
+		conf, err := decompressConfig(data)
+		if err == nil {
+			return conf, nil
+		}
+		log.Warnf("ignoring invalid proxy config of service %s in shared cache - %v", request.ServiceID, err)
+	}
+
+	conf, err := c.Authorizer.GetSystemConfiguration(systemURL, request)
+	if err != nil {
+		return conf, err
+	}
+
+	if data, err = compressConfig(conf); err == nil {
+		err = c.store.Set(key, data, c.ttl)
+	}
+
+	if err != nil {
+		log.Warnf("unable to write proxy config of service %s to shared cache - %v", request.ServiceID, err)
+	}
+	return conf, nil
+}
+
+// sharedConfigKey identifies a proxy config in the store. The access token is hashed along with the rest of the
+// request, so that it is not exposed by the key.
+func sharedConfigKey(systemURL string, request authorizer.SystemRequest) string {
+	return sharedConfigKeyPrefix + configKey(systemURL, request)
+}
@@ -0,0 +1,86 @@
+package threescale
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+// mapStore is a ConfigStore shared by the caches of simulated replicas
+type mapStore struct {
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	err    error
+}
+
+func (m *mapStore) Get(key string) ([]byte, bool, error) {
+	if m.err != nil {
+		return nil, false, m.err
+	}
+	v, ok := m.values[key]
+	return v, ok, nil
+}
+
+func (m *mapStore) Set(key string, value []byte, ttl time.Duration) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.values[key] = value
+	m.ttls[key] = ttl
+	return nil
+}
+
+func TestSharedConfigCache(t *testing.T) {
+	conf := client.ProxyConfig{
+		ID:      1,
+		Version: 2,
+		Content: client.Content{
+			Proxy: client.ContentProxy{
+				Backend: client.Backend{Endpoint: "https://su1.3scale.net"},
+			},
+		},
+	}
+
+	store := &mapStore{values: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+	request := authorizer.SystemRequest{ServiceID: "123", AccessToken: "secret-token", Environment: "production"}
+
+	var replicas []*configAuthorizer
+	for i := 0; i < 3; i++ {
+		next := &configAuthorizer{conf: conf}
+		replicas = append(replicas, next)
+
+		got, err := NewSharedConfigCache(next, store, time.Minute).GetSystemConfiguration("https://system", request)
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+
+		if !reflect.DeepEqual(got, conf) {
+			t.Errorf("expected shared config to match original")
+		}
+	}
+
+	if replicas[0].calls != 1 || replicas[1].calls != 0 || replicas[2].calls != 0 {
+		t.Errorf("expected config to be fetched by the first replica only")
+	}
+
+	for key, ttl := range store.ttls {
+		if strings.Contains(key, "secret-token") || ttl != time.Minute {
+			t.Errorf("unexpected key %s stored for %s", key, ttl)
+		}
+	}
+
+	store.err = errors.New("unreachable")
+	next := &configAuthorizer{conf: conf}
+	if _, err := NewSharedConfigCache(next, store, time.Minute).GetSystemConfiguration("https://system", request); err != nil {
+		t.Errorf("expected config to be fetched when the store fails but got %v", err)
+	}
+
+	if next.calls != 1 {
+		t.Errorf("expected config to be fetched from system when the store fails")
+	}
+}
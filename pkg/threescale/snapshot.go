@@ -0,0 +1,119 @@
+package threescale
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+)
+
+// snapshotConfig is a cached config as saved in a snapshot, still compressed. The access token of the request is not
+// saved, the config being identified by its key, in which the token is hashed.
+type snapshotConfig struct {
+	Key       string                   `json:"key"`
+	SystemURL string                   `json:"system_url"`
+	Request   authorizer.SystemRequest `json:"request"`
+	Fetched   time.Time                `json:"fetched"`
+	TTL       time.Duration            `json:"ttl"`
+	Options   ConfigCacheOptions       `json:"options"`
+	Data      []byte                   `json:"data"`
+}
+
+// configSnapshot is the format the cached configs are saved in
+type configSnapshot struct {
+	SavedAt time.Time        `json:"saved_at"`
+	Configs []snapshotConfig `json:"configs"`
+}
+
+// SaveSnapshot writes the cached configs to w, so that they can be restored after a restart. The access tokens the
+// configs were fetched with are not saved, but the configs hold the credentials of each service, so must be kept
+// private.
+func (c *CompressedConfigCache) SaveSnapshot(w io.Writer, now time.Time) error {
+	snapshot := configSnapshot{SavedAt: now, Configs: []snapshotConfig{}}
+
+	c.mutex.RLock()
+	for key, entry := range c.entries {
+		request := entry.request
+		request.AccessToken = ""
+		snapshot.Configs = append(snapshot.Configs, snapshotConfig{
+			Key:       key,
+			SystemURL: entry.systemURL,
+			Request:   request,
+			Fetched:   entry.fetched,
+			TTL:       entry.ttl,
+			Options:   entry.opts,
+			Data:      entry.data,
+		})
+	}
+	c.mutex.RUnlock()
+
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// RestoreSnapshot caches the configs saved by SaveSnapshot, so that requests can be authorized after a restart while
+// 3scale system cannot be reached. Configs fetched maxAge or longer ago are not restored. Restored configs are fresh
+// for their ttl from when they are restored, but no longer than maxAge from when they were fetched, and keep the time
+// they were fetched at, so that those in use are refreshed by RunRefresh, or the refresh interval of the request, once
+// 3scale system can be reached. Since the snapshot holds no access tokens, a restored config is only refreshed by
+// RunRefresh once a request has presented the token it was fetched with. Configs already cached are kept, and no more
+// are restored than the cache holds. maxAge must be positive. It returns the number of configs restored and the time
+// they were saved at.
+func (c *CompressedConfigCache) RestoreSnapshot(r io.Reader, maxAge time.Duration) (int, time.Time, error) {
+	var snapshot configSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var restored int
+	now := c.now()
+	for _, saved := range snapshot.Configs {
+		if len(c.entries) >= c.maxEntries {
+			break
+		}
+
+		if saved.Key == "" || !now.Before(saved.Fetched.Add(maxAge)) {
+			continue
+		}
+
+		if _, ok := c.entries[saved.Key]; ok {
+			continue
+		}
+
+		conf, err := decompressConfig(saved.Data)
+		if err != nil {
+			continue
+		}
+		conf.Content.Proxy.ProxyRules = nil
+
+		ttl := saved.TTL
+		if ttl <= 0 {
+			ttl = c.ttl
+		}
+
+		expires := now.Add(ttl)
+		if limit := saved.Fetched.Add(maxAge); limit.Before(expires) {
+			expires = limit
+		}
+
+		lastUsed := now.UnixNano()
+		c.entries[saved.Key] = compressedConfig{
+			conf:      conf,
+			data:      saved.Data,
+			fetched:   saved.Fetched,
+			expires:   expires,
+			ttl:       ttl,
+			systemURL: saved.SystemURL,
+			request:   saved.Request,
+			opts:      saved.Options,
+			used:      new(int32),
+			lastUsed:  &lastUsed,
+		}
+		restored++
+	}
+	c.metrics.configCacheEntries(len(c.entries))
+	return restored, snapshot.SavedAt, nil
+}
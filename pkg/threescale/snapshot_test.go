@@ -0,0 +1,94 @@
+package threescale
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+func TestCompressedConfigCacheSnapshot(t *testing.T) {
+	get := func(cache *CompressedConfigCache, serviceID string, strategy ConfigStrategy) (client.ProxyConfig, error) {
+		request := authorizer.SystemRequest{ServiceID: serviceID, AccessToken: "secret-token", Environment: "production"}
+		return cache.Get(context.Background(), "https://system", request, ConfigCacheOptions{Strategy: strategy})
+	}
+
+	saved := NewCompressedConfigCache(&failingAuthorizer{}, time.Minute, 10)
+	for _, serviceID := range []string{"1", "2"} {
+		if _, err := get(saved, serviceID, ConfigWaitForFresh); err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+	}
+
+	savedAt := time.Now()
+	var snapshot bytes.Buffer
+	if err := saved.SaveSnapshot(&snapshot, savedAt); err != nil {
+		t.Fatalf("unexpected error saving snapshot - %v", err)
+	}
+
+	if bytes.Contains(snapshot.Bytes(), []byte("secret-token")) {
+		t.Errorf("expected snapshot not to hold access tokens")
+	}
+
+	// system cannot be reached after the restart
+	next := &failingAuthorizer{failures: 10}
+	now := time.Now().Add(time.Hour)
+	restored := NewCompressedConfigCache(next, time.Minute, 10)
+	restored.now = func() time.Time {
+		return now
+	}
+
+	n, at, err := restored.RestoreSnapshot(bytes.NewReader(snapshot.Bytes()), 2*time.Hour)
+	if err != nil || n != 2 || !at.Equal(savedAt) {
+		t.Fatalf("expected 2 configs saved at %s to be restored but got %d at %s - %v", savedAt, n, at, err)
+	}
+
+	conf, err := get(restored, "1", ConfigFailFast)
+	if err != nil || conf.Version == 0 {
+		t.Errorf("expected restored config to be served but got version %d - %v", conf.Version, err)
+	}
+
+	request := authorizer.SystemRequest{ServiceID: "1", AccessToken: "secret-token", Environment: "production"}
+	if entry := restored.entries[configKey("https://system", request)]; entry.request.AccessToken != request.AccessToken {
+		t.Errorf("expected restored config to adopt the request presenting its token but got %+v", entry.request)
+	}
+
+	// the config in use is due to be refreshed, as it was fetched before the restart
+	restored.refresh(time.Minute, make(chan struct{}))
+	if next.calls != 1 {
+		t.Errorf("expected only the restored config in use to be refreshed but got %d calls", next.calls)
+	}
+
+	if _, err := get(restored, "1", ConfigFailFast); err != nil {
+		t.Errorf("expected restored config to be kept when it cannot be refreshed - %v", err)
+	}
+
+	small := NewCompressedConfigCache(next, time.Minute, 1)
+	if n, _, err := small.RestoreSnapshot(bytes.NewReader(snapshot.Bytes()), 2*time.Hour); err != nil || n != 1 {
+		t.Errorf("expected restore to be limited by the size of the cache but got %d - %v", n, err)
+	}
+
+	expired := NewCompressedConfigCache(next, time.Minute, 10)
+	expired.now = restored.now
+	if n, _, err := expired.RestoreSnapshot(bytes.NewReader(snapshot.Bytes()), time.Hour); err != nil || n != 0 {
+		t.Errorf("expected configs older than the maximum age not to be restored but got %d - %v", n, err)
+	}
+
+	// a config restored shortly before reaching the maximum age is only served until then
+	bounded := NewCompressedConfigCache(next, time.Hour, 10)
+	bounded.now = func() time.Time {
+		return savedAt.Add(90 * time.Minute)
+	}
+	if n, _, err := bounded.RestoreSnapshot(bytes.NewReader(snapshot.Bytes()), 2*time.Hour); err != nil || n != 2 {
+		t.Fatalf("expected 2 configs to be restored but got %d - %v", n, err)
+	}
+
+	for _, entry := range bounded.entries {
+		if limit := entry.fetched.Add(2 * time.Hour); entry.expires.After(limit) {
+			t.Errorf("expected restored config to expire by %s but got %s", limit, entry.expires)
+		}
+	}
+}
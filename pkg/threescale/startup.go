@@ -0,0 +1,36 @@
+package threescale
+
+import (
+	"fmt"
+	"time"
+
+	"istio.io/istio/pkg/log"
+)
+
+// StartupHook is run by Server.Run before the server is reported as ready, for example to preload state or start
+// background workers. Returning an error aborts the start, with the error sent to the shutdown channel.
+type StartupHook func() error
+
+// Ready returns a channel which is closed once the startup hooks have completed and the server accepts requests.
+// It is never closed if the server fails to start.
+func (s *Threescale) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// runStartupHooks runs the configured hooks in order, stopping at the first to fail
+func (s *Threescale) runStartupHooks() error {
+	for i, hook := range s.conf.StartupHooks {
+		if err := hook(); err != nil {
+			return fmt.Errorf("startup hook %d failed - %v", i, err)
+		}
+	}
+	return nil
+}
+
+// markReady closes the ready channel. The listener is bound before Run is called, so connections made once the
+// server is reported ready are queued until they are accepted.
+func (s *Threescale) markReady() {
+	log.Infof("Threescale Istio Adapter is ready on \"%v\"", s.Addr())
+	s.readyAt = time.Now()
+	close(s.ready)
+}
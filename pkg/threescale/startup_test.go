@@ -0,0 +1,79 @@
+package threescale
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunStartupHooks(t *testing.T) {
+	inputs := []struct {
+		name        string
+		hookErr     error
+		expectReady bool
+		expectCalls []int
+	}{
+		{
+			name:        "Test server is ready once hooks complete",
+			expectReady: true,
+			expectCalls: []int{1, 2},
+		},
+		{
+			name:        "Test failing hook aborts the start",
+			hookErr:     errors.New("preload failed"),
+			expectCalls: []int{1},
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var calls []int
+			conf := &AdapterConfig{
+				KeepAliveMaxAge: time.Minute,
+				StartupHooks: []StartupHook{
+					func() error {
+						calls = append(calls, 1)
+						return input.hookErr
+					},
+					func() error {
+						calls = append(calls, 2)
+						return nil
+					},
+				},
+			}
+
+			s, err := NewThreescale("0", conf)
+			if err != nil {
+				t.Fatalf("unexpected error creating server - %v", err)
+			}
+			defer s.Close()
+
+			select {
+			case <-s.Ready():
+				t.Fatalf("expected server not to be ready before it is run")
+			default:
+			}
+
+			shutdown := make(chan error, 1)
+			go s.Run(shutdown)
+
+			select {
+			case <-s.Ready():
+				if !input.expectReady {
+					t.Errorf("unexpected ready server")
+				}
+			case err := <-shutdown:
+				if input.expectReady || err == nil {
+					t.Errorf("unexpected shutdown - %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("timed out waiting for server to start")
+			}
+
+			if !reflect.DeepEqual(calls, input.expectCalls) {
+				t.Errorf("unexpected hooks run, wanted %v but got %v", input.expectCalls, calls)
+			}
+		})
+	}
+}
@@ -12,23 +12,24 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	"regexp"
-	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/3scale/3scale-authorizer/pkg/authorizer"
-	"github.com/3scale/3scale-go-client/threescale/api"
-	convert "github.com/3scale/3scale-go-client/threescale/http"
 	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/statuses"
 	system "github.com/3scale/3scale-porta-go-client/client"
+	rls "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v2"
 	"github.com/gogo/googleapis/google/rpc"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 
 	"istio.io/api/mixer/adapter/model/v1beta1"
-	"istio.io/istio/mixer/pkg/status"
 	"istio.io/istio/mixer/template/authorization"
 	"istio.io/istio/pkg/log"
 )
@@ -41,6 +42,14 @@ const (
 	AppIDAttributeKey  = "app_id"
 	AppKeyAttributeKey = "app_key"
 	OIDCAttributeKey   = "client_id"
+	// LocalityAttributeKey is the action property holding the locality of the workload
+	LocalityAttributeKey = "locality"
+	// RequestSizeAttributeKey is the action property holding the size of the request in bytes
+	RequestSizeAttributeKey = "request_size"
+	// RequestHeadersAttributeKey is the action property holding the request headers checked against required_headers
+	RequestHeadersAttributeKey = "request_headers"
+	// TrustedIdentityAttributeKey is the subject property holding an application identity signed by a trusted gateway
+	TrustedIdentityAttributeKey = "trusted_identity"
 
 	// oauthTypeIdentifier refers to the name by which 3scale config described oauth OpenID connect authentication pattern
 	openIDTypeIdentifier = "oauth"
@@ -67,29 +76,93 @@ func (s *Threescale) HandleAuthorization(ctx context.Context, r *authorization.H
 	if err != nil {
 		// this theoretically should not happen
 		log.Errorf("error parsing params - %v", err)
-		result.Status = status.WithInternal(err.Error())
-		return result, err
+		result.Status = statuses.Internal(err.Error())
+		return s.decide(cfg, result, ReasonInvalidConfig, ""), err
 	}
 
+	s.warnDeprecatedParams(r.AdapterConfig.Value, cfg)
+
 	err = s.validateRequestAndConfigParams(r, cfg)
 	if err != nil {
 		// intentionally return nil as error here as failed rpc.Status is sufficient
-		result.Status = status.WithFailedPrecondition(err.Error())
-		return result, nil
+		result.Status = statuses.InvalidConfig(err.Error())
+		return s.decide(cfg, result, ReasonInvalidConfig, ""), nil
+	}
+
+	err = s.checkRequiredHeaders(cfg, r.Instance.Action)
+	if err != nil {
+		// intentionally return nil as error here as failed rpc.Status is sufficient
+		result.Status = statuses.MalformedRequest(err.Error())
+		return s.decide(cfg, result, ReasonMalformedRequest, ""), nil
 	}
 
-	proxyConf, err := s.conf.Authorizer.GetSystemConfiguration(cfg.SystemUrl, s.systemRequestFromHandlerConfig(cfg))
+	withShadowService(cfg, time.Now())
+	upstreamCtx, err := s.withHandlerAuthorizer(withCacheBypass(ctx, *r.Instance, *cfg), cfg)
 	if err != nil {
-		result.Status, err = rpcStatusErrorHandler("error fetching config from 3scale", systemErrorToRpcStatus(err), err)
-		return result, err
+		// intentionally return nil as error here as failed rpc.Status is sufficient
+		result.Status = statuses.InvalidConfig(err.Error())
+		return s.decide(cfg, result, ReasonInvalidConfig, ""), nil
+	}
+	s.preconnect(upstreamCtx, cfg, r.Instance)
+
+	timeouts, _ := parseHandlerTimeouts(cfg)
+	upstreamCtx, cancel := timeouts.withClientTimeout(upstreamCtx)
+	defer cancel()
+
+	proxyConf, proxyRules, err := s.getProxyConfig(upstreamCtx, cfg, timeouts)
+	if err != nil {
+		result.Status, err = s.rpcStatusErrorHandler("error fetching config from 3scale", systemErrorToRpcStatus(err), err)
+		reason := s.withFailOpen(cfg, result, ReasonUpstreamError)
+		if reason == ReasonFailOpen {
+			err = nil
+		}
+		return s.decide(cfg, result, reason, ""), err
+	}
+
+	engine, _ := s.ruleMatcherEngine(cfg.MappingRuleMatcher)
+	rules, err := s.rules.get(cfg.SystemUrl, cfg.ServiceId, cfg.MappingRuleMatcher, engine, proxyConf, proxyRules)
+	if err != nil {
+		log.Errorf("error decoding mapping rules of service %s - %v", cfg.ServiceId, err)
+		result.Status = statuses.Internal(err.Error())
+		return s.decide(cfg, result, ReasonUpstreamError, ""), nil
+	}
+	backendReq := s.requestFromConfig(proxyConf, rules, *r.Instance, *cfg)
+	configVersion := rules.fingerprint
+
+	if err := withTrustedIdentity(&backendReq, *r.Instance, *cfg, time.Now()); err != nil {
+		result.Status = statuses.InvalidCredentials(err.Error())
+		reason := s.withOnboarding(cfg, result, ReasonInvalidKey)
+		return s.decide(cfg, result, reason, configVersion), nil
 	}
 
-	backendReq := s.requestFromConfig(proxyConf, *r.Instance, *cfg)
 	rpcFN, err := s.validateBackendRequest(backendReq)
 	if err != nil {
 		result.Status = rpcFN(err.Error())
+		reason := s.withOnboarding(cfg, result, requestErrorToReason(err))
 		// intentionally return nil as error here as failed rpc.Status is sufficient
-		return result, nil
+		return s.decide(cfg, result, reason, configVersion), nil
+	}
+
+	if s.conf.PlanRestrictions != nil {
+		size := r.Instance.Action.Properties[RequestSizeAttributeKey].GetInt64Value()
+		rpcFN, reason, err := s.checkPlanRestrictions(cfg, r.Instance.Action.Method, size, backendReq)
+		if err != nil {
+			result.Status = rpcFN(err.Error())
+			reason = s.withOnboarding(cfg, result, reason)
+			return s.decide(cfg, result, reason, configVersion), nil
+		}
+	}
+
+	apiVersion := s.apiVersions.parse(cfg.ApiVersionPattern, normalizePath(r.Instance.Action.Path))
+	if apiVersion != "" && cfg.ApiVersionMetricSuffix {
+		for i := range backendReq.Transactions {
+			backendReq.Transactions[i].Metrics = withVersionedMetrics(backendReq.Transactions[i].Metrics, apiVersion)
+		}
+	}
+
+	locality := r.Instance.Action.Properties[LocalityAttributeKey].GetStringValue()
+	if backendURL := backendForLocality(cfg.BackendUrlByLocality, locality); backendURL != "" {
+		cfg.BackendUrl = backendURL
 	}
 
 	if cfg.BackendUrl == "" {
@@ -97,8 +170,39 @@ func (s *Threescale) HandleAuthorization(ctx context.Context, r *authorization.H
 		cfg.BackendUrl = proxyConf.Content.Proxy.Backend.Endpoint
 	}
 
-	authResult, err := s.conf.Authorizer.AuthRep(cfg.BackendUrl, backendReq)
-	return s.convertAuthResponse(authResult, result, err)
+	s.routeExtensions(cfg, cfg.BackendUrl)
+	s.validateServiceCredentials(r.AdapterConfig.Value, cfg, backendReq.Auth)
+
+	hookReq := AuthorizationRequest{Instance: r.Instance, Params: *cfg, BackendRequest: backendReq}
+	if status := s.beforeAuthorization(ctx, hookReq); status != nil {
+		result.Status = *status
+		s.afterAuthorization(ctx, hookReq, Decision{Status: result.Status, Reason: ReasonHookDenied, ConfigVersion: configVersion})
+		return s.decide(cfg, result, ReasonHookDenied, configVersion), nil
+	}
+
+	authResult, err := s.authRep(upstreamCtx, cfg.BackendUrl, backendReq, timeouts)
+	if err == nil {
+		authResult, err = s.retryWithRefreshedToken(upstreamCtx, cfg, backendReq, authResult, timeouts)
+	}
+	if err == nil && authResult != nil && authResult.Authorized {
+		// only authorized requests are counted, so the versions and applications reported cannot be inflated
+		// by arbitrary paths or credentials
+		if apiVersion != "" {
+			s.conf.MetricsReporter.apiVersionRequest(cfg.ServiceId, apiVersion)
+		}
+		for _, transaction := range backendReq.Transactions {
+			s.conf.MetricsReporter.applicationRequest(cfg.ServiceId, transaction.Params)
+		}
+	}
+
+	result, reason := s.convertAuthResponse(authResult, result, err)
+	attributes := backendExtensionAttributes(cfg, authResult)
+	s.withUpgradeHint(cfg, result, reason)
+	s.conf.ErrorBudget.Record(cfg.ServiceId, reason == ReasonUpstreamError, time.Now())
+	reason = s.withOnboarding(cfg, result, reason)
+	reason = s.withFailOpen(cfg, result, reason)
+	s.afterAuthorization(ctx, hookReq, Decision{Status: result.Status, Reason: reason, ConfigVersion: configVersion})
+	return s.decide(cfg, result, reason, configVersion, attributes...), nil
 }
 
 // parseConfigParams - parses the configuration passed to the adapter from mixer
@@ -109,9 +213,14 @@ func (s *Threescale) parseConfigParams(r *authorization.HandleAuthorizationReque
 		return nil, err
 	}
 
+	if err := s.withSessionConfig(r); err != nil {
+		return nil, err
+	}
+
 	cfg := &config.Params{}
 	if err := cfg.Unmarshal(r.AdapterConfig.Value); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal adapter config")
+		s.reportUnmarshalFailure(r)
+		return nil, errUnmarshalConfig
 	}
 
 	// Support receiving service_id as both hardcoded value in handler and at request time
@@ -122,7 +231,35 @@ func (s *Threescale) parseConfigParams(r *authorization.HandleAuthorizationReque
 	return cfg, nil
 }
 
+// reportUnmarshalFailure identifies the instance and handler whose config could not be unmarshalled, so that a single
+// malformed handler can be found. Mixer does not send the handler name, so the handler is identified by fingerprint.
+func (s *Threescale) reportUnmarshalFailure(r *authorization.HandleAuthorizationRequest) {
+	var instance string
+	if r.Instance != nil {
+		instance = r.Instance.Name
+	}
+
+	handler := handlerFingerprint(r.AdapterConfig.Value)
+	log.Errorf("failed to unmarshal adapter config of handler %s for instance %q", handler, instance)
+	s.conf.MetricsReporter.configUnmarshalFailure(instance, handler)
+}
+
 func (s *Threescale) validateRequestAndConfigParams(r *authorization.HandleAuthorizationRequest, config *config.Params) error {
+	errMsgs := s.configParamsErrors(config)
+	if config.ServiceId == "" {
+		errMsgs = append(errMsgs, errServiceID.Error())
+	}
+
+	if r.Instance.Action.Path == "" {
+		errMsgs = append(errMsgs, errRequestPath.Error())
+	}
+
+	return joinErrors(errMsgs)
+}
+
+// configParamsErrors validates the handler params which do not depend on the request. The service ID is not
+// required, since it can be provided by the request.
+func (s *Threescale) configParamsErrors(config *config.Params) []string {
 	var errMsgs []string
 	if config.AccessToken == "" {
 		errMsgs = append(errMsgs, errAccessToken.Error())
@@ -132,14 +269,53 @@ func (s *Threescale) validateRequestAndConfigParams(r *authorization.HandleAutho
 		errMsgs = append(errMsgs, errSystemURL.Error())
 	}
 
-	if config.ServiceId == "" {
-		errMsgs = append(errMsgs, errServiceID.Error())
+	if config.ApiVersionPattern != "" {
+		if _, err := s.apiVersions.compile(config.ApiVersionPattern); err != nil {
+			errMsgs = append(errMsgs, errAPIVersionPattern.Error())
+		}
+	} else if config.ApiVersionMetricSuffix {
+		errMsgs = append(errMsgs, errAPIVersionSuffix.Error())
 	}
 
-	if r.Instance.Action.Path == "" {
-		errMsgs = append(errMsgs, errRequestPath.Error())
+	if config.BackendAuthType != "" {
+		if !backendAuthTypes[config.BackendAuthType] {
+			errMsgs = append(errMsgs, errBackendAuthType.Error())
+		}
+
+		if config.BackendAuthValue == "" {
+			errMsgs = append(errMsgs, errBackendAuthValue.Error())
+		}
+	}
+
+	if config.OnboardingUntil != "" {
+		if _, err := time.Parse(time.RFC3339, config.OnboardingUntil); err != nil {
+			errMsgs = append(errMsgs, errOnboardingUntil.Error())
+		}
+	} else if config.ShadowServiceId != "" {
+		errMsgs = append(errMsgs, errShadowServiceID.Error())
+	}
+
+	if config.TrustedIdentityMaxAgeSeconds < 0 {
+		errMsgs = append(errMsgs, errTrustedIdentityAge.Error())
 	}
 
+	if _, err := s.ruleMatcherEngine(config.MappingRuleMatcher); err != nil {
+		errMsgs = append(errMsgs, err.Error())
+	}
+
+	_, timeoutErrs := parseHandlerTimeouts(config)
+	errMsgs = append(errMsgs, timeoutErrs...)
+	_, cacheErrs := parseCacheOptions(config)
+	errMsgs = append(errMsgs, cacheErrs...)
+	errMsgs = append(errMsgs, proxyURLErrors(config)...)
+	errMsgs = append(errMsgs, backendExtensionsErrors(config)...)
+
+	errMsgs = append(errMsgs, s.requiredHeadersErrors(config.RequiredHeaders)...)
+	return errMsgs
+}
+
+// joinErrors returns a single error listing each message as a sentence, or nil if there are none
+func joinErrors(errMsgs []string) error {
 	if len(errMsgs) > 0 {
 		var errMsg string
 		for _, msg := range errMsgs {
@@ -158,7 +334,7 @@ func (s *Threescale) systemRequestFromHandlerConfig(cfg *config.Params) authoriz
 	}
 }
 
-func (s *Threescale) requestFromConfig(systemConf system.ProxyConfig, istioConf authorization.InstanceMsg, cfg config.Params) authorizer.BackendRequest {
+func (s *Threescale) requestFromConfig(systemConf system.ProxyConfig, rules *mappingRules, istioConf authorization.InstanceMsg, cfg config.Params) authorizer.BackendRequest {
 	var (
 		// Application ID/OpenID Connect authentication pattern - App Key is optional when using this authn
 		appID, appKey string
@@ -179,14 +355,15 @@ func (s *Threescale) requestFromConfig(systemConf system.ProxyConfig, istioConf
 		appID = istioConf.Subject.Properties[appIdentifierKey].GetStringValue()
 		appKey = istioConf.Subject.Properties[AppKeyAttributeKey].GetStringValue()
 		userKey = istioConf.Subject.User
+
+		if appIdentifierKey == OIDCAttributeKey {
+			userKey = oidcUserKey(appID, userKey, cfg)
+		}
 	}
-	metrics := generateMetrics(istioConf.Action.Path, istioConf.Action.Method, systemConf)
+	metrics := rules.metrics(normalizePath(istioConf.Action.Path), istioConf.Action.Method)
 
 	request := authorizer.BackendRequest{
-		Auth: authorizer.BackendAuth{
-			Type:  systemConf.Content.BackendAuthenticationType,
-			Value: systemConf.Content.BackendAuthenticationValue,
-		},
+		Auth:    s.withRefreshedToken(cfg, backendAuth(systemConf, cfg)),
 		Service: cfg.ServiceId,
 		Transactions: []authorizer.BackendTransaction{
 			{
@@ -203,62 +380,55 @@ func (s *Threescale) requestFromConfig(systemConf system.ProxyConfig, istioConf
 	return request
 }
 
+// oidcUserKey returns the user key to authorize a request to a service using OpenID Connect with. The user key is only
+// used in the absence of a client_id, and only while the handler falls back to it, so that a service can be moved from
+// the API key pattern to OpenID Connect without breaking applications which have not yet obtained a token.
+func oidcUserKey(clientID string, userKey string, cfg config.Params) string {
+	if clientID != "" || !cfg.OidcUserKeyFallback {
+		return ""
+	}
+	return userKey
+}
+
 // validateBackendRequest will help us reduce network calls by verifying that required auth credentials have been set
-func (s *Threescale) validateBackendRequest(request authorizer.BackendRequest) (func(string) rpc.Status, error) {
+func (s *Threescale) validateBackendRequest(request authorizer.BackendRequest) (statuses.Constructor, error) {
 	for _, transaction := range request.Transactions {
 		if transaction.Params.AppID == "" && transaction.Params.UserKey == "" {
-			return status.WithUnauthenticated, errNoCredentials
+			return statuses.MissingCredentials, errNoCredentials
 		}
 
 		if len(transaction.Metrics) == 0 {
-			return status.WithNotFound, errNoMappingRule
+			return statuses.MappingRuleMiss, errNoMappingRule
 		}
 	}
 	return nil, nil
 }
 
-func (s *Threescale) convertAuthResponse(resp *authorizer.BackendResponse, result *v1beta1.CheckResult, err error) (*v1beta1.CheckResult, error) {
+func (s *Threescale) convertAuthResponse(resp *authorizer.BackendResponse, result *v1beta1.CheckResult, err error) (*v1beta1.CheckResult, DecisionReason) {
 	if err != nil {
 		// Try to obtain a correct mapping for the cause of failure. This will occur in events of 500+ status codes from
 		// upstream where we have not managed to get an actual response from Apisonator.
-		result.Status, _ = rpcStatusErrorHandler("request authorization failed", backendResponseToRpcStatus(resp), err)
-		return result, nil
+		respondWith := backendResponseToRpcStatus(resp)
+		if _, ok := err.(*TimeoutError); ok {
+			respondWith = statuses.FromHTTPStatus(http.StatusGatewayTimeout)
+		}
+		result.Status, _ = s.rpcStatusErrorHandler("request authorization failed", respondWith, err)
+		return result, ReasonUpstreamError
 
 	}
 	if !resp.Authorized {
-		result.Status = errorCodeToRpcStatus(resp.ErrorCode)(resp.ErrorCode)
-	} else {
-		result.Status = status.OK
+		result.Status = statuses.FromBackendErrorCode(resp.ErrorCode)(resp.ErrorCode)
+		return result, reasonFromErrorCode(resp.ErrorCode)
 	}
 
-	return result, nil
-}
-
-func generateMetrics(path string, method string, conf system.ProxyConfig) api.Metrics {
-	metrics := make(api.Metrics)
-
-	// sort proxy rules based on Position field to establish priority
-	sort.Slice(conf.Content.Proxy.ProxyRules, func(i, j int) bool {
-		return conf.Content.Proxy.ProxyRules[i].Position < conf.Content.Proxy.ProxyRules[j].Position
-	})
-
-	for _, pr := range conf.Content.Proxy.ProxyRules {
-		if match, err := regexp.MatchString(pr.Pattern, path); err == nil {
-			if match && strings.ToUpper(pr.HTTPMethod) == strings.ToUpper(method) {
-				metrics.Add(pr.MetricSystemName, int(pr.Delta))
-				// stop matching if this rule has been marked as Last
-				if pr.Last {
-					break
-				}
-			}
-		}
-	}
-	return metrics
+	result.Status = statuses.OK
+	return result, ReasonOK
 }
 
 // rpcStatusErrorHandler provides a uniform way to log and format error messages and status which should be
 // returned to the user in cases where the authorization request is rejected.
-func rpcStatusErrorHandler(userFacingErrMsg string, fn func(string) rpc.Status, err error) (rpc.Status, error) {
+// Logging is subject to sampling, since the same failure is typically repeated for every request during an outage.
+func (s *Threescale) rpcStatusErrorHandler(userFacingErrMsg string, fn statuses.Constructor, err error) (rpc.Status, error) {
 	if userFacingErrMsg != "" {
 		var errMsg string
 		if err != nil {
@@ -267,63 +437,61 @@ func rpcStatusErrorHandler(userFacingErrMsg string, fn func(string) rpc.Status,
 		err = fmt.Errorf("%s %s", userFacingErrMsg, errMsg)
 	}
 
-	log.Error(err.Error())
+	s.logSampledError(err.Error())
 	return fn(err.Error()), err
 }
 
-func systemErrorToRpcStatus(err error) func(string) rpc.Status {
-	switch e := err.(type) {
-	case system.ApiErr:
-		code, ok := httpStatusToRpcStatus[e.Code()]
-		if !ok {
-			return status.WithUnknown
-		}
-		return code
-	default:
-		return status.WithUnknown
+// logSampledError logs msg unless identical messages have been logged too often recently
+func (s *Threescale) logSampledError(msg string) {
+	if msg, ok := s.sampled(msg); ok {
+		log.Error(msg)
 	}
 }
 
-func backendResponseToRpcStatus(result *authorizer.BackendResponse) func(string) rpc.Status {
-	respondWith := status.WithUnknown
-	if result != nil && result.RawResponse != nil {
-		if val, ok := result.RawResponse.(*http.Response); ok {
-			if code, ok := httpStatusToRpcStatus[val.StatusCode]; ok {
-				respondWith = code
-			}
-		}
+// logSampledWarning is the warning level equivalent of logSampledError
+func (s *Threescale) logSampledWarning(msg string) {
+	if msg, ok := s.sampled(msg); ok {
+		log.Warn(msg)
 	}
-	return respondWith
 }
 
-func errorCodeToRpcStatus(threescaleErrorCode string) func(string) rpc.Status {
-	if threescaleErrorCode == "limits_exceeded" {
-		// return equiv of 429
-		return status.WithResourceExhausted
+// sampled reports whether msg should be logged, returning it annotated with the number of identical messages suppressed
+func (s *Threescale) sampled(msg string) (string, bool) {
+	ok, suppressed := s.conf.LogSampler.Sample(msg)
+	if !ok {
+		return "", false
 	}
 
-	switch convert.CodeToStatusCode(threescaleErrorCode) {
-	//this should never occur unless we are passed an empty reason/code by backend
-	// or backend provides us with an unmapped code
-	case 0:
-		return status.WithUnknown
-	case http.StatusConflict:
-		return status.WithPermissionDenied
+	if suppressed > 0 {
+		msg = fmt.Sprintf("%s (%d identical messages suppressed)", msg, suppressed)
+	}
+	return msg, true
+}
+
+func systemErrorToRpcStatus(err error) statuses.Constructor {
+	switch err {
+	case errConfigNotCached, context.Canceled:
+		return statuses.FromHTTPStatus(http.StatusServiceUnavailable)
+	case context.DeadlineExceeded:
+		return statuses.FromHTTPStatus(http.StatusGatewayTimeout)
+	}
+
+	switch e := err.(type) {
+	case system.ApiErr:
+		return statuses.FromHTTPStatus(e.Code())
 	default:
-		// for all other cases that have reached backend return equiv of 403
-		return status.WithPermissionDenied
+		return statuses.Unknown
 	}
 }
 
-var httpStatusToRpcStatus = map[int]func(string) rpc.Status{
-	http.StatusInternalServerError: status.WithUnknown,
-	http.StatusBadRequest:          status.WithInvalidArgument,
-	http.StatusGatewayTimeout:      status.WithDeadlineExceeded,
-	http.StatusNotFound:            status.WithNotFound,
-	http.StatusForbidden:           status.WithPermissionDenied,
-	http.StatusUnauthorized:        status.WithUnauthenticated,
-	http.StatusTooManyRequests:     status.WithResourceExhausted,
-	http.StatusServiceUnavailable:  status.WithUnavailable,
+func backendResponseToRpcStatus(result *authorizer.BackendResponse) statuses.Constructor {
+	respondWith := statuses.Unknown
+	if result != nil && result.RawResponse != nil {
+		if val, ok := result.RawResponse.(*http.Response); ok {
+			respondWith = statuses.FromHTTPStatus(val.StatusCode)
+		}
+	}
+	return respondWith
 }
 
 var (
@@ -333,6 +501,19 @@ var (
 	errRequestPath   = errors.New("request path must be provided")
 	errNoMappingRule = errors.New("no matching mapping rule for request")
 	errNoCredentials = errors.New("no auth credentials provided or provided in invalid location")
+
+	errDeprecatedConfig   = errors.New("handler configuration uses deprecated params")
+	errMethodNotPermitted = errors.New("request method not permitted by application plan")
+	errRequestTooLarge    = errors.New("request size not permitted by application plan")
+	errAPIVersionPattern  = errors.New("api_version_pattern is not a valid regular expression")
+	errAPIVersionSuffix   = errors.New("api_version_metric_suffix requires api_version_pattern")
+	errConcurrencyLimit   = errors.New("adapter is at its limit of concurrent requests")
+	errBackendAuthType    = errors.New("backend_auth_type must be one of service_token or provider_key")
+	errBackendAuthValue   = errors.New("backend_auth_type requires backend_auth_value")
+	errOnboardingUntil    = errors.New("onboarding_until must be a time in RFC 3339 format")
+	errShadowServiceID    = errors.New("shadow_service_id requires onboarding_until")
+	errTrustedIdentityAge = errors.New("trusted_identity_max_age_seconds must not be negative")
+	errUnmarshalConfig    = errors.New("failed to unmarshal adapter config")
 )
 
 // NewThreescale returns a Server interface
@@ -345,14 +526,51 @@ func NewThreescale(addr string, conf *AdapterConfig) (Server, error) {
 	s := &Threescale{
 		listener: listener,
 		conf:     conf,
+		ready:    make(chan struct{}),
+		health:   health.NewServer(),
+		stop:     make(chan struct{}),
 	}
+	s.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
 
 	log.Infof("Threescale Istio Adapter is listening on \"%v\"\n", s.Addr())
 
-	s.server = grpc.NewServer(grpc.KeepaliveParams(keepalive.ServerParameters{
-		MaxConnectionAge: conf.KeepAliveMaxAge,
-	}))
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionAge: conf.KeepAliveMaxAge,
+		}),
+	}
+
+	if conf.TLS != nil {
+		log.Infof("serving gRPC over TLS")
+		opts = append(opts, grpc.Creds(credentials.NewTLS(conf.TLS)))
+	}
+
+	var interceptors []grpc.UnaryServerInterceptor
+	if conf.MetricsReporter != nil && conf.MetricsReporter.DeadlineCB != nil {
+		interceptors = append(interceptors, conf.MetricsReporter.deadlineInterceptor)
+	}
+
+	limiter := newConcurrencyLimiter(conf.MaxConcurrentRequests, conf.MetricsReporter).withPriorities(conf.Priorities, s.requestServiceID)
+	if limiter != nil {
+		log.Infof("limiting the adapter to %d concurrent requests", conf.MaxConcurrentRequests)
+		if limiter.thresholds != nil {
+			log.Infof("shedding requests by service priority at %v requests in flight", limiter.thresholds)
+		}
+		interceptors = append(interceptors, limiter.unaryInterceptor)
+	}
+
+	if len(interceptors) > 0 {
+		opts = append(opts, grpc.UnaryInterceptor(chainUnaryInterceptors(interceptors)))
+	}
+
+	s.server = grpc.NewServer(opts...)
 	authorization.RegisterHandleAuthorizationServiceServer(s.server, s)
+	v1beta1.RegisterInfrastructureBackendServer(s.server, s)
+	healthpb.RegisterHealthServer(s.server, s.health)
+	if conf.RateLimit != nil {
+		log.Infof("serving envoy rate limit service for %d domains", len(conf.RateLimit.Domains))
+		rls.RegisterRateLimitServiceServer(s.server, s)
+	}
 	return s, nil
 }
 
@@ -361,17 +579,34 @@ func (s *Threescale) Addr() string {
 	return s.listener.Addr().String()
 }
 
-// Run starts the Threescale grpc Server
+// Run runs the startup hooks and then starts the Threescale grpc Server, reporting it as ready in between
 func (s *Threescale) Run(shutdown chan error) {
-	shutdown <- s.server.Serve(s.listener)
+	if err := s.runStartupHooks(); err != nil {
+		shutdown <- err
+		return
+	}
+
+	s.markReady()
+	go s.runHealthUpdates(s.stop)
+
+	atomic.StoreInt32(&s.serving, 1)
+	err := s.server.Serve(s.listener)
+	atomic.StoreInt32(&s.serving, 0)
+	shutdown <- err
 }
 
 // Close stops the Threescale grpc Server
 func (s *Threescale) Close() error {
+	if s.stop != nil {
+		s.stopOnce.Do(func() { close(s.stop) })
+	}
+
 	if s.server != nil {
 		s.server.GracefulStop()
 	}
 
+	s.handlerAuthorizers.shutdown()
+
 	if s.listener != nil {
 		_ = s.listener.Close()
 	}
@@ -26,7 +26,9 @@ const authenticatedSuccess = `
 		"Returns": [
 			{
 				"Check": {
-					"Status": {},
+					"Status": {
+						"message": "threescale.decision_reason=ok"
+					},
 					"ValidDuration": 0,
 					"ValidUseCount": -1
 				},
@@ -94,7 +96,7 @@ func TestAuthorizationCheck(t *testing.T) {
 				withConfig:    client.ProxyConfig{},
 				withSystemErr: nil,
 			},
-			expect: generatedExpectedError(t, rpc.FAILED_PRECONDITION, "request path must be provided."),
+			expect: generatedExpectedError(t, rpc.FAILED_PRECONDITION, "request path must be provided.", ReasonInvalidConfig),
 		},
 		{
 			name: "Test failure when no mapping rule matches incoming request",
@@ -127,7 +129,7 @@ func TestAuthorizationCheck(t *testing.T) {
 				},
 				withSystemErr: nil,
 			},
-			expect: generatedExpectedError(t, rpc.NOT_FOUND, "no matching mapping rule for request"),
+			expect: generatedExpectedError(t, rpc.NOT_FOUND, "no matching mapping rule for request", ReasonRuleMiss),
 		},
 		{
 			name: "Test failure when no access token set in handler",
@@ -173,7 +175,7 @@ spec:
     address: '[::]:3333'
   params:
     system_url: http://127.0.0.1:8090`),
-			expect: generatedExpectedError(t, rpc.FAILED_PRECONDITION, errAccessToken.Error()+"."),
+			expect: generatedExpectedError(t, rpc.FAILED_PRECONDITION, errAccessToken.Error()+".", ReasonInvalidConfig),
 		},
 		{
 			name: "Test failure when no system url set in handler",
@@ -219,7 +221,7 @@ spec:
     address: '[::]:3333'
   params:
     access_token: secret-token`),
-			expect: generatedExpectedError(t, rpc.FAILED_PRECONDITION, errSystemURL.Error()+"."),
+			expect: generatedExpectedError(t, rpc.FAILED_PRECONDITION, errSystemURL.Error()+".", ReasonInvalidConfig),
 		},
 		{
 			name: "Test failure when no service ID provided",
@@ -251,7 +253,7 @@ spec:
 					},
 				},
 			},
-			expect: generatedExpectedError(t, rpc.FAILED_PRECONDITION, errServiceID.Error()+"."),
+			expect: generatedExpectedError(t, rpc.FAILED_PRECONDITION, errServiceID.Error()+".", ReasonInvalidConfig),
 		},
 		{
 			name: "Test error when no credentials provided",
@@ -283,7 +285,7 @@ spec:
 					},
 				},
 			},
-			expect: generatedExpectedError(t, rpc.UNAUTHENTICATED, errNoCredentials.Error()),
+			expect: generatedExpectedError(t, rpc.UNAUTHENTICATED, errNoCredentials.Error(), ReasonNoCredentials),
 		},
 		{
 			name: "Test Authorization API Key via headers success",
@@ -468,7 +470,7 @@ spec:
 					ErrorCode:  "should not overwrite",
 				},
 			},
-			expect: generatedExpectedError(t, rpc.UNAUTHENTICATED, errNoCredentials.Error()),
+			expect: generatedExpectedError(t, rpc.UNAUTHENTICATED, errNoCredentials.Error(), ReasonNoCredentials),
 		},
 		{
 			name: "Test OIDC integration success",
@@ -544,7 +546,7 @@ spec:
 					ErrorCode:  "should not overwrite",
 				},
 			},
-			expect: generatedExpectedError(t, rpc.UNKNOWN, "should not overwrite"),
+			expect: generatedExpectedError(t, rpc.UNKNOWN, "should not overwrite", ReasonDenied),
 		},
 		{
 			name: "Test correct mapping of status codes for 409 from backend using error codes",
@@ -582,7 +584,7 @@ spec:
 					ErrorCode:  "application_key_invalid",
 				},
 			},
-			expect: generatedExpectedError(t, rpc.PERMISSION_DENIED, "application_key_invalid"),
+			expect: generatedExpectedError(t, rpc.PERMISSION_DENIED, "application_key_invalid", ReasonInvalidKey),
 		},
 		{
 			name: "Test rate limited request returns a resource exhausted response",
@@ -620,7 +622,7 @@ spec:
 					ErrorCode:  "limits_exceeded",
 				},
 			},
-			expect: generatedExpectedError(t, rpc.RESOURCE_EXHAUSTED, "limits_exceeded"),
+			expect: generatedExpectedError(t, rpc.RESOURCE_EXHAUSTED, "limits_exceeded", ReasonLimits),
 		},
 		{
 			name: "Test upstream unavailable produces the correct response",
@@ -661,7 +663,7 @@ spec:
 					},
 				},
 			},
-			expect: generatedExpectedError(t, rpc.UNAVAILABLE, "request authorization failed - upstream unavailable"),
+			expect: generatedExpectedError(t, rpc.UNAVAILABLE, "request authorization failed - upstream unavailable", ReasonUpstreamError),
 		},
 	}
 
@@ -713,7 +715,7 @@ spec:
 
 }
 
-func generatedExpectedError(t *testing.T, status rpc.Code, reason string) string {
+func generatedExpectedError(t *testing.T, status rpc.Code, message string, reason DecisionReason) string {
 	t.Helper()
 	return fmt.Sprintf(`
 	{
@@ -723,7 +725,7 @@ func generatedExpectedError(t *testing.T, status rpc.Code, reason string) string
 				"Check":{
 					"Status":{
 						"code":%d,
-						"message":"threescale.handler.istio-system:%s"
+						"message":"threescale.handler.istio-system:%s - %s=%s"
 					},
 					"ValidDuration": 0,
 					"ValidUseCount": -1
@@ -732,5 +734,5 @@ func generatedExpectedError(t *testing.T, status rpc.Code, reason string) string
 				"Error":null
 			}
 		]
-	}`, status, reason)
+	}`, status, message, DecisionReasonAttribute, reason)
 }
@@ -2,7 +2,14 @@ package threescale
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
+	"math/big"
 	"net/http"
 	"strings"
 	"testing"
@@ -14,6 +21,7 @@ import (
 	"github.com/gogo/googleapis/google/rpc"
 	"github.com/gogo/protobuf/types"
 
+	"istio.io/api/policy/v1beta1"
 	"istio.io/istio/mixer/template/authorization"
 )
 
@@ -220,6 +228,76 @@ func TestHandleAuthorization(t *testing.T) {
 			},
 			expectStatus: int32(rpc.OK),
 		},
+		{
+			name: "Test handler overrides backend auth advertised in proxy config",
+			params: config.Params{
+				ServiceId:        "123",
+				SystemUrl:        "https://www.fake-system.3scale.net",
+				AccessToken:      "any",
+				BackendAuthType:  "provider_key",
+				BackendAuthValue: "new-key",
+			},
+			request: &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: "get",
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "secret",
+					},
+				},
+				AdapterConfig: &types.Any{},
+			},
+			authorizer: mockAuthorizer{
+				withConfig: client.ProxyConfig{
+					Content: client.Content{
+						BackendAuthenticationType:  "service_token",
+						BackendAuthenticationValue: "old-token",
+						Proxy: client.ContentProxy{
+							ProxyRules: []client.ProxyRule{
+								{
+									HTTPMethod: http.MethodGet,
+									Pattern:    "/test",
+								},
+							},
+						},
+					},
+				},
+				withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+					if request.Auth.Type != "provider_key" || request.Auth.Value != "new-key" {
+						t.Errorf("expected backend auth to be overridden but got %s=%s", request.Auth.Type, request.Auth.Value)
+					}
+				},
+				withAuthResponse: &authorizer.BackendResponse{
+					Authorized: true,
+				},
+			},
+			expectStatus: int32(rpc.OK),
+		},
+		{
+			name: "Test fail - invalid backend auth override",
+			params: config.Params{
+				ServiceId:       "123",
+				SystemUrl:       "https://www.fake-system.3scale.net",
+				AccessToken:     "any",
+				BackendAuthType: "password",
+			},
+			request: &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: "get",
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "secret",
+					},
+				},
+				AdapterConfig: &types.Any{},
+			},
+			expectStatus:         int32(rpc.FAILED_PRECONDITION),
+			expectErrMsgContains: errBackendAuthType.Error(),
+		},
 	}
 	for _, input := range inputs {
 		t.Run(input.name, func(t *testing.T) {
@@ -250,6 +328,38 @@ func TestHandleAuthorization(t *testing.T) {
 	}
 }
 
+func TestHandleAuthorizationUnmarshalFailure(t *testing.T) {
+	malformed := []byte{0xff}
+
+	var instance, handler string
+	c := &Threescale{
+		conf: &AdapterConfig{
+			MetricsReporter: &MetricsReporter{
+				ConfigUnmarshalCB: func(i string, h string) {
+					instance, handler = i, h
+				},
+			},
+		},
+	}
+
+	result, err := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Name:    "threescale-authorization.istio-system",
+			Action:  &authorization.ActionMsg{Path: "/test"},
+			Subject: &authorization.SubjectMsg{},
+		},
+		AdapterConfig: &types.Any{Value: malformed},
+	})
+
+	if err != errUnmarshalConfig || result.Status.Code != int32(rpc.INTERNAL) {
+		t.Errorf("expected internal error for malformed config but got %d - %v", result.Status.Code, err)
+	}
+
+	if instance != "threescale-authorization.istio-system" || handler != handlerFingerprint(malformed) {
+		t.Errorf("unexpected instance %q and handler %q reported", instance, handler)
+	}
+}
+
 func Test_NewThreescale(t *testing.T) {
 	addr := "0"
 	threescaleConf := &AdapterConfig{
@@ -302,3 +412,138 @@ func (m mockAuthorizer) AuthRep(backendURL string, request authorizer.BackendReq
 }
 
 func (m mockAuthorizer) Shutdown() {}
+
+func Test_NewThreescaleTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error generating key - %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate - %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	s, err := NewThreescale("0", &AdapterConfig{
+		KeepAliveMaxAge: time.Minute,
+		TLS:             &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error creating server - %v", err)
+	}
+	defer s.Close()
+
+	shutdown := make(chan error, 1)
+	go s.Run(shutdown)
+	<-s.Ready()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	addr := strings.Replace(s.Addr(), "[::]", "localhost", 1)
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots, ServerName: "localhost", NextProtos: []string{"h2"}})
+	if err != nil {
+		t.Fatalf("expected TLS handshake with the gRPC listener to succeed - %v", err)
+	}
+	conn.Close()
+}
+
+func TestHandleAuthorizationOIDCUserKeyFallback(t *testing.T) {
+	inputs := []struct {
+		name          string
+		fallback      bool
+		clientID      string
+		userKey       string
+		expectStatus  int32
+		expectAppID   string
+		expectUserKey string
+	}{
+		{
+			name:         "Test user key is ignored without fallback",
+			userKey:      "VALID",
+			expectStatus: int32(rpc.UNAUTHENTICATED),
+		},
+		{
+			name:          "Test user key is used without client_id during fallback",
+			fallback:      true,
+			userKey:       "VALID",
+			expectStatus:  int32(rpc.OK),
+			expectUserKey: "VALID",
+		},
+		{
+			name:         "Test client_id is preferred during fallback",
+			fallback:     true,
+			clientID:     "VALID",
+			userKey:      "user",
+			expectStatus: int32(rpc.OK),
+			expectAppID:  "VALID",
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := config.Params{
+				ServiceId:           "123",
+				SystemUrl:           "https://www.fake-system.3scale.net",
+				AccessToken:         "any",
+				BackendUrl:          internalBackend,
+				OidcUserKeyFallback: input.fallback,
+			}
+			b, _ := params.Marshal()
+
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								BackendVersion: openIDTypeIdentifier,
+								Proxy: client.ContentProxy{
+									ProxyRules: []client.ProxyRule{
+										{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+									},
+								},
+							},
+						},
+						withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+							params := request.Transactions[0].Params
+							if params.AppID != input.expectAppID || params.UserKey != input.expectUserKey {
+								t.Errorf("expected app ID %q and user key %q but got %+v", input.expectAppID, input.expectUserKey, params)
+							}
+						},
+						withAuthResponse: &authorizer.BackendResponse{},
+						t:                t,
+					},
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						User: input.userKey,
+						Properties: map[string]*v1beta1.Value{
+							OIDCAttributeKey: {Value: &v1beta1.Value_StringValue{StringValue: input.clientID}},
+						},
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != input.expectStatus {
+				t.Errorf("expected status %d but got %d - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+		})
+	}
+}
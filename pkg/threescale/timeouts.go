@@ -0,0 +1,116 @@
+package threescale
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+)
+
+// handlerTimeouts are the limits the handler places on the time an authorization waits on 3scale, zero when unset
+type handlerTimeouts struct {
+	client  time.Duration
+	system  time.Duration
+	backend time.Duration
+}
+
+// parseHandlerTimeouts returns the timeouts of the handler, along with a message for each which is invalid
+func parseHandlerTimeouts(cfg *config.Params) (handlerTimeouts, []string) {
+	var t handlerTimeouts
+	var errMsgs []string
+
+	for _, timeout := range []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{
+		{"client_timeout", cfg.ClientTimeout, &t.client},
+		{"system_timeout", cfg.SystemTimeout, &t.system},
+		{"backend_timeout", cfg.BackendTimeout, &t.backend},
+	} {
+		if timeout.value == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(timeout.value)
+		if err != nil || d <= 0 {
+			errMsgs = append(errMsgs, fmt.Sprintf("%s must be a positive duration such as 500ms or 2s", timeout.name))
+			continue
+		}
+		*timeout.into = d
+	}
+	return t, errMsgs
+}
+
+// withClientTimeout returns a context bounding every call made to 3scale for the authorization
+func (t handlerTimeouts) withClientTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withOptionalTimeout(ctx, t.client)
+}
+
+// call calls fn with ctx bounded by the timeout d. When neither the client timeout nor d is set, fn is called directly,
+// since the Authorizer is only aware of the context when fetching through a ConfigCache. Otherwise fn runs in its own
+// goroutine and the error of the context is returned if it is done first, leaving fn to complete in the background,
+// bounded by the timeout of the HTTP client.
+func (t handlerTimeouts) call(ctx context.Context, d time.Duration, fn func(context.Context)) error {
+	if t.client == 0 && d == 0 {
+		fn(ctx)
+		return nil
+	}
+
+	ctx, cancel := withOptionalTimeout(ctx, d)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		fn(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func withOptionalTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// getProxyConfig fetches the proxy config for the handler within its system timeout. The health of 3scale system is
+// recorded once the fetch completes, even when the timeout has been reached first. The mapping rules may be omitted
+// from the config, and are returned by the proxyRulesFunc.
+func (s *Threescale) getProxyConfig(ctx context.Context, cfg *config.Params, t handlerTimeouts) (client.ProxyConfig, proxyRulesFunc, error) {
+	var proxyConf client.ProxyConfig
+	var rules proxyRulesFunc
+	var err error
+	fetch := func(ctx context.Context) { proxyConf, rules, err = s.getSystemConfiguration(ctx, cfg) }
+	if callErr := t.call(ctx, t.system, fetch); callErr != nil {
+		return client.ProxyConfig{}, nil, callErr
+	}
+	return proxyConf, rules, err
+}
+
+// authRep authorizes and reports the request to 3scale backend within the backend timeout of the handler. Any error
+// returned is a BackendError.
+func (s *Threescale) authRep(ctx context.Context, backendURL string, request authorizer.BackendRequest, t handlerTimeouts) (*authorizer.BackendResponse, error) {
+	var resp *authorizer.BackendResponse
+	var err error
+	authRep := func(context.Context) { resp, err = s.authorizerFor(ctx).AuthRep(backendURL, request) }
+	if callErr := t.call(ctx, t.backend, authRep); callErr != nil {
+		s.conf.MetricsReporter.backendResponse(request.Service, BackendResponseNone)
+		return nil, s.backendError(request.Service, nil, callErr)
+	}
+	s.conf.MetricsReporter.backendResponse(request.Service, backendResponseClass(resp, err))
+	if err != nil {
+		return resp, s.backendError(request.Service, resp, err)
+	}
+	return resp, nil
+}
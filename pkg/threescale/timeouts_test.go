@@ -0,0 +1,147 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// slowAuthorizer delays the responses of the wrapped mockAuthorizer
+type slowAuthorizer struct {
+	mockAuthorizer
+	systemDelay  time.Duration
+	backendDelay time.Duration
+}
+
+func (s slowAuthorizer) GetSystemConfiguration(systemURL string, request authorizer.SystemRequest) (client.ProxyConfig, error) {
+	time.Sleep(s.systemDelay)
+	return s.mockAuthorizer.GetSystemConfiguration(systemURL, request)
+}
+
+func (s slowAuthorizer) AuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	time.Sleep(s.backendDelay)
+	return s.mockAuthorizer.AuthRep(backendURL, request)
+}
+
+func TestParseHandlerTimeouts(t *testing.T) {
+	timeouts, errMsgs := parseHandlerTimeouts(&config.Params{ClientTimeout: "2s", BackendTimeout: "500ms"})
+	if len(errMsgs) != 0 {
+		t.Errorf("unexpected errors %v", errMsgs)
+	}
+
+	expect := handlerTimeouts{client: 2 * time.Second, backend: 500 * time.Millisecond}
+	if timeouts != expect {
+		t.Errorf("expected %+v but got %+v", expect, timeouts)
+	}
+
+	_, errMsgs = parseHandlerTimeouts(&config.Params{ClientTimeout: "2", SystemTimeout: "-1s", BackendTimeout: "0s"})
+	if len(errMsgs) != 3 {
+		t.Fatalf("expected an error for each invalid timeout but got %v", errMsgs)
+	}
+
+	for i, name := range []string{"client_timeout", "system_timeout", "backend_timeout"} {
+		if !strings.HasPrefix(errMsgs[i], name) {
+			t.Errorf("expected error for %s but got %q", name, errMsgs[i])
+		}
+	}
+}
+
+func TestHandleAuthorizationTimeouts(t *testing.T) {
+	inputs := []struct {
+		name         string
+		params       config.Params
+		systemDelay  time.Duration
+		backendDelay time.Duration
+		expectStatus int32
+	}{
+		{
+			name:         "Test slow responses are awaited without timeouts",
+			systemDelay:  20 * time.Millisecond,
+			backendDelay: 20 * time.Millisecond,
+			expectStatus: int32(rpc.OK),
+		},
+		{
+			name:         "Test system timeout is applied",
+			params:       config.Params{SystemTimeout: "10ms"},
+			systemDelay:  time.Second,
+			expectStatus: int32(rpc.DEADLINE_EXCEEDED),
+		},
+		{
+			name:         "Test backend timeout is applied",
+			params:       config.Params{BackendTimeout: "10ms"},
+			backendDelay: time.Second,
+			expectStatus: int32(rpc.DEADLINE_EXCEEDED),
+		},
+		{
+			name:         "Test client timeout bounds both calls",
+			params:       config.Params{ClientTimeout: "30ms", SystemTimeout: "1s", BackendTimeout: "1s"},
+			systemDelay:  20 * time.Millisecond,
+			backendDelay: 20 * time.Millisecond,
+			expectStatus: int32(rpc.DEADLINE_EXCEEDED),
+		},
+		{
+			name:         "Test invalid timeout is rejected",
+			params:       config.Params{BackendTimeout: "soon"},
+			expectStatus: int32(rpc.FAILED_PRECONDITION),
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := input.params
+			params.ServiceId = "123"
+			params.SystemUrl = "https://www.fake-system.3scale.net"
+			params.AccessToken = "any"
+			b, _ := params.Marshal()
+
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: slowAuthorizer{
+						mockAuthorizer: mockAuthorizer{
+							withConfig: client.ProxyConfig{
+								Content: client.Content{
+									Proxy: client.ContentProxy{
+										ProxyRules: []client.ProxyRule{
+											{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+										},
+									},
+								},
+							},
+							withAuthResponse: &authorizer.BackendResponse{},
+							t:                t,
+						},
+						systemDelay:  input.systemDelay,
+						backendDelay: input.backendDelay,
+					},
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "VALID",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != input.expectStatus {
+				t.Errorf("expected status %d but got %d - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package threescale
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errNoVerifiedClientCert = errors.New("no verified client certificate")
+
+// VerifyPeerSANs returns a tls.Config VerifyPeerCertificate function accepting only clients whose verified
+// certificate has a DNS or URI subject alternative name in allowed, such as the SPIFFE identity of Mixer.
+// It must be combined with tls.RequireAndVerifyClientCert, since unverified certificates are rejected.
+func VerifyPeerSANs(allowed []string) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	sans := make(map[string]bool, len(allowed))
+	for _, san := range allowed {
+		sans[san] = true
+	}
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+			return errNoVerifiedClientCert
+		}
+
+		cert := verifiedChains[0][0]
+		for _, name := range cert.DNSNames {
+			if sans[name] {
+				return nil
+			}
+		}
+
+		for _, uri := range cert.URIs {
+			if sans[uri.String()] {
+				return nil
+			}
+		}
+		return fmt.Errorf("client certificate for %q has no allowed subject alternative name", cert.Subject.CommonName)
+	}
+}
+
+// TLSPolicy restricts the protocol versions and algorithms negotiated over TLS, so that connections can meet a crypto
+// baseline such as FIPS 140-2. Zero values leave the defaults of the tls.Config the policy is applied to in place.
+type TLSPolicy struct {
+	MinVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+}
+
+// tlsCipherSuites are the cipher suites which may be configured, by their crypto/tls name. RC4 and 3DES based suites
+// are deliberately left out.
+var tlsCipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_CBC_SHA256":         tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":    tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":      tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":   tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256": tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":   tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384": tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":    tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":  tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+	"X25519": tls.X25519,
+}
+
+// ParseTLSPolicy returns the policy for a minimum version such as 1.2, and comma separated lists of cipher suites and
+// curves named as in crypto/tls, such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 and P256. Lists are in order of
+// preference, and empty values are left unset.
+func ParseTLSPolicy(minVersion, cipherSuites, curves string) (TLSPolicy, error) {
+	var policy TLSPolicy
+	if minVersion != "" {
+		version, ok := tlsVersions[strings.TrimSpace(minVersion)]
+		if !ok {
+			return TLSPolicy{}, fmt.Errorf("unsupported TLS version %q", minVersion)
+		}
+		policy.MinVersion = version
+	}
+
+	for _, name := range splitList(cipherSuites) {
+		suite, ok := tlsCipherSuites[name]
+		if !ok {
+			return TLSPolicy{}, fmt.Errorf("unsupported TLS cipher suite %q", name)
+		}
+		policy.CipherSuites = append(policy.CipherSuites, suite)
+	}
+
+	for _, name := range splitList(curves) {
+		curve, ok := tlsCurves[name]
+		if !ok {
+			return TLSPolicy{}, fmt.Errorf("unsupported TLS curve %q", name)
+		}
+		policy.CurvePreferences = append(policy.CurvePreferences, curve)
+	}
+	return policy, nil
+}
+
+// IsZero reports whether the policy leaves every default in place
+func (p TLSPolicy) IsZero() bool {
+	return p.MinVersion == 0 && len(p.CipherSuites) == 0 && len(p.CurvePreferences) == 0
+}
+
+// Apply sets the restrictions of the policy on conf, leaving settings the policy does not restrict unchanged
+func (p TLSPolicy) Apply(conf *tls.Config) {
+	if p.MinVersion != 0 {
+		conf.MinVersion = p.MinVersion
+	}
+
+	if len(p.CipherSuites) > 0 {
+		conf.CipherSuites = p.CipherSuites
+		// without this, the client would pick from the listed suites by its own preference
+		conf.PreferServerCipherSuites = true
+	}
+
+	if len(p.CurvePreferences) > 0 {
+		conf.CurvePreferences = p.CurvePreferences
+	}
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
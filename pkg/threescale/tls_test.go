@@ -0,0 +1,83 @@
+package threescale
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyPeerSANs(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/istio-system/sa/istio-mixer-service-account")
+	verify := VerifyPeerSANs([]string{"mixer.istio-system.svc", spiffe.String()})
+
+	inputs := []struct {
+		name      string
+		chains    [][]*x509.Certificate
+		expectErr bool
+	}{
+		{
+			name:   "Test allowed DNS name is accepted",
+			chains: [][]*x509.Certificate{{{DNSNames: []string{"other", "mixer.istio-system.svc"}}}},
+		},
+		{
+			name:   "Test allowed URI is accepted",
+			chains: [][]*x509.Certificate{{{URIs: []*url.URL{spiffe}}}},
+		},
+		{
+			name:      "Test certificate without allowed names is rejected",
+			chains:    [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "mixer.istio-system.svc"}, DNSNames: []string{"other"}}}},
+			expectErr: true,
+		},
+		{
+			name:      "Test unverified certificate is rejected",
+			expectErr: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			if err := verify(nil, input.chains); (err != nil) != input.expectErr {
+				t.Errorf("unexpected result %v", err)
+			}
+		})
+	}
+}
+
+func TestParseTLSPolicy(t *testing.T) {
+	policy, err := ParseTLSPolicy("1.2", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "P384,P256")
+	if err != nil {
+		t.Fatalf("unexpected error - %v", err)
+	}
+
+	expect := TLSPolicy{
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		CurvePreferences: []tls.CurveID{tls.CurveP384, tls.CurveP256},
+	}
+	if !reflect.DeepEqual(policy, expect) {
+		t.Errorf("expected %+v but got %+v", expect, policy)
+	}
+
+	conf := &tls.Config{MinVersion: tls.VersionTLS11}
+	policy.Apply(conf)
+	if conf.MinVersion != tls.VersionTLS12 || !reflect.DeepEqual(conf.CipherSuites, expect.CipherSuites) || !conf.PreferServerCipherSuites {
+		t.Errorf("policy was not applied to %+v", conf)
+	}
+
+	if policy, err := ParseTLSPolicy("", "", ""); err != nil || !policy.IsZero() {
+		t.Errorf("expected empty policy but got %+v - %v", policy, err)
+	}
+
+	for _, input := range [][]string{
+		{"1.4", "", ""},
+		{"", "TLS_RSA_WITH_RC4_128_SHA", ""},
+		{"", "", "P224"},
+	} {
+		if _, err := ParseTLSPolicy(input[0], input[1], input[2]); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
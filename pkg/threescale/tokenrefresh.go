@@ -0,0 +1,114 @@
+package threescale
+
+import (
+	"context"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+
+	"istio.io/istio/pkg/log"
+)
+
+// tokenRefreshInterval is the minimum period between refreshing the credentials of a service from 3scale system, so
+// that a token which remains invalid does not cause a fetch for every request
+const tokenRefreshInterval = time.Minute
+
+// serviceTokenInvalidCodes are the 3scale backend error codes returned when the credentials of the service are rejected
+var serviceTokenInvalidCodes = map[string]bool{
+	"service_token_invalid": true,
+	"provider_key_invalid":  true,
+}
+
+// refreshedToken holds the credentials of a service refreshed from 3scale system, along with the stale credentials
+// of the cached proxy config they replace
+type refreshedToken struct {
+	stale authorizer.BackendAuth
+	fresh authorizer.BackendAuth
+}
+
+func serviceTokenKey(cfg config.Params) string {
+	return cfg.SystemUrl + "|" + cfg.ServiceId
+}
+
+func sameBackendAuth(a, b authorizer.BackendAuth) bool {
+	return a.Type == b.Type && a.Value == b.Value
+}
+
+// withRefreshedToken returns the credentials refreshed from 3scale system in place of auth where auth is known to be
+// stale, until the cached proxy config catches up with the change
+func (s *Threescale) withRefreshedToken(cfg config.Params, auth authorizer.BackendAuth) authorizer.BackendAuth {
+	if cfg.BackendAuthValue != "" {
+		return auth
+	}
+
+	key := serviceTokenKey(cfg)
+	val, ok := s.serviceTokens.Load(key)
+	if !ok {
+		return auth
+	}
+
+	token := val.(refreshedToken)
+	if !sameBackendAuth(auth, token.stale) {
+		// the proxy config has since been refreshed
+		s.serviceTokens.Delete(key)
+		return auth
+	}
+	return token.fresh
+}
+
+// retryWithRefreshedToken handles 3scale backend rejecting the credentials of a service, which happens when its
+// service token is rotated while the old proxy config is cached. The credentials are refreshed from 3scale system,
+// bypassing the caches, and the request is retried once with them. Credentials which cannot be refreshed, or which
+// are rejected again, are reported as invalid.
+func (s *Threescale) retryWithRefreshedToken(ctx context.Context, cfg *config.Params, request authorizer.BackendRequest, resp *authorizer.BackendResponse, t handlerTimeouts) (*authorizer.BackendResponse, error) {
+	if resp == nil || resp.Authorized || !serviceTokenInvalidCodes[resp.ErrorCode] {
+		return resp, nil
+	}
+
+	// credentials set by the handler are not taken from 3scale system, so refreshing them would not help
+	if cfg.BackendAuthValue != "" || s.conf.UncachedAuthorizer == nil {
+		return resp, nil
+	}
+
+	fresh, ok := s.refreshServiceToken(cfg, request.Auth)
+	if !ok {
+		return resp, nil
+	}
+
+	request.Auth = fresh
+	retried, err := s.authRep(ctx, cfg.BackendUrl, request, t)
+	if err == nil && retried != nil && !retried.Authorized && serviceTokenInvalidCodes[retried.ErrorCode] {
+		log.Errorf("refreshed %s for service %s was also rejected by 3scale backend - requests will be denied", fresh.Type, cfg.ServiceId)
+		s.conf.MetricsReporter.invalidCredentials(cfg.ServiceId)
+	}
+	return retried, err
+}
+
+// refreshServiceToken fetches the credentials of the service from 3scale system, returning false when they could not
+// be fetched, are unchanged from stale, or were refreshed too recently to be fetched again
+func (s *Threescale) refreshServiceToken(cfg *config.Params, stale authorizer.BackendAuth) (authorizer.BackendAuth, bool) {
+	key := serviceTokenKey(*cfg)
+	if last, ok := s.tokenRefreshes.Load(key); ok && time.Since(last.(time.Time)) < tokenRefreshInterval {
+		return authorizer.BackendAuth{}, false
+	}
+	s.tokenRefreshes.Store(key, time.Now())
+
+	conf, err := s.conf.UncachedAuthorizer.GetSystemConfiguration(cfg.SystemUrl, s.systemRequestFromHandlerConfig(cfg))
+	if err != nil {
+		log.Errorf("%s for service %s rejected by 3scale backend and unable to refresh it from 3scale system - %v", stale.Type, cfg.ServiceId, err)
+		s.conf.MetricsReporter.invalidCredentials(cfg.ServiceId)
+		return authorizer.BackendAuth{}, false
+	}
+
+	fresh := backendAuth(conf, *cfg)
+	if sameBackendAuth(fresh, stale) {
+		log.Errorf("%s for service %s rejected by 3scale backend is current in 3scale system - requests will be denied", stale.Type, cfg.ServiceId)
+		s.conf.MetricsReporter.invalidCredentials(cfg.ServiceId)
+		return authorizer.BackendAuth{}, false
+	}
+
+	log.Infof("refreshed %s for service %s from 3scale system after it was rejected by 3scale backend", fresh.Type, cfg.ServiceId)
+	s.serviceTokens.Store(key, refreshedToken{stale: stale, fresh: fresh})
+	return fresh, true
+}
@@ -0,0 +1,120 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+// rotatedTokenAuthorizer rejects any service token other than token, as 3scale backend does once a token is rotated
+type rotatedTokenAuthorizer struct {
+	mockAuthorizer
+	token string
+	calls int
+}
+
+func (r *rotatedTokenAuthorizer) AuthRep(backendURL string, request authorizer.BackendRequest) (*authorizer.BackendResponse, error) {
+	r.calls++
+	if request.Auth.Value != r.token {
+		return &authorizer.BackendResponse{ErrorCode: "service_token_invalid"}, nil
+	}
+	return &authorizer.BackendResponse{Authorized: true}, nil
+}
+
+func proxyConfigWithToken(token string) client.ProxyConfig {
+	return client.ProxyConfig{
+		Content: client.Content{
+			BackendAuthenticationType:  "service_token",
+			BackendAuthenticationValue: token,
+			Proxy: client.ContentProxy{
+				Backend: client.Backend{Endpoint: internalBackend},
+				ProxyRules: []client.ProxyRule{
+					{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+				},
+			},
+		},
+	}
+}
+
+func TestHandleAuthorizationTokenRefresh(t *testing.T) {
+	params := config.Params{
+		ServiceId:   "123",
+		SystemUrl:   "https://www.fake-system.3scale.net",
+		AccessToken: "any",
+	}
+	b, _ := params.Marshal()
+
+	request := &authorization.HandleAuthorizationRequest{
+		Instance: &authorization.InstanceMsg{
+			Action:  &authorization.ActionMsg{Method: http.MethodGet, Path: "/test"},
+			Subject: &authorization.SubjectMsg{User: "VALID"},
+		},
+		AdapterConfig: &types.Any{Value: b},
+	}
+
+	inputs := []struct {
+		name         string
+		systemToken  string
+		expectOK     bool
+		expectCalls  int
+		expectAlerts int
+	}{
+		{
+			name:        "Test rotated token is refreshed from system",
+			systemToken: "rotated",
+			expectOK:    true,
+			// the rejected request is retried, and the next request uses the refreshed token
+			expectCalls: 3,
+		},
+		{
+			name:        "Test token unchanged in system is reported",
+			systemToken: "stale",
+			// neither request is retried, and the second does not refresh the token again so soon after the first
+			expectCalls:  2,
+			expectAlerts: 1,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var alerts int
+			backend := &rotatedTokenAuthorizer{
+				mockAuthorizer: mockAuthorizer{withConfig: proxyConfigWithToken("stale"), t: t},
+				token:          "rotated",
+			}
+
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer:         backend,
+					UncachedAuthorizer: mockAuthorizer{withConfig: proxyConfigWithToken(input.systemToken), t: t},
+					MetricsReporter: &MetricsReporter{
+						InvalidCredentialsCB: func(serviceID string) { alerts++ },
+					},
+				},
+			}
+
+			for i := 0; i < 2; i++ {
+				result, _ := c.HandleAuthorization(context.TODO(), request)
+				if authorized := result.Status.Code == int32(rpc.OK); authorized != input.expectOK {
+					t.Errorf("expected authorized to be %t but got %v", input.expectOK, result.Status)
+				}
+			}
+
+			if backend.calls != input.expectCalls {
+				t.Errorf("expected %d calls to backend but got %d", input.expectCalls, backend.calls)
+			}
+
+			if alerts != input.expectAlerts {
+				t.Errorf("expected %d alerts but got %d", input.expectAlerts, alerts)
+			}
+		})
+	}
+}
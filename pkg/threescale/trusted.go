@@ -0,0 +1,119 @@
+package threescale
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/secrets"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+const (
+	// defaultTrustedIdentityMaxAge is the age after which a signed identity is rejected when the handler does not set one
+	defaultTrustedIdentityMaxAge = 5 * time.Minute
+	// userKeyCredentialType identifies a trusted identity holding a user key
+	userKeyCredentialType = "user_key"
+)
+
+var (
+	errTrustedIdentity        = errors.New("trusted identity is malformed or its signature is invalid")
+	errTrustedIdentityExpired = errors.New("trusted identity has expired")
+)
+
+// SignTrustedIdentity returns the value a trusted gateway provides in the trusted_identity subject property to vouch
+// for an application it has already authenticated. It takes the form <type>:<value>:<timestamp>:<signature>, where
+// type is one of app_id or user_key, timestamp is the signing time in seconds since the Unix epoch, and signature
+// is the hex encoded HMAC-SHA256 of the preceding fields, keyed with the secret shared with the adapter.
+func SignTrustedIdentity(secret string, credentialType string, value string, at time.Time) string {
+	payload := strings.Join([]string{credentialType, value, strconv.FormatInt(at.Unix(), 10)}, ":")
+	return payload + ":" + trustedIdentitySignature(secret, payload)
+}
+
+func trustedIdentitySignature(secret string, payload string) string {
+	key, data := []byte(secret), []byte(payload)
+	defer secrets.Zero(key)
+	defer secrets.Zero(data)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseTrustedIdentity verifies the signature and age of a trusted identity, returning the application it identifies
+func parseTrustedIdentity(secret string, maxAge time.Duration, identity string, now time.Time) (authorizer.BackendParams, error) {
+	var params authorizer.BackendParams
+
+	// the signature and timestamp are split from the right, so the value may itself contain separators
+	i := strings.LastIndex(identity, ":")
+	if i < 0 {
+		return params, errTrustedIdentity
+	}
+	payload, signature := identity[:i], identity[i+1:]
+
+	if !hmac.Equal([]byte(signature), []byte(trustedIdentitySignature(secret, payload))) {
+		return params, errTrustedIdentity
+	}
+
+	first, last := strings.Index(payload, ":"), strings.LastIndex(payload, ":")
+	if first < 0 || first == last {
+		return params, errTrustedIdentity
+	}
+	credentialType, value, timestamp := payload[:first], payload[first+1:last], payload[last+1:]
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || value == "" {
+		return params, errTrustedIdentity
+	}
+
+	if age := now.Sub(time.Unix(signedAt, 0)); age > maxAge || age < -maxAge {
+		return params, errTrustedIdentityExpired
+	}
+
+	switch credentialType {
+	case AppIDAttributeKey:
+		params.AppID = value
+	case userKeyCredentialType:
+		params.UserKey = value
+	default:
+		return params, errTrustedIdentity
+	}
+	return params, nil
+}
+
+// withTrustedIdentity replaces the credentials of the request with the identity provided by a trusted gateway, when
+// the handler configures a secret and the instance provides an identity. The credentials presented by the client are
+// not checked again, so 3scale backend only identifies the application, applying its limits and reporting its usage.
+// An error is returned if the identity cannot be verified, in which case the request must be denied.
+func withTrustedIdentity(request *authorizer.BackendRequest, instance authorization.InstanceMsg, cfg config.Params, now time.Time) error {
+	if cfg.TrustedIdentitySecret == "" || instance.Subject == nil {
+		return nil
+	}
+
+	identity := instance.Subject.Properties[TrustedIdentityAttributeKey].GetStringValue()
+	if identity == "" {
+		return nil
+	}
+
+	maxAge := defaultTrustedIdentityMaxAge
+	if cfg.TrustedIdentityMaxAgeSeconds > 0 {
+		maxAge = time.Duration(cfg.TrustedIdentityMaxAgeSeconds) * time.Second
+	}
+
+	params, err := parseTrustedIdentity(cfg.TrustedIdentitySecret, maxAge, identity, now)
+	if err != nil {
+		return err
+	}
+
+	for i := range request.Transactions {
+		request.Transactions[i].Params = params
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/policy/v1beta1"
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestParseTrustedIdentity(t *testing.T) {
+	const secret = "shared"
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	inputs := []struct {
+		name      string
+		identity  string
+		expect    authorizer.BackendParams
+		expectErr error
+	}{
+		{
+			name:     "Test valid app ID",
+			identity: SignTrustedIdentity(secret, "app_id", "abc", now),
+			expect:   authorizer.BackendParams{AppID: "abc"},
+		},
+		{
+			name:     "Test valid user key containing a separator",
+			identity: SignTrustedIdentity(secret, "user_key", "a:b", now.Add(-time.Minute)),
+			expect:   authorizer.BackendParams{UserKey: "a:b"},
+		},
+		{
+			name:      "Test signature with another secret",
+			identity:  SignTrustedIdentity("other", "app_id", "abc", now),
+			expectErr: errTrustedIdentity,
+		},
+		{
+			name:      "Test tampered value",
+			identity:  "app_id:xyz" + SignTrustedIdentity(secret, "app_id", "abc", now)[len("app_id:abc"):],
+			expectErr: errTrustedIdentity,
+		},
+		{
+			name:      "Test expired identity",
+			identity:  SignTrustedIdentity(secret, "app_id", "abc", now.Add(-time.Hour)),
+			expectErr: errTrustedIdentityExpired,
+		},
+		{
+			name:      "Test unsupported credential type",
+			identity:  SignTrustedIdentity(secret, "app_key", "abc", now),
+			expectErr: errTrustedIdentity,
+		},
+		{
+			name:      "Test empty value",
+			identity:  SignTrustedIdentity(secret, "app_id", "", now),
+			expectErr: errTrustedIdentity,
+		},
+		{
+			name:      "Test malformed identity",
+			identity:  "abc",
+			expectErr: errTrustedIdentity,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params, err := parseTrustedIdentity(secret, defaultTrustedIdentityMaxAge, input.identity, now)
+			if err != input.expectErr {
+				t.Fatalf("unexpected error, wanted %v but got %v", input.expectErr, err)
+			}
+
+			if params != input.expect {
+				t.Errorf("unexpected params, wanted %+v but got %+v", input.expect, params)
+			}
+		})
+	}
+}
+
+func TestHandleAuthorizationTrustedIdentity(t *testing.T) {
+	const secret = "shared"
+
+	inputs := []struct {
+		name          string
+		identity      string
+		expectStatus  int32
+		expectParams  authorizer.BackendParams
+		expectAuthRep bool
+	}{
+		{
+			name:          "Test signed identity replaces client credentials",
+			identity:      SignTrustedIdentity(secret, "app_id", "VALID", time.Now()),
+			expectStatus:  int32(rpc.OK),
+			expectParams:  authorizer.BackendParams{AppID: "VALID"},
+			expectAuthRep: true,
+		},
+		{
+			name:         "Test invalid signature is denied",
+			identity:     SignTrustedIdentity("other", "app_id", "VALID", time.Now()),
+			expectStatus: int32(rpc.PERMISSION_DENIED),
+		},
+		{
+			name:          "Test client credentials are used without an identity",
+			expectStatus:  int32(rpc.PERMISSION_DENIED),
+			expectParams:  authorizer.BackendParams{UserKey: "secret", AppKey: "key"},
+			expectAuthRep: true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := config.Params{
+				ServiceId:             "123",
+				SystemUrl:             "https://www.fake-system.3scale.net",
+				AccessToken:           "any",
+				BackendUrl:            internalBackend,
+				TrustedIdentitySecret: secret,
+			}
+			b, _ := params.Marshal()
+
+			var calledAuthRep bool
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								Proxy: client.ContentProxy{
+									ProxyRules: []client.ProxyRule{
+										{HTTPMethod: http.MethodGet, Pattern: "/test", MetricSystemName: "hits", Delta: 1},
+									},
+								},
+							},
+						},
+						withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+							calledAuthRep = true
+							if got := request.Transactions[0].Params; got != input.expectParams {
+								t.Errorf("unexpected credentials sent to backend, wanted %+v but got %+v", input.expectParams, got)
+							}
+						},
+						withAuthResponse: &authorizer.BackendResponse{ErrorCode: "user_key_invalid"},
+						t:                t,
+					},
+				},
+			}
+
+			properties := map[string]*v1beta1.Value{
+				AppKeyAttributeKey: {Value: &v1beta1.Value_StringValue{StringValue: "key"}},
+			}
+			if input.identity != "" {
+				properties[TrustedIdentityAttributeKey] = &v1beta1.Value{Value: &v1beta1.Value_StringValue{StringValue: input.identity}}
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						User:       "secret",
+						Properties: properties,
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != input.expectStatus {
+				t.Errorf("expected status %d but got %d - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+
+			if calledAuthRep != input.expectAuthRep {
+				t.Errorf("unexpected call to backend, wanted %v but got %v", input.expectAuthRep, calledAuthRep)
+			}
+		})
+	}
+}
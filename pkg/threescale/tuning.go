@@ -0,0 +1,156 @@
+package threescale
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/statuses"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+)
+
+// Tuning holds the options operators can adjust at runtime via the admin endpoints, so that they can react to an
+// incident without editing resources and waiting for the change to propagate. Changes last until the adapter restarts.
+type Tuning struct {
+	mutex    sync.RWMutex
+	failOpen bool
+	// cache is nil unless the config cache is owned by the adapter rather than the authorizer
+	cache *CompressedConfigCache
+}
+
+// TuningConfig describes the options currently applied
+type TuningConfig struct {
+	// CacheTTLSeconds and CacheEntriesMax are only present when the config cache can be tuned
+	CacheTTLSeconds *int `json:"cache_ttl_seconds,omitempty"`
+	CacheEntriesMax *int `json:"cache_entries_max,omitempty"`
+	// FailOpen allows requests which could not be authorized due to errors reaching 3scale
+	FailOpen bool `json:"fail_open"`
+}
+
+// NewTuning returns a Tuning for the provided cache, which may be nil if the config cache cannot be tuned
+func NewTuning(cache *CompressedConfigCache) *Tuning {
+	return &Tuning{cache: cache}
+}
+
+// FailOpen reports whether requests failing due to errors reaching 3scale should be allowed. A nil Tuning fails closed.
+func (t *Tuning) FailOpen() bool {
+	if t == nil {
+		return false
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.failOpen
+}
+
+// SetFailOpen changes whether requests failing due to errors reaching 3scale are allowed
+func (t *Tuning) SetFailOpen(failOpen bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.failOpen = failOpen
+}
+
+// Config returns the options currently applied
+func (t *Tuning) Config() TuningConfig {
+	conf := TuningConfig{FailOpen: t.FailOpen()}
+
+	if t.cache != nil {
+		ttl, max := t.cache.Config()
+		ttlSeconds := int(ttl / time.Second)
+		conf.CacheTTLSeconds, conf.CacheEntriesMax = &ttlSeconds, &max
+	}
+	return conf
+}
+
+// ServeHTTP responds with the options currently applied and, for PUT requests, applies the "cache_ttl_seconds",
+// "cache_entries_max" and "fail_open" query parameters beforehand. Changes to the cache are rejected with a
+// 409 when the config cache is owned by the authorizer. It does not authenticate clients, so must only accept changes
+// where the caller does, see admin.ReadOnly.
+func (t *Tuning) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if status, err := t.apply(r); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t.Config())
+}
+
+// apply validates every query parameter before changing anything, so that a bad request has no effect
+func (t *Tuning) apply(r *http.Request) (int, error) {
+	query := r.URL.Query()
+	conf := t.Config()
+
+	var err error
+	if v := query.Get("fail_open"); v != "" {
+		if conf.FailOpen, err = strconv.ParseBool(v); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid value for fail_open")
+		}
+	}
+
+	cacheParams := map[string]**int{
+		"cache_ttl_seconds": &conf.CacheTTLSeconds,
+		"cache_entries_max": &conf.CacheEntriesMax,
+	}
+
+	var cacheChanged bool
+	for name, field := range cacheParams {
+		v := query.Get(name)
+		if v == "" {
+			continue
+		}
+
+		if t.cache == nil {
+			return http.StatusConflict, fmt.Errorf("%s can only be tuned when cache compression is enabled", name)
+		}
+
+		val, err := strconv.Atoi(v)
+		if err != nil || val < 0 {
+			return http.StatusBadRequest, fmt.Errorf("invalid value for %s", name)
+		}
+		*field = &val
+		cacheChanged = true
+	}
+
+	if cacheChanged {
+		t.cache.Configure(time.Duration(*conf.CacheTTLSeconds)*time.Second, *conf.CacheEntriesMax)
+	}
+	t.SetFailOpen(conf.FailOpen)
+	return http.StatusOK, nil
+}
+
+// withFailOpen allows a request which could not be authorized due to an error reaching 3scale while failing open
+// has been enabled by its handler or at runtime, while its service has exceeded its error budget, or within the startup
+// grace window. The error is logged and counted and the reason returned is ReasonFailOpen. Otherwise the result and
+// reason are left unchanged.
+func (s *Threescale) withFailOpen(cfg *config.Params, result *v1beta1.CheckResult, reason DecisionReason) DecisionReason {
+	if reason != ReasonUpstreamError || !(cfg.FailOpen || s.conf.Tuning.FailOpen() || s.conf.ErrorBudget.FailOpen(cfg.ServiceId) ||
+		s.inStartupGrace(time.Now())) {
+		return reason
+	}
+
+	s.logSampledWarning(fmt.Sprintf("allowing request for service %s which failed with reason %s while failing open",
+		cfg.ServiceId, reason))
+	s.conf.MetricsReporter.failOpen(cfg.ServiceId)
+
+	message := fmt.Sprintf("would deny with reason %s", reason)
+	if result.Status.Message != "" {
+		message = fmt.Sprintf("%s - %s", message, result.Status.Message)
+	}
+
+	result.Status = statuses.OK
+	result.Status.Message = message
+	return ReasonFailOpen
+}
@@ -0,0 +1,263 @@
+package threescale
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestTuningServeHTTP(t *testing.T) {
+	inputs := []struct {
+		name         string
+		withCache    bool
+		method       string
+		query        string
+		expectStatus int
+		expectTTL    int
+		expectMax    int
+		expectOpen   bool
+	}{
+		{
+			name:         "Test current options are served",
+			withCache:    true,
+			method:       http.MethodGet,
+			query:        "fail_open=true",
+			expectStatus: http.StatusOK,
+			expectTTL:    300,
+			expectMax:    1000,
+		},
+		{
+			name:         "Test options are applied",
+			withCache:    true,
+			method:       http.MethodPut,
+			query:        "cache_ttl_seconds=30&cache_entries_max=50&fail_open=true",
+			expectStatus: http.StatusOK,
+			expectTTL:    30,
+			expectMax:    50,
+			expectOpen:   true,
+		},
+		{
+			name:         "Test invalid value changes nothing",
+			withCache:    true,
+			method:       http.MethodPut,
+			query:        "cache_ttl_seconds=30&cache_entries_max=-1",
+			expectStatus: http.StatusBadRequest,
+			expectTTL:    300,
+			expectMax:    1000,
+		},
+		{
+			name:         "Test fail open can be applied without a cache",
+			method:       http.MethodPut,
+			query:        "fail_open=true",
+			expectStatus: http.StatusOK,
+			expectOpen:   true,
+		},
+		{
+			name:         "Test cache cannot be tuned when owned by the authorizer",
+			method:       http.MethodPut,
+			query:        "cache_ttl_seconds=30&fail_open=true",
+			expectStatus: http.StatusConflict,
+		},
+		{
+			name:         "Test unsupported method",
+			method:       http.MethodPost,
+			expectStatus: http.StatusMethodNotAllowed,
+			expectTTL:    300,
+			expectMax:    1000,
+			withCache:    true,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			var cache *CompressedConfigCache
+			if input.withCache {
+				cache = NewCompressedConfigCache(&configAuthorizer{}, 300*time.Second, 1000)
+			}
+			tuning := NewTuning(cache)
+
+			rec := httptest.NewRecorder()
+			tuning.ServeHTTP(rec, httptest.NewRequest(input.method, "/tuning?"+input.query, nil))
+
+			if rec.Code != input.expectStatus {
+				t.Fatalf("unexpected status, wanted %d but got %d - %s", input.expectStatus, rec.Code, rec.Body.String())
+			}
+
+			conf := tuning.Config()
+			if conf.FailOpen != input.expectOpen {
+				t.Errorf("unexpected fail open %v", conf.FailOpen)
+			}
+
+			if !input.withCache {
+				if conf.CacheTTLSeconds != nil || conf.CacheEntriesMax != nil {
+					t.Errorf("expected cache options to be omitted without a cache")
+				}
+				return
+			}
+
+			if *conf.CacheTTLSeconds != input.expectTTL || *conf.CacheEntriesMax != input.expectMax {
+				t.Errorf("unexpected cache options, ttl %d and max %d", *conf.CacheTTLSeconds, *conf.CacheEntriesMax)
+			}
+
+			if rec.Code == http.StatusOK {
+				var served TuningConfig
+				if err := json.NewDecoder(rec.Body).Decode(&served); err != nil || *served.CacheTTLSeconds != input.expectTTL {
+					t.Errorf("expected applied options to be served")
+				}
+			}
+		})
+	}
+}
+
+func TestHandleAuthorizationFailOpen(t *testing.T) {
+	inputs := []struct {
+		name         string
+		failOpen     bool
+		budgetOpen   bool
+		handlerOpen  bool
+		systemErr    error
+		backendErr   error
+		expectStatus int32
+		expectReason DecisionReason
+	}{
+		{
+			name:         "Test system error is allowed while failing open",
+			failOpen:     true,
+			systemErr:    errors.New("unreachable"),
+			expectStatus: int32(rpc.OK),
+			expectReason: ReasonFailOpen,
+		},
+		{
+			name:         "Test backend error is allowed while failing open",
+			failOpen:     true,
+			backendErr:   errors.New("unreachable"),
+			expectStatus: int32(rpc.OK),
+			expectReason: ReasonFailOpen,
+		},
+		{
+			name:         "Test backend error is allowed while the service exceeds its error budget",
+			budgetOpen:   true,
+			backendErr:   errors.New("unreachable"),
+			expectStatus: int32(rpc.OK),
+			expectReason: ReasonFailOpen,
+		},
+		{
+			name:         "Test system error is allowed when the handler fails open",
+			handlerOpen:  true,
+			systemErr:    errors.New("unreachable"),
+			expectStatus: int32(rpc.OK),
+			expectReason: ReasonFailOpen,
+		},
+		{
+			name:         "Test backend error is allowed when the handler fails open",
+			handlerOpen:  true,
+			backendErr:   errors.New("unreachable"),
+			expectStatus: int32(rpc.OK),
+			expectReason: ReasonFailOpen,
+		},
+		{
+			name:         "Test backend error is denied by default",
+			backendErr:   errors.New("unreachable"),
+			expectStatus: int32(rpc.UNKNOWN),
+			expectReason: ReasonUpstreamError,
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := config.Params{
+				ServiceId:   "123",
+				SystemUrl:   "https://www.fake-system.3scale.net",
+				AccessToken: "any",
+				BackendUrl:  internalBackend,
+				FailOpen:    input.handlerOpen,
+			}
+			b, _ := params.Marshal()
+
+			var failedOpen int
+			var reason DecisionReason
+			tuning := NewTuning(nil)
+			tuning.SetFailOpen(input.failOpen)
+
+			budget := NewErrorBudget(ErrorBudgetConfig{Threshold: 0.5, MinRequests: 1})
+			if input.budgetOpen {
+				now := time.Now()
+				budget.Record("123", true, now.Add(-DefaultErrorBudgetWindow))
+				budget.Record("123", true, now)
+			}
+
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withSystemErr:  input.systemErr,
+						withBackendErr: input.backendErr,
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								Proxy: client.ContentProxy{
+									ProxyRules: []client.ProxyRule{
+										{HTTPMethod: http.MethodGet, Pattern: "/test", MetricSystemName: "hits", Delta: 1},
+									},
+								},
+							},
+						},
+						withAuthResponse: &authorizer.BackendResponse{},
+						t:                t,
+					},
+					Tuning:      tuning,
+					ErrorBudget: budget,
+					MetricsReporter: recordReason(&MetricsReporter{
+						FailOpenCB: func(serviceID string) {
+							failedOpen++
+						},
+					}, &reason),
+				},
+			}
+
+			result, err := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "secret",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if (input.failOpen || input.budgetOpen || input.handlerOpen) && err != nil {
+				t.Errorf("unexpected error while failing open - %v", err)
+			}
+
+			var expectFailedOpen int
+			if input.expectReason == ReasonFailOpen {
+				expectFailedOpen = 1
+			}
+
+			if failedOpen != expectFailedOpen {
+				t.Errorf("expected %d requests counted as failing open but got %d", expectFailedOpen, failedOpen)
+			}
+
+			if result.Status.Code != input.expectStatus {
+				t.Errorf("expected status %d but got %d - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+
+			if reason != input.expectReason {
+				t.Errorf("expected decision reason %s but got %s", input.expectReason, reason)
+			}
+		})
+	}
+}
@@ -1,13 +1,18 @@
 package threescale
 
 import (
+	"crypto/tls"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/3scale/3scale-porta-go-client/client"
 
 	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/pkg/logging"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 // Server interface - specifies the interface for gRPC server/adapter
@@ -15,6 +20,14 @@ type Server interface {
 	Addr() string
 	Close() error
 	Run(shutdown chan error)
+	// Ready is closed once the server has started and accepts requests
+	Ready() <-chan struct{}
+	// Serving returns an error unless the gRPC server is serving
+	Serving() error
+	// ConfigReady returns an error while no proxy config can be fetched from 3scale system
+	ConfigReady() error
+	// ConfigVersions lists the proxy config whose mapping rules currently evaluate the requests of each service
+	ConfigVersions() []ConfigVersion
 }
 
 // Threescale contains the Listener and the server
@@ -22,6 +35,40 @@ type Threescale struct {
 	listener net.Listener
 	server   *grpc.Server
 	conf     *AdapterConfig
+	// handlerAuthorizers holds the Authorizer of each handler client built by the HandlerAuthorizers builder
+	handlerAuthorizers handlerAuthorizers
+	// deprecationsSeen tracks the handlers which have already been warned about deprecated params
+	deprecationsSeen handlerSet
+	// rules holds the compiled mapping rules for each service
+	rules ruleCache
+	// apiVersions holds the compiled api_version_pattern for each handler
+	apiVersions regexpCache
+	// headerPatterns holds the compiled required_headers patterns of each handler
+	headerPatterns regexpCache
+	// credentialsChecked tracks, per handler and service, when the service credentials should next be validated
+	credentialsChecked sync.Map
+	// serviceTokens holds, per system URL and service, the credentials refreshed from 3scale system after backend
+	// rejected those of the cached proxy config
+	serviceTokens sync.Map
+	// tokenRefreshes tracks, per system URL and service, when the credentials were last refreshed
+	tokenRefreshes sync.Map
+	// sessions holds the handler config for each session created by Mixer, by session ID
+	sessions sync.Map
+	// ready is closed once the startup hooks have completed, at readyAt
+	ready   chan struct{}
+	readyAt time.Time
+	// graceEnded is set to 1 once the startup grace window has ended
+	graceEnded int32
+	// health serves the gRPC health checking protocol, with the status last set by updateHealth
+	health       *health.Server
+	healthMutex  sync.Mutex
+	healthStatus healthpb.HealthCheckResponse_ServingStatus
+	systemHealth systemHealth
+	// serving is set to 1 while the gRPC server is serving
+	serving int32
+	// stop is closed by Close to stop background work
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 type Authorizer interface {
@@ -35,4 +82,203 @@ type AdapterConfig struct {
 	Authorizer Authorizer
 	//gRPC connection keepalive duration
 	KeepAliveMaxAge time.Duration
+	// TLS is optional and, when set, serves the gRPC listener over TLS rather than plaintext
+	TLS *tls.Config
+	// StrictConfig rejects handler config relying on deprecated params when Mixer validates it
+	StrictConfig bool
+	// SessionBased accepts sessions created by Mixer for adapter resources with session_based set, after which
+	// requests carry the session ID rather than the handler params
+	SessionBased bool
+	// MetricsReporter is optional and receives the adapters own metrics
+	MetricsReporter *MetricsReporter
+	// PlanRestrictions is optional and, when set, denies requests using HTTP methods or sizes not permitted by the application plan
+	PlanRestrictions PlanRestrictions
+	// UpgradeHints is optional and, when set, points API consumers exceeding their limits to where they can upgrade
+	UpgradeHints UpgradeHints
+	// LogSampler is optional and limits how often identical errors are logged
+	LogSampler *logging.Sampler
+	// CredentialsValidator is optional and, when set, validates the service credentials the first time each handler is used
+	CredentialsValidator CredentialsValidator
+	// DecisionHooks are optional and called before and after each request is authorized by 3scale
+	DecisionHooks []DecisionHook
+	// MaxConcurrentRequests rejects requests beyond this number in flight with RESOURCE_EXHAUSTED. Zero is unlimited
+	MaxConcurrentRequests int
+	// Priorities is optional and, when set with MaxConcurrentRequests, sheds the requests of low priority services first
+	Priorities *PriorityConfig
+	// ConfigStrategies is optional and determines, per priority class, how requests are handled when the proxy config
+	// of their service is expired or missing. It applies when the Authorizer is a ConfigCache, and defaults to
+	// ConfigWaitForFresh for unlisted classes.
+	ConfigStrategies map[PriorityClass]ConfigStrategy
+	// Tuning is optional and holds the options adjusted at runtime via the admin endpoints
+	Tuning *Tuning
+	// ErrorBudget is optional and, when set, fails open services whose requests to 3scale backend keep failing
+	ErrorBudget *ErrorBudget
+	// StartupHooks are optional and run in order when the server is run, before it is reported as ready
+	StartupHooks []StartupHook
+	// Preconnector is optional and, when set, is called with the 3scale endpoints named by the handler of each request
+	Preconnector Preconnector
+	// HandlerAuthorizers is optional and, when set, builds the Authorizer of handlers which send their requests to
+	// 3scale through their own proxies or skip TLS verification. Requests of such handlers are rejected when it is not set.
+	HandlerAuthorizers HandlerAuthorizerBuilder
+	// ExtensionsRouter is optional and, when set, is called with the 3scale backend endpoints of each service and the
+	// backend extensions its handler enables
+	ExtensionsRouter ExtensionsRouter
+	// UncachedAuthorizer is optional and, when set, is used in place of Authorizer for requests which present the cache
+	// bypass token of their handler. It must not cache proxy configs or authorizations.
+	UncachedAuthorizer Authorizer
+	// RuleMatcherEngines are optional engines, by name, which handlers can select with mapping_rule_matcher in addition
+	// to the regex and prefix engines
+	RuleMatcherEngines map[string]RuleMatcherEngine
+	// RateLimit is optional and, when set, serves Envoy's rate limit service alongside the Mixer adapter
+	RateLimit *RateLimitConfig
+	// HealthCheck is optional and, when it returns an error, reports the adapter as not serving to gRPC health checks
+	HealthCheck func() error
+	// StartupGrace is optional and, when set, fails open for a period after the adapter starts
+	StartupGrace *StartupGrace
+}
+
+// MetricsReporter wraps the callbacks which report metrics about the behaviour of the adapter itself
+type MetricsReporter struct {
+	// DeprecatedConfigCB is called when a handler is first seen using a deprecated field
+	DeprecatedConfigCB func(field string)
+	// APIVersionCB is called for each authorized request for which an API version was identified
+	APIVersionCB func(serviceID string, version string)
+	// ConcurrencyLimitedCB is called when a request is rejected due to the concurrency limit
+	ConcurrencyLimitedCB func()
+	// InvalidCredentialsCB is called when backend rejects the credentials configured for a service
+	InvalidCredentialsCB func(serviceID string)
+	// OnboardingCB is called for each request allowed while its service is onboarding which would otherwise be denied
+	OnboardingCB func(serviceID string, reason string)
+	// FailOpenCB is called for each request allowed despite an error reaching 3scale, since it is failing open
+	FailOpenCB func(serviceID string)
+	// ConfigUnmarshalCB is called for each request whose handler config cannot be unmarshalled, with the name of the
+	// instance and a fingerprint of the raw handler config
+	ConfigUnmarshalCB func(instance string, handler string)
+	// ApplicationCB is called for each authorized request with a hash of the credentials identifying the application.
+	// Raw credentials are never passed to the callback.
+	ApplicationCB func(serviceID string, application string)
+	// ApplicationSalt is combined with the credentials when hashing, so that the hashes cannot be reversed by
+	// hashing known or guessed credentials. Replicas sharing a salt report the same hash for an application.
+	ApplicationSalt string
+	// DeadlineCB is called for each gRPC request with a deadline, with the name of the method, the time remaining until
+	// the deadline when the request arrived, and the time taken to handle it
+	DeadlineCB func(method string, remaining time.Duration, consumed time.Duration)
+	// BackendErrorCB is called for each request which could not be authorized by 3scale backend due to an error,
+	// with the class of the BackendError
+	BackendErrorCB func(serviceID string, class string)
+	// ConfigCacheHitCB is called by the CompressedConfigCache for each request served a cached config, with the time
+	// since the config was fetched
+	ConfigCacheHitCB func(age time.Duration)
+	// ConfigCacheMissCB is called by the CompressedConfigCache for each request without a config it could serve
+	ConfigCacheMissCB func()
+	// ConfigFetchFailedCB is called by the CompressedConfigCache for each fetch, including background refreshes, which
+	// failed once retried
+	ConfigFetchFailedCB func(serviceID string)
+	// ConfigEvictedCB is called by the CompressedConfigCache for each config evicted to make room for another
+	ConfigEvictedCB func()
+	// ConfigCacheEntriesCB is called by the CompressedConfigCache with the number of configs held, whenever it changes
+	ConfigCacheEntriesCB func(entries int)
+	// DecisionCB is called for each authorization decision with the service, whether the request was allowed and why
+	DecisionCB func(serviceID string, allowed bool, reason string)
+	// BackendResponseCB is called for each request sent to 3scale backend to authorize with the class of its response,
+	// one of the BackendResponse classes
+	BackendResponseCB func(serviceID string, class string)
+}
+
+func (m *MetricsReporter) deprecatedConfig(field string) {
+	if m != nil && m.DeprecatedConfigCB != nil {
+		m.DeprecatedConfigCB(field)
+	}
+}
+
+func (m *MetricsReporter) apiVersionRequest(serviceID string, version string) {
+	if m != nil && m.APIVersionCB != nil {
+		m.APIVersionCB(serviceID, version)
+	}
+}
+
+func (m *MetricsReporter) concurrencyLimited() {
+	if m != nil && m.ConcurrencyLimitedCB != nil {
+		m.ConcurrencyLimitedCB()
+	}
+}
+
+func (m *MetricsReporter) invalidCredentials(serviceID string) {
+	if m != nil && m.InvalidCredentialsCB != nil {
+		m.InvalidCredentialsCB(serviceID)
+	}
+}
+
+func (m *MetricsReporter) applicationRequest(serviceID string, params authorizer.BackendParams) {
+	if m != nil && m.ApplicationCB != nil {
+		if application := hashApplication(m.ApplicationSalt, params); application != "" {
+			m.ApplicationCB(serviceID, application)
+		}
+	}
+}
+
+func (m *MetricsReporter) onboardingWouldDeny(serviceID string, reason DecisionReason) {
+	if m != nil && m.OnboardingCB != nil {
+		m.OnboardingCB(serviceID, string(reason))
+	}
+}
+
+func (m *MetricsReporter) configUnmarshalFailure(instance string, handler string) {
+	if m != nil && m.ConfigUnmarshalCB != nil {
+		m.ConfigUnmarshalCB(instance, handler)
+	}
+}
+
+func (m *MetricsReporter) failOpen(serviceID string) {
+	if m != nil && m.FailOpenCB != nil {
+		m.FailOpenCB(serviceID)
+	}
+}
+
+func (m *MetricsReporter) backendError(serviceID string, class string) {
+	if m != nil && m.BackendErrorCB != nil {
+		m.BackendErrorCB(serviceID, class)
+	}
+}
+
+func (m *MetricsReporter) configCacheHit(age time.Duration) {
+	if m != nil && m.ConfigCacheHitCB != nil {
+		m.ConfigCacheHitCB(age)
+	}
+}
+
+func (m *MetricsReporter) configCacheMiss() {
+	if m != nil && m.ConfigCacheMissCB != nil {
+		m.ConfigCacheMissCB()
+	}
+}
+
+func (m *MetricsReporter) configFetchFailed(serviceID string) {
+	if m != nil && m.ConfigFetchFailedCB != nil {
+		m.ConfigFetchFailedCB(serviceID)
+	}
+}
+
+func (m *MetricsReporter) configEvicted() {
+	if m != nil && m.ConfigEvictedCB != nil {
+		m.ConfigEvictedCB()
+	}
+}
+
+func (m *MetricsReporter) configCacheEntries(entries int) {
+	if m != nil && m.ConfigCacheEntriesCB != nil {
+		m.ConfigCacheEntriesCB(entries)
+	}
+}
+
+func (m *MetricsReporter) decision(serviceID string, allowed bool, reason DecisionReason) {
+	if m != nil && m.DecisionCB != nil {
+		m.DecisionCB(serviceID, allowed, string(reason))
+	}
+}
+
+func (m *MetricsReporter) backendResponse(serviceID string, class string) {
+	if m != nil && m.BackendResponseCB != nil {
+		m.BackendResponseCB(serviceID, class)
+	}
 }
@@ -0,0 +1,150 @@
+package threescale
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/api/mixer/adapter/model/v1beta1"
+)
+
+const (
+	// DefaultUpgradeURLAnnotation is the service annotation read to determine where API consumers can upgrade their plan
+	DefaultUpgradeURLAnnotation = "upgrade_url"
+
+	serviceEndpoint = "/admin/api/services/%s.json"
+)
+
+// UpgradeHints determines the URL that API consumers exceeding the limits of their plan are pointed to.
+// An empty URL indicates that no hint should be given.
+type UpgradeHints interface {
+	UpgradeURL(systemURL, accessToken, serviceID string) (string, error)
+}
+
+// ServiceAnnotationUpgradeHints reads the upgrade URL from an annotation on the 3scale service.
+// Results are cached per service for the configured TTL.
+type ServiceAnnotationUpgradeHints struct {
+	client     *http.Client
+	annotation string
+	ttl        time.Duration
+	mutex      sync.RWMutex
+	cache      map[string]upgradeEntry
+}
+
+type upgradeEntry struct {
+	url     string
+	expires time.Time
+}
+
+type serviceResponse struct {
+	Service struct {
+		Annotations map[string]interface{} `json:"annotations"`
+	} `json:"service"`
+}
+
+// NewServiceAnnotationUpgradeHints returns a ServiceAnnotationUpgradeHints which reads the provided annotation.
+// If annotation is empty, DefaultUpgradeURLAnnotation is used.
+func NewServiceAnnotationUpgradeHints(client *http.Client, annotation string, ttl time.Duration) *ServiceAnnotationUpgradeHints {
+	if annotation == "" {
+		annotation = DefaultUpgradeURLAnnotation
+	}
+
+	return &ServiceAnnotationUpgradeHints{
+		client:     client,
+		annotation: annotation,
+		ttl:        ttl,
+		cache:      make(map[string]upgradeEntry),
+	}
+}
+
+// UpgradeURL implements UpgradeHints
+func (u *ServiceAnnotationUpgradeHints) UpgradeURL(systemURL, accessToken, serviceID string) (string, error) {
+	key := systemURL + "|" + serviceID
+
+	u.mutex.RLock()
+	entry, ok := u.cache[key]
+	u.mutex.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.url, nil
+	}
+
+	upgradeURL, err := u.fetchUpgradeURL(systemURL, accessToken, serviceID)
+	if err != nil {
+		return "", err
+	}
+
+	u.mutex.Lock()
+	u.cache[key] = upgradeEntry{url: upgradeURL, expires: time.Now().Add(u.ttl)}
+	u.mutex.Unlock()
+
+	return upgradeURL, nil
+}
+
+func (u *ServiceAnnotationUpgradeHints) fetchUpgradeURL(systemURL, accessToken, serviceID string) (string, error) {
+	query := url.Values{}
+	query.Set("access_token", accessToken)
+
+	endpoint := systemURL + fmt.Sprintf(serviceEndpoint, url.PathEscape(serviceID))
+	resp, err := u.client.Get(endpoint + "?" + query.Encode())
+	if err != nil {
+		return "", fmt.Errorf("error fetching service - %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching service - unexpected status code %d", resp.StatusCode)
+	}
+
+	service := &serviceResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(service); err != nil {
+		return "", fmt.Errorf("error fetching service - %s", err.Error())
+	}
+
+	value, _ := service.Service.Annotations[u.annotation].(string)
+	if value = strings.TrimSpace(value); value == "" {
+		return "", nil
+	}
+
+	// the URL is returned to API consumers, so only absolute web links are accepted
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid value %q for service annotation %s", value, u.annotation)
+	}
+	return value, nil
+}
+
+// withUpgradeHint adds the upgrade URL of the service to the status of a request denied for exceeding its limits,
+// both in the message, which Mixer passes on as check.error_message, and as a google.rpc.Help detail.
+// Failing to determine the URL is logged and the request is denied without a hint.
+func (s *Threescale) withUpgradeHint(cfg *config.Params, result *v1beta1.CheckResult, reason DecisionReason) {
+	if s.conf.UpgradeHints == nil || reason != ReasonLimits {
+		return
+	}
+
+	upgradeURL, err := s.conf.UpgradeHints.UpgradeURL(cfg.SystemUrl, cfg.AccessToken, cfg.ServiceId)
+	if err != nil {
+		s.logSampledWarning(fmt.Sprintf("unable to determine upgrade url for service %s - %v", cfg.ServiceId, err))
+		return
+	}
+
+	if upgradeURL == "" {
+		return
+	}
+
+	result.Status.Message = fmt.Sprintf("%s - upgrade your plan at %s", result.Status.Message, upgradeURL)
+
+	help, err := types.MarshalAny(&rpc.Help{
+		Links: []*rpc.Help_Link{{Description: "Upgrade your plan", Url: upgradeURL}},
+	})
+	if err == nil {
+		result.Status.Details = append(result.Status.Details, help)
+	}
+}
@@ -0,0 +1,177 @@
+package threescale
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestServiceAnnotationUpgradeHints(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("access_token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case fmt.Sprintf(serviceEndpoint, "123"):
+			fmt.Fprint(w, `{"service":{"id":123,"annotations":{"upgrade_url":"https://developer.example.com/plans"}}}`)
+		case fmt.Sprintf(serviceEndpoint, "456"):
+			fmt.Fprint(w, `{"service":{"id":456}}`)
+		case fmt.Sprintf(serviceEndpoint, "789"):
+			fmt.Fprint(w, `{"service":{"id":789,"annotations":{"upgrade_url":"javascript:alert(1)"}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	hints := NewServiceAnnotationUpgradeHints(server.Client(), "", time.Minute)
+
+	for i := 0; i < 2; i++ {
+		upgradeURL, err := hints.UpgradeURL(server.URL, "token", "123")
+		if err != nil {
+			t.Fatalf("unexpected error - %v", err)
+		}
+
+		if upgradeURL != "https://developer.example.com/plans" {
+			t.Errorf("unexpected upgrade url %q", upgradeURL)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected upgrade url to be cached but got %d calls to system", calls)
+	}
+
+	upgradeURL, err := hints.UpgradeURL(server.URL, "token", "456")
+	if err != nil || upgradeURL != "" {
+		t.Errorf("expected no upgrade url for service without annotation but got %q - %v", upgradeURL, err)
+	}
+
+	if _, err := hints.UpgradeURL(server.URL, "token", "789"); err == nil {
+		t.Errorf("expected error for annotation which is not a web link")
+	}
+
+	if _, err := hints.UpgradeURL(server.URL, "invalid", "000"); err == nil {
+		t.Errorf("expected error for failed request")
+	}
+}
+
+type staticUpgradeHints string
+
+func (s staticUpgradeHints) UpgradeURL(systemURL, accessToken, serviceID string) (string, error) {
+	return string(s), nil
+}
+
+func TestHandleAuthorizationUpgradeHint(t *testing.T) {
+	inputs := []struct {
+		name          string
+		authResponse  *authorizer.BackendResponse
+		hints         UpgradeHints
+		expectStatus  int32
+		expectHint    bool
+		expectDetails int
+	}{
+		{
+			name:          "Test limits exceeded carries upgrade hint",
+			authResponse:  &authorizer.BackendResponse{Authorized: false, ErrorCode: "limits_exceeded"},
+			hints:         staticUpgradeHints("https://developer.example.com/plans"),
+			expectStatus:  int32(rpc.RESOURCE_EXHAUSTED),
+			expectHint:    true,
+			expectDetails: 1,
+		},
+		{
+			name:         "Test other denials carry no upgrade hint",
+			authResponse: &authorizer.BackendResponse{Authorized: false, ErrorCode: "user_key_invalid"},
+			hints:        staticUpgradeHints("https://developer.example.com/plans"),
+			expectStatus: int32(rpc.PERMISSION_DENIED),
+		},
+		{
+			name:         "Test service without upgrade url carries no hint",
+			authResponse: &authorizer.BackendResponse{Authorized: false, ErrorCode: "limits_exceeded"},
+			hints:        staticUpgradeHints(""),
+			expectStatus: int32(rpc.RESOURCE_EXHAUSTED),
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := config.Params{
+				ServiceId:   "123",
+				SystemUrl:   "https://www.fake-system.3scale.net",
+				AccessToken: "any",
+				BackendUrl:  internalBackend,
+			}
+			b, _ := params.Marshal()
+
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								Proxy: client.ContentProxy{
+									ProxyRules: []client.ProxyRule{
+										{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+									},
+								},
+							},
+						},
+						withAuthResponse: input.authResponse,
+						t:                t,
+					},
+					UpgradeHints: input.hints,
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/test",
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "secret",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != input.expectStatus {
+				t.Errorf("expected status %d but got %d - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+
+			if hinted := strings.Contains(result.Status.Message, "https://developer.example.com/plans"); hinted != input.expectHint {
+				t.Errorf("unexpected upgrade hint in message %q", result.Status.Message)
+			}
+
+			if len(result.Status.Details) != input.expectDetails {
+				t.Fatalf("expected %d details but got %d", input.expectDetails, len(result.Status.Details))
+			}
+
+			if input.expectDetails > 0 {
+				help := &rpc.Help{}
+				if err := types.UnmarshalAny(result.Status.Details[0], help); err != nil {
+					t.Fatalf("unexpected error unmarshalling detail - %v", err)
+				}
+
+				if len(help.Links) != 1 || help.Links[0].Url != "https://developer.example.com/plans" {
+					t.Errorf("unexpected help detail %+v", help)
+				}
+			}
+		})
+	}
+}
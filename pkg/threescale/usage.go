@@ -0,0 +1,219 @@
+package threescale
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gogo/googleapis/google/rpc"
+)
+
+const (
+	// UsageRetention is the period over which the UsageRecorder keeps decisions, and so the longest window it reports
+	UsageRetention = time.Hour
+	// DefaultUsageWindow is the window reported when none is requested
+	DefaultUsageWindow = 5 * time.Minute
+)
+
+// UsageRow counts the decisions reached for the requests of an application of a service over a window.
+// Application is the salted hash of the credentials reported in application metrics, empty where the request carried
+// no credentials.
+type UsageRow struct {
+	ServiceID   string `json:"service_id"`
+	Application string `json:"application"`
+	Allowed     int    `json:"allowed"`
+	Denied      int    `json:"denied"`
+	Limited     int    `json:"limited"`
+}
+
+// UsageReport is the usage summary served by the UsageRecorder
+type UsageReport struct {
+	WindowMinutes int        `json:"window_minutes"`
+	Usage         []UsageRow `json:"usage"`
+}
+
+type usageKey struct {
+	serviceID   string
+	application string
+}
+
+type usageCounts struct {
+	allowed, denied, limited int
+}
+
+// UsageRecorder is a DecisionHook which counts the decisions reached for each service and application by minute, so
+// that recent traffic can be summarised for triage without a metrics backend. Only requests which reach the hooks are
+// counted, so requests rejected earlier, such as for matching no mapping rule or lacking credentials, are not.
+type UsageRecorder struct {
+	// Salt used to hash the credentials of applications, which should match that of application metrics
+	Salt string
+
+	mutex sync.Mutex
+	// minutes holds the counts for each minute, by the Unix time of its start in minutes
+	minutes map[int64]map[usageKey]*usageCounts
+	now     func() time.Time
+}
+
+// NewUsageRecorder returns a UsageRecorder which hashes application credentials with salt
+func NewUsageRecorder(salt string) *UsageRecorder {
+	return &UsageRecorder{
+		Salt:    salt,
+		minutes: make(map[int64]map[usageKey]*usageCounts),
+		now:     time.Now,
+	}
+}
+
+// BeforeAuthorization implements DecisionHook and never denies a request
+func (u *UsageRecorder) BeforeAuthorization(ctx context.Context, request AuthorizationRequest) *rpc.Status {
+	return nil
+}
+
+// AfterAuthorization implements DecisionHook, counting the decision against the service and application
+func (u *UsageRecorder) AfterAuthorization(ctx context.Context, request AuthorizationRequest, decision Decision) {
+	key := usageKey{serviceID: request.Params.ServiceId}
+	if len(request.BackendRequest.Transactions) > 0 {
+		key.application = hashApplication(u.Salt, request.BackendRequest.Transactions[0].Params)
+	}
+
+	minute := u.now().Unix() / 60
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	counts, ok := u.minutes[minute]
+	if !ok {
+		counts = make(map[usageKey]*usageCounts)
+		u.minutes[minute] = counts
+		u.prune(minute)
+	}
+
+	c, ok := counts[key]
+	if !ok {
+		c = &usageCounts{}
+		counts[key] = c
+	}
+
+	switch {
+	case decision.Reason == ReasonLimits:
+		c.limited++
+	case decision.Status.Code == int32(rpc.OK):
+		c.allowed++
+	default:
+		c.denied++
+	}
+}
+
+// prune drops the minutes older than UsageRetention. It must be called with the mutex held.
+func (u *UsageRecorder) prune(minute int64) {
+	oldest := minute - int64(UsageRetention/time.Minute) + 1
+	for m := range u.minutes {
+		if m < oldest {
+			delete(u.minutes, m)
+		}
+	}
+}
+
+// Report returns the usage over the window ending now, which includes the current minute, sorted by service and
+// application
+func (u *UsageRecorder) Report(window time.Duration) UsageReport {
+	minutes := int(window / time.Minute)
+	current := u.now().Unix() / 60
+
+	totals := make(map[usageKey]*usageCounts)
+	u.mutex.Lock()
+	for minute, counts := range u.minutes {
+		if minute <= current-int64(minutes) {
+			continue
+		}
+
+		for key, c := range counts {
+			total, ok := totals[key]
+			if !ok {
+				total = &usageCounts{}
+				totals[key] = total
+			}
+			total.allowed += c.allowed
+			total.denied += c.denied
+			total.limited += c.limited
+		}
+	}
+	u.mutex.Unlock()
+
+	report := UsageReport{WindowMinutes: minutes, Usage: make([]UsageRow, 0, len(totals))}
+	for key, c := range totals {
+		report.Usage = append(report.Usage, UsageRow{
+			ServiceID:   key.serviceID,
+			Application: key.application,
+			Allowed:     c.allowed,
+			Denied:      c.denied,
+			Limited:     c.limited,
+		})
+	}
+
+	sort.Slice(report.Usage, func(i, j int) bool {
+		if report.Usage[i].ServiceID != report.Usage[j].ServiceID {
+			return report.Usage[i].ServiceID < report.Usage[j].ServiceID
+		}
+		return report.Usage[i].Application < report.Usage[j].Application
+	})
+	return report
+}
+
+// ServeHTTP responds to GET requests with the usage over the window set by the "minutes" query parameter, between
+// 1 and 60, which defaults to 5
+func (u *UsageRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	window := DefaultUsageWindow
+	if v := r.URL.Query().Get("minutes"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes < 1 || time.Duration(minutes)*time.Minute > UsageRetention {
+			http.Error(w, fmt.Sprintf("invalid value for minutes, expected 1 to %d", UsageRetention/time.Minute), http.StatusBadRequest)
+			return
+		}
+		window = time.Duration(minutes) * time.Minute
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(u.Report(window))
+}
+
+// WriteUsageTable writes the report as a table, with a total for each service when it has more than one application
+func WriteUsageTable(w io.Writer, report UsageReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "SERVICE\tAPPLICATION\tALLOWED\tDENIED\tLIMITED\n")
+
+	for i := 0; i < len(report.Usage); {
+		serviceID := report.Usage[i].ServiceID
+		var total UsageRow
+		var rows int
+		for ; i < len(report.Usage) && report.Usage[i].ServiceID == serviceID; i++ {
+			row := report.Usage[i]
+			application := row.Application
+			if application == "" {
+				application = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\n", serviceID, application, row.Allowed, row.Denied, row.Limited)
+
+			total.Allowed += row.Allowed
+			total.Denied += row.Denied
+			total.Limited += row.Limited
+			rows++
+		}
+
+		if rows > 1 {
+			fmt.Fprintf(tw, "%s\t(total)\t%d\t%d\t%d\n", serviceID, total.Allowed, total.Denied, total.Limited)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,91 @@
+package threescale
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-istio-adapter/pkg/statuses"
+)
+
+func TestUsageRecorder(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 30, 0, time.UTC)
+	u := NewUsageRecorder("salt")
+	u.now = func() time.Time { return now }
+
+	request := func(serviceID string, userKey string) AuthorizationRequest {
+		return AuthorizationRequest{
+			Params: config.Params{ServiceId: serviceID},
+			BackendRequest: authorizer.BackendRequest{
+				Transactions: []authorizer.BackendTransaction{{Params: authorizer.BackendParams{UserKey: userKey}}},
+			},
+		}
+	}
+
+	// recorded outside of the window reported
+	u.now = func() time.Time { return now.Add(-10 * time.Minute) }
+	u.AfterAuthorization(context.TODO(), request("123", "a"), Decision{Status: statuses.OK, Reason: ReasonOK})
+
+	u.now = func() time.Time { return now }
+	u.AfterAuthorization(context.TODO(), request("123", "a"), Decision{Status: statuses.OK, Reason: ReasonOK})
+	u.AfterAuthorization(context.TODO(), request("123", "a"), Decision{Status: statuses.OK, Reason: ReasonFailOpen})
+	u.AfterAuthorization(context.TODO(), request("123", "b"), Decision{Status: statuses.LimitsExceeded("limits_exceeded"), Reason: ReasonLimits})
+	u.AfterAuthorization(context.TODO(), request("456", "a"), Decision{Status: statuses.Denied("denied"), Reason: ReasonDenied})
+
+	appA, appB := hashApplication("salt", authorizer.BackendParams{UserKey: "a"}), hashApplication("salt", authorizer.BackendParams{UserKey: "b"})
+	expect := []UsageRow{
+		{ServiceID: "123", Application: appA, Allowed: 2},
+		{ServiceID: "123", Application: appB, Limited: 1},
+		{ServiceID: "456", Application: appA, Denied: 1},
+	}
+	if appB < appA {
+		expect[0], expect[1] = expect[1], expect[0]
+	}
+
+	report := u.Report(DefaultUsageWindow)
+	if report.WindowMinutes != 5 || !reflect.DeepEqual(report.Usage, expect) {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	if report := u.Report(UsageRetention); report.Usage[0].Allowed+report.Usage[1].Allowed != 3 {
+		t.Errorf("expected the earlier decision within the retention to be reported but got %+v", report)
+	}
+
+	u.now = func() time.Time { return now.Add(UsageRetention) }
+	if report := u.Report(UsageRetention); len(report.Usage) != 0 {
+		t.Errorf("expected decisions older than the retention not to be reported but got %+v", report)
+	}
+
+	var buf bytes.Buffer
+	WriteUsageTable(&buf, report)
+	if !bytes.Contains(buf.Bytes(), []byte("(total)")) {
+		t.Errorf("expected a total for the service with several applications but got\n%s", buf.String())
+	}
+}
+
+func TestUsageRecorderServeHTTP(t *testing.T) {
+	u := NewUsageRecorder("")
+
+	w := httptest.NewRecorder()
+	u.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/usage?minutes=15", nil))
+
+	var report UsageReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil || report.WindowMinutes != 15 {
+		t.Errorf("unexpected report %+v - %v", report, err)
+	}
+
+	for _, minutes := range []string{"0", "61", "soon"} {
+		w := httptest.NewRecorder()
+		u.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/usage?minutes="+minutes, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s minutes but got %d", minutes, w.Code)
+		}
+	}
+}
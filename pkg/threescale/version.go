@@ -0,0 +1,89 @@
+package threescale
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/3scale/3scale-go-client/threescale/api"
+)
+
+// maxAPIVersionLength bounds the API version parsed from a path, since it is used as a metric label and name
+const maxAPIVersionLength = 32
+
+// regexpCache holds regular expressions compiled from handler params, which are shared by handlers using the same pattern
+type regexpCache struct {
+	mutex    sync.RWMutex
+	patterns map[string]*regexp.Regexp
+}
+
+// compile returns the compiled expression for the pattern, compiling it on first use
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mutex.RLock()
+	re, ok := c.patterns[pattern]
+	c.mutex.RUnlock()
+
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	if c.patterns == nil {
+		c.patterns = make(map[string]*regexp.Regexp)
+	}
+	c.patterns[pattern] = re
+	c.mutex.Unlock()
+
+	return re, nil
+}
+
+// parse returns the API version identified in the path by the pattern, or an empty string if the
+// pattern is unset, invalid or does not match. The first capture group is used if the pattern has one.
+func (c *regexpCache) parse(pattern string, path string) string {
+	if pattern == "" {
+		return ""
+	}
+
+	re, err := c.compile(pattern)
+	if err != nil {
+		return ""
+	}
+
+	match := re.FindStringSubmatch(path)
+	if match == nil {
+		return ""
+	}
+
+	version := match[0]
+	if len(match) > 1 {
+		version = match[1]
+	}
+
+	if len(version) > maxAPIVersionLength {
+		return ""
+	}
+	return version
+}
+
+// withVersionedMetrics returns a copy of the metrics, adding a metric suffixed with the API version for each.
+// Characters which are not permitted in a 3scale metric system name are replaced with an underscore.
+func withVersionedMetrics(metrics api.Metrics, version string) api.Metrics {
+	suffix := "_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, version)
+
+	versioned := make(api.Metrics, len(metrics)*2)
+	for name, delta := range metrics {
+		versioned.Add(name, delta)
+		versioned.Add(name+suffix, delta)
+	}
+	return versioned
+}
@@ -0,0 +1,163 @@
+package threescale
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/3scale/3scale-authorizer/pkg/authorizer"
+	"github.com/3scale/3scale-go-client/threescale/api"
+	"github.com/3scale/3scale-istio-adapter/config"
+	"github.com/3scale/3scale-porta-go-client/client"
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/protobuf/types"
+
+	"istio.io/istio/mixer/template/authorization"
+)
+
+func TestAPIVersionParse(t *testing.T) {
+	inputs := []struct {
+		pattern string
+		path    string
+		expect  string
+	}{
+		{pattern: `^/(v[0-9]+)/`, path: "/v2/orders", expect: "v2"},
+		{pattern: `^/v[0-9]+/`, path: "/v2/orders", expect: "/v2/"},
+		{pattern: `^/api/([0-9.]+)/`, path: "/api/1.1/orders", expect: "1.1"},
+		{pattern: `^/(v[0-9]+)/`, path: "/orders", expect: ""},
+		{pattern: `^/([^/]+)/`, path: "/" + strings.Repeat("a", maxAPIVersionLength+1) + "/", expect: ""},
+		{pattern: `^/(v[0-9]+/`, path: "/v2/orders", expect: ""},
+		{pattern: "", path: "/v2/orders", expect: ""},
+	}
+
+	cache := &regexpCache{}
+	for _, input := range inputs {
+		if version := cache.parse(input.pattern, input.path); version != input.expect {
+			t.Errorf("unexpected version for pattern %q and path %q, wanted %q but got %q", input.pattern, input.path, input.expect, version)
+		}
+	}
+}
+
+func TestWithVersionedMetrics(t *testing.T) {
+	metrics := api.Metrics{"hits": 1, "orders": 2}
+
+	got := withVersionedMetrics(metrics, "1.1")
+	expect := api.Metrics{"hits": 1, "orders": 2, "hits_1_1": 1, "orders_1_1": 2}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("unexpected metrics, wanted %v but got %v", expect, got)
+	}
+
+	if len(metrics) != 2 {
+		t.Errorf("expected original metrics to be unmodified but got %v", metrics)
+	}
+}
+
+func TestHandleAuthorizationAPIVersion(t *testing.T) {
+	inputs := []struct {
+		name          string
+		params        config.Params
+		expectStatus  int32
+		expectMetrics api.Metrics
+		expectVersion string
+	}{
+		{
+			name: "Test version is reported without changing usage",
+			params: config.Params{
+				ApiVersionPattern: `^/(v[0-9]+)/`,
+			},
+			expectStatus:  int32(rpc.OK),
+			expectMetrics: api.Metrics{"hits": 1},
+			expectVersion: "v2",
+		},
+		{
+			name: "Test version is reported as metric suffix",
+			params: config.Params{
+				ApiVersionPattern:      `^/(v[0-9]+)/`,
+				ApiVersionMetricSuffix: true,
+			},
+			expectStatus:  int32(rpc.OK),
+			expectMetrics: api.Metrics{"hits": 1, "hits_v2": 1},
+			expectVersion: "v2",
+		},
+		{
+			name: "Test invalid pattern is rejected",
+			params: config.Params{
+				ApiVersionPattern: `^/(v[0-9]+/`,
+			},
+			expectStatus: int32(rpc.FAILED_PRECONDITION),
+		},
+		{
+			name: "Test metric suffix without pattern is rejected",
+			params: config.Params{
+				ApiVersionMetricSuffix: true,
+			},
+			expectStatus: int32(rpc.FAILED_PRECONDITION),
+		},
+	}
+
+	for _, input := range inputs {
+		t.Run(input.name, func(t *testing.T) {
+			params := input.params
+			params.ServiceId = "123"
+			params.SystemUrl = "https://www.fake-system.3scale.net"
+			params.AccessToken = "any"
+			params.BackendUrl = internalBackend
+			b, _ := params.Marshal()
+
+			var gotMetrics api.Metrics
+			var gotVersion string
+			c := &Threescale{
+				conf: &AdapterConfig{
+					Authorizer: mockAuthorizer{
+						withConfig: client.ProxyConfig{
+							Content: client.Content{
+								Proxy: client.ContentProxy{
+									ProxyRules: []client.ProxyRule{
+										{HTTPMethod: http.MethodGet, Pattern: "/", MetricSystemName: "hits", Delta: 1},
+									},
+								},
+							},
+						},
+						withAuthRepCallback: func(backendURL string, request authorizer.BackendRequest, t *testing.T) {
+							gotMetrics = request.Transactions[0].Metrics
+						},
+						withAuthResponse: &authorizer.BackendResponse{Authorized: true},
+						t:                t,
+					},
+					MetricsReporter: &MetricsReporter{
+						APIVersionCB: func(serviceID string, version string) {
+							gotVersion = version
+						},
+					},
+				},
+			}
+
+			result, _ := c.HandleAuthorization(context.TODO(), &authorization.HandleAuthorizationRequest{
+				Instance: &authorization.InstanceMsg{
+					Action: &authorization.ActionMsg{
+						Method: http.MethodGet,
+						Path:   "/v2/orders",
+					},
+					Subject: &authorization.SubjectMsg{
+						User: "secret",
+					},
+				},
+				AdapterConfig: &types.Any{Value: b},
+			})
+
+			if result.Status.Code != input.expectStatus {
+				t.Fatalf("expected status %d but got %d - %s", input.expectStatus, result.Status.Code, result.Status.Message)
+			}
+
+			if input.expectMetrics != nil && !reflect.DeepEqual(gotMetrics, input.expectMetrics) {
+				t.Errorf("unexpected metrics reported, wanted %v but got %v", input.expectMetrics, gotMetrics)
+			}
+
+			if gotVersion != input.expectVersion {
+				t.Errorf("unexpected version reported, wanted %q but got %q", input.expectVersion, gotVersion)
+			}
+		})
+	}
+}